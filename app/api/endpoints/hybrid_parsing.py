@@ -52,6 +52,51 @@ async def hybrid_parsing_single_video(request: Request,
                                     )
         raise HTTPException(status_code=status_code, detail=detail.dict())
 
+@router.get("/music_data", response_model=ResponseModel, tags=["Hybrid-API"],
+            summary="混合解析音乐页接口/Hybrid parsing music page endpoint")
+async def hybrid_parsing_music(request: Request,
+                               url: str = Query(example="https://www.tiktok.com/music/original-sound-7016547803243022337"),
+                               cursor: int = Query(default=0),
+                               count: int = Query(default=20, ge=1, le=50)):
+    """
+    # [中文]
+    ### 用途:
+    - 该接口用于解析抖音/TikTok音乐页的数据，以及使用该音乐的作品列表。
+    ### 参数:
+    - `url`: 音乐页链接或分享链接。
+    - `cursor`: 作品列表的翻页游标，首页为0。
+    - `count`: 每页作品数量。
+    ### 返回:
+    - `data`: 音乐数据，`videos`为作品列表，`cursor`和`has_more`用于翻页。
+
+    # [English]
+    ### Purpose:
+    - This endpoint is used to parse a Douyin/TikTok sound page and the posts using that sound.
+    ### Parameters:
+    - `url`: Sound page link or share link.
+    - `cursor`: Paging cursor of the post list, 0 for the first page.
+    - `count`: Number of posts per page.
+    ### Returns:
+    - `data`: Sound data, with the posts under `videos` and `cursor`/`has_more` for paging.
+
+    # [Example]
+    url = "https://www.tiktok.com/music/original-sound-7016547803243022337"
+    """
+    try:
+        # 解析音乐/Parse music
+        data = await HybridCrawler.hybrid_parsing_music(url=url, cursor=cursor, count=count)
+        # 返回数据/Return data
+        return ResponseModel(code=200,
+                             router=request.url.path,
+                             data=data)
+    except Exception as e:
+        status_code = 400
+        detail = ErrorResponseModel(code=status_code,
+                                    router=request.url.path,
+                                    params=dict(request.query_params),
+                                    )
+        raise HTTPException(status_code=status_code, detail=detail.dict())
+
 # 更新Cookie
 @router.post("/update_cookie",
              response_model=ResponseModel,
@@ -87,6 +87,12 @@ class DouyinAPIEndpoints:
     # 用户音乐收藏 (User Music Collection)
     USER_MUSIC_COLLECTION = f"{DOUYIN_DOMAIN}/aweme/v1/web/music/listcollection/"
 
+    # 音乐详情 (Music Detail)
+    MUSIC_DETAIL = f"{DOUYIN_DOMAIN}/aweme/v1/web/music/detail/"
+
+    # 音乐作品 (Music Aweme)
+    MUSIC_AWEME = f"{DOUYIN_DOMAIN}/aweme/v1/web/music/aweme/"
+
     # 首页朋友作品 (Friend Feed)
     FRIEND_FEED = f"{DOUYIN_DOMAIN}/aweme/v1/web/familiar/feed/"
 
@@ -132,6 +132,16 @@ class UserMusicCollection(BaseRequestModel):
     count: int
 
 
+class MusicDetail(BaseRequestModel):
+    music_id: str
+
+
+class MusicAweme(BaseRequestModel):
+    music_id: str
+    cursor: int = 0
+    count: int = 20
+
+
 class UserMix(BaseRequestModel):
     cursor: int
     count: int
@@ -44,7 +44,7 @@ from crawlers.base_crawler import BaseCrawler
 from crawlers.douyin.web.endpoints import DouyinAPIEndpoints
 # 抖音接口数据请求模型
 from crawlers.douyin.web.models import (
-    BaseRequestModel, LiveRoomRanking, PostComments,
+    BaseRequestModel, LiveRoomRanking, MusicAweme, MusicDetail, PostComments,
     PostCommentsReply, PostDetail,
     UserProfile, UserCollection, UserLike, UserLive,
     UserLive2, UserMix, UserPost
@@ -173,6 +173,34 @@ class DouyinWebCrawler:
             response = await crawler.fetch_get_json(endpoint)
         return response
 
+    # 获取音乐详情数据
+    async def fetch_music_detail(self, music_id: str):
+        kwargs = await self.get_douyin_headers()
+        base_crawler = BaseCrawler(proxies=kwargs["proxies"], crawler_headers=kwargs["headers"])
+        async with base_crawler as crawler:
+            params = MusicDetail(music_id=music_id)
+            params_dict = params.dict()
+            params_dict["msToken"] = ''
+            a_bogus = BogusManager.ab_model_2_endpoint(params_dict, kwargs["headers"]["User-Agent"])
+            endpoint = f"{DouyinAPIEndpoints.MUSIC_DETAIL}?{urlencode(params_dict)}&a_bogus={a_bogus}"
+
+            response = await crawler.fetch_get_json(endpoint)
+        return response
+
+    # 获取使用该音乐的作品数据
+    async def fetch_music_videos(self, music_id: str, cursor: int = 0, count: int = 20):
+        kwargs = await self.get_douyin_headers()
+        base_crawler = BaseCrawler(proxies=kwargs["proxies"], crawler_headers=kwargs["headers"])
+        async with base_crawler as crawler:
+            params = MusicAweme(music_id=music_id, cursor=cursor, count=count)
+            params_dict = params.dict()
+            params_dict["msToken"] = ''
+            a_bogus = BogusManager.ab_model_2_endpoint(params_dict, kwargs["headers"]["User-Agent"])
+            endpoint = f"{DouyinAPIEndpoints.MUSIC_AWEME}?{urlencode(params_dict)}&a_bogus={a_bogus}"
+
+            response = await crawler.fetch_get_json(endpoint)
+        return response
+
     # 获取用户直播流数据
     async def fetch_user_live_videos(self, webcast_id: str, room_id_str=""):
         kwargs = await self.get_douyin_headers()
@@ -299,6 +299,110 @@ class HybridCrawler:
         result_data.update(api_data)
         return result_data
 
+    async def get_music_id(self, url: str) -> str:
+        """
+        从音乐页链接中提取音乐ID，支持短链重定向
+        Extract the music ID from a sound page URL, following share short links
+        """
+        # tiktok.com/music/<title>-<id>, douyin.com/music/<id>, iesdouyin.com/share/music/<id>/
+        music_pattern = r'/music/(?:[^/?#]*-)?(\d+)'
+        match = re.search(music_pattern, url)
+        if not match:
+            async with httpx.AsyncClient() as client:
+                response = await client.head(url, follow_redirects=True)
+                match = re.search(music_pattern, str(response.url))
+        if match:
+            return match.group(1)
+        raise ValueError(f"Cannot extract music ID from URL: {url}")
+
+    async def hybrid_parsing_music(self, url: str, cursor: int = 0, count: int = 20):
+        """
+        解析抖音/TikTok音乐页，返回音乐信息和使用该音乐的作品列表(分页)
+        Parse a Douyin/TikTok sound page into the sound's metadata and a page of
+        the posts using it. The music object mirrors the music of a single video
+        so clients can read both the same way.
+        """
+        music_id = await self.get_music_id(url)
+        if "douyin" in url:
+            platform = "douyin"
+            detail = await self.DouyinWebCrawler.fetch_music_detail(music_id)
+            info = detail.get("music_info") or {}
+            music = {
+                'id': info.get("id_str") or music_id,
+                'title': info.get("title"),
+                'author': info.get("author"),
+                'album': info.get("album"),
+                'duration': info.get("duration"),
+                'is_original_sound': info.get("is_original_sound", info.get("original")),
+                'play_url': info.get("play_url"),
+                'cover_large': info.get("cover_large"),
+                'cover_medium': info.get("cover_medium"),
+                'cover_thumb': info.get("cover_thumb"),
+                'user_count': info.get("user_count"),
+            }
+            page = await self.DouyinWebCrawler.fetch_music_videos(music_id, cursor=cursor, count=count)
+            videos = []
+            for aweme in page.get("aweme_list") or []:
+                aweme_id = aweme.get("aweme_id")
+                # 2/68为图集/2 and 68 are image posts
+                kind = 'note' if aweme.get("aweme_type") in (2, 68) else 'video'
+                videos.append({
+                    'video_id': aweme_id,
+                    'url': f"https://www.douyin.com/{kind}/{aweme_id}",
+                    'desc': aweme.get("desc"),
+                    'author': aweme.get("author"),
+                    'cover': (aweme.get("video", {}).get("cover", {}).get("url_list") or [None])[0],
+                    'play_count': aweme.get("statistics", {}).get("play_count"),
+                })
+            next_cursor = page.get("cursor", 0)
+            has_more = bool(page.get("has_more"))
+        elif "tiktok" in url:
+            platform = "tiktok"
+            detail = await self.TikTokWebCrawler.fetch_music_detail(music_id)
+            info = detail.get("musicInfo", {}).get("music") or {}
+            play_url = info.get("playUrl")
+            music = {
+                'id': info.get("id") or music_id,
+                'title': info.get("title"),
+                'author': info.get("authorName"),
+                'album': info.get("album"),
+                'duration': info.get("duration"),
+                'is_original_sound': info.get("original"),
+                'play_url': {'uri': play_url, 'url_list': [play_url] if play_url else []},
+                'cover_large': {'url_list': [info["coverLarge"]] if info.get("coverLarge") else []},
+                'cover_medium': {'url_list': [info["coverMedium"]] if info.get("coverMedium") else []},
+                'cover_thumb': {'url_list': [info["coverThumb"]] if info.get("coverThumb") else []},
+                'user_count': detail.get("musicInfo", {}).get("stats", {}).get("videoCount"),
+            }
+            page = await self.TikTokWebCrawler.fetch_music_post(music_id, cursor=cursor, count=count)
+            videos = []
+            for item in page.get("itemList") or []:
+                author = item.get("author") or {}
+                # 图集作品带有imagePost/Image posts carry imagePost
+                kind = 'photo' if item.get("imagePost") else 'video'
+                videos.append({
+                    'video_id': item.get("id"),
+                    'url': f"https://www.tiktok.com/@{author.get('uniqueId')}/{kind}/{item.get('id')}",
+                    'desc': item.get("desc"),
+                    'author': {'nickname': author.get("nickname"), 'unique_id': author.get("uniqueId")},
+                    'cover': item.get("video", {}).get("cover"),
+                    'play_count': item.get("stats", {}).get("playCount"),
+                })
+            next_cursor = int(page.get("cursor") or 0)
+            has_more = bool(page.get("hasMore"))
+        else:
+            raise ValueError("hybrid_parsing_music: Cannot judge the music source from the URL.")
+
+        return {
+            'type': 'music',
+            'platform': platform,
+            'music_id': music_id,
+            'music': music,
+            'videos': videos,
+            'cursor': next_cursor,
+            'has_more': has_more,
+        }
+
     async def main(self):
         # 测试混合解析单一视频接口/Test hybrid parsing single video endpoint
         # url = "https://v.douyin.com/L4FJNR3/"
@@ -50,3 +50,9 @@ class TikTokAPIEndpoints:
 
     # 作品评论回复 (Post Comment Reply)
     POST_COMMENT_REPLY = f"{TIKTOK_DOMAIN}/api/comment/list/reply/"
+
+    # 音乐详情 (Music Detail)
+    MUSIC_DETAIL = f"{TIKTOK_DOMAIN}/api/music/detail/"
+
+    # 音乐作品 (Music Post)
+    MUSIC_POST = f"{TIKTOK_DOMAIN}/api/music/item_list/"
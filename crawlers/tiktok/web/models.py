@@ -124,6 +124,17 @@ class PostDetail(BaseRequestModel):
     itemId: str
 
 
+class MusicDetail(BaseRequestModel):
+    musicId: str
+
+
+class MusicPost(BaseRequestModel):
+    count: int = 30
+    cursor: int = 0
+    musicID: str
+    coverFormat: int = 2
+
+
 class PostComment(BaseRequestModel):
     aweme_id: str
     count: int = 20
@@ -59,6 +59,8 @@ from crawlers.tiktok.web.models import (
     UserMix,
     UserCollect,
     PostDetail,
+    MusicDetail,
+    MusicPost,
     UserPlayList,
     PostComment,
     PostCommentReply,
@@ -211,6 +213,38 @@ class TikTokWebCrawler:
             response = await crawler.fetch_get_json(endpoint)
         return response
 
+    # 获取音乐详情
+    async def fetch_music_detail(self, musicId: str):
+        # 获取TikTok的实时Cookie
+        kwargs = await self.get_tiktok_headers()
+        # 创建一个基础爬虫
+        base_crawler = BaseCrawler(proxies=kwargs["proxies"], crawler_headers=kwargs["headers"])
+        async with base_crawler as crawler:
+            # 创建一个音乐详情的BaseModel参数
+            params = MusicDetail(musicId=musicId)
+            # 生成一个音乐详情的带有加密参数的Endpoint
+            endpoint = BogusManager.model_2_endpoint(
+                TikTokAPIEndpoints.MUSIC_DETAIL, params.dict(), kwargs["headers"]["User-Agent"]
+            )
+            response = await crawler.fetch_get_json(endpoint)
+        return response
+
+    # 获取使用该音乐的作品列表
+    async def fetch_music_post(self, musicId: str, cursor: int = 0, count: int = 30):
+        # 获取TikTok的实时Cookie
+        kwargs = await self.get_tiktok_headers()
+        # 创建一个基础爬虫
+        base_crawler = BaseCrawler(proxies=kwargs["proxies"], crawler_headers=kwargs["headers"])
+        async with base_crawler as crawler:
+            # 创建一个音乐作品的BaseModel参数
+            params = MusicPost(musicID=musicId, cursor=cursor, count=count)
+            # 生成一个音乐作品的带有加密参数的Endpoint
+            endpoint = BogusManager.model_2_endpoint(
+                TikTokAPIEndpoints.MUSIC_POST, params.dict(), kwargs["headers"]["User-Agent"]
+            )
+            response = await crawler.fetch_get_json(endpoint)
+        return response
+
     # 获取作品的评论列表
     async def fetch_post_comment(self, aweme_id: str, cursor: int = 0, count: int = 20, current_region: str = ""):
         # 获取TikTok的实时Cookie
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -78,6 +79,80 @@ func cleanupOldFolders(maxAgeMs int64) (int, error) {
 	return removedCount, nil
 }
 
+// quotaMinAge spares folders modified this recently from quota eviction, as
+// their downloads and renders are likely still being written
+const quotaMinAge = time.Minute
+
+// enforceTempQuota removes folders from the temp directory, least recently
+// modified first, until it fits in TEMP_DIR_MAX_MB
+func enforceTempQuota() (int, error) {
+	if TEMP_DIR_MAX_MB <= 0 {
+		return 0, nil
+	}
+	maxBytes := int64(TEMP_DIR_MAX_MB) << 20
+
+	entries, err := os.ReadDir(TEMP_DIR)
+	if err != nil {
+		log.Printf("Error reading temp directory: %v", err)
+		return 0, err
+	}
+
+	type folder struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+	var folders []folder
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if !entry.IsDir() {
+			total += info.Size()
+			continue
+		}
+
+		itemPath := filepath.Join(TEMP_DIR, entry.Name())
+		size := folderSize(itemPath)
+		folders = append(folders, folder{path: itemPath, modTime: info.ModTime(), size: size})
+		total += size
+	}
+	if total <= maxBytes {
+		return 0, nil
+	}
+
+	log.Printf("Temp directory is %d MB, over its %d MB quota; evicting old folders...", total>>20, TEMP_DIR_MAX_MB)
+	sort.Slice(folders, func(i, j int) bool { return folders[i].modTime.Before(folders[j].modTime) })
+	removedCount := 0
+	for _, f := range folders {
+		if total <= maxBytes || time.Since(f.modTime) < quotaMinAge {
+			break
+		}
+		if err := cleanupFolder(f.path); err == nil {
+			total -= f.size
+			removedCount++
+		}
+	}
+	if total > maxBytes {
+		log.Printf("Temp directory is still %d MB over its quota; the rest is in use", (total-maxBytes)>>20)
+	}
+	return removedCount, nil
+}
+
+// folderSize returns the total size of the files in a folder
+func folderSize(folderPath string) int64 {
+	var size int64
+	filepath.Walk(folderPath, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
 // initCleanupSchedule initializes the cleanup schedule
 func initCleanupSchedule(schedule string) {
 	log.Printf("Setting up cleanup schedule: %s", schedule)
@@ -109,6 +184,15 @@ func initCleanupSchedule(schedule string) {
 		})
 	}
 	
+	// Check the temp directory quota far more often than the age-based sweep
+	if TEMP_DIR_MAX_MB > 0 {
+		_, _ = c.AddFunc("@every 30s", func() {
+			if count, err := enforceTempQuota(); err == nil && count > 0 {
+				log.Printf("Quota check evicted %d folders", count)
+			}
+		})
+	}
+
 	// Start the scheduler
 	c.Start()
 	
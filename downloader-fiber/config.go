@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	"envconfig"
+
+	"github.com/joho/godotenv"
+)
+
+// Environment variables, read like downloader-go/config reads them and with
+// the same defaults (see envconfig), so both variants can share one env file
+var (
+	PORT                     string
+	BASE_URL                 string
+	ENCRYPTION_KEY           string
+	DOUYIN_API_URL           string
+	TEMP_DIR                 string
+	HYBRID_API_TIMEOUT       time.Duration
+	SLIDESHOW_IMAGE_SECONDS  int
+	SOURCE_RETRY_ATTEMPTS    int
+	SOURCE_RETRY_BACKOFF     time.Duration
+	SOURCE_RETRY_MAX_BACKOFF time.Duration
+
+	// Bounds of the per-request ?duration= override of SLIDESHOW_IMAGE_SECONDS
+	SLIDESHOW_MIN_IMAGE_SECONDS int
+	SLIDESHOW_MAX_IMAGE_SECONDS int
+
+	// Largest the temp directory may grow, in MB, before its least recently
+	// used folders are evicted; 0 for no limit
+	TEMP_DIR_MAX_MB int
+)
+
+// Load environment variables
+func loadEnv() {
+	// Try to load from .env file
+	godotenv.Load()
+
+	// Set variables with defaults
+	PORT = envconfig.String("PORT", envconfig.DefaultPort)
+	BASE_URL = envconfig.String("BASE_URL", "http://localhost:"+PORT)
+	ENCRYPTION_KEY = envconfig.String("ENCRYPTION_KEY", envconfig.DefaultEncryptionKey)
+	// downloader-go fails over between a list of endpoints; only the first is used here
+	DOUYIN_API_URL = envconfig.List("DOUYIN_API_URL", []string{envconfig.DefaultHybridAPIURL})[0]
+	TEMP_DIR = envconfig.String("TEMP_DIR", envconfig.DefaultTempDir)
+	HYBRID_API_TIMEOUT = envconfig.Duration("HYBRID_API_TIMEOUT", envconfig.DefaultHybridAPITimeout)
+	SLIDESHOW_IMAGE_SECONDS = envconfig.Int("SLIDESHOW_IMAGE_SECONDS", envconfig.DefaultSlideshowImageSeconds)
+	SLIDESHOW_MIN_IMAGE_SECONDS = envconfig.Int("SLIDESHOW_MIN_IMAGE_SECONDS", envconfig.DefaultSlideshowMinImageSeconds)
+	SLIDESHOW_MAX_IMAGE_SECONDS = envconfig.Int("SLIDESHOW_MAX_IMAGE_SECONDS", envconfig.DefaultSlideshowMaxImageSeconds)
+	TEMP_DIR_MAX_MB = envconfig.Int("TEMP_DIR_MAX_MB", envconfig.DefaultTempDirMaxMB)
+	SOURCE_RETRY_ATTEMPTS = envconfig.Int("SOURCE_RETRY_ATTEMPTS", envconfig.DefaultSourceRetryAttempts)
+	SOURCE_RETRY_BACKOFF = envconfig.Duration("SOURCE_RETRY_BACKOFF", envconfig.DefaultSourceRetryBackoff)
+	SOURCE_RETRY_MAX_BACKOFF = envconfig.Duration("SOURCE_RETRY_MAX_BACKOFF", envconfig.DefaultSourceRetryMaxBackoff)
+	if SOURCE_RETRY_MAX_BACKOFF < SOURCE_RETRY_BACKOFF {
+		SOURCE_RETRY_MAX_BACKOFF = SOURCE_RETRY_BACKOFF
+	}
+}
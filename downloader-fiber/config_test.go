@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"envconfig"
+)
+
+// TestLoadEnvSharedDefaults fails when a default shared with downloader-go
+// drifts from internal/envconfig; downloader-go/config has the counterpart
+// of this test
+func TestLoadEnvSharedDefaults(t *testing.T) {
+	for _, key := range envconfig.SharedKeys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	loadEnv()
+
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{"PORT", PORT, envconfig.DefaultPort},
+		{"DOUYIN_API_URL", DOUYIN_API_URL, envconfig.DefaultHybridAPIURL},
+		{"HYBRID_API_TIMEOUT", HYBRID_API_TIMEOUT, envconfig.DefaultHybridAPITimeout},
+		{"ENCRYPTION_KEY", ENCRYPTION_KEY, envconfig.DefaultEncryptionKey},
+		{"TEMP_DIR", TEMP_DIR, envconfig.DefaultTempDir},
+		{"TEMP_DIR_MAX_MB", TEMP_DIR_MAX_MB, envconfig.DefaultTempDirMaxMB},
+		{"SLIDESHOW_IMAGE_SECONDS", SLIDESHOW_IMAGE_SECONDS, envconfig.DefaultSlideshowImageSeconds},
+		{"SLIDESHOW_MIN_IMAGE_SECONDS", SLIDESHOW_MIN_IMAGE_SECONDS, envconfig.DefaultSlideshowMinImageSeconds},
+		{"SLIDESHOW_MAX_IMAGE_SECONDS", SLIDESHOW_MAX_IMAGE_SECONDS, envconfig.DefaultSlideshowMaxImageSeconds},
+		{"SOURCE_RETRY_ATTEMPTS", SOURCE_RETRY_ATTEMPTS, envconfig.DefaultSourceRetryAttempts},
+		{"SOURCE_RETRY_BACKOFF", SOURCE_RETRY_BACKOFF, envconfig.DefaultSourceRetryBackoff},
+		{"SOURCE_RETRY_MAX_BACKOFF", SOURCE_RETRY_MAX_BACKOFF, envconfig.DefaultSourceRetryMaxBackoff},
+	}
+	if len(tests) != len(envconfig.SharedKeys) {
+		t.Fatalf("checked %d settings, envconfig shares %d", len(tests), len(envconfig.SharedKeys))
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s default = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
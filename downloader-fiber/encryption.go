@@ -1,123 +1,29 @@
 package main
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/json"
-	"errors"
-	"io"
 	"time"
-)
 
-// Prepare a 32-byte key using SHA-256
-func deriveKey(key string) []byte {
-	hash := sha256.Sum256([]byte(key))
-	return hash[:]
-}
+	"linkcrypto"
+)
 
-// Encrypt data with TTL using AES-GCM (simpler and more secure)
+// Encrypt data with TTL using the AES-GCM scheme shared with downloader-go,
+// so links minted here also open there and the other way round
 func encrypt(plaintext, key string, ttlInSeconds int) (string, error) {
-	// Add timestamp and TTL to the data
-	data := struct {
-		Text      string `json:"t"`
-		Timestamp int64  `json:"ts"`
-		TTL       int    `json:"ttl"`
-	}{
-		Text:      plaintext,
-		Timestamp: time.Now().Unix(),
-		TTL:       ttlInSeconds,
-	}
-	
-	// Convert to JSON
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return "", err
-	}
-	
-	// Derive key
-	keyBytes := deriveKey(key)
-	
-	// Create cipher block
-	block, err := aes.NewCipher(keyBytes)
+	sealer, err := linkcrypto.New(key)
 	if err != nil {
 		return "", err
 	}
-	
-	// Create GCM cipher mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-	
-	// Create nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-	
-	// Encrypt
-	ciphertext := gcm.Seal(nonce, nonce, jsonData, nil)
-	
-	// Convert to URL-safe base64
-	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+	return sealer.Seal(plaintext, time.Duration(ttlInSeconds)*time.Second)
 }
 
-// Decrypt data and check TTL
+// Decrypt data and check TTL; links sealed by older releases still open
 func decrypt(encryptedText, key string) (string, error) {
-	// Decode URL-safe base64
-	ciphertext, err := base64.RawURLEncoding.DecodeString(encryptedText)
-	if err != nil {
-		return "", err
-	}
-	
-	// Derive key
-	keyBytes := deriveKey(key)
-	
-	// Create cipher block
-	block, err := aes.NewCipher(keyBytes)
+	sealer, err := linkcrypto.New(key)
 	if err != nil {
 		return "", err
 	}
-	
-	// Create GCM cipher mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-	
-	// Extract nonce
-	if len(ciphertext) < gcm.NonceSize() {
-		return "", errors.New("ciphertext too short")
-	}
-	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
-	
-	// Decrypt
-	jsonData, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", err
-	}
-	
-	// Parse JSON
-	var data struct {
-		Text      string `json:"t"`
-		Timestamp int64  `json:"ts"`
-		TTL       int    `json:"ttl"`
-	}
-	if err := json.Unmarshal(jsonData, &data); err != nil {
-		return "", err
-	}
-	
-	// Check TTL
-	currentTime := time.Now().Unix()
-	expirationTime := data.Timestamp + int64(data.TTL)
-	if currentTime > expirationTime {
-		return "", errors.New("link expired")
-	}
-	
-	return data.Text, nil
+	return sealer.Open(encryptedText)
 }
 
 // EncryptDownloadData encrypts a DownloadData struct
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os/exec"
+	"strconv"
 )
 
-// createSlideshow creates a slideshow from images and audio
-func createSlideshow(imagePaths []string, audioPath, outputPath string) error {
+// createSlideshow creates a slideshow from images and audio, showing each
+// image for imageSeconds; ffmpeg is killed when ctx ends
+func createSlideshow(ctx context.Context, imagePaths []string, audioPath, outputPath string, imageSeconds float64) error {
 	log.Printf("Creating slideshow with %d images", len(imagePaths))
 	
 	// Build FFmpeg command
@@ -15,7 +18,7 @@ func createSlideshow(imagePaths []string, audioPath, outputPath string) error {
 	
 	// Add each image as input
 	for _, imagePath := range imagePaths {
-		args = append(args, "-loop", "1", "-t", "4", "-i", imagePath)
+		args = append(args, "-loop", "1", "-t", strconv.FormatFloat(imageSeconds, 'f', -1, 64), "-i", imagePath)
 	}
 	
 	// Add audio with loop
@@ -38,10 +41,10 @@ func createSlideshow(imagePaths []string, audioPath, outputPath string) error {
 	filterComplex += fmt.Sprintf("%sconcat=n=%d:v=1:a=0[vout];", concatInputs, len(imagePaths))
 	
 	// Calculate total duration
-	videoDuration := len(imagePaths) * 4
+	videoDuration := float64(len(imagePaths)) * imageSeconds
 	
 	// Add audio filter to trim the looping audio to the video duration
-	filterComplex += fmt.Sprintf("[%d:a]atrim=0:%d[aout]", len(imagePaths), videoDuration)
+	filterComplex += fmt.Sprintf("[%d:a]atrim=0:%s[aout]", len(imagePaths), strconv.FormatFloat(videoDuration, 'f', -1, 64))
 	
 	// Add filter complex to args
 	args = append(args, "-filter_complex", filterComplex)
@@ -60,7 +63,7 @@ func createSlideshow(imagePaths []string, audioPath, outputPath string) error {
 	args = append(args, outputPath)
 	
 	// Create command
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	
 	// Get command as string for logging
 	//cmdStr := fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
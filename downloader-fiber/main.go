@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,17 +16,13 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"	
 	"github.com/subosito/gozaru"
 )
 
-// Environment variables
-var (
-	PORT           string
-	BASE_URL       string
-	ENCRYPTION_KEY string
-	DOUYIN_API_URL string
-	TEMP_DIR       string
+// Per-stage deadlines of a slideshow request
+const (
+	mediaFetchTimeout = 2 * time.Minute
+	renderTimeout     = 5 * time.Minute
 )
 
 // ContentType mapping
@@ -38,7 +35,7 @@ var contentTypes = map[string][]string{
 // Response structures
 type TikTokResponse struct {
 	Status               string         `json:"status"`
-	Photos               []PhotoItem    `json:"photos,omitempty"`
+	Photos               []PhotoItem    `json:"photos"`
 	Title                string         `json:"title"`
 	Description          string         `json:"description"`
 	Statistics           Statistics     `json:"statistics"`
@@ -113,6 +110,7 @@ func main() {
 	router.GET("/download", handleDownload)
 	router.GET("/download-slideshow", handleSlideshow)
 	router.GET("/health", handleHealth)
+	router.GET("/version", handleVersion)
 
 	// 404 handler
 	router.NoRoute(func(c *gin.Context) {
@@ -120,6 +118,8 @@ func main() {
 	})
 
 	// Start server
+	build := getBuildInfo()
+	log.Printf("Starting %s %s (commit %s, built %s)", build.Variant, build.Version, build.Commit, build.BuildDate)
 	log.Printf("Server started on port %s", PORT)
 	log.Printf("Base URL: %s", BASE_URL)
 	log.Printf("Temp directory: %s", TEMP_DIR)
@@ -127,27 +127,6 @@ func main() {
 	log.Fatal(router.Run(":" + PORT))
 }
 
-// Load environment variables
-func loadEnv() {
-	// Try to load from .env file
-	godotenv.Load()
-
-	// Set variables with defaults
-	PORT = getEnv("PORT", "6075")
-	BASE_URL = getEnv("BASE_URL", "http://localhost:"+PORT)
-	ENCRYPTION_KEY = getEnv("ENCRYPTION_KEY", "overflow")
-	DOUYIN_API_URL = getEnv("DOUYIN_API_URL", "http://127.0.0.1:3035/api/hybrid/video_data")
-	TEMP_DIR = filepath.Join(".", "temp")
-}
-
-// Get environment variable with fallback
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return fallback
-}
-
 // Health check handler
 func handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -172,13 +151,16 @@ func handleTikTok(c *gin.Context) {
 	}
 
 	// Check if URL is from TikTok or Douyin
-	if !strings.Contains(req.URL, "tiktok.com") && !strings.Contains(req.URL, "douyin.com") {
+	if !isSupportedURL(req.URL) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Only TikTok and Douyin URLs are supported"})
 		return
 	}
 
+	// Follow share short links to the post they stand for
+	req.URL = resolveShareURL(c.Request.Context(), req.URL)
+
 	// Fetch data from hybrid API
-	data, err := fetchTikTokData(req.URL, true)
+	data, err := fetchTikTokData(c.Request.Context(), req.URL, true)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -230,7 +212,7 @@ func handleDownload(c *gin.Context) {
 	encodedFilename := gozaru.Sanitize(filename)
 
 	// Stream the file
-	streamDownload(downloadData.URL, c, contentType, encodedFilename)
+	streamDownload(c.Request.Context(), downloadData.URL, c, contentType, encodedFilename)
 }
 
 // Slideshow download handler
@@ -244,6 +226,17 @@ func handleSlideshow(c *gin.Context) {
 		return
 	}
 
+	// Seconds each image is shown, e.g. 1.5 for a faster slideshow
+	imageSeconds := float64(SLIDESHOW_IMAGE_SECONDS)
+	if durationParam := c.Query("duration"); durationParam != "" {
+		seconds, err := strconv.ParseFloat(durationParam, 64)
+		if err != nil || !(seconds >= float64(SLIDESHOW_MIN_IMAGE_SECONDS) && seconds <= float64(SLIDESHOW_MAX_IMAGE_SECONDS)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duration must be between %d and %d seconds", SLIDESHOW_MIN_IMAGE_SECONDS, SLIDESHOW_MAX_IMAGE_SECONDS)})
+			return
+		}
+		imageSeconds = seconds
+	}
+
 	// Decrypt the URL
 	decryptedURL, err := decrypt(encryptedURL, ENCRYPTION_KEY)
 	if err != nil {
@@ -251,8 +244,11 @@ func handleSlideshow(c *gin.Context) {
 		return
 	}
 
+	// Every stage below stops when the client goes away
+	ctx := c.Request.Context()
+
 	// Fetch data from hybrid API
-	data, err := fetchTikTokData(decryptedURL, true)
+	data, err := fetchTikTokData(ctx, decryptedURL, true)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -290,13 +286,19 @@ func handleSlideshow(c *gin.Context) {
 	workDir = filepath.Join(TEMP_DIR, folderName)
 	os.MkdirAll(workDir, 0755)
 
+	// Make room for this render right away if the temp directory is over quota
+	go enforceTempQuota()
+
+	// Remove the work directory on every path, including abandoned requests;
+	// the served file is removed once streaming ends
 	defer func() {
-		// Cleanup on error
-		if err != nil && workDir != "" {
-			cleanupFolder(workDir)
-		}
+		go cleanupFolder(workDir)
 	}()
 
+	// Bound the downloads so a stalled CDN can't hold the work directory
+	fetchCtx, cancelFetch := context.WithTimeout(ctx, mediaFetchTimeout)
+	defer cancelFetch()
+
 	// Get image URLs
 	imageData, ok := videoData["image_data"].(map[string]interface{})
 	if !ok {
@@ -319,7 +321,7 @@ func handleSlideshow(c *gin.Context) {
 		}
 
 		imagePath := filepath.Join(workDir, fmt.Sprintf("image_%d.jpg", i))
-		if err := downloadFile(imgURLStr, imagePath); err != nil {
+		if err := downloadFile(fetchCtx, imgURLStr, imagePath); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download image: " + err.Error()})
 			return
 		}
@@ -354,14 +356,17 @@ func handleSlideshow(c *gin.Context) {
 
 	// Download audio
 	audioPath := filepath.Join(workDir, "audio.mp3")
-	if err := downloadFile(audioURL, audioPath); err != nil {
+	if err := downloadFile(fetchCtx, audioURL, audioPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download audio: " + err.Error()})
 		return
 	}
 
 	// Create slideshow
+	renderCtx, cancelRender := context.WithTimeout(ctx, renderTimeout)
+	defer cancelRender()
+
 	outputPath := filepath.Join(workDir, "slideshow.mp4")
-	if err := createSlideshow(imagePaths, audioPath, outputPath); err != nil {
+	if err := createSlideshow(renderCtx, imagePaths, audioPath, outputPath, imageSeconds); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create slideshow: " + err.Error()})
 		return
 	}
@@ -406,17 +411,13 @@ func handleSlideshow(c *gin.Context) {
 	// Use DataFromReader to stream the file
 	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	c.DataFromReader(http.StatusOK, fileInfo.Size(), "video/mp4", file, nil)
-	
-	// Clean up the temp directory after streaming
-	go func() {
-		cleanupFolder(workDir)
-	}()
 }
 
 // Helper functions
 
-// Stream a file from a URL to the response
-func streamDownload(url string, c *gin.Context, contentType, encodedFilename string) {
+// Stream a file from a URL to the response; the source request is cancelled
+// with ctx, so a client that disconnects releases the upstream connection
+func streamDownload(ctx context.Context, url string, c *gin.Context, contentType, encodedFilename string) {
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: 120 * time.Second,
@@ -426,7 +427,7 @@ func streamDownload(url string, c *gin.Context, contentType, encodedFilename str
 	log.Printf("Starting download from: %s", url)
 
 	// Create a request so we can modify headers
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Printf("Failed to create request: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request: " + err.Error()})
@@ -439,8 +440,28 @@ func streamDownload(url string, c *gin.Context, contentType, encodedFilename str
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("Connection", "keep-alive")
 
-	// Send the request
-	resp, err := client.Do(req)
+	// Forward Range headers so interrupted downloads can resume
+	for _, name := range []string{"Range", "If-Range"} {
+		if value := c.GetHeader(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	// Send the request, retrying transient failures; once retries are
+	// exhausted the last response is relayed whatever its status
+	var resp *http.Response
+	err = retrySource(ctx, url, func(last bool) error {
+		var err error
+		resp, err = client.Do(req.Clone(ctx))
+		if err != nil {
+			return err
+		}
+		if !last && retryableStatus(resp.StatusCode) {
+			resp.Body.Close()
+			return sourceStatusError{resp.StatusCode}
+		}
+		return nil
+	})
 	if err != nil {
 		log.Printf("Download error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download from source: " + err.Error()})
@@ -448,7 +469,9 @@ func streamDownload(url string, c *gin.Context, contentType, encodedFilename str
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// Relay full, partial and unsatisfiable-range responses; anything else is an error
+	status := resp.StatusCode
+	if status != http.StatusOK && status != http.StatusPartialContent && status != http.StatusRequestedRangeNotSatisfiable {
 		log.Printf("Source returned status: %d", resp.StatusCode)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Source returned error: %d", resp.StatusCode)})
 		return
@@ -461,30 +484,49 @@ func streamDownload(url string, c *gin.Context, contentType, encodedFilename str
 	// Set additional headers
 	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, encodedFilename, encodedFilename))
 	c.Header("x-filename", encodedFilename)
+	if strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		c.Header("Accept-Ranges", "bytes")
+	}
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		c.Header("Content-Range", contentRange)
+	}
 
 	// Use DataFromReader to stream the content directly to the client
 	// This handles setting content-length and content-type automatically
-	c.DataFromReader(http.StatusOK, contentLength, contentType, resp.Body, nil)
+	c.DataFromReader(status, contentLength, contentType, resp.Body, nil)
 
 	log.Printf("Download completed successfully")
 }
 
-// Download a file from URL to local path
-func downloadFile(url, outputPath string) error {
+// Download a file from URL to local path, giving up when ctx ends and
+// retrying transient failures
+func downloadFile(ctx context.Context, url, outputPath string) error {
+	return retrySource(ctx, url, func(bool) error {
+		return downloadOnce(ctx, url, outputPath)
+	})
+}
+
+// Make a single attempt of downloadFile
+func downloadOnce(ctx context.Context, url, outputPath string) error {
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: 120 * time.Second,
 	}
 
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
 	// Get the data
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+		return sourceStatusError{resp.StatusCode}
 	}
 
 	// Create the file
@@ -500,7 +542,7 @@ func downloadFile(url, outputPath string) error {
 }
 
 // Fetch TikTok data from the hybrid API
-func fetchTikTokData(urlStr string, minimal bool) (map[string]interface{}, error) {
+func fetchTikTokData(ctx context.Context, urlStr string, minimal bool) (map[string]interface{}, error) {
 	minimalStr := "false"
 	if minimal {
 		minimalStr = "true"
@@ -511,11 +553,16 @@ func fetchTikTokData(urlStr string, minimal bool) (map[string]interface{}, error
 
 	// Create HTTP client with timeout
 	client := &http.Client{
-		Timeout: 120 * time.Second,
+		Timeout: HYBRID_API_TIMEOUT,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %v", err)
 	}
 
 	// Fetch data
-	resp, err := client.Get(apiURL)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch data: %v", err)
 	}
@@ -538,6 +585,7 @@ func fetchTikTokData(urlStr string, minimal bool) (map[string]interface{}, error
 func generateJsonResponse(data map[string]interface{}, urlStr string) (TikTokResponse, error) {
 	// Initialize default response
 	response := TikTokResponse{
+		Photos:       []PhotoItem{},
 		DownloadLink: make(map[string]any),
 	}
 
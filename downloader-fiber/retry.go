@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// sourceStatusError is a source response with an unexpected status
+type sourceStatusError struct {
+	code int
+}
+
+func (e sourceStatusError) Error() string {
+	return fmt.Sprintf("bad status: %d", e.code)
+}
+
+// retryableStatus reports whether a source status is worth retrying: CDN
+// URLs intermittently answer 403, and 408, 429 and 5xx are transient
+func retryableStatus(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusRequestTimeout ||
+		code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryableError reports whether a failed attempt is worth retrying.
+// Cancellation of the caller's context never is.
+func retryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr sourceStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryDelay returns the jittered exponential backoff before retry number attempt (1-based)
+func retryDelay(attempt int) time.Duration {
+	delay := SOURCE_RETRY_BACKOFF << (attempt - 1)
+	if delay > SOURCE_RETRY_MAX_BACKOFF || delay <= 0 {
+		delay = SOURCE_RETRY_MAX_BACKOFF
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retrySource runs attempt until it succeeds, fails permanently or
+// SOURCE_RETRY_ATTEMPTS is reached. attempt is told when it is the last try.
+func retrySource(ctx context.Context, url string, attempt func(last bool) error) error {
+	for try := 1; ; try++ {
+		err := attempt(try >= SOURCE_RETRY_ATTEMPTS)
+		if err == nil {
+			return nil
+		}
+		if try >= SOURCE_RETRY_ATTEMPTS || !retryableError(ctx, err) {
+			return err
+		}
+
+		delay := retryDelay(try)
+		log.Printf("Retrying %s in %s after attempt %d failed: %v", url, delay, try, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
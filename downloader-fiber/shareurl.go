@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// shareURLTimeout bounds resolving a share short link, all redirects included
+const shareURLTimeout = 10 * time.Second
+
+// shareURLMaxHops bounds the redirects followed from a short link
+const shareURLMaxHops = 5
+
+// shareURLUserAgent is sent when resolving short links, which redirect
+// clients that don't look like a browser to the app store instead of the post
+const shareURLUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+
+// supportedHostSuffixes lists the domains of post URLs accepted by the API
+var supportedHostSuffixes = []string{"tiktok.com", "douyin.com", "iesdouyin.com"}
+
+// shareHosts lists the hosts of share short links
+var shareHosts = map[string]bool{
+	"vm.tiktok.com": true,
+	"vt.tiktok.com": true,
+	"v.douyin.com":  true,
+}
+
+// parsePostURL parses a post URL, defaulting to https when the scheme was
+// left out, as in links pasted from share sheets
+func parsePostURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	return url.Parse(raw)
+}
+
+// isSupportedURL reports whether a URL points to TikTok or Douyin, share
+// short links (vm.tiktok.com, vt.tiktok.com, v.douyin.com) included
+func isSupportedURL(raw string) bool {
+	parsed, err := parsePostURL(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, suffix := range supportedHostSuffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isShareURL reports whether a URL is a share short link, including the
+// www.tiktok.com/t/<code> form
+func isShareURL(raw string) bool {
+	parsed, err := parsePostURL(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if shareHosts[host] {
+		return true
+	}
+	return (host == "tiktok.com" || host == "www.tiktok.com") && strings.HasPrefix(parsed.Path, "/t/")
+}
+
+// resolveShareURL follows the redirects of a share short link to the post
+// URL it stands for. Other URLs, and links that fail to resolve, are
+// returned unchanged; the hybrid API then resolves them itself.
+func resolveShareURL(ctx context.Context, raw string) string {
+	if !isShareURL(raw) {
+		return raw
+	}
+	resolved, err := followShareRedirects(ctx, raw)
+	if err != nil {
+		log.Printf("Resolving share link failed, passing it on as is: %v", err)
+		return raw
+	}
+	return resolved
+}
+
+// followShareRedirects follows redirects one at a time until they leave the
+// short link hosts, without downloading the post page they end at
+func followShareRedirects(ctx context.Context, raw string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, shareURLTimeout)
+	defer cancel()
+
+	current, err := parsePostURL(raw)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for hop := 0; hop < shareURLMaxHops; hop++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", current.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", shareURLUserAgent)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return "", fmt.Errorf("share link answered %d without a redirect", resp.StatusCode)
+		}
+		next, err := current.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect %q: %v", location, err)
+		}
+		if !isShareURL(next.String()) {
+			if !isSupportedURL(next.String()) {
+				return "", fmt.Errorf("share link redirected off TikTok/Douyin to %s", next.Host)
+			}
+			return next.String(), nil
+		}
+		current = next
+	}
+	return "", fmt.Errorf("share link redirected more than %d times", shareURLMaxHops)
+}
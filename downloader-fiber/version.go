@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VARIANT identifies this implementation among the downloader services
+const VARIANT = "downloader-fiber"
+
+// Build metadata, injected at build time with:
+//
+//	go build -ldflags "-X main.Version=v1.2.3 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// BuildInfo describes the running build
+type BuildInfo struct {
+	Variant   string `json:"variant"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// getBuildInfo returns the build metadata, falling back to the VCS
+// information embedded by the Go toolchain when ldflags were not provided
+func getBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Variant:   VARIANT,
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = setting.Value
+				if len(info.Commit) > 12 {
+					info.Commit = info.Commit[:12]
+				}
+			case setting.Key == "vcs.time" && info.BuildDate == "unknown":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// Version handler
+func handleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, getBuildInfo())
+}
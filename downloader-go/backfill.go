@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"tiktok-downloader/handlers"
+)
+
+// runBackfillCommand implements `tiktok-downloader backfill [flags] <manifest>`
+// and returns the process exit code
+func runBackfillCommand(h *handlers.HandlerContext, args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	naming := fs.String("naming", "", "naming mode: default or ytdlp (defaults to ARCHIVE_NAMING)")
+	restart := fs.Bool("restart", false, "ignore any saved checkpoint and start from the first line")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tiktok-downloader backfill [flags] <file|file://path|s3://bucket/key>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	mode := *naming
+	if mode == "" {
+		mode = h.Config.ArchiveNaming
+	}
+	if mode != handlers.NamingDefault && mode != handlers.NamingYtDlp {
+		log.Printf("Invalid naming mode %q, expected 'default' or 'ytdlp'", mode)
+		return 2
+	}
+
+	// Stop after the current item on Ctrl-C; the checkpoint lets a rerun resume
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	source := fs.Arg(0)
+	checkpoint, err := h.RunBackfill(ctx, source, mode, *restart, func(cp handlers.BackfillCheckpoint) {
+		log.Printf("Backfill %s: line %d (archived %d, skipped %d, failed %d)", source, cp.Line, cp.Processed, cp.Skipped, cp.Failed)
+	})
+	if err != nil {
+		log.Printf("Backfill stopped at line %d: %v", checkpoint.Line, err)
+		return 1
+	}
+
+	log.Printf("Backfill of %s complete: archived %d, skipped %d, failed %d", source, checkpoint.Processed, checkpoint.Skipped, checkpoint.Failed)
+	return 0
+}
@@ -0,0 +1,14 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores opaque values by key with a per-entry TTL
+type Cache interface {
+	// Get returns the value stored under key, or false on a miss
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
@@ -1,18 +1,324 @@
 package config
 
 import (
-	"os"
+	"crypto/subtle"
 	"path/filepath"
+	"time"
+
+	"envconfig"
 )
 
 // AppConfig holds all the application configuration
 type AppConfig struct {
-	BaseURL       string
-	EncryptionKey string
-	TempDir       string
-	HybridAPIURL  string
-	Port          string
-	ContentTypes  map[string][]string
+	BaseURL        string
+	EncryptionKey  string
+	LinkSigner     string
+	TempDir        string
+	HybridAPIURL   string
+	Port           string
+	ArchiveDir     string
+	ArchiveNaming  string
+	AdminToken     string
+	LogLevel       string
+	LogRedaction   string
+	DiagnosticsDir string
+	ContentTypes   map[string][]string
+
+	// Largest the temp directory may grow, in MB, before its least recently
+	// used folders are evicted; 0 for no limit
+	TempDirMaxMB int
+
+	// How long temp folders of crashed requests and jobs, found by the hourly
+	// reaper, are kept in the quarantine directory for inspection; 0 deletes
+	// them right away
+	TempQuarantineRetention time.Duration
+
+	// Hand local files to a front proxy: off, x-accel (nginx) or x-sendfile,
+	// and the internal nginx location that maps to TempDir
+	SendfileMode        string
+	AccelRedirectPrefix string
+
+	// CORS and browser extension support
+	CorsAllowOrigins []string
+	CorsMaxAge       time.Duration
+	CorsPrivateNet   bool
+	JSONPEnabled     bool
+
+	// Secret for HMAC-signed anonymous /tiktok, /info, /oembed and /embed
+	// requests; empty allows unsigned requests
+	InfoSigningKey string
+
+	// How long /embed/:token player links stay valid
+	EmbedLinkTTL time.Duration
+
+	// Async /tiktok webhooks: optional HMAC secret for X-Signature, and
+	// whether callbacks may target private addresses
+	WebhookSecret       string
+	WebhookAllowPrivate bool
+
+	// Where slideshow jobs are persisted until they finish, and what happens
+	// on boot to those a restart interrupted: "resume" renders them again
+	// (once), "fail" marks them failed
+	JobStateDir          string
+	SlideshowJobRecovery string
+
+	// Whisper-compatible speech-to-text endpoint for POST /transcribe, e.g.
+	// https://api.openai.com/v1/audio/transcriptions; empty disables it
+	TranscribeURL    string
+	TranscribeAPIKey string
+	TranscribeModel  string
+
+	// Translation of descriptions and transcripts (?translate=): deepl,
+	// libretranslate or openai (any OpenAI-compatible chat endpoint), empty
+	// to disable. TranslateURL overrides the provider's default endpoint.
+	TranslateProvider string
+	TranslateURL      string
+	TranslateAPIKey   string
+	TranslateModel    string
+
+	// Service discovery registration
+	DiscoveryBackend     string
+	DiscoveryURL         string
+	DiscoveryServiceName string
+	DiscoveryAddress     string
+	DiscoveryToken       string
+	DiscoveryTTL         time.Duration
+
+	// Slideshow encoder defaults and the bounds for per-request overrides
+	SlideshowPreset        string
+	SlideshowSlowestPreset string
+	SlideshowCRF           int
+	SlideshowMinCRF        int
+	SlideshowMaxCRF        int
+	SlideshowMaxBitrate    string
+	SlideshowFPS           int
+	SlideshowMaxFPS        int
+	SlideshowPixelFormat   string
+	// Font file of the ?intro=true frame and watermark text; empty uses
+	// fontconfig's Sans
+	SlideshowIntroFont string
+	// Brand slideshows with a watermark, ?watermark=true|false overriding it
+	// per request: the PNG at SlideshowWatermarkImage, or the author's handle
+	// when no image is set
+	SlideshowWatermark      bool
+	SlideshowWatermarkImage string
+	// Hardware encoder for slideshows (nvenc, vaapi, qsv or videotoolbox),
+	// empty for libx264, and the VAAPI render node
+	FFmpegHWAccel       string
+	FFmpegHWAccelDevice string
+
+	// GIF conversion defaults and the bounds for per-request overrides
+	GifFPS        int
+	GifMaxFPS     int
+	GifWidth      int
+	GifMaxWidth   int
+	GifMaxSeconds int
+
+	// Embed the source URL, author, post ID and time into served gallery
+	// images as EXIF/XMP; ?attribution=true|false overrides it per request
+	ImageAttribution bool
+
+	// Offer a best-effort no-watermark video, processed from the watermarked
+	// one with delogo, for posts that come back without a clean URL
+	WatermarkRemoval bool
+
+	// Tone-map HDR sources to SDR when re-encoding, with the tonemap algorithm
+	HDRToneMap          bool
+	HDRToneMapAlgorithm string
+
+	// Shared audio cache, keyed by music ID
+	AudioCacheDir string
+	AudioCacheTTL time.Duration
+
+	// Bundled hybrid API run as a managed subprocess; "{port}" in the command
+	// is replaced by HybridEmbedPort, or by a free port when that is 0
+	HybridEmbedCommand string
+	HybridEmbedDir     string
+	HybridEmbedPort    int
+
+	// Hybrid API instances requests are spread over round-robin, skipping
+	// those that are down; HybridAPIURL is the first of them
+	HybridAPIURLs []string
+
+	// Public hybrid API instances used, in order, only while every primary
+	// is down, and how often the primaries are probed for recovery
+	HybridFallbackURLs   []string
+	HybridHealthInterval time.Duration
+
+	// Alternate hybrid API and proxy used to retry geo-blocked media
+	RegionRetryAPIURL string
+	RegionRetryProxy  string
+
+	// Douyin cookie jar attached to Douyin CDN fetches
+	DouyinCookieFile            string
+	DouyinCookieRefreshURL      string
+	DouyinCookieRefreshInterval time.Duration
+
+	// Instagram Reels and posts, resolved through instagram.com's GraphQL
+	// query InstagramDocID; InstagramSessionID is an optional sessionid
+	// cookie for posts only served to logged-in sessions
+	InstagramEnabled   bool
+	InstagramDocID     string
+	InstagramSessionID string
+
+	// YouTube Shorts, resolved through the InnerTube player API
+	YouTubeEnabled bool
+
+	// Kuaishou videos, resolved from their kuaishou.com pages; KuaishouCookie
+	// is an optional Cookie header (with a did cookie) that avoids captchas
+	KuaishouEnabled bool
+	KuaishouCookie  string
+
+	// Platforms whose CDN fetches mimic Chrome's TLS fingerprint
+	TLSImpersonatePlatforms []string
+
+	// HTTP or SOCKS5 proxy for hybrid API requests and CDN fetches, and the
+	// hosts that bypass it
+	ProxyURL    string
+	ProxyBypass []string
+
+	// Size-aware source streaming: the size of ranged fetches of large
+	// bodies, how long a connection may stall before resuming, and the
+	// minimum throughput (bytes/s) the transfer deadline allows for
+	SourceChunkSize     int
+	SourceStallTimeout  time.Duration
+	SourceMinThroughput int
+
+	// Per-host pacing for the hybrid API and CDN hosts
+	PacingHybridInterval time.Duration
+	PacingCDNInterval    time.Duration
+	PacingJitter         time.Duration
+
+	// S3/MinIO access for backfill manifests
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Region    string
+	S3UseSSL    bool
+
+	// S3 media cache, optionally served through CloudFront signed URLs
+	MediaCacheBucket         string
+	MediaCachePrefix         string
+	CloudFrontDomain         string
+	CloudFrontKeyPairID      string
+	CloudFrontPrivateKeyFile string
+	CloudFrontURLTTL         time.Duration
+
+	// Where finished slideshows are kept: "local" serves them from TempDir,
+	// "s3" uploads them to StorageBucket and answers with presigned URLs
+	// valid for StorageURLTTL
+	StorageBackend string
+	StorageBucket  string
+	StoragePrefix  string
+	StorageURLTTL  time.Duration
+
+	// Workers serving the background job queue
+	JobWorkers int
+
+	// Workers serving the ffmpeg render queue, and the API keys allowed to jump it
+	RenderWorkers      int
+	InteractiveAPIKeys []string
+
+	// API keys that always get the Node.js-compatible response schema
+	NodeCompatAPIKeys []string
+
+	// Shadow mode: mirror ShadowPercent of /tiktok requests to the fiber
+	// variant at ShadowURL and log how its responses and latency differ
+	ShadowURL     string
+	ShadowPercent int
+
+	// How /download links carry their data: "encrypted" as a LINK_SIGNER
+	// token, "signed" as readable query parameters with an HMAC-SHA256
+	// signature, for shorter links that are easier to debug, or "short" as a
+	// /d/:code link to data kept in Redis, or in memory for up to
+	// ShortLinkCacheSize links
+	DownloadLinkMode   string
+	ShortLinkCacheSize int
+
+	// How long the links of a response stay valid, and the longest a
+	// request may ask for with link_ttl
+	DownloadLinkTTL    time.Duration
+	DownloadLinkMaxTTL time.Duration
+
+	// API keys by tenant name; tokens issued to a tenant's key account their
+	// streamed bytes to it, in a ledger saved to UsageFile
+	TenantAPIKeys     map[string]string
+	UsageFile         string
+	UsageSaveInterval time.Duration
+
+	// Per-client request budget per window, and the in-flight request limit
+	// advertised to clients (soft) and enforced with 429 (hard); 0 disables
+	RateLimitRequests    int
+	RateLimitWindow      time.Duration
+	ConcurrencySoftLimit int
+	ConcurrencyHardLimit int
+
+	// Guest tier for public instances: with GuestMode on, requests without a
+	// known API key (APIKeys or any key configured above) get watermarked
+	// links only and no slideshows, within GuestRateLimitRequests per
+	// GuestRateLimitWindow. That budget shrinks as a client uses up its
+	// GuestQuota requests per GuestQuotaWindow, after which a key is required.
+	GuestMode              bool
+	APIKeys                []string
+	GuestRateLimitRequests int
+	GuestRateLimitWindow   time.Duration
+	GuestQuota             int
+	GuestQuotaWindow       time.Duration
+
+	// Features switched off by the operator, e.g. without FFmpeg or to avoid
+	// proxying audio: their endpoints answer 501 and responses omit their links
+	DisableSlideshow  bool
+	DisableMP3        bool
+	DisableGIF        bool
+	DisableScenes     bool
+	DisableWaveform   bool
+	DisableTranscribe bool
+	DisableArchive    bool
+
+	// Most post URLs one POST /archive request may queue
+	ArchiveMaxURLs int
+
+	// Egress budget: once EgressBudgetGB gigabytes or EgressBudgetDownloads
+	// downloads are served in an EgressBudgetWindow (aligned to the clock in
+	// UTC), /tiktok answers metadata only and downloads are refused until the
+	// window resets. Range requests continuing a download add their bytes but
+	// don't count as downloads. Zero disables a cap.
+	EgressBudgetGB        int
+	EgressBudgetDownloads int
+	EgressBudgetWindow    time.Duration
+
+	// Command run on every /tiktok response to post-process it (JSON on
+	// stdin and stdout), with a timeout per response
+	ResponseHookCommand string
+	ResponseHookTimeout time.Duration
+
+	// How long job and diagnostics records are kept
+	DataRetention time.Duration
+
+	// Cache of hybrid API responses: Redis when configured, otherwise an
+	// in-memory LRU of MemoryCacheSize entries (0 disables it)
+	RedisURL         string
+	RedisPrefix      string
+	MetadataCacheTTL time.Duration
+	MemoryCacheSize  int
+
+	// Largest hybrid API response read, in MB; larger ones fail the request
+	HybridMaxPayloadMB int
+
+	// Settings shared with downloader-fiber; both variants read the same
+	// env vars with the same defaults
+	HybridAPITimeout      time.Duration
+	SlideshowImageSeconds int
+
+	// Bounds of the per-request ?duration= override of SlideshowImageSeconds
+	SlideshowMinImageSeconds int
+	SlideshowMaxImageSeconds int
+
+	// Retries of source (CDN) downloads: total attempts, and the first and
+	// largest backoff between them
+	SourceRetryAttempts   int
+	SourceRetryBackoff    time.Duration
+	SourceRetryMaxBackoff time.Duration
 }
 
 // ContentType returns the content type and file extension for a given media type
@@ -23,29 +329,241 @@ func (cfg *AppConfig) ContentType(mediaType string) (string, string, bool) {
 	return "", "", false
 }
 
+// KnownAPIKey reports whether key is one of the configured API keys, of any
+// kind, comparing in constant time per candidate
+func (cfg *AppConfig) KnownAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	candidates := append(append(append([]string{}, cfg.APIKeys...), cfg.InteractiveAPIKeys...), cfg.NodeCompatAPIKeys...)
+	for _, tenantKey := range cfg.TenantAPIKeys {
+		candidates = append(candidates, tenantKey)
+	}
+	known := false
+	for _, candidate := range candidates {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			known = true
+		}
+	}
+	return known
+}
+
 // LoadConfig loads the application configuration from environment variables
 // with fallback to default values
 func LoadConfig() *AppConfig {
 	config := &AppConfig{
-		BaseURL:       getEnv("BASE_URL", "https://d.snaptik.fit"),
-		EncryptionKey: getEnv("ENCRYPTION_KEY", "overflow"),
-		TempDir:       filepath.Join(".", "temp"),
-		HybridAPIURL:  getEnv("DOUYIN_API_URL", "http://douyin_tiktok_download_api:8000/api/hybrid/video_data"),
-		Port:          getEnv("PORT", "3021"),
+		BaseURL:        envconfig.String("BASE_URL", "https://d.snaptik.fit"),
+		EncryptionKey:  envconfig.String("ENCRYPTION_KEY", envconfig.DefaultEncryptionKey),
+		LinkSigner:     envconfig.String("LINK_SIGNER", "aes-gcm"),
+		TempDir:        envconfig.String("TEMP_DIR", envconfig.DefaultTempDir),
+		HybridAPIURLs:  envconfig.List("DOUYIN_API_URL", []string{envconfig.DefaultHybridAPIURL}),
+		Port:           envconfig.String("PORT", envconfig.DefaultPort),
+		ArchiveDir:     envconfig.String("ARCHIVE_DIR", filepath.Join(".", "archive")),
+		ArchiveNaming:  envconfig.String("ARCHIVE_NAMING", "default"),
+		AdminToken:     envconfig.String("ADMIN_TOKEN", ""),
+		LogLevel:       envconfig.String("LOG_LEVEL", "info"),
+		LogRedaction:   envconfig.String("LOG_REDACTION", "truncate"),
+		DiagnosticsDir: envconfig.String("DIAGNOSTICS_DIR", ""),
+
+		TempDirMaxMB: envconfig.Int("TEMP_DIR_MAX_MB", envconfig.DefaultTempDirMaxMB),
+
+		TempQuarantineRetention: envconfig.Duration("TEMP_QUARANTINE_RETENTION", 6*time.Hour),
+
 		ContentTypes: map[string][]string{
 			"mp3":   {"audio/mpeg", "mp3"},
 			"video": {"video/mp4", "mp4"},
 			"image": {"image/jpeg", "jpg"},
 		},
+
+		SendfileMode:        envconfig.String("SENDFILE_MODE", "off"),
+		AccelRedirectPrefix: envconfig.String("ACCEL_REDIRECT_PREFIX", "/internal/temp"),
+
+		CorsAllowOrigins: envconfig.List("CORS_ALLOW_ORIGINS", []string{"*"}),
+		CorsMaxAge:       envconfig.Duration("CORS_MAX_AGE", 12*time.Hour),
+		CorsPrivateNet:   envconfig.Bool("CORS_ALLOW_PRIVATE_NETWORK", false),
+		JSONPEnabled:     envconfig.Bool("JSONP_ENABLED", false),
+
+		InfoSigningKey: envconfig.String("INFO_SIGNING_KEY", ""),
+
+		EmbedLinkTTL: envconfig.Duration("EMBED_LINK_TTL", 24*time.Hour),
+
+		WebhookSecret:       envconfig.String("WEBHOOK_SECRET", ""),
+		WebhookAllowPrivate: envconfig.Bool("WEBHOOK_ALLOW_PRIVATE", false),
+
+		JobStateDir:          envconfig.String("JOB_STATE_DIR", filepath.Join(".", "cache", "jobs")),
+		SlideshowJobRecovery: envconfig.String("SLIDESHOW_JOB_RECOVERY", "resume"),
+
+		TranscribeURL:    envconfig.String("TRANSCRIBE_URL", ""),
+		TranscribeAPIKey: envconfig.String("TRANSCRIBE_API_KEY", ""),
+		TranscribeModel:  envconfig.String("TRANSCRIBE_MODEL", "whisper-1"),
+
+		TranslateProvider: envconfig.String("TRANSLATE_PROVIDER", ""),
+		TranslateURL:      envconfig.String("TRANSLATE_URL", ""),
+		TranslateAPIKey:   envconfig.String("TRANSLATE_API_KEY", ""),
+		TranslateModel:    envconfig.String("TRANSLATE_MODEL", "gpt-4o-mini"),
+
+		DiscoveryBackend:     envconfig.String("DISCOVERY_BACKEND", ""),
+		DiscoveryURL:         envconfig.String("DISCOVERY_URL", "http://127.0.0.1:8500"),
+		DiscoveryServiceName: envconfig.String("DISCOVERY_SERVICE_NAME", "tikdownloader"),
+		DiscoveryAddress:     envconfig.String("DISCOVERY_ADVERTISE_ADDRESS", ""),
+		DiscoveryToken:       envconfig.String("DISCOVERY_TOKEN", ""),
+		DiscoveryTTL:         envconfig.Duration("DISCOVERY_TTL", 30*time.Second),
+
+		SlideshowPreset:        envconfig.String("SLIDESHOW_PRESET", "medium"),
+		SlideshowSlowestPreset: envconfig.String("SLIDESHOW_SLOWEST_PRESET", "slow"),
+		SlideshowCRF:           envconfig.Int("SLIDESHOW_CRF", 23),
+		SlideshowMinCRF:        envconfig.Int("SLIDESHOW_MIN_CRF", 18),
+		SlideshowMaxCRF:        envconfig.Int("SLIDESHOW_MAX_CRF", 35),
+		SlideshowMaxBitrate:    envconfig.String("SLIDESHOW_MAX_BITRATE", ""),
+		SlideshowFPS:           envconfig.Int("SLIDESHOW_FPS", 24),
+		SlideshowMaxFPS:        envconfig.Int("SLIDESHOW_MAX_FPS", 60),
+		SlideshowPixelFormat:   envconfig.String("SLIDESHOW_PIX_FMT", "yuv420p"),
+		SlideshowIntroFont:     envconfig.String("SLIDESHOW_INTRO_FONT", ""),
+		FFmpegHWAccel:          envconfig.String("FFMPEG_HWACCEL", ""),
+		FFmpegHWAccelDevice:    envconfig.String("FFMPEG_HWACCEL_DEVICE", "/dev/dri/renderD128"),
+
+		SlideshowWatermark:      envconfig.Bool("SLIDESHOW_WATERMARK", false),
+		SlideshowWatermarkImage: envconfig.String("SLIDESHOW_WATERMARK_IMAGE", ""),
+
+		GifFPS:        envconfig.Int("GIF_FPS", 12),
+		GifMaxFPS:     envconfig.Int("GIF_MAX_FPS", 30),
+		GifWidth:      envconfig.Int("GIF_WIDTH", 480),
+		GifMaxWidth:   envconfig.Int("GIF_MAX_WIDTH", 1080),
+		GifMaxSeconds: envconfig.Int("GIF_MAX_SECONDS", 15),
+
+		ImageAttribution: envconfig.Bool("IMAGE_ATTRIBUTION", false),
+
+		WatermarkRemoval: envconfig.Bool("WATERMARK_REMOVAL", false),
+
+		HDRToneMap:          envconfig.Bool("HDR_TONEMAP", true),
+		HDRToneMapAlgorithm: envconfig.String("HDR_TONEMAP_ALGORITHM", "hable"),
+
+		AudioCacheDir: envconfig.String("AUDIO_CACHE_DIR", filepath.Join(".", "cache", "audio")),
+		AudioCacheTTL: envconfig.Duration("AUDIO_CACHE_TTL", 24*time.Hour),
+
+		HybridEmbedCommand: envconfig.String("HYBRID_API_EMBED_COMMAND", ""),
+		HybridEmbedDir:     envconfig.String("HYBRID_API_EMBED_DIR", ""),
+		HybridEmbedPort:    envconfig.Int("HYBRID_API_EMBED_PORT", 0),
+
+		HybridFallbackURLs:   envconfig.List("HYBRID_FALLBACK_API_URLS", nil),
+		HybridHealthInterval: envconfig.Duration("HYBRID_HEALTH_INTERVAL", 15*time.Second),
+
+		RegionRetryAPIURL: envconfig.String("REGION_RETRY_API_URL", ""),
+		RegionRetryProxy:  envconfig.String("REGION_RETRY_PROXY", ""),
+
+		DouyinCookieFile:            envconfig.String("DOUYIN_COOKIE_FILE", filepath.Join(".", "cache", "douyin_cookies.json")),
+		DouyinCookieRefreshURL:      envconfig.String("DOUYIN_COOKIE_REFRESH_URL", ""),
+		DouyinCookieRefreshInterval: envconfig.Duration("DOUYIN_COOKIE_REFRESH_INTERVAL", 6*time.Hour),
+
+		InstagramEnabled:   envconfig.Bool("INSTAGRAM_ENABLED", true),
+		InstagramDocID:     envconfig.String("INSTAGRAM_DOC_ID", "8845758582119845"),
+		InstagramSessionID: envconfig.String("INSTAGRAM_SESSION_ID", ""),
+
+		YouTubeEnabled: envconfig.Bool("YOUTUBE_ENABLED", true),
+
+		KuaishouEnabled: envconfig.Bool("KUAISHOU_ENABLED", true),
+		KuaishouCookie:  envconfig.String("KUAISHOU_COOKIE", ""),
+
+		TLSImpersonatePlatforms: envconfig.List("TLS_IMPERSONATE_PLATFORMS", nil),
+
+		ProxyURL:    envconfig.String("PROXY_URL", ""),
+		ProxyBypass: envconfig.List("PROXY_BYPASS", nil),
+
+		SourceChunkSize:     envconfig.Int("SOURCE_CHUNK_SIZE", 16<<20),
+		SourceStallTimeout:  envconfig.Duration("SOURCE_STALL_TIMEOUT", 30*time.Second),
+		SourceMinThroughput: envconfig.Int("SOURCE_MIN_THROUGHPUT", 128<<10),
+
+		PacingHybridInterval: envconfig.Duration("PACING_HYBRID_INTERVAL", 0),
+		PacingCDNInterval:    envconfig.Duration("PACING_CDN_INTERVAL", 0),
+		PacingJitter:         envconfig.Duration("PACING_JITTER", 0),
+
+		S3Endpoint:  envconfig.String("S3_ENDPOINT", ""),
+		S3AccessKey: envconfig.String("S3_ACCESS_KEY", ""),
+		S3SecretKey: envconfig.String("S3_SECRET_KEY", ""),
+		S3Region:    envconfig.String("S3_REGION", ""),
+		S3UseSSL:    envconfig.Bool("S3_USE_SSL", true),
+
+		MediaCacheBucket:         envconfig.String("MEDIA_CACHE_S3_BUCKET", ""),
+		MediaCachePrefix:         envconfig.String("MEDIA_CACHE_S3_PREFIX", "media/"),
+		CloudFrontDomain:         envconfig.String("CLOUDFRONT_DOMAIN", ""),
+		CloudFrontKeyPairID:      envconfig.String("CLOUDFRONT_KEY_PAIR_ID", ""),
+		CloudFrontPrivateKeyFile: envconfig.String("CLOUDFRONT_PRIVATE_KEY_FILE", ""),
+		CloudFrontURLTTL:         envconfig.Duration("CLOUDFRONT_URL_TTL", 6*time.Minute),
+
+		StorageBackend: envconfig.String("STORAGE_BACKEND", "local"),
+		StorageBucket:  envconfig.String("STORAGE_S3_BUCKET", ""),
+		StoragePrefix:  envconfig.String("STORAGE_S3_PREFIX", "slideshows/"),
+		StorageURLTTL:  envconfig.Duration("STORAGE_URL_TTL", time.Hour),
+
+		JobWorkers: envconfig.Int("JOB_WORKERS", 2),
+
+		RenderWorkers:      envconfig.Int("RENDER_WORKERS", 2),
+		InteractiveAPIKeys: envconfig.List("INTERACTIVE_API_KEYS", nil),
+
+		NodeCompatAPIKeys: envconfig.List("NODE_COMPAT_API_KEYS", nil),
+
+		ShadowURL:     envconfig.String("SHADOW_URL", ""),
+		ShadowPercent: envconfig.Int("SHADOW_PERCENT", 5),
+
+		DownloadLinkMode:   envconfig.String("DOWNLOAD_LINK_MODE", "encrypted"),
+		ShortLinkCacheSize: envconfig.Int("SHORT_LINK_CACHE_SIZE", 100000),
+
+		DownloadLinkTTL:    envconfig.Duration("DOWNLOAD_LINK_TTL", 6*time.Minute),
+		DownloadLinkMaxTTL: envconfig.Duration("DOWNLOAD_LINK_MAX_TTL", 24*time.Hour),
+
+		TenantAPIKeys:     envconfig.Pairs("TENANT_API_KEYS"),
+		UsageFile:         envconfig.String("USAGE_FILE", filepath.Join(".", "cache", "usage.json")),
+		UsageSaveInterval: envconfig.Duration("USAGE_SAVE_INTERVAL", time.Minute),
+
+		RateLimitRequests:    envconfig.Int("RATE_LIMIT_REQUESTS", 0),
+		RateLimitWindow:      envconfig.Duration("RATE_LIMIT_WINDOW", time.Minute),
+		ConcurrencySoftLimit: envconfig.Int("CONCURRENCY_SOFT_LIMIT", 0),
+		ConcurrencyHardLimit: envconfig.Int("CONCURRENCY_HARD_LIMIT", 0),
+
+		GuestMode:              envconfig.Bool("GUEST_MODE", false),
+		APIKeys:                envconfig.List("API_KEYS", nil),
+		GuestRateLimitRequests: envconfig.Int("GUEST_RATE_LIMIT_REQUESTS", 10),
+		GuestRateLimitWindow:   envconfig.Duration("GUEST_RATE_LIMIT_WINDOW", time.Minute),
+		GuestQuota:             envconfig.Int("GUEST_QUOTA", 100),
+		GuestQuotaWindow:       envconfig.Duration("GUEST_QUOTA_WINDOW", 24*time.Hour),
+
+		DisableSlideshow:  envconfig.Bool("DISABLE_SLIDESHOW", false),
+		DisableMP3:        envconfig.Bool("DISABLE_MP3", false),
+		DisableGIF:        envconfig.Bool("DISABLE_GIF", false),
+		DisableScenes:     envconfig.Bool("DISABLE_SCENES", false),
+		DisableWaveform:   envconfig.Bool("DISABLE_WAVEFORM", false),
+		DisableTranscribe: envconfig.Bool("DISABLE_TRANSCRIBE", false),
+		DisableArchive:    envconfig.Bool("DISABLE_ARCHIVE", false),
+
+		ArchiveMaxURLs: envconfig.Int("ARCHIVE_MAX_URLS", 100),
+
+		EgressBudgetGB:        envconfig.Int("EGRESS_BUDGET_GB", 0),
+		EgressBudgetDownloads: envconfig.Int("EGRESS_BUDGET_DOWNLOADS", 0),
+		EgressBudgetWindow:    envconfig.Duration("EGRESS_BUDGET_WINDOW", 24*time.Hour),
+
+		ResponseHookCommand: envconfig.String("RESPONSE_HOOK_COMMAND", ""),
+		ResponseHookTimeout: envconfig.Duration("RESPONSE_HOOK_TIMEOUT", 2*time.Second),
+
+		DataRetention: envconfig.Duration("DATA_RETENTION", 30*24*time.Hour),
+
+		RedisURL:         envconfig.String("REDIS_URL", ""),
+		RedisPrefix:      envconfig.String("REDIS_PREFIX", "tikdownloader:"),
+		MetadataCacheTTL: envconfig.Duration("METADATA_CACHE_TTL", 10*time.Minute),
+		MemoryCacheSize:  envconfig.Int("MEMORY_CACHE_SIZE", 1000),
+
+		HybridMaxPayloadMB: envconfig.Int("HYBRID_MAX_PAYLOAD_MB", 32),
+
+		HybridAPITimeout:      envconfig.Duration("HYBRID_API_TIMEOUT", envconfig.DefaultHybridAPITimeout),
+		SlideshowImageSeconds: envconfig.Int("SLIDESHOW_IMAGE_SECONDS", envconfig.DefaultSlideshowImageSeconds),
+
+		SlideshowMinImageSeconds: envconfig.Int("SLIDESHOW_MIN_IMAGE_SECONDS", envconfig.DefaultSlideshowMinImageSeconds),
+		SlideshowMaxImageSeconds: envconfig.Int("SLIDESHOW_MAX_IMAGE_SECONDS", envconfig.DefaultSlideshowMaxImageSeconds),
+
+		SourceRetryAttempts:   envconfig.Int("SOURCE_RETRY_ATTEMPTS", envconfig.DefaultSourceRetryAttempts),
+		SourceRetryBackoff:    envconfig.Duration("SOURCE_RETRY_BACKOFF", envconfig.DefaultSourceRetryBackoff),
+		SourceRetryMaxBackoff: envconfig.Duration("SOURCE_RETRY_MAX_BACKOFF", envconfig.DefaultSourceRetryMaxBackoff),
 	}
+	config.HybridAPIURL = config.HybridAPIURLs[0]
 
 	return config
 }
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return fallback
-}
\ No newline at end of file
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"envconfig"
+)
+
+// TestLoadConfigSharedDefaults fails when a default shared with the fiber
+// variant drifts from internal/envconfig; downloader-fiber has the
+// counterpart of this test
+func TestLoadConfigSharedDefaults(t *testing.T) {
+	for _, key := range envconfig.SharedKeys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	cfg := LoadConfig()
+
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{"PORT", cfg.Port, envconfig.DefaultPort},
+		{"DOUYIN_API_URL", cfg.HybridAPIURL, envconfig.DefaultHybridAPIURL},
+		{"HYBRID_API_TIMEOUT", cfg.HybridAPITimeout, envconfig.DefaultHybridAPITimeout},
+		{"ENCRYPTION_KEY", cfg.EncryptionKey, envconfig.DefaultEncryptionKey},
+		{"TEMP_DIR", cfg.TempDir, envconfig.DefaultTempDir},
+		{"TEMP_DIR_MAX_MB", cfg.TempDirMaxMB, envconfig.DefaultTempDirMaxMB},
+		{"SLIDESHOW_IMAGE_SECONDS", cfg.SlideshowImageSeconds, envconfig.DefaultSlideshowImageSeconds},
+		{"SLIDESHOW_MIN_IMAGE_SECONDS", cfg.SlideshowMinImageSeconds, envconfig.DefaultSlideshowMinImageSeconds},
+		{"SLIDESHOW_MAX_IMAGE_SECONDS", cfg.SlideshowMaxImageSeconds, envconfig.DefaultSlideshowMaxImageSeconds},
+		{"SOURCE_RETRY_ATTEMPTS", cfg.SourceRetryAttempts, envconfig.DefaultSourceRetryAttempts},
+		{"SOURCE_RETRY_BACKOFF", cfg.SourceRetryBackoff, envconfig.DefaultSourceRetryBackoff},
+		{"SOURCE_RETRY_MAX_BACKOFF", cfg.SourceRetryMaxBackoff, envconfig.DefaultSourceRetryMaxBackoff},
+	}
+	if len(tests) != len(envconfig.SharedKeys) {
+		t.Fatalf("checked %d settings, envconfig shares %d", len(tests), len(envconfig.SharedKeys))
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s default = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
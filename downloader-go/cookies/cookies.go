@@ -0,0 +1,260 @@
+package cookies
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/utils"
+)
+
+// minRefreshGap keeps 403-triggered refreshes from hammering the login helper
+const minRefreshGap = time.Minute
+
+// IsDouyinHost reports whether a host belongs to Douyin's CDN
+func IsDouyinHost(host string) bool {
+	return utils.PlatformForHost(host) == utils.PlatformDouyin
+}
+
+// Status describes the cookie jar without exposing cookie values
+type Status struct {
+	Names       []string   `json:"names"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Source      string     `json:"source,omitempty"`
+	RefreshURL  bool       `json:"refresh_configured"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// jarFile is the on-disk format of the cookie jar
+type jarFile struct {
+	Cookies   string    `json:"cookies"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Source    string    `json:"source"`
+}
+
+// Manager keeps the Douyin cookies in a file-backed jar, refreshes them from
+// an optional login helper and attaches them to Douyin CDN requests
+type Manager struct {
+	path            string
+	refreshURL      string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu          sync.RWMutex
+	jar         jarFile
+	lastRefresh time.Time
+	lastError   string
+	refreshing  bool
+}
+
+// NewManager creates a cookie manager backed by path; refreshURL may be empty
+func NewManager(path, refreshURL string, refreshInterval time.Duration) *Manager {
+	return &Manager{
+		path:            path,
+		refreshURL:      refreshURL,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Load reads the jar from disk; a missing file leaves the jar empty
+func (m *Manager) Load() error {
+	if m.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var jar jarFile
+	if err := json.Unmarshal(raw, &jar); err != nil {
+		return fmt.Errorf("invalid cookie file %s: %w", m.path, err)
+	}
+	m.mu.Lock()
+	m.jar = jar
+	m.mu.Unlock()
+	return nil
+}
+
+// Set replaces the cookies with a Cookie header style string and persists them
+func (m *Manager) Set(header, source string) error {
+	header = strings.TrimSpace(header)
+	if _, err := http.ParseCookie(header); err != nil {
+		return fmt.Errorf("invalid cookies: %w", err)
+	}
+
+	jar := jarFile{Cookies: header, UpdatedAt: time.Now(), Source: source}
+	m.mu.Lock()
+	m.jar = jar
+	m.mu.Unlock()
+
+	if m.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(jar, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// Status returns the cookie names and refresh state
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := Status{
+		Names:      []string{},
+		Source:     m.jar.Source,
+		RefreshURL: m.refreshURL != "",
+		LastError:  m.lastError,
+	}
+	if !m.jar.UpdatedAt.IsZero() {
+		updatedAt := m.jar.UpdatedAt
+		status.UpdatedAt = &updatedAt
+	}
+	if !m.lastRefresh.IsZero() {
+		lastRefresh := m.lastRefresh
+		status.LastRefresh = &lastRefresh
+	}
+	if parsed, err := http.ParseCookie(m.jar.Cookies); err == nil {
+		for _, cookie := range parsed {
+			status.Names = append(status.Names, cookie.Name)
+		}
+	}
+	return status
+}
+
+// Refresh fetches fresh cookies from the login helper. The helper may answer
+// with JSON {"cookies": "..."} or a plain Cookie header string.
+func (m *Manager) Refresh(ctx context.Context) error {
+	if m.refreshURL == "" {
+		return fmt.Errorf("no cookie refresh URL configured")
+	}
+
+	err := m.refresh(ctx)
+	m.mu.Lock()
+	m.lastRefresh = time.Now()
+	m.lastError = ""
+	if err != nil {
+		m.lastError = err.Error()
+	}
+	m.mu.Unlock()
+	return err
+}
+
+func (m *Manager) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.refreshURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cookie refresh returned %d", resp.StatusCode)
+	}
+
+	header := strings.TrimSpace(string(body))
+	var payload struct {
+		Cookies string `json:"cookies"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Cookies != "" {
+		header = payload.Cookies
+	}
+	if header == "" {
+		return fmt.Errorf("cookie refresh returned no cookies")
+	}
+	return m.Set(header, "refresh")
+}
+
+// Run refreshes the cookies periodically until ctx is done
+func (m *Manager) Run(ctx context.Context) {
+	if m.refreshURL == "" || m.refreshInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Refresh(ctx); err != nil {
+				log.Printf("Douyin cookie refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// Before attaches the managed cookies to Douyin CDN requests
+func (m *Manager) Before(req *http.Request) {
+	if !IsDouyinHost(req.URL.Host) {
+		return
+	}
+	m.mu.RLock()
+	header := m.jar.Cookies
+	m.mu.RUnlock()
+	if header == "" {
+		return
+	}
+	if existing := req.Header.Get("Cookie"); existing != "" {
+		header = existing + "; " + header
+	}
+	req.Header.Set("Cookie", header)
+}
+
+// After triggers a background refresh when a Douyin CDN rejects the cookies
+func (m *Manager) After(req *http.Request, resp *http.Response) {
+	if resp.StatusCode != http.StatusForbidden || m.refreshURL == "" || !IsDouyinHost(req.URL.Host) {
+		return
+	}
+
+	m.mu.Lock()
+	if m.refreshing || time.Since(m.lastRefresh) < minRefreshGap {
+		m.mu.Unlock()
+		return
+	}
+	m.refreshing = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.refreshing = false
+			m.mu.Unlock()
+		}()
+		log.Printf("Douyin CDN returned 403 for %s, refreshing cookies", req.URL.Host)
+		if err := m.Refresh(context.Background()); err != nil {
+			log.Printf("Douyin cookie refresh failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// consulRegistrar registers the instance with a Consul agent and lets the
+// agent poll the /health endpoint
+type consulRegistrar struct {
+	client   *http.Client
+	endpoint string
+	token    string
+	instance Instance
+}
+
+func (r *consulRegistrar) headers() map[string]string {
+	if r.token == "" {
+		return nil
+	}
+	return map[string]string{"X-Consul-Token": r.token}
+}
+
+// Register registers the service with an HTTP health check
+func (r *consulRegistrar) Register(ctx context.Context) error {
+	body := map[string]interface{}{
+		"ID":      r.instance.ID,
+		"Name":    r.instance.Name,
+		"Address": r.instance.Address,
+		"Port":    r.instance.Port,
+		"Check": map[string]interface{}{
+			"HTTP":                           r.instance.healthURL(),
+			"Interval":                       "10s",
+			"Timeout":                        "5s",
+			"DeregisterCriticalServiceAfter": "1m",
+		},
+	}
+
+	if err := doJSON(ctx, r.client, http.MethodPut, r.endpoint+"/v1/agent/service/register", r.headers(), body, nil); err != nil {
+		return err
+	}
+	log.Printf("Registered %s with Consul at %s", r.instance.ID, r.endpoint)
+	return nil
+}
+
+// Deregister removes the service from the Consul agent
+func (r *consulRegistrar) Deregister(ctx context.Context) error {
+	url := r.endpoint + "/v1/agent/service/deregister/" + r.instance.ID
+	if err := doJSON(ctx, r.client, http.MethodPut, url, r.headers(), nil, nil); err != nil {
+		return err
+	}
+	log.Printf("Deregistered %s from Consul", r.instance.ID)
+	return nil
+}
@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiktok-downloader/config"
+)
+
+// Registrar registers this instance with a service discovery backend
+type Registrar interface {
+	// Register announces the instance and keeps the registration alive until ctx is done
+	Register(ctx context.Context) error
+	// Deregister removes the instance from the backend
+	Deregister(ctx context.Context) error
+}
+
+// Instance describes the advertised service instance
+type Instance struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+}
+
+// New returns a registrar for the configured backend, or nil when discovery is disabled
+func New(cfg *config.AppConfig) (Registrar, error) {
+	if cfg.DiscoveryBackend == "" {
+		return nil, nil
+	}
+
+	port, err := strconv.Atoi(cfg.Port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", cfg.Port, err)
+	}
+
+	address := cfg.DiscoveryAddress
+	if address == "" {
+		if address, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("could not determine advertised address: %w", err)
+		}
+	}
+
+	instance := Instance{
+		ID:      fmt.Sprintf("%s-%s-%d", cfg.DiscoveryServiceName, address, port),
+		Name:    cfg.DiscoveryServiceName,
+		Address: address,
+		Port:    port,
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	endpoint := strings.TrimRight(cfg.DiscoveryURL, "/")
+
+	switch cfg.DiscoveryBackend {
+	case "consul":
+		return &consulRegistrar{client: client, endpoint: endpoint, token: cfg.DiscoveryToken, instance: instance}, nil
+	case "etcd":
+		return &etcdRegistrar{client: client, endpoint: endpoint, ttl: cfg.DiscoveryTTL, instance: instance}, nil
+	default:
+		return nil, fmt.Errorf("unsupported discovery backend %q", cfg.DiscoveryBackend)
+	}
+}
+
+// healthURL returns the local health endpoint used to gate registrations
+func (i Instance) healthURL() string {
+	return fmt.Sprintf("http://%s:%d/health", i.Address, i.Port)
+}
+
+// doJSON sends a JSON request and decodes the JSON response into out when non-nil
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s returned %d: %s", method, url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
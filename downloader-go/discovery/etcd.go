@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// etcdRegistrar publishes the instance under /services/<name>/<id> in etcd
+// using the v3 JSON gateway. The key is bound to a lease that is only kept
+// alive while the local /health endpoint reports healthy, so unhealthy
+// instances drop out of discovery on their own.
+type etcdRegistrar struct {
+	client   *http.Client
+	endpoint string
+	ttl      time.Duration
+	instance Instance
+
+	mu      sync.Mutex
+	leaseID string
+}
+
+func (r *etcdRegistrar) key() string {
+	return fmt.Sprintf("/services/%s/%s", r.instance.Name, r.instance.ID)
+}
+
+// Register grants a lease, writes the instance key and keeps the lease alive
+func (r *etcdRegistrar) Register(ctx context.Context) error {
+	if err := r.put(ctx); err != nil {
+		return err
+	}
+	log.Printf("Registered %s with etcd at %s", r.key(), r.endpoint)
+
+	go r.keepAlive(ctx)
+	return nil
+}
+
+// put grants a fresh lease and stores the instance under it
+func (r *etcdRegistrar) put(ctx context.Context) error {
+	var lease struct {
+		ID string `json:"ID"`
+	}
+	ttl := int64(r.ttl.Seconds())
+	if ttl < 5 {
+		ttl = 5
+	}
+	if err := doJSON(ctx, r.client, http.MethodPost, r.endpoint+"/v3/lease/grant", nil, map[string]interface{}{"TTL": ttl}, &lease); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.leaseID = lease.ID
+	r.mu.Unlock()
+
+	value, err := json.Marshal(r.instance)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"key":   base64.StdEncoding.EncodeToString([]byte(r.key())),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": lease.ID,
+	}
+	return doJSON(ctx, r.client, http.MethodPost, r.endpoint+"/v3/kv/put", nil, body, nil)
+}
+
+// keepAlive refreshes the lease while the instance is healthy and
+// re-registers once it recovers
+func (r *etcdRegistrar) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+
+	registered := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		healthy := r.healthy(ctx)
+		switch {
+		case healthy && registered:
+			if err := doJSON(ctx, r.client, http.MethodPost, r.endpoint+"/v3/lease/keepalive", nil, map[string]interface{}{"ID": r.lease()}, nil); err != nil {
+				log.Printf("etcd lease keepalive failed: %v", err)
+				registered = false
+			}
+		case healthy && !registered:
+			if err := r.put(ctx); err != nil {
+				log.Printf("etcd re-registration failed: %v", err)
+				continue
+			}
+			log.Printf("Re-registered %s with etcd", r.key())
+			registered = true
+		case !healthy && registered:
+			log.Printf("Health check failing, letting etcd lease for %s expire", r.key())
+			registered = false
+		}
+	}
+}
+
+// lease returns the current lease ID
+func (r *etcdRegistrar) lease() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leaseID
+}
+
+// healthy reports whether the local health endpoint responds with 200
+func (r *etcdRegistrar) healthy(ctx context.Context) bool {
+	url := fmt.Sprintf("http://127.0.0.1:%d/health", r.instance.Port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Deregister revokes the lease, which deletes the instance key
+func (r *etcdRegistrar) Deregister(ctx context.Context) error {
+	leaseID := r.lease()
+	if leaseID == "" {
+		return nil
+	}
+	if err := doJSON(ctx, r.client, http.MethodPost, r.endpoint+"/v3/lease/revoke", nil, map[string]interface{}{"ID": leaseID}, nil); err != nil {
+		return err
+	}
+	log.Printf("Deregistered %s from etcd", r.key())
+	return nil
+}
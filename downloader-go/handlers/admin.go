@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tiktok-downloader/cache"
+	"tiktok-downloader/logging"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logLevelRequest changes the log level and/or enables payload dumping for a post
+type logLevelRequest struct {
+	Level       string `json:"level"`
+	DumpAwemeID string `json:"dump_aweme_id"`
+	DumpMinutes int    `json:"dump_minutes"`
+}
+
+// GetLogLevelHandler reports the active log level and payload dumps
+func (h *HandlerContext) GetLogLevelHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"level":         logging.Level(),
+		"payload_dumps": logging.PayloadDumps(),
+	})
+}
+
+// SetLogLevelHandler changes the log level at runtime and optionally enables
+// verbose upstream payload dumping for a single aweme_id
+func (h *HandlerContext) SetLogLevelHandler(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if req.Level == "" && req.DumpAwemeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide level and/or dump_aweme_id"})
+		return
+	}
+
+	if req.Level != "" {
+		if err := logging.SetLevel(req.Level); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logging.Infof("Log level changed to %s", logging.Level())
+	}
+
+	if req.DumpAwemeID != "" {
+		minutes := req.DumpMinutes
+		if minutes <= 0 || minutes > 60 {
+			minutes = 15
+		}
+		until := logging.EnablePayloadDump(req.DumpAwemeID, time.Duration(minutes)*time.Minute)
+		logging.Infof("Upstream payload dumping enabled for %s until %s", req.DumpAwemeID, until.Format(time.RFC3339))
+	}
+
+	h.GetLogLevelHandler(c)
+}
+
+// DiagnosticsHandler returns a captured upstream payload by reference ID
+func (h *HandlerContext) DiagnosticsHandler(c *gin.Context) {
+	path, ok := utils.DiagnosticsPath(h.Config.DiagnosticsDir, c.Param("ref"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Diagnostics record not found"})
+		return
+	}
+	c.File(path)
+}
+
+// cookiesRequest replaces the Douyin cookies with a Cookie header style string
+type cookiesRequest struct {
+	Cookies string `json:"cookies" binding:"required"`
+}
+
+// GetCookiesHandler reports which Douyin cookies are set, without their values
+func (h *HandlerContext) GetCookiesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Cookies.Status())
+}
+
+// SetCookiesHandler replaces the Douyin cookies and persists them to the jar file
+func (h *HandlerContext) SetCookiesHandler(c *gin.Context) {
+	var req cookiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.Cookies.Set(req.Cookies, "admin"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logging.Infof("Douyin cookies updated via admin API")
+
+	h.GetCookiesHandler(c)
+}
+
+// RefreshCookiesHandler fetches fresh Douyin cookies from the login helper now
+func (h *HandlerContext) RefreshCookiesHandler(c *gin.Context) {
+	if err := h.Cookies.Refresh(c.Request.Context()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Cookie refresh failed: " + err.Error()})
+		return
+	}
+
+	h.GetCookiesHandler(c)
+}
+
+// CacheStatsHandler reports which response cache is active and, for the
+// in-memory cache, its size and hit/miss counters
+func (h *HandlerContext) CacheStatsHandler(c *gin.Context) {
+	switch responseCache := h.ResponseCache.(type) {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"backend": "none"})
+	case *cache.LRU:
+		c.JSON(http.StatusOK, gin.H{
+			"backend": "memory",
+			"ttl":     h.Config.MetadataCacheTTL.String(),
+			"stats":   responseCache.Stats(),
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{"backend": "redis", "ttl": h.Config.MetadataCacheTTL.String()})
+	}
+}
+
+// CleanupPreviewHandler handles GET /admin/cleanup/preview: a dry run of the
+// next temp cleanup pass, listing the folders it would quarantine, delete or
+// purge with their ages, sizes and owners, so retention can be tuned safely
+func (h *HandlerContext) CleanupPreviewHandler(c *gin.Context) {
+	preview := utils.PreviewCleanup(h.Config.TempDir, h.Config.TempQuarantineRetention)
+	c.JSON(http.StatusOK, gin.H{
+		"temp_dir":             h.Config.TempDir,
+		"quarantine_retention": h.Config.TempQuarantineRetention.String(),
+		"next_run":             preview.NextRun.Format(time.RFC3339),
+		"candidates":           preview.Candidates,
+		"reclaimable_bytes":    preview.DeleteBytes,
+	})
+}
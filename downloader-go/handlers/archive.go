@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/logging"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Archive naming modes
+const (
+	NamingDefault = "default"
+	NamingYtDlp   = "ytdlp"
+)
+
+// ytdlpArchiveFile is the yt-dlp style download archive kept in the archive directory
+const ytdlpArchiveFile = "archive.txt"
+
+// archiveJobsDir holds the persisted state of archive jobs inside the archive directory
+const archiveJobsDir = ".jobs"
+
+// archiveItemTimeout bounds fetching and saving a single archived post
+const archiveItemTimeout = 10 * time.Minute
+
+// Archive item states
+const (
+	ItemPending = "pending"
+	ItemDone    = "done"
+	ItemFailed  = "failed"
+	ItemSkipped = "skipped"
+)
+
+// archiveJobState is the persisted, per-item progress of an archive job
+type archiveJobState struct {
+	JobID     string               `json:"job_id"`
+	Naming    string               `json:"naming"`
+	Finished  bool                 `json:"finished"`
+	Items     []models.ArchiveItem `json:"items"`
+	Client    string               `json:"client,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// archiveFileMutex serializes writes to the download archive file
+var archiveFileMutex sync.Mutex
+
+// ArchiveHandler queues a background job that saves posts to the archive
+// directory. It writes to the server's disk, so like backfills it is
+// reserved to the admin.
+func (h *HandlerContext) ArchiveHandler(c *gin.Context) {
+	var req models.ArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if len(req.URLs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one URL is required"})
+		return
+	}
+	if maxURLs := h.Config.ArchiveMaxURLs; maxURLs > 0 && len(req.URLs) > maxURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d URLs can be archived per request", maxURLs)})
+		return
+	}
+
+	naming, ok := h.namingMode(req.Naming)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid naming mode, expected 'default' or 'ytdlp'"})
+		return
+	}
+
+	job := h.Jobs.Create("archive", h.clientFingerprint(c))
+	state := &archiveJobState{JobID: job.ID, Naming: naming, Client: job.Client, CreatedAt: job.CreatedAt}
+	for _, sourceURL := range req.URLs {
+		state.Items = append(state.Items, models.ArchiveItem{URL: sourceURL, State: ItemPending})
+	}
+	if err := h.saveArchiveJobState(state); err != nil {
+		log.Printf("Archive job %s: error saving job state: %v", job.ID, err)
+	}
+	h.submitJob(job.ID, func() { h.runArchiveJob(state) })
+
+	h.acceptJob(c, job.ID)
+}
+
+// JobStatusHandler returns the current state of a background job
+func (h *HandlerContext) JobStatusHandler(c *gin.Context) {
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if job.Status == jobs.StatusQueued {
+		h.fillQueueDetails(&job)
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// submitJob queues fn as the work of a job. A panic in fn marks the job
+// failed with the reference of the logged stack trace instead of crashing
+// the process.
+func (h *HandlerContext) submitJob(jobID string, fn func()) {
+	h.JobQueue.Submit(jobID, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ref := logging.Panic(r)
+				h.Jobs.Update(jobID, func(job *jobs.Job) {
+					job.Status = jobs.StatusFailed
+					job.Error = fmt.Sprintf("internal error (reference %s)", ref)
+					job.Result["panic_reference"] = ref
+				})
+				h.Jobs.Event(jobID, jobs.EventFailed, "job panicked, see log reference %s", ref)
+			}
+		}()
+		fn()
+	})
+}
+
+// acceptJob replies 202 with the job's status URL and queue details
+func (h *HandlerContext) acceptJob(c *gin.Context, jobID string) {
+	job, _ := h.Jobs.Get(jobID)
+	h.fillQueueDetails(&job)
+
+	body := gin.H{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": fmt.Sprintf("%s/jobs/%s", h.Config.BaseURL, job.ID),
+	}
+	if job.QueuePosition > 0 {
+		body["queue_position"] = job.QueuePosition
+	}
+	if job.ETASeconds != nil {
+		body["eta_seconds"] = *job.ETASeconds
+	}
+	c.JSON(http.StatusAccepted, body)
+}
+
+// fillQueueDetails sets the queue position and ETA of a job still waiting for a worker
+func (h *HandlerContext) fillQueueDetails(job *jobs.Job) {
+	position, waiting := h.JobQueue.Position(job.ID)
+	if !waiting {
+		return
+	}
+	job.QueuePosition = position
+	if eta, ok := h.JobQueue.ETA(position); ok {
+		seconds := math.Ceil(eta.Seconds())
+		job.ETASeconds = &seconds
+	}
+}
+
+// JobEventsHandler returns the event timeline of a background job
+func (h *HandlerContext) JobEventsHandler(c *gin.Context) {
+	events, ok := h.Jobs.Events(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job_id": c.Param("id"), "events": events})
+}
+
+// namingMode resolves a requested naming mode, falling back to the configured default
+func (h *HandlerContext) namingMode(requested string) (string, bool) {
+	naming := requested
+	if naming == "" {
+		naming = h.Config.ArchiveNaming
+	}
+	return naming, naming == NamingDefault || naming == NamingYtDlp
+}
+
+// runArchiveJob archives each pending item in turn, persisting per-item
+// state so the job can resume after a restart
+func (h *HandlerContext) runArchiveJob(state *archiveJobState) {
+	jobID := state.JobID
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusProcessing
+		job.Result["items"] = append([]models.ArchiveItem(nil), state.Items...)
+	})
+	h.Jobs.Event(jobID, jobs.EventStarted, "%d items", len(state.Items))
+
+	if err := os.MkdirAll(h.Config.ArchiveDir, os.ModePerm); err != nil {
+		h.Jobs.Update(jobID, func(job *jobs.Job) {
+			job.Status = jobs.StatusFailed
+			job.Error = "Error creating archive directory: " + err.Error()
+		})
+		h.Jobs.Event(jobID, jobs.EventFailed, "%v", err)
+		return
+	}
+
+	for i := range state.Items {
+		if state.Items[i].State != ItemPending {
+			continue
+		}
+
+		sourceURL := state.Items[i].URL
+		h.Jobs.Event(jobID, jobs.EventFetchStarted, "item %d: %s", i, sourceURL)
+		item := h.archivePost(context.Background(), sourceURL, state.Naming)
+		switch {
+		case item.Error != "":
+			item.State = ItemFailed
+			log.Printf("Archive job %s: failed to archive %s: %s", jobID, sourceURL, item.Error)
+			h.Jobs.Event(jobID, jobs.EventItemFailed, "item %d: %s", i, item.Error)
+		case item.Skipped:
+			item.State = ItemSkipped
+			h.Jobs.Event(jobID, jobs.EventItemDone, "item %d: already in download archive", i)
+		default:
+			item.State = ItemDone
+			h.Jobs.Event(jobID, jobs.EventItemDone, "item %d: saved %d files", i, len(item.Files))
+		}
+		state.Items[i] = item
+
+		if err := h.saveArchiveJobState(state); err != nil {
+			log.Printf("Archive job %s: error saving job state: %v", jobID, err)
+		}
+		h.Jobs.Update(jobID, func(job *jobs.Job) {
+			job.Result["items"] = append([]models.ArchiveItem(nil), state.Items...)
+		})
+	}
+
+	failed := 0
+	for _, item := range state.Items {
+		if item.State == ItemFailed {
+			failed++
+		}
+	}
+
+	state.Finished = true
+	if err := h.saveArchiveJobState(state); err != nil {
+		log.Printf("Archive job %s: error saving job state: %v", jobID, err)
+	}
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusCompleted
+		if failed == len(state.Items) {
+			job.Status = jobs.StatusFailed
+			job.Error = "All items failed to archive"
+		}
+		job.Result["naming"] = state.Naming
+		job.Result["failed"] = failed
+	})
+	if failed == len(state.Items) {
+		h.Jobs.Event(jobID, jobs.EventFailed, "all %d items failed", failed)
+	} else {
+		h.Jobs.Event(jobID, jobs.EventCompleted, "%d of %d items failed", failed, len(state.Items))
+	}
+}
+
+// ResumeArchiveJobs restores unfinished archive jobs from their persisted
+// state and continues them from the first pending item
+func (h *HandlerContext) ResumeArchiveJobs() {
+	paths, err := filepath.Glob(filepath.Join(h.Config.ArchiveDir, archiveJobsDir, "*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		state := &archiveJobState{}
+		if err := json.Unmarshal(raw, state); err != nil || state.JobID == "" {
+			log.Printf("Skipping unreadable archive job state %s: %v", path, err)
+			continue
+		}
+		if state.Finished {
+			continue
+		}
+
+		h.Jobs.Restore(jobs.Job{
+			ID:        state.JobID,
+			Type:      "archive",
+			Status:    jobs.StatusQueued,
+			Client:    state.Client,
+			CreatedAt: state.CreatedAt,
+			UpdatedAt: time.Now(),
+		})
+		log.Printf("Resuming archive job %s", state.JobID)
+		h.submitJob(state.JobID, func() { h.runArchiveJob(state) })
+	}
+}
+
+// saveArchiveJobState atomically writes an archive job's per-item state
+func (h *HandlerContext) saveArchiveJobState(state *archiveJobState) error {
+	dir := filepath.Join(h.Config.ArchiveDir, archiveJobsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, state.JobID+".json")
+	if err := os.WriteFile(path+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// archivePost downloads the media and metadata of a single post into the archive directory
+func (h *HandlerContext) archivePost(ctx context.Context, sourceURL, naming string) models.ArchiveItem {
+	item := models.ArchiveItem{URL: sourceURL}
+
+	// Bound each post so one stalled download can't hold up the whole job
+	ctx, cancel := context.WithTimeout(ctx, archiveItemTimeout)
+	defer cancel()
+
+	data, err := utils.FetchPostData(ctx, h.Config, sourceURL)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	utils.CompleteVideoData(ctx, h.Config, sourceURL, data)
+
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		item.Error = "Invalid data format"
+		return item
+	}
+
+	id := utils.GetAwemeID(videoData)
+	platform := fmt.Sprintf("%v", videoData["platform"])
+	item.ID = id
+
+	// The ID names the files on disk, so refuse anything that isn't a single path element
+	if err := utils.SafePathComponent(id); err != nil {
+		item.Error = "Invalid post ID: " + err.Error()
+		return item
+	}
+
+	archiveID := utils.YtDlpArchiveID(platform, id)
+	if naming == NamingYtDlp && inDownloadArchive(h.Config.ArchiveDir, archiveID) {
+		item.Skipped = true
+		return item
+	}
+
+	// Collect the media to save
+	var mediaURLs []string
+	ext := "mp4"
+	if typeVal, _ := videoData["type"].(string); typeVal == "image" {
+		mediaURLs = utils.GetImageURLs(videoData)
+		ext = "jpg"
+	} else if videoURLs, ok := videoData["video_data"].(map[string]interface{}); ok {
+		for _, key := range []string{"nwm_video_url_HQ", "nwm_video_url"} {
+			if urlVal, ok := videoURLs[key].(string); ok && urlVal != "" {
+				mediaURLs = append(mediaURLs, urlVal)
+				break
+			}
+		}
+	}
+
+	if len(mediaURLs) == 0 {
+		item.Error = "No downloadable media found"
+		return item
+	}
+
+	title := utils.YtDlpTitle(videoData, id)
+	for i, mediaURL := range mediaURLs {
+		filename := archiveFilename(naming, title, id, ext, i, len(mediaURLs))
+		if err := utils.DownloadFile(ctx, mediaURL, filepath.Join(h.Config.ArchiveDir, filename)); err != nil {
+			item.Error = fmt.Sprintf("Error downloading %s: %v", filename, err)
+			return item
+		}
+		item.Files = append(item.Files, filename)
+	}
+
+	// Write the metadata next to the media
+	var infoName string
+	var info interface{}
+	if naming == NamingYtDlp {
+		infoName = strings.TrimSuffix(utils.YtDlpFilename(title, id, ext), "."+ext) + ".info.json"
+		info = utils.BuildYtDlpInfo(videoData, sourceURL, mediaURLs[0], ext, item.Files[0])
+	} else {
+		infoName = id + ".json"
+		info = videoData
+	}
+
+	infoJSON, err := json.MarshalIndent(info, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(h.Config.ArchiveDir, infoName), infoJSON, 0644)
+	}
+	if err != nil {
+		item.Error = "Error writing metadata: " + err.Error()
+		return item
+	}
+	item.Files = append(item.Files, infoName)
+
+	if naming == NamingYtDlp {
+		if err := recordDownloadArchive(h.Config.ArchiveDir, archiveID); err != nil {
+			log.Printf("Error updating download archive: %v", err)
+		}
+	}
+
+	return item
+}
+
+// archiveFilename returns the on-disk name of a media file for the naming mode
+func archiveFilename(naming, title, id, ext string, index, total int) string {
+	if total > 1 {
+		ext = fmt.Sprintf("%d.%s", index+1, ext)
+	}
+	if naming == NamingYtDlp {
+		return utils.YtDlpFilename(title, id, ext)
+	}
+	return fmt.Sprintf("%s.%s", id, ext)
+}
+
+// inDownloadArchive reports whether a post is already listed in the download archive
+func inDownloadArchive(dir, archiveID string) bool {
+	archiveFileMutex.Lock()
+	defer archiveFileMutex.Unlock()
+
+	file, err := os.Open(filepath.Join(dir, ytdlpArchiveFile))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == archiveID {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDownloadArchive appends a post to the download archive
+func recordDownloadArchive(dir, archiveID string) error {
+	archiveFileMutex.Lock()
+	defer archiveFileMutex.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(dir, ytdlpArchiveFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, archiveID)
+	return err
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// asyncTikTokTimeout bounds resolving a post in the background
+const asyncTikTokTimeout = 2 * time.Minute
+
+// tiktokWebhook is the body POSTed to an async request's callback_url
+type tiktokWebhook struct {
+	JobID      string      `json:"job_id"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Response   interface{} `json:"response"`
+}
+
+// acceptAsyncTikTok queues a /tiktok request and answers 202 with the job ID
+func (h *HandlerContext) acceptAsyncTikTok(c *gin.Context, req models.TikTokRequest, opts tiktokOptions) {
+	callbackURL := req.CallbackURL
+	if callbackURL == "" {
+		callbackURL = c.Query("callback_url")
+	}
+	if callbackURL != "" {
+		if err := utils.ValidateCallbackURL(callbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job := h.Jobs.Create("tiktok", opts.Client)
+	h.submitJob(job.ID, func() { h.runAsyncTikTok(job.ID, req.URL, callbackURL, opts) })
+
+	h.acceptJob(c, job.ID)
+}
+
+// runAsyncTikTok resolves a post, stores the response on the job and
+// delivers it to the callback URL
+func (h *HandlerContext) runAsyncTikTok(jobID, sourceURL, callbackURL string, opts tiktokOptions) {
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusProcessing
+		job.Result["url"] = sourceURL
+	})
+	h.Jobs.Event(jobID, jobs.EventFetchStarted, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), asyncTikTokTimeout)
+	status, body := h.resolveTikTok(ctx, sourceURL, opts)
+	cancel()
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Result["status_code"] = status
+		job.Result["response"] = body
+	})
+
+	if callbackURL != "" {
+		h.Jobs.Event(jobID, "callback_started", "")
+		ctx, cancel := context.WithTimeout(context.Background(), asyncTikTokTimeout)
+		err := utils.PostWebhook(ctx, callbackURL, tiktokWebhook{
+			JobID:      jobID,
+			URL:        sourceURL,
+			StatusCode: status,
+			Response:   body,
+		}, h.Config.WebhookSecret, h.Config.WebhookAllowPrivate)
+		cancel()
+
+		if err != nil {
+			h.Jobs.Update(jobID, func(job *jobs.Job) {
+				job.Status = jobs.StatusFailed
+				job.Error = "callback delivery failed: " + err.Error()
+			})
+			h.Jobs.Event(jobID, jobs.EventFailed, "callback delivery failed: %v", err)
+			return
+		}
+		h.Jobs.Event(jobID, "callback_delivered", "")
+	}
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusCompleted
+		if status != http.StatusOK {
+			job.Status = jobs.StatusFailed
+			if errBody, ok := body.(gin.H); ok {
+				job.Error, _ = errBody["error"].(string)
+			}
+		}
+	})
+	if status != http.StatusOK {
+		h.Jobs.Event(jobID, jobs.EventFailed, "resolution failed with status %d", status)
+		return
+	}
+	h.Jobs.Event(jobID, jobs.EventCompleted, "")
+}
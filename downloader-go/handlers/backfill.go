@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backfillDir holds backfill checkpoints inside the archive directory
+const backfillDir = ".backfill"
+
+// runningBackfills tracks manifests currently being processed, so the same
+// source is never backfilled twice at once
+var runningBackfills sync.Map
+
+// BackfillCheckpoint records how far a backfill has progressed through its manifest
+type BackfillCheckpoint struct {
+	Source    string    `json:"source"`
+	Naming    string    `json:"naming"`
+	Line      int       `json:"line"`
+	Processed int       `json:"processed"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BackfillHandler starts, or resumes, a backfill of a file or S3 manifest
+func (h *HandlerContext) BackfillHandler(c *gin.Context) {
+	var req models.BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	naming, ok := h.namingMode(req.Naming)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid naming mode, expected 'default' or 'ytdlp'"})
+		return
+	}
+
+	if _, running := runningBackfills.Load(req.Source); running {
+		c.JSON(http.StatusConflict, gin.H{"error": "A backfill of this source is already running"})
+		return
+	}
+
+	job := h.Jobs.Create("backfill", h.clientFingerprint(c))
+	h.submitJob(job.ID, func() { h.runBackfillJob(job.ID, req.Source, naming, req.Restart) })
+
+	h.acceptJob(c, job.ID)
+}
+
+// runBackfillJob runs a backfill and mirrors its checkpoint onto the job
+func (h *HandlerContext) runBackfillJob(jobID, source, naming string, restart bool) {
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusProcessing
+		job.Result["source"] = source
+	})
+	h.Jobs.Event(jobID, jobs.EventStarted, "manifest %s", source)
+
+	checkpoint, err := h.RunBackfill(context.Background(), source, naming, restart, func(cp BackfillCheckpoint) {
+		h.Jobs.Update(jobID, func(job *jobs.Job) {
+			job.Result["checkpoint"] = cp
+		})
+		h.Jobs.Event(jobID, "checkpoint", "line %d (archived %d, skipped %d, failed %d)", cp.Line, cp.Processed, cp.Skipped, cp.Failed)
+	})
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Result["checkpoint"] = checkpoint
+		job.Status = jobs.StatusCompleted
+		if err != nil {
+			job.Status = jobs.StatusFailed
+			job.Error = err.Error()
+		}
+	})
+	if err != nil {
+		h.Jobs.Event(jobID, jobs.EventFailed, "%v", err)
+	} else {
+		h.Jobs.Event(jobID, jobs.EventCompleted, "archived %d, skipped %d, failed %d", checkpoint.Processed, checkpoint.Skipped, checkpoint.Failed)
+	}
+}
+
+// RunBackfill archives every URL in a manifest, checkpointing after each item
+// so an interrupted backfill of the same source resumes where it stopped.
+// Blank lines and lines starting with # are ignored.
+func (h *HandlerContext) RunBackfill(ctx context.Context, source, naming string, restart bool, progress func(BackfillCheckpoint)) (BackfillCheckpoint, error) {
+	if _, running := runningBackfills.LoadOrStore(source, true); running {
+		return BackfillCheckpoint{Source: source}, fmt.Errorf("a backfill of %s is already running", source)
+	}
+	defer runningBackfills.Delete(source)
+
+	checkpointPath := h.backfillCheckpointPath(source)
+	checkpoint := BackfillCheckpoint{Source: source, Naming: naming}
+	if !restart {
+		if saved, err := loadBackfillCheckpoint(checkpointPath); err == nil {
+			checkpoint = saved
+			checkpoint.Naming = naming
+			if checkpoint.Done {
+				return checkpoint, nil
+			}
+			log.Printf("Resuming backfill of %s from line %d", source, checkpoint.Line)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(checkpointPath), os.ModePerm); err != nil {
+		return checkpoint, fmt.Errorf("error creating checkpoint directory: %w", err)
+	}
+
+	manifest, err := utils.OpenManifest(ctx, h.Config, source)
+	if err != nil {
+		return checkpoint, fmt.Errorf("error opening manifest: %w", err)
+	}
+	defer manifest.Close()
+
+	scanner := bufio.NewScanner(manifest)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line <= checkpoint.Line {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return checkpoint, err
+		}
+
+		sourceURL := strings.TrimSpace(scanner.Text())
+		if sourceURL != "" && !strings.HasPrefix(sourceURL, "#") {
+			item := h.archivePost(ctx, sourceURL, naming)
+			switch {
+			case item.Error != "":
+				checkpoint.Failed++
+				log.Printf("Backfill %s line %d: failed to archive %s: %s", source, line, sourceURL, item.Error)
+			case item.Skipped:
+				checkpoint.Skipped++
+			default:
+				checkpoint.Processed++
+			}
+		}
+
+		checkpoint.Line = line
+		if err := saveBackfillCheckpoint(checkpointPath, &checkpoint); err != nil {
+			return checkpoint, fmt.Errorf("error saving checkpoint: %w", err)
+		}
+		if progress != nil {
+			progress(checkpoint)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return checkpoint, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	checkpoint.Done = true
+	if err := saveBackfillCheckpoint(checkpointPath, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// backfillCheckpointPath returns the checkpoint file for a manifest source
+func (h *HandlerContext) backfillCheckpointPath(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return filepath.Join(h.Config.ArchiveDir, backfillDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadBackfillCheckpoint reads a saved checkpoint
+func loadBackfillCheckpoint(path string) (BackfillCheckpoint, error) {
+	var checkpoint BackfillCheckpoint
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(raw, &checkpoint)
+	return checkpoint, err
+}
+
+// saveBackfillCheckpoint atomically writes a checkpoint
+func saveBackfillCheckpoint(path string, checkpoint *BackfillCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now()
+	raw, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
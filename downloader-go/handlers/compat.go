@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"tiktok-downloader/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Compatibility modes: compatNode selects the response schema of the original
+// Node.js implementation, compatLegacy keeps download_link as a map keyed by
+// media key instead of the ordered picker
+const (
+	compatNode   = "node"
+	compatLegacy = "legacy"
+)
+
+// legacyResponse is the response with download_link rendered as a map
+type legacyResponse struct {
+	models.TikTokResponse
+	DownloadLink map[string]interface{} `json:"download_link"`
+}
+
+// nodeDownloadKeys lists the download_link keys the Node.js implementation emits
+var nodeDownloadKeys = []string{"watermark", "watermark_hd", "no_watermark", "no_watermark_hd", "mp3"}
+
+// nodeAuthor mirrors the Node.js author object, where every key is always present
+type nodeAuthor struct {
+	Nickname  string `json:"nickname"`
+	Signature string `json:"signature"`
+	Avatar    string `json:"avatar"`
+}
+
+// nodeResponse mirrors the /tiktok response of the Node.js implementation,
+// keeping its key order and emitting empty strings instead of omitting keys
+type nodeResponse struct {
+	Status            string                 `json:"status"`
+	Photos            []models.PhotoItem     `json:"photos"`
+	Title             string                 `json:"title"`
+	Description       string                 `json:"description"`
+	Statistics        models.Statistics      `json:"statistics"`
+	Artist            string                 `json:"artist"`
+	Cover             string                 `json:"cover"`
+	Duration          int                    `json:"duration"`
+	Audio             string                 `json:"audio"`
+	DownloadLink      map[string]interface{} `json:"download_link"`
+	MusicDuration     int                    `json:"music_duration"`
+	Author            nodeAuthor             `json:"author"`
+	SlideshowDownLink string                 `json:"download_slideshow_link,omitempty"`
+}
+
+// compatMode returns the requested compatibility mode, from the compat query
+// parameter or, failing that, from the caller's API key
+func (h *HandlerContext) compatMode(c *gin.Context) (string, error) {
+	switch mode := c.Query("compat"); mode {
+	case "":
+		if matchAPIKey(c.GetHeader("X-API-Key"), h.Config.NodeCompatAPIKeys) {
+			return compatNode, nil
+		}
+		return "", nil
+	case compatNode, compatLegacy:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown compat mode %q", mode)
+	}
+}
+
+// nodeCompatResponse converts a response to the Node.js schema
+func nodeCompatResponse(response models.TikTokResponse) nodeResponse {
+	// Node.js falls back to the nickname when the post has no description
+	title := response.Title
+	if title == "" {
+		title = response.Author.Nickname
+	}
+	description := response.Description
+	if description == "" {
+		description = response.Author.Nickname
+	}
+
+	links := make(map[string]interface{})
+	for _, key := range nodeDownloadKeys {
+		if link, ok := response.DownloadLink[key]; ok {
+			links[key] = link
+		}
+	}
+
+	photos := response.Photos
+	if photos == nil {
+		photos = []models.PhotoItem{}
+	}
+
+	return nodeResponse{
+		Status:        response.Status,
+		Photos:        photos,
+		Title:         title,
+		Description:   description,
+		Statistics:    response.Statistics,
+		Artist:        response.Artist,
+		Cover:         response.Cover,
+		Duration:      response.Duration,
+		Audio:         response.Audio,
+		DownloadLink:  links,
+		MusicDuration: response.MusicDuration,
+		Author: nodeAuthor{
+			Nickname:  response.Author.Nickname,
+			Signature: response.Author.Signature,
+			Avatar:    response.Author.Avatar,
+		},
+		SlideshowDownLink: response.SlideshowDownLink,
+	}
+}
+
+// matchAPIKey reports whether key is one of candidates, in constant time per candidate
+func matchAPIKey(key string, candidates []string) bool {
+	if key == "" {
+		return false
+	}
+	matched := false
+	for _, candidate := range candidates {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
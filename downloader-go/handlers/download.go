@@ -2,42 +2,91 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"tiktok-downloader/logging"
 	"tiktok-downloader/models"
 	"tiktok-downloader/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Per-stage deadlines of a render: fetching the source media, then running
+// ffmpeg once a render worker is free. Both also end with the request.
+const (
+	mediaFetchTimeout = 2 * time.Minute
+	renderTimeout     = 5 * time.Minute
+)
+
 // DownloadHandler handles file download requests
 func (h *HandlerContext) DownloadHandler(c *gin.Context) {
-	data := c.Query("data")
-	if data == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data parameter is required"})
-		return
+	var downloadData models.DownloadData
+	if c.Query("sig") != "" {
+		// Signed link: the data travels in the clear next to its signature
+		var err error
+		downloadData, err = utils.VerifySignedDownload(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Error verifying signature: " + err.Error()})
+			return
+		}
+	} else {
+		data := c.Query("data")
+		if data == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data parameter is required"})
+			return
+		}
+
+		// Decrypt the data
+		if err := utils.VerifyLinkJSON(data, &downloadData); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting data: " + err.Error()})
+			return
+		}
 	}
 
-	// Decrypt the data
-	var downloadData models.DownloadData
-	if err := utils.DecryptJSON(data, h.Config.EncryptionKey, &downloadData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting data: " + err.Error()})
+	h.serveDownload(c, downloadData)
+}
+
+// ShortDownloadHandler handles GET /d/:code, the short download links whose
+// data is kept server-side, as long query strings get truncated by some
+// messengers
+func (h *HandlerContext) ShortDownloadHandler(c *gin.Context) {
+	downloadData, err := utils.ResolveShortLink(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	h.serveDownload(c, downloadData)
+}
 
-	if downloadData.URL == "" || downloadData.Author == "" || downloadData.Type == "" {
+// serveDownload streams the media of a verified download link
+func (h *HandlerContext) serveDownload(c *gin.Context, downloadData models.DownloadData) {
+	if downloadData.URL == "" || (downloadData.Author == "" && downloadData.Filename == "") || downloadData.Type == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid decrypted data: missing url, author, or type"})
 		return
 	}
 
+	// Embed tokens live longer than DOWNLOAD_LINK_MAX_TTL allows downloads to
+	if downloadData.Audience != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a download link"})
+		return
+	}
+
+	// Links issued before audio downloads were switched off stop working too
+	if downloadData.Type == "mp3" && h.Config.DisableMP3 {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "MP3 downloads are disabled on this server"})
+		return
+	}
+
 	// Determine content type and file extension
 	contentType, fileExtension, ok := h.Config.ContentType(downloadData.Type)
 	if !ok {
@@ -45,76 +94,253 @@ func (h *HandlerContext) DownloadHandler(c *gin.Context) {
 		return
 	}
 
-	// Configure the filename
-	filename := fmt.Sprintf("%s.%s", downloadData.Author, fileExtension)
-	encodedFilename := url.QueryEscape(filename)
+	// Configure the filename: the nickname as-is, with an ASCII fallback that
+	// older links don't carry and get transliterated instead
+	displayName, asciiName := downloadData.Author, downloadData.Filename
+	if displayName == "" {
+		displayName = asciiName
+	}
+	if asciiName == "" {
+		asciiName = utils.FilenameBase(displayName, "", "")
+	}
+	filename := fmt.Sprintf("%s.%s", displayName, fileExtension)
+	encodedFilename := utils.EncodeRFC5987(filename)
+
+	// MP3s of posts without a music URL are extracted from the video
+	if downloadData.Key == utils.ExtractedAudioKey {
+		c.Header("Content-Disposition", utils.ContentDisposition(filename, fmt.Sprintf("%s.%s", asciiName, fileExtension)))
+		c.Header("x-filename", encodedFilename)
+		h.serveExtractedAudio(c, downloadData)
+		return
+	}
 
-	// Stream the file from source to client
-	httpClient := &http.Client{Timeout: 60 * time.Second}
-	resp, err := httpClient.Get(downloadData.URL)
+	// Best-effort no-watermark videos are processed from the watermarked one
+	if downloadData.Key == utils.ProcessedNoWatermarkKey {
+		c.Header("Content-Disposition", utils.ContentDisposition(filename, fmt.Sprintf("%s.%s", asciiName, fileExtension)))
+		c.Header("x-filename", encodedFilename)
+		h.serveWatermarkRemoved(c, downloadData)
+		return
+	}
+
+	// Stream the file from source to client, passing any Range request
+	// through so interrupted downloads can resume. Attributed images are
+	// rewritten whole, so they are always fetched in full.
+	attribute := h.attributeImage(c, downloadData)
+	rangeHeader := c.Request.Header
+	if attribute {
+		rangeHeader = http.Header{}
+	}
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, downloadData.URL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request: " + err.Error()})
+		return
+	}
+	forwardRangeHeaders(req, rangeHeader)
+	resp, err := utils.OpenSource(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download from source: " + err.Error()})
 		return
 	}
-	defer resp.Body.Close()
+	defer func() { resp.Body.Close() }()
+
+	// Geo-fenced CDN URLs return 403; re-resolve through the alternate region
+	if resp.StatusCode == http.StatusForbidden && h.regionRetryEnabled() {
+		if retryResp, err := h.regionRetry(c.Request.Context(), downloadData, rangeHeader); err != nil {
+			log.Printf("Region retry failed for %s: %v", downloadData.Source, err)
+		} else {
+			resp.Body.Close()
+			resp = retryResp
+		}
+	}
 
-	if resp.StatusCode != http.StatusOK {
+	if !relayableStatus(resp.StatusCode) {
 		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Source returned error: %d", resp.StatusCode)})
 		return
 	}
 
 	// Set headers
 	c.Header("Content-Type", contentType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", encodedFilename, encodedFilename))
+	c.Header("Content-Disposition", utils.ContentDisposition(filename, fmt.Sprintf("%s.%s", asciiName, fileExtension)))
 	c.Header("x-filename", encodedFilename)
+	if !attribute {
+		copyRangeHeaders(c.Writer.Header(), resp.Header)
+	}
 
-	// Stream the file to the client
-	c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, resp.Body, nil)
+	// Clients poll /download-progress/<token> while long videos transfer
+	token := progressToken(c)
+	c.Header("X-Progress-Token", token)
+	progress := trackProgress(token, resp.ContentLength)
+	defer progress.finish(token)
+
+	// Account the streamed bytes to the tenant the link was issued to; a
+	// player's Range requests count as one download, not one each
+	body := &countingReader{Reader: progress.Reader(resp.Body)}
+	download := startsDownload(resp)
+	defer func() {
+		if download {
+			h.accountDownload(downloadData.Tenant, body.n)
+		} else {
+			h.accountBytes(downloadData.Tenant, body.n)
+		}
+	}()
+
+	// Partial and unsatisfiable responses are relayed as-is and never cached
+	if resp.StatusCode != http.StatusOK {
+		c.DataFromReader(resp.StatusCode, resp.ContentLength, contentType, body, nil)
+		return
+	}
+
+	// Stream the file to the client, keeping a copy for the media cache; the
+	// cache holds the source bytes, without attribution
+	tee := h.newMediaCacheTee(downloadData, resp.ContentLength)
+	if attribute && resp.ContentLength > 0 && resp.ContentLength <= maxAttributedImageBytes {
+		h.serveAttributedImage(c, downloadData, contentType, tee.Reader(body))
+	} else {
+		c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, tee.Reader(body), nil)
+	}
+	h.finishMediaCacheTee(tee, contentType, encodedFilename)
 }
 
-// DownloadSlideshowHandler handles slideshow download requests
-func (h *HandlerContext) DownloadSlideshowHandler(c *gin.Context) {
+// slideshowRequest is a validated /download-slideshow request
+type slideshowRequest struct {
+	SourceURL string
+	Options   utils.SlideshowOptions
+	Dedupe    bool
+	// MultiAspect renders every utils.SlideshowAspects frame in one run
+	MultiAspect bool
+	// Intro opens the slideshow with a frame showing the author and post stats
+	Intro bool
+	// Watermark composites the configured branding onto every frame
+	Watermark bool
+	Priority  int
+	Client    string
+	Tenant    string
+}
+
+// renderedSlideshow is a finished slideshow in its temp directory, with the
+// response headers describing it
+type renderedSlideshow struct {
+	TempDir    string
+	OutputPath string
+	Filename   string
+	Headers    map[string]string
+	// Outputs maps each aspect of a multi-aspect render to its download link
+	Outputs map[string]string
+	// URL is the storage link of the MP4 once offloaded, and StoredBytes
+	// the size of the offloaded outputs; see offloadSlideshow
+	URL         string
+	StoredBytes int64
+}
+
+// parseSlideshowRequest reads the encoder options and the signed post URL of
+// a slideshow request, returning an error status and body when invalid
+func (h *HandlerContext) parseSlideshowRequest(c *gin.Context) (slideshowRequest, int, gin.H) {
 	urlParam := c.Query("url")
 	if urlParam == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
-		return
+		return slideshowRequest{}, http.StatusBadRequest, gin.H{"error": "URL parameter is required"}
 	}
 
-	// Decrypt the URL
-	decryptedURL, err := utils.Decrypt(urlParam, h.Config.EncryptionKey)
+	// Resolve encoder options before doing any work
+	slideshowOpts, err := h.slideshowOptions(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting URL: " + err.Error()})
-		return
+		return slideshowRequest{}, http.StatusBadRequest, gin.H{"error": "Invalid slideshow options: " + err.Error()}
 	}
 
-	// Fetch data from the hybrid API
-	apiURL := fmt.Sprintf("%s?url=%s&minimal=true", h.Config.HybridAPIURL, url.QueryEscape(decryptedURL))
-	httpClient := &http.Client{Timeout: 30 * time.Second}
+	// aspects=all renders vertical, square and horizontal copies at once
+	multiAspect := false
+	if aspects := c.Query("aspects"); aspects != "" {
+		if aspects != "all" {
+			return slideshowRequest{}, http.StatusBadRequest, gin.H{"error": `Invalid slideshow options: aspects must be "all"`}
+		}
+		if slideshowOpts.MaxSizeBytes > 0 || slideshowOpts.TargetBitrateKbps > 0 {
+			return slideshowRequest{}, http.StatusBadRequest, gin.H{"error": "Invalid slideshow options: size budgets can't be combined with aspects=all"}
+		}
+		multiAspect = true
+	}
+
+	// ?watermark=true|false overrides SLIDESHOW_WATERMARK
+	watermark := h.Config.SlideshowWatermark
+	if watermarkParam := c.Query("watermark"); watermarkParam != "" {
+		watermark, err = strconv.ParseBool(watermarkParam)
+		if err != nil {
+			return slideshowRequest{}, http.StatusBadRequest, gin.H{"error": "Invalid slideshow options: watermark must be true or false"}
+		}
+	}
 
-	resp, err := httpClient.Get(apiURL)
+	// Decrypt the URL
+	decryptedURL, err := utils.VerifyLink(urlParam)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data: " + err.Error()})
+		return slideshowRequest{}, http.StatusInternalServerError, gin.H{"error": "Error decrypting URL: " + err.Error()}
+	}
+
+	return slideshowRequest{
+		SourceURL:   decryptedURL,
+		Options:     slideshowOpts,
+		Dedupe:      c.Query("dedupe") == "true",
+		MultiAspect: multiAspect,
+		Intro:       c.Query("intro") == "true",
+		Watermark:   watermark,
+		Priority:    h.renderPriority(c),
+		Client:      h.clientFingerprint(c),
+		Tenant:      verifyTenant(c.Query("tenant")),
+	}, http.StatusOK, nil
+}
+
+// DownloadSlideshowHandler handles slideshow download requests
+func (h *HandlerContext) DownloadSlideshowHandler(c *gin.Context) {
+	req, status, body := h.parseSlideshowRequest(c)
+	if body != nil {
+		c.JSON(status, body)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("External API returned error: %d", resp.StatusCode),
-		})
+	slideshow, status, body := h.renderSlideshow(c.Request.Context(), req)
+	if body != nil {
+		c.JSON(status, body)
 		return
 	}
+	for header, value := range slideshow.Headers {
+		c.Header(header, value)
+	}
+
+	// Offloaded renders are downloaded from storage, so they are accounted
+	// to the tenant as handed out; local ones when they are served
+	if slideshow.URL != "" {
+		h.accountDownload(req.Tenant, slideshow.StoredBytes)
+	}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error parsing response: " + err.Error()})
+	// Multi-aspect renders answer with their links; the files stay for the
+	// render's cleanup window
+	if slideshow.Outputs != nil {
+		c.JSON(http.StatusOK, gin.H{"outputs": slideshow.Outputs})
 		return
 	}
+	if slideshow.URL != "" {
+		c.Redirect(http.StatusFound, slideshow.URL)
+		return
+	}
+
+	// Set up quick cleanup after serving the file (5 minutes)
+	defer utils.ScheduleCleanup(slideshow.TempDir, 5*time.Minute)
+
+	// Return the file, accounting it to the tenant the link was issued to
+	h.serveLocalFile(c, slideshow.OutputPath, slideshow.Filename)
+	h.accountDownload(req.Tenant, h.servedBytes(c, slideshow.OutputPath))
+}
+
+// renderSlideshow downloads the images and audio of an image post and renders
+// them into an MP4, returning an error status and body on failure
+func (h *HandlerContext) renderSlideshow(ctx context.Context, req slideshowRequest) (*renderedSlideshow, int, gin.H) {
+	// Fetch data from the hybrid API
+	data, err := utils.FetchPostData(ctx, h.Config, req.SourceURL)
+	if err != nil {
+		status, body := upstreamErrorResponse(err)
+		return nil, status, body
+	}
 
 	videoData, ok := data["data"].(map[string]interface{})
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid data format"})
-		return
+		return nil, http.StatusInternalServerError, h.diagnosticBody(req.SourceURL, req.Client, data, "Invalid data format")
 	}
 
 	// Check if it's an image post
@@ -124,141 +350,306 @@ func (h *HandlerContext) DownloadSlideshowHandler(c *gin.Context) {
 	}
 
 	if !isImage {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only image posts are supported"})
-		return
-	}
-
-	// Create a unique temp directory
-	awemeID := fmt.Sprintf("%v", videoData["aweme_id"])
-	authorUID := "unknown"
-	if author, ok := videoData["author"].(map[string]interface{}); ok {
-		if uid, ok := author["uid"].(string); ok {
-			authorUID = uid
-		}
+		return nil, http.StatusBadRequest, gin.H{"error": "Only image posts are supported"}
 	}
 
-	folderName := fmt.Sprintf("%s_%s_%d", awemeID, authorUID, time.Now().UnixNano())
-	tempDir := filepath.Join(h.Config.TempDir, folderName)
-	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()})
-		return
+	// Create a unique temp directory, owned by the post being rendered
+	awemeID := utils.GetAwemeID(videoData)
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, "slideshow", awemeID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()}
 	}
 
-	// Track the temp directory
-	utils.TempFiles.Add(tempDir)
-
 	// Schedule cleanup in case of unexpected errors (1 hour)
 	utils.ScheduleCleanup(tempDir, time.Hour)
 
-	// Get image URLs
-	var imageURLs []string
-	if imageData, ok := videoData["image_data"].(map[string]interface{}); ok {
-		if nwImages, ok := imageData["no_watermark_image_list"].([]interface{}); ok {
-			for _, img := range nwImages {
-				if imgStr, ok := img.(string); ok {
-					imageURLs = append(imageURLs, imgStr)
-				}
-			}
-		}
+	// removeTempDir drops the temp directory of a failed render
+	removeTempDir := func() {
+		os.RemoveAll(tempDir)
+		utils.TempFiles.Delete(tempDir)
 	}
 
+	// Get image URLs
+	imageURLs := utils.GetImageURLs(videoData)
+
 	if len(imageURLs) == 0 {
 		// Clean up the directory since we won't use it
-		os.RemoveAll(tempDir)
-		utils.TempFiles.Delete(tempDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No images found"})
-		return
+		removeTempDir()
+		return nil, http.StatusInternalServerError, h.diagnosticBody(req.SourceURL, req.Client, data, "No images found")
 	}
 
-	// Download images concurrently
-	imagePaths := make([]string, len(imageURLs))
+	// Download images concurrently, validating each so corrupt files are
+	// re-fetched or skipped before ffmpeg sees them
+	fetchCtx, cancelFetch := context.WithTimeout(ctx, mediaFetchTimeout)
+	defer cancelFetch()
+	downloaded := make([]string, len(imageURLs))
+	imageErrs := make([]error, len(imageURLs))
 	var wg sync.WaitGroup
-	var downloadErr error
-	var errMutex sync.Mutex
 
 	for i, imageURL := range imageURLs {
 		wg.Add(1)
 		go func(idx int, url string) {
 			defer wg.Done()
-			
-			imagePath := filepath.Join(tempDir, fmt.Sprintf("image_%d.jpg", idx))
-			imagePaths[idx] = imagePath
-			
-			if err := utils.DownloadFile(url, imagePath); err != nil {
-				errMutex.Lock()
-				if downloadErr == nil { // only capture the first error
-					downloadErr = fmt.Errorf("error downloading image %d: %w", idx, err)
+			// A malformed image must not take the process down with it
+			defer func() {
+				if r := recover(); r != nil {
+					imageErrs[idx] = fmt.Errorf("internal error (reference %s)", logging.Panic(r))
 				}
-				errMutex.Unlock()
+			}()
+
+			// The extension is added once the real format is known
+			imagePath := filepath.Join(tempDir, fmt.Sprintf("image_%d", idx))
+			format, err := utils.DownloadImage(fetchCtx, url, imagePath)
+			if err == nil {
+				imagePath, err = utils.PrepareImage(ctx, imagePath, format)
+			}
+			if err != nil {
+				imageErrs[idx] = err
+				return
 			}
+			downloaded[idx] = imagePath
 		}(i, imageURL)
 	}
 
 	// Wait for all downloads to complete
 	wg.Wait()
 
-	// Check for download errors
-	if downloadErr != nil {
-		os.RemoveAll(tempDir)
-		utils.TempFiles.Delete(tempDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": downloadErr.Error()})
-		return
+	slideshow := &renderedSlideshow{
+		TempDir:    tempDir,
+		OutputPath: filepath.Join(tempDir, "slideshow.mp4"),
+		Headers:    make(map[string]string),
+	}
+
+	var imagePaths []string
+	var skipped []int
+	for idx, imagePath := range downloaded {
+		if imagePath == "" {
+			log.Printf("Skipping image %d of aweme %s: %v", idx, awemeID, imageErrs[idx])
+			skipped = append(skipped, idx)
+			continue
+		}
+		imagePaths = append(imagePaths, imagePath)
+	}
+
+	if len(imagePaths) == 0 {
+		removeTempDir()
+		return nil, http.StatusBadGateway, gin.H{"error": fmt.Sprintf("error downloading images: %v", imageErrs[0])}
+	}
+	if len(skipped) > 0 {
+		slideshow.Headers["X-Skipped-Indices"] = joinInts(skipped)
+	}
+
+	// Optionally drop near-identical frames before rendering
+	if req.Dedupe {
+		var removed []int
+		imagePaths, removed = utils.DedupeImages(imagePaths, utils.DefaultDedupeThreshold)
+		slideshow.Headers["X-Removed-Indices"] = joinInts(removed)
 	}
 
 	// Download audio
 	audioURL := ""
+	musicID := ""
 	if music, ok := videoData["music"].(map[string]interface{}); ok {
 		audioURL = utils.GetFirstFromNestedList(music, []string{"play_url", "url_list"}, "")
+		musicID = utils.GetMusicID(music)
 	}
 	
 	if audioURL == "" {
-		os.RemoveAll(tempDir)
-		utils.TempFiles.Delete(tempDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find audio URL"})
-		return
+		removeTempDir()
+		return nil, http.StatusInternalServerError, gin.H{"error": "Could not find audio URL"}
 	}
 
 	audioPath := filepath.Join(tempDir, "audio.mp3")
-	if err := utils.DownloadFile(audioURL, audioPath); err != nil {
-		os.RemoveAll(tempDir)
-		utils.TempFiles.Delete(tempDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error downloading audio: " + err.Error()})
-		return
+	if err := h.AudioCache.Fetch(fetchCtx, musicID, audioURL, audioPath); err != nil {
+		removeTempDir()
+		return nil, http.StatusInternalServerError, gin.H{"error": "Error downloading audio: " + err.Error()}
+	}
+
+	avatarPath := ""
+	if req.Intro {
+		avatarPath = fetchIntroAvatar(fetchCtx, videoData, tempDir)
+	}
+	if req.Watermark {
+		req.Options.Watermark = h.slideshowWatermark(videoData, tempDir)
 	}
 
-	// Create slideshow
-	outputPath := filepath.Join(tempDir, "slideshow.mp4")
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	// Create slideshow once a render worker is free
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
 	defer cancel()
 
-	if err := utils.CreateSlideshow(ctx, imagePaths, audioPath, outputPath); err != nil {
-		os.RemoveAll(tempDir)
-		utils.TempFiles.Delete(tempDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating slideshow: " + err.Error()})
-		return
+	// Multi-aspect outputs are named after the author, as they are
+	// downloaded straight from the asset route
+	var outputPaths []string
+	if req.MultiAspect {
+		base := renderFilenameBase(videoData)
+		for _, aspect := range utils.SlideshowAspects {
+			outputPaths = append(outputPaths, filepath.Join(tempDir, fmt.Sprintf("%s_%s.mp4", base, aspect.Name)))
+		}
+		slideshow.OutputPath = outputPaths[0]
+	}
+
+	var renderErr error
+	err = h.RenderQueue.Do(renderCtx, filepath.Base(tempDir), req.Priority, func() {
+		if req.Intro {
+			imagePaths = prependIntro(renderCtx, videoData, tempDir, avatarPath, imagePaths)
+		}
+		if req.MultiAspect {
+			renderErr = utils.CreateSlideshowAspects(renderCtx, imagePaths, audioPath, outputPaths, req.Options)
+			return
+		}
+		renderErr = utils.CreateSlideshow(renderCtx, imagePaths, audioPath, slideshow.OutputPath, req.Options)
+	})
+	if err == nil {
+		err = renderErr
+	}
+	if err != nil {
+		removeTempDir()
+		status := http.StatusInternalServerError
+		if errors.Is(err, utils.ErrSizeBudget) {
+			status = http.StatusUnprocessableEntity
+		}
+		return nil, status, gin.H{"error": "Error creating slideshow: " + err.Error()}
+	}
+
+	// Generate the poster and preview clip next to the MP4 so frontends can
+	// show a preview; they share the slideshow's cleanup window
+	for header, asset := range h.createSlideshowPreviews(renderCtx, tempDir, slideshow.OutputPath) {
+		slideshow.Headers[header] = asset
 	}
 
 	// Generate filename
-	authorNickname := "unknown"
-	if author, ok := videoData["author"].(map[string]interface{}); ok {
-		if nick, ok := author["nickname"].(string); ok {
-			authorNickname = nick
+	slideshow.Filename = fmt.Sprintf("%s_%d.mp4", renderFilenameBase(videoData), time.Now().Unix())
+
+	if req.MultiAspect {
+		tenant := ""
+		if token := signTenant(req.Tenant, h.defaultLinkTTL()); token != "" {
+			tenant = "?tenant=" + url.QueryEscape(token)
+		}
+		slideshow.Outputs = make(map[string]string, len(outputPaths))
+		for i, aspect := range utils.SlideshowAspects {
+			slideshow.Outputs[aspect.Name] = h.slideshowAssetURL(tempDir, filepath.Base(outputPaths[i])) + tenant
 		}
 	}
-	
-	// Sanitize filename
-	sanitized := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			return r
+
+	// Hand the files to remote storage when configured, so any instance can
+	// answer for them; they are served locally if the upload fails
+	if h.Storage != nil {
+		if err := h.offloadSlideshow(ctx, slideshow, outputPaths); err != nil {
+			log.Printf("Error offloading slideshow of aweme %s: %v", awemeID, err)
+		} else {
+			removeTempDir()
 		}
-		return '_'
-	}, authorNickname)
-	
-	filename := fmt.Sprintf("%s_%d.mp4", sanitized, time.Now().Unix())
+	}
 
-	// Set up quick cleanup after serving the file (5 minutes)
-	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+	return slideshow, http.StatusOK, nil
+}
+
+// diagnosticBody builds an error body, capturing the upstream payload under
+// a reference ID when diagnostics capture is enabled
+func (h *HandlerContext) diagnosticBody(sourceURL, client string, payload interface{}, message string) gin.H {
+	body := gin.H{"error": message}
+	if ref := utils.CaptureDiagnostics(h.Config.DiagnosticsDir, sourceURL, client, payload, errors.New(message)); ref != "" {
+		body["reference_id"] = ref
+	}
+	return body
+}
+
+// Slideshow preview asset names
+const (
+	slideshowPosterFile  = "poster.jpg"
+	slideshowPreviewFile = "preview.mp4"
+)
+
+// createSlideshowPreviews renders the poster and preview clip for a slideshow
+// and returns the response headers that link to them
+func (h *HandlerContext) createSlideshowPreviews(ctx context.Context, tempDir, videoPath string) map[string]string {
+	assets := make(map[string]string)
+	assetURL := func(name string) string {
+		return h.slideshowAssetURL(tempDir, name)
+	}
+
+	if err := utils.CreatePoster(ctx, videoPath, filepath.Join(tempDir, slideshowPosterFile)); err != nil {
+		log.Printf("Error creating slideshow poster: %v", err)
+	} else {
+		assets["X-Slideshow-Poster"] = assetURL(slideshowPosterFile)
+	}
+
+	if err := utils.CreatePreview(ctx, videoPath, filepath.Join(tempDir, slideshowPreviewFile), 2); err != nil {
+		log.Printf("Error creating slideshow preview: %v", err)
+	} else {
+		assets["X-Slideshow-Preview"] = assetURL(slideshowPreviewFile)
+	}
+
+	return assets
+}
+
+// slideshowAssetURL links to a file in a slideshow's temp directory
+func (h *HandlerContext) slideshowAssetURL(tempDir, name string) string {
+	return fmt.Sprintf("%s/slideshow-assets/%s/%s", h.Config.BaseURL, filepath.Base(tempDir), name)
+}
+
+// aspectOutput reports whether an asset name is a multi-aspect slideshow output
+func aspectOutput(name string) bool {
+	if utils.SafePathComponent(name) != nil {
+		return false
+	}
+	for _, aspect := range utils.SlideshowAspects {
+		if strings.HasSuffix(name, "_"+aspect.Name+".mp4") {
+			return true
+		}
+	}
+	return false
+}
+
+// SlideshowAssetHandler serves the poster, preview clip or multi-aspect
+// outputs of a rendered slideshow
+func (h *HandlerContext) SlideshowAssetHandler(c *gin.Context) {
+	folder := c.Param("id")
+	name := c.Param("file")
+	output := aspectOutput(name)
+	if name != slideshowPosterFile && name != slideshowPreviewFile && !output {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	// Only serve folders that are tracked temp directories
+	if err := utils.SafePathComponent(folder); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	tempDir := filepath.Join(h.Config.TempDir, folder)
+	if _, ok := utils.TempFiles.Get(tempDir); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found or expired"})
+		return
+	}
 
-	// Return the file
-	c.FileAttachment(outputPath, filename)
-}
\ No newline at end of file
+	assetPath := filepath.Join(tempDir, name)
+	if _, err := os.Stat(assetPath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found or expired"})
+		return
+	}
+
+	// Outputs are downloads, accounted to the tenant the render was for
+	if output {
+		h.serveLocalFile(c, assetPath, name)
+		h.accountDownload(verifyTenant(c.Query("tenant")), h.servedBytes(c, assetPath))
+		return
+	}
+	h.serveLocalFile(c, assetPath, "")
+}
+
+// renderFilenameBase names a file rendered from a post after its author,
+// transliterated to ASCII, or after the author UID and post ID
+func renderFilenameBase(videoData map[string]interface{}) string {
+	nickname, _ := utils.GetNestedValue(videoData, []string{"author", "nickname"}, "").(string)
+	return postDownloadName(videoData, nickname).ASCII
+}
+
+// joinInts formats a list of integers as a comma-separated string
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
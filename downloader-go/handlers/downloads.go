@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+
+	"tiktok-downloader/models"
+)
+
+// downloadKind describes how a download_link key is presented in the picker
+type downloadKind struct {
+	Key     string
+	Label   string
+	Quality string
+}
+
+// downloadKinds lists the picker entries in display order, best first.
+// mp3_original is left out since it always duplicates mp3; it remains
+// available under the legacy compat mode.
+var downloadKinds = []downloadKind{
+	{"no_watermark_hd", "No watermark (HD)", "hd"},
+	{"no_watermark", "No watermark", "sd"},
+	{"watermark_hd", "Watermark (HD)", "hd"},
+	{"watermark", "Watermark", "sd"},
+	{"no_watermark_processed", "No watermark (processed, best effort)", "sd"},
+	{"mp3", "Audio (MP3)", "audio"},
+	{"mp3_full", "Full song (MP3)", "audio"},
+}
+
+// downloadOptions flattens the download_link map into the ordered picker,
+// flagging the best video variant (or every photo) as recommended
+func downloadOptions(response models.TikTokResponse) []models.DownloadOption {
+	options := []models.DownloadOption{}
+	recommended := false
+
+	for _, kind := range downloadKinds {
+		switch link := response.DownloadLink[kind.Key].(type) {
+		case string:
+			option := models.DownloadOption{
+				Key:     kind.Key,
+				Label:   kind.Label,
+				Quality: kind.Quality,
+				URL:     link,
+				Size:    response.MediaSizes[kind.Key],
+			}
+			if !recommended && kind.Quality != "audio" {
+				option.Recommended = true
+				recommended = true
+			}
+			options = append(options, option)
+		case []string:
+			// Image posts carry one link per photo
+			for i, photoLink := range link {
+				index := i
+				options = append(options, models.DownloadOption{
+					Key:         kind.Key,
+					Label:       fmt.Sprintf("Photo %d", i+1),
+					Quality:     "original",
+					URL:         photoLink,
+					Index:       &index,
+					Recommended: true,
+				})
+			}
+			recommended = true
+		}
+	}
+	return options
+}
+
+// videoSizes reads the byte size of each video variant from the full hybrid
+// payload; the minimal payload carries no sizes, so this is often empty
+func videoSizes(videoData map[string]interface{}) map[string]int64 {
+	sizes := make(map[string]int64)
+	video, ok := videoData["video"].(map[string]interface{})
+	if !ok {
+		return sizes
+	}
+
+	addSize := func(key string, addr interface{}) {
+		if addr, ok := addr.(map[string]interface{}); ok {
+			if size, ok := addr["data_size"].(float64); ok && size > 0 {
+				sizes[key] = int64(size)
+			}
+		}
+	}
+	addSize("no_watermark", video["play_addr"])
+	addSize("watermark", video["download_addr"])
+	addSize("watermark_hd", video["download_addr"])
+	if bitRates, ok := video["bit_rate"].([]interface{}); ok && len(bitRates) > 0 {
+		if best, ok := bitRates[0].(map[string]interface{}); ok {
+			addSize("no_watermark_hd", best["play_addr"])
+		}
+	}
+	return sizes
+}
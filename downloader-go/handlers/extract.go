@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveExtractedAudio downloads the video behind an extracted MP3 link and
+// serves its audio track as an MP3. The caller sets the filename headers.
+func (h *HandlerContext) serveExtractedAudio(c *gin.Context, downloadData models.DownloadData) {
+	h.serveProcessedVideo(c, downloadData, "mp3", "audio.mp3", "extracting audio", func(ctx context.Context, videoPath, outputPath string) error {
+		return utils.ExtractAudio(ctx, videoPath, outputPath)
+	})
+}
+
+// serveWatermarkRemoved downloads the watermarked video behind a processed
+// no-watermark link and serves it with the watermark blurred out. The caller
+// sets the filename headers.
+func (h *HandlerContext) serveWatermarkRemoved(c *gin.Context, downloadData models.DownloadData) {
+	c.Header("X-Media-Processed", "watermark-removed")
+	h.serveProcessedVideo(c, downloadData, "delogo", "video.mp4", "removing watermark", func(ctx context.Context, videoPath, outputPath string) error {
+		// The output is 8-bit H.264, so HDR sources are always tone-mapped
+		toneMap, err := h.toneMapping(ctx, c, videoPath, "")
+		if err != nil {
+			return err
+		}
+		return utils.RemoveWatermark(ctx, videoPath, outputPath, toneMap)
+	})
+}
+
+// serveProcessedVideo downloads the video behind a link into a temp
+// directory, runs process on it once a render worker is free and serves the
+// resulting outputName. action names the processing in error messages.
+func (h *HandlerContext) serveProcessedVideo(c *gin.Context, downloadData models.DownloadData, prefix, outputName, action string, process func(ctx context.Context, videoPath, outputPath string) error) {
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, prefix, downloadData.Filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()})
+		return
+	}
+	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+
+	ctx := c.Request.Context()
+	videoPath := filepath.Join(tempDir, "source.mp4")
+	if err := utils.DownloadFile(ctx, downloadData.URL, videoPath); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to download from source: " + err.Error()})
+		return
+	}
+
+	// Process once a render worker is free
+	processCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	outputPath := filepath.Join(tempDir, outputName)
+	var processErr error
+	err = h.RenderQueue.Do(processCtx, filepath.Base(tempDir), h.renderPriority(c), func() {
+		processErr = process(processCtx, videoPath, outputPath)
+	})
+	if err == nil {
+		err = processErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+		return
+	}
+
+	h.serveLocalFile(c, outputPath, "")
+	h.accountDownload(downloadData.Tenant, h.servedBytes(c, outputPath))
+}
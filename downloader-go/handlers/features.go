@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"slices"
+
+	"tiktok-downloader/models"
+)
+
+// mp3Keys lists the download link keys of audio downloads
+var mp3Keys = []string{"mp3", "mp3_original", "mp3_full"}
+
+// stripDisabledFeatures drops the links of features switched off by the
+// operator from a response
+func (h *HandlerContext) stripDisabledFeatures(response *models.TikTokResponse) {
+	if h.Config.DisableSlideshow {
+		response.SlideshowDownLink = ""
+	}
+	if h.Config.DisableGIF {
+		response.GifDownLink = ""
+	}
+	if h.Config.DisableScenes {
+		response.ScenesLink = ""
+	}
+	if h.Config.DisableWaveform {
+		response.WaveformLink = ""
+	}
+	if h.Config.DisableMP3 {
+		for _, key := range mp3Keys {
+			delete(response.DownloadLink, key)
+			delete(response.MediaSources, key)
+		}
+	}
+}
+
+// stripDisabledMusicFeatures drops the MP3 download of a sound page when
+// MP3 downloads are switched off
+func (h *HandlerContext) stripDisabledMusicFeatures(response *models.MusicResponse) {
+	if !h.Config.DisableMP3 {
+		return
+	}
+	response.Downloads = slices.DeleteFunc(response.Downloads, func(option models.DownloadOption) bool {
+		return slices.Contains(mp3Keys, option.Key)
+	})
+}
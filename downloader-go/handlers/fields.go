@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tiktok-downloader/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseFieldNames lists the top-level JSON keys of a /tiktok response
+var responseFieldNames = jsonFieldNames(reflect.TypeOf(models.TikTokResponse{}))
+
+// jsonFieldNames returns the set of JSON keys a struct type serializes to
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// requestedFields parses the comma-separated fields query parameter,
+// returning nil when the full response was asked for
+func requestedFields(c *gin.Context) ([]string, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !responseFieldNames[field] {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// filterFields keeps only the requested top-level keys of a response
+func filterFields(response interface{}, fields []string) (map[string]interface{}, error) {
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]interface{}
+	if err := json.Unmarshal(raw, &full); err != nil {
+		return nil, err
+	}
+
+	filtered := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := full[field]; ok {
+			filtered[field] = value
+		}
+	}
+	return filtered, nil
+}
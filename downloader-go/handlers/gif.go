@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gifOptions builds the conversion options for a GIF request, applying query
+// overrides within the bounds set by the server config
+func (h *HandlerContext) gifOptions(c *gin.Context) (utils.GifOptions, error) {
+	cfg := h.Config
+	opts := utils.GifOptions{
+		FPS:        cfg.GifFPS,
+		Width:      cfg.GifWidth,
+		Palette:    true,
+		MaxSeconds: cfg.GifMaxSeconds,
+	}
+
+	if fpsParam := c.Query("fps"); fpsParam != "" {
+		fps, err := strconv.Atoi(fpsParam)
+		if err != nil || fps < 1 || fps > cfg.GifMaxFPS {
+			return opts, fmt.Errorf("fps must be between 1 and %d", cfg.GifMaxFPS)
+		}
+		opts.FPS = fps
+	}
+
+	// Output width in pixels, e.g. 320 for chat stickers
+	if scaleParam := c.Query("scale"); scaleParam != "" {
+		width, err := strconv.Atoi(scaleParam)
+		if err != nil || width < 16 || width > cfg.GifMaxWidth {
+			return opts, fmt.Errorf("scale must be between 16 and %d", cfg.GifMaxWidth)
+		}
+		opts.Width = width
+	}
+
+	// palettegen=false trades color quality for a faster single pass
+	if paletteParam := c.Query("palettegen"); paletteParam != "" {
+		palette, err := strconv.ParseBool(paletteParam)
+		if err != nil {
+			return opts, fmt.Errorf("palettegen must be true or false")
+		}
+		opts.Palette = palette
+	}
+
+	return opts, nil
+}
+
+// resolvePostVideo fetches a post and returns its data and no-watermark video
+// URL, answering the request itself and reporting false when the post can't
+// be resolved or is not a video. purpose completes "Only video posts can be".
+func (h *HandlerContext) resolvePostVideo(c *gin.Context, sourceURL, purpose string) (map[string]interface{}, string, bool) {
+	ctx := c.Request.Context()
+	client := h.clientFingerprint(c)
+
+	data, err := utils.FetchPostData(ctx, h.Config, sourceURL)
+	if err != nil {
+		c.JSON(upstreamErrorResponse(err))
+		return nil, "", false
+	}
+	utils.CompleteVideoData(ctx, h.Config, sourceURL, data)
+
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusInternalServerError, h.diagnosticBody(sourceURL, client, data, "Invalid data format"))
+		return nil, "", false
+	}
+	if typeVal, _ := videoData["type"].(string); typeVal == "image" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only video posts can be " + purpose})
+		return nil, "", false
+	}
+
+	videoURL := utils.ResolveMediaURL(videoData, "no_watermark", 0)
+	if videoURL == "" {
+		videoURL = utils.ResolveMediaURL(videoData, "no_watermark_hd", 0)
+	}
+	if videoURL == "" {
+		c.JSON(http.StatusInternalServerError, h.diagnosticBody(sourceURL, client, data, "No no-watermark video found"))
+		return nil, "", false
+	}
+	return videoData, videoURL, true
+}
+
+// ConvertGIFHandler handles /convert/gif: it downloads the no-watermark video
+// of a post and converts it to an animated GIF in a temp directory
+func (h *HandlerContext) ConvertGIFHandler(c *gin.Context) {
+	urlParam := c.Query("url")
+	if urlParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
+		return
+	}
+
+	gifOpts, err := h.gifOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GIF options: " + err.Error()})
+		return
+	}
+
+	sourceURL, err := utils.VerifyLink(urlParam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting URL: " + err.Error()})
+		return
+	}
+	tenant := verifyTenant(c.Query("tenant"))
+	ctx := c.Request.Context()
+
+	videoData, videoURL, ok := h.resolvePostVideo(c, sourceURL, "converted to GIF")
+	if !ok {
+		return
+	}
+
+	// Create a unique temp directory, cleaned up like slideshow renders
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, "gif", utils.GetAwemeID(videoData))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()})
+		return
+	}
+	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+
+	videoPath := filepath.Join(tempDir, "video.mp4")
+	if err := utils.DownloadFile(ctx, videoURL, videoPath); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error downloading video: " + err.Error()})
+		return
+	}
+
+	// GIF has no HDR, so HDR sources are always tone-mapped
+	gifOpts.ToneMap, err = h.toneMapping(ctx, c, videoPath, "gif")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if gifOpts.ToneMap != "" {
+		c.Header("X-HDR-Tonemapped", "true")
+	}
+
+	// Convert once a render worker is free
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	outputPath := filepath.Join(tempDir, "animation.gif")
+	var convertErr error
+	err = h.RenderQueue.Do(renderCtx, filepath.Base(tempDir), h.renderPriority(c), func() {
+		convertErr = utils.CreateGIF(renderCtx, videoPath, outputPath, gifOpts)
+	})
+	if err == nil {
+		err = convertErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating GIF: " + err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%d.gif", renderFilenameBase(videoData), time.Now().Unix())
+
+	h.serveLocalFile(c, outputPath, filename)
+	h.accountDownload(tenant, h.servedBytes(c, outputPath))
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+)
+
+// WarnGuestRestricted is reported on responses trimmed for guests
+const WarnGuestRestricted = "guest_restricted"
+
+// guestWithheldKeys lists the video links withheld from guests, who only get
+// the watermarked variants. Photos have no watermarked variant and are kept.
+var guestWithheldKeys = []string{"no_watermark_hd", "no_watermark", utils.ProcessedNoWatermarkKey}
+
+// restrictGuestResponse trims a response to what guests may download:
+// watermarked video, audio and photos, without slideshow or embed links, nor
+// the GIF and scene links rendered from the no-watermark video
+func restrictGuestResponse(response *models.TikTokResponse) {
+	withheld := false
+	for _, key := range guestWithheldKeys {
+		if _, ok := response.DownloadLink[key].(string); ok {
+			delete(response.DownloadLink, key)
+			delete(response.MediaSources, key)
+			withheld = true
+		}
+	}
+	for _, link := range []*string{&response.SlideshowDownLink, &response.EmbedLink, &response.GifDownLink, &response.ScenesLink} {
+		if *link != "" {
+			*link = ""
+			withheld = true
+		}
+	}
+	if withheld {
+		addWarning(response, WarnGuestRestricted, "guests get watermarked links only and no slideshows, use an API key for full features")
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toneMapping decides how an HDR source is re-encoded into format: it returns
+// the tonemap algorithm to apply, or "" when the source is SDR, tone-mapping
+// is disabled, or ?keep_hdr=true asks to keep HDR in a format that carries it
+func (h *HandlerContext) toneMapping(ctx context.Context, c *gin.Context, videoPath, format string) (string, error) {
+	if !h.Config.HDRToneMap {
+		return "", nil
+	}
+	algorithm := h.Config.HDRToneMapAlgorithm
+	if !slices.Contains(utils.ToneMapAlgorithms, algorithm) {
+		return "", fmt.Errorf("HDR_TONEMAP_ALGORITHM must be one of %s", strings.Join(utils.ToneMapAlgorithms, ", "))
+	}
+	if c.Query("keep_hdr") == "true" && utils.FormatKeepsHDR(format) {
+		return "", nil
+	}
+	if !utils.IsHDR(ctx, videoPath) {
+		return "", nil
+	}
+	return algorithm, nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+	"tiktok-downloader/models"
+)
+
+// ResponseHook post-processes /tiktok responses before they are rendered,
+// so deployments can add fields (under Extra), rewrite links or add
+// attribution without patching generateJSONResponse. Hooks are registered
+// once at startup and run in order; one failing leaves the response as the
+// previous hooks left it.
+type ResponseHook interface {
+	ProcessResponse(ctx context.Context, sourceURL string, response *models.TikTokResponse) error
+}
+
+// responseHooks are the registered response hooks
+var responseHooks []ResponseHook
+
+func init() {
+	metrics.Register("tikdownloader_response_hook_errors_total", "Response hooks that failed, leaving the response unchanged.", metrics.Counter)
+}
+
+// AddResponseHook registers a response hook
+func AddResponseHook(hook ResponseHook) {
+	responseHooks = append(responseHooks, hook)
+}
+
+// runResponseHooks applies the registered hooks to a response
+func runResponseHooks(ctx context.Context, sourceURL string, response *models.TikTokResponse) {
+	for _, hook := range responseHooks {
+		if err := hook.ProcessResponse(ctx, sourceURL, response); err != nil {
+			metrics.Inc("tikdownloader_response_hook_errors_total", nil)
+			logging.Warnf("Response hook failed for %s: %v", logging.RedactURL(sourceURL), err)
+		}
+	}
+}
+
+// execHookPayload is what an exec hook reads on stdin and writes back on
+// stdout: the response, with the download links that are otherwise not
+// serialized as a map
+type execHookPayload struct {
+	SourceURL    string                 `json:"source_url"`
+	Response     models.TikTokResponse  `json:"response"`
+	DownloadLink map[string]interface{} `json:"download_link"`
+}
+
+// ExecResponseHook runs a command per response, for hooks written in any
+// language: the command gets an execHookPayload as JSON on stdin and answers
+// with the payload, modified as it sees fit, on stdout
+type ExecResponseHook struct {
+	args    []string
+	timeout time.Duration
+}
+
+// NewExecResponseHook returns a hook running command, split on spaces, with
+// a timeout per response
+func NewExecResponseHook(command string, timeout time.Duration) *ExecResponseHook {
+	return &ExecResponseHook{args: strings.Fields(command), timeout: timeout}
+}
+
+// ProcessResponse implements ResponseHook
+func (e *ExecResponseHook) ProcessResponse(ctx context.Context, sourceURL string, response *models.TikTokResponse) error {
+	input, err := json.Marshal(execHookPayload{
+		SourceURL:    sourceURL,
+		Response:     *response,
+		DownloadLink: response.DownloadLink,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.args[0], e.args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output execHookPayload
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return fmt.Errorf("invalid hook output: %v", err)
+	}
+
+	// Fields that are never serialized survive the round trip untouched
+	output.Response.MediaSources = response.MediaSources
+	output.Response.MediaSizes = response.MediaSizes
+	output.Response.DownloadLink = output.DownloadLink
+	if output.Response.DownloadLink == nil {
+		output.Response.DownloadLink = response.DownloadLink
+	}
+	*response = output.Response
+	return nil
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+
+	"tiktok-downloader/utils"
+)
+
+// Intro frame file names in a slideshow's temp directory
+const (
+	introAvatarFile = "intro_avatar"
+	introFrameFile  = "intro.jpg"
+)
+
+// fetchIntroAvatar downloads the author's avatar for the intro frame,
+// returning "" when the post has none or it can't be fetched
+func fetchIntroAvatar(ctx context.Context, videoData map[string]interface{}, tempDir string) string {
+	author, _ := videoData["author"].(map[string]interface{})
+	avatarURL := ""
+	for _, key := range []string{"avatar_larger", "avatar_medium", "avatar_thumb"} {
+		if avatarURL = utils.GetFirstFromNestedList(author, []string{key, "url_list"}, ""); avatarURL != "" {
+			break
+		}
+	}
+	if avatarURL == "" {
+		return ""
+	}
+
+	path := filepath.Join(tempDir, introAvatarFile)
+	format, err := utils.DownloadImage(ctx, avatarURL, path)
+	if err == nil {
+		path, err = utils.PrepareImage(ctx, path, format)
+	}
+	if err != nil {
+		log.Printf("Error downloading intro avatar: %v", err)
+		return ""
+	}
+	return path
+}
+
+// prependIntro renders the intro frame of a post and puts it before the
+// slideshow's images. The intro is best effort: on failure the images are
+// returned unchanged.
+func prependIntro(ctx context.Context, videoData map[string]interface{}, tempDir, avatarPath string, imagePaths []string) []string {
+	author, _ := videoData["author"].(map[string]interface{})
+	statistics := utils.PostStatistics(videoData)
+	card := utils.IntroCard{
+		Likes:      utils.GetIntStat(statistics, "digg_count"),
+		Comments:   utils.GetIntStat(statistics, "comment_count"),
+		AvatarPath: avatarPath,
+	}
+	card.Nickname, _ = author["nickname"].(string)
+	card.Caption, _ = videoData["desc"].(string)
+
+	framePath := filepath.Join(tempDir, introFrameFile)
+	err := utils.CreateIntroFrame(ctx, card, tempDir, framePath)
+	if err != nil && avatarPath != "" {
+		// Avatars in formats FFmpeg can't decode shouldn't cost the whole intro
+		log.Printf("Error creating intro frame with avatar, retrying without: %v", err)
+		card.AvatarPath = ""
+		err = utils.CreateIntroFrame(ctx, card, tempDir, framePath)
+	}
+	if err != nil {
+		log.Printf("Error creating intro frame: %v", err)
+		return imagePaths
+	}
+	return append([]string{framePath}, imagePaths...)
+}
+
+// slideshowWatermarkFile is the file holding the watermark text of a slideshow
+const slideshowWatermarkFile = "watermark.txt"
+
+// slideshowWatermark returns the watermark of a post's slideshow: the
+// configured image, else the author's @handle. An author without a handle
+// gets no watermark.
+func (h *HandlerContext) slideshowWatermark(videoData map[string]interface{}, tempDir string) utils.SlideshowWatermark {
+	if h.Config.SlideshowWatermarkImage != "" {
+		return utils.SlideshowWatermark{ImagePath: h.Config.SlideshowWatermarkImage}
+	}
+
+	author, _ := videoData["author"].(map[string]interface{})
+	handle, _ := author["unique_id"].(string)
+	if handle == "" {
+		handle, _ = author["nickname"].(string)
+	} else {
+		handle = "@" + handle
+	}
+	if handle == "" {
+		return utils.SlideshowWatermark{}
+	}
+
+	path := filepath.Join(tempDir, slideshowWatermarkFile)
+	if err := os.WriteFile(path, []byte(handle), 0644); err != nil {
+		log.Printf("Error writing slideshow watermark: %v", err)
+		return utils.SlideshowWatermark{}
+	}
+	return utils.SlideshowWatermark{TextPath: path}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+
+	"tiktok-downloader/models"
+)
+
+// applyCachedMediaLinks swaps download links for CloudFront signed URLs when
+// the media is already in the S3 cache, so those downloads bypass this service
+func (h *HandlerContext) applyCachedMediaLinks(ctx context.Context, response *models.TikTokResponse, sourceURL string) {
+	if h.MediaCache == nil {
+		return
+	}
+
+	for key, source := range response.MediaSources {
+		switch source.(type) {
+		case string:
+			if signed, ok := h.MediaCache.SignedURL(ctx, h.MediaCache.ObjectKey(sourceURL, key, 0)); ok {
+				response.DownloadLink[key] = signed
+			}
+		case []string:
+			links, ok := response.DownloadLink[key].([]string)
+			if !ok {
+				continue
+			}
+			for i := range links {
+				if signed, ok := h.MediaCache.SignedURL(ctx, h.MediaCache.ObjectKey(sourceURL, key, i)); ok {
+					links[i] = signed
+				}
+			}
+		}
+	}
+}
+
+// mediaCacheTee copies a streamed download into a temp file so it can be
+// uploaded to the media cache once the client has received all of it
+type mediaCacheTee struct {
+	file      *os.File
+	objectKey string
+	size      int64
+}
+
+// newMediaCacheTee starts capturing a download of size bytes, or returns nil
+// when the download can't be cached
+func (h *HandlerContext) newMediaCacheTee(data models.DownloadData, size int64) *mediaCacheTee {
+	if h.MediaCache == nil || data.Source == "" || data.Key == "" || size <= 0 {
+		return nil
+	}
+	file, err := os.CreateTemp(h.Config.TempDir, "mediacache-*")
+	if err != nil {
+		log.Printf("Error creating media cache temp file: %v", err)
+		return nil
+	}
+	return &mediaCacheTee{
+		file:      file,
+		objectKey: h.MediaCache.ObjectKey(data.Source, data.Key, data.Index),
+		size:      size,
+	}
+}
+
+// Reader wraps body so everything read from it is also captured
+func (t *mediaCacheTee) Reader(body io.Reader) io.Reader {
+	if t == nil {
+		return body
+	}
+	return io.TeeReader(body, t.file)
+}
+
+// finishMediaCacheTee uploads the capture if the whole file was streamed and discards it otherwise
+func (h *HandlerContext) finishMediaCacheTee(t *mediaCacheTee, contentType, filename string) {
+	if t == nil {
+		return
+	}
+	t.file.Close()
+
+	info, err := os.Stat(t.file.Name())
+	if err != nil || info.Size() != t.size {
+		os.Remove(t.file.Name())
+		return
+	}
+	h.MediaCache.StoreInBackground(t.objectKey, t.file.Name(), contentType, filename)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveMetadata answers metadata_only requests with the title, author,
+// cover and counts of a post and no download links. TikTok posts are looked
+// up through oEmbed without a hybrid API round trip; oEmbed has no counts, so
+// those come back flagged as missing. Douyin posts, and TikTok posts oEmbed
+// can't resolve, fall back to the hybrid API.
+func (h *HandlerContext) resolveMetadata(ctx context.Context, sourceURL string, opts tiktokOptions) (int, interface{}) {
+	var response models.TikTokResponse
+	if parsed, err := url.Parse(sourceURL); err == nil && utils.PlatformForHost(parsed.Host) == utils.PlatformTikTok {
+		embed, err := utils.FetchOEmbed(ctx, sourceURL)
+		if err == nil {
+			response = oembedResponse(embed)
+		} else {
+			logging.Debugf("oEmbed could not resolve %s, using the hybrid API: %v", sourceURL, err)
+		}
+	}
+
+	if response.Status == "" {
+		data, err := utils.FetchPostData(ctx, h.Config, sourceURL)
+		if err != nil {
+			return upstreamErrorResponse(err)
+		}
+		full, err := generateJSONResponse(data, sourceURL, opts.Tenant, opts.LinkTTL, h.Config)
+		if err != nil {
+			body := gin.H{"error": "Error processing response: " + err.Error()}
+			if ref := utils.CaptureDiagnostics(h.Config.DiagnosticsDir, sourceURL, opts.Client, data, err); ref != "" {
+				body["reference_id"] = ref
+			}
+			return http.StatusInternalServerError, body
+		}
+		response = metadataResponse(full)
+	}
+	h.Usage.RecordRequest(opts.Tenant)
+
+	if !opts.BudgetResets.IsZero() {
+		addWarning(&response, WarnEgressBudget, "Download links are disabled until the bandwidth budget resets at "+opts.BudgetResets.Format(time.RFC3339))
+	}
+
+	if opts.Translate != "" {
+		h.translateResponse(ctx, &response, opts.Translate)
+	}
+
+	if opts.Fields != nil {
+		filtered, err := filterFields(response, opts.Fields)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": "Error filtering response: " + err.Error()}
+		}
+		return http.StatusOK, filtered
+	}
+	return http.StatusOK, response
+}
+
+// oembedResponse builds a metadata-only response from a TikTok oEmbed document
+func oembedResponse(embed *utils.OEmbed) models.TikTokResponse {
+	nickname := embed.AuthorName
+	if nickname == "" {
+		nickname = embed.AuthorUniqueID
+	}
+	response := models.TikTokResponse{
+		Status:      "metadata",
+		Photos:      []models.PhotoItem{},
+		Title:       embed.Title,
+		Description: embed.Title,
+		Artist:      nickname,
+		Cover:       embed.ThumbnailURL,
+		Author:      models.Author{Nickname: nickname},
+		Downloads:   []models.DownloadOption{},
+	}
+	addWarning(&response, WarnStatisticsMissing, "oEmbed reports no counts, they are reported as 0")
+	if response.Cover == "" {
+		addWarning(&response, WarnCoverMissing, "cover image unavailable")
+	}
+	return response
+}
+
+// metadataResponse strips the media of a full response, keeping its metadata
+func metadataResponse(full models.TikTokResponse) models.TikTokResponse {
+	return models.TikTokResponse{
+		Status:        "metadata",
+		Photos:        []models.PhotoItem{},
+		Title:         full.Title,
+		Description:   full.Description,
+		Statistics:    full.Statistics,
+		Artist:        full.Artist,
+		Cover:         full.Cover,
+		Duration:      full.Duration,
+		MusicDuration: full.MusicDuration,
+		Music:         full.Music,
+		Author:        full.Author,
+		Downloads:     []models.DownloadOption{},
+		Warnings:      full.Warnings,
+	}
+}
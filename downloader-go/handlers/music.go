@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of the post list on a sound page; the hybrid API caps count at 50
+const (
+	defaultMusicPageSize = 20
+	maxMusicPageSize     = 50
+)
+
+// musicPaging reads the cursor and count of a sound page request from the
+// request body, or from the query string for GET /tiktok
+func musicPaging(c *gin.Context, req models.TikTokRequest) (int64, int, error) {
+	cursor, count := req.Cursor, req.Count
+	if value := c.Query("cursor"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cursor must be an integer")
+		}
+		cursor = parsed
+	}
+	if value := c.Query("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("count must be an integer")
+		}
+		count = parsed
+	}
+
+	if cursor < 0 {
+		return 0, 0, fmt.Errorf("cursor must not be negative")
+	}
+	if count == 0 {
+		count = defaultMusicPageSize
+	}
+	if count < 1 || count > maxMusicPageSize {
+		return 0, 0, fmt.Errorf("count must be between 1 and %d", maxMusicPageSize)
+	}
+	return cursor, count, nil
+}
+
+// resolveMusic fetches a sound page from the hybrid API and builds the
+// response: the sound's metadata and cover, a signed MP3 link and one page of
+// the posts using the sound. Compat modes don't apply to sound pages.
+func (h *HandlerContext) resolveMusic(ctx context.Context, sourceURL string, opts tiktokOptions) (int, interface{}) {
+	data, err := utils.FetchMusicData(ctx, h.Config, sourceURL, opts.MusicCursor, opts.MusicCount)
+	if err != nil {
+		return upstreamErrorResponse(err)
+	}
+
+	musicData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		return http.StatusInternalServerError, gin.H{"error": "Error processing response: invalid data format"}
+	}
+	music, ok := musicData["music"].(map[string]interface{})
+	if !ok {
+		return http.StatusInternalServerError, gin.H{"error": "Error processing response: music data not found"}
+	}
+
+	duration := 0
+	if durVal, ok := music["duration"].(float64); ok {
+		duration = int(durVal)
+	}
+	info := buildMusicInfo(music, duration)
+	musicURL := utils.GetMusicURL(music)
+
+	response := models.MusicResponse{
+		Status:    "music",
+		Music:     info,
+		Cover:     info.Cover,
+		Audio:     musicURL,
+		UserCount: utils.GetIntStat(music, "user_count"),
+		Downloads: []models.DownloadOption{},
+		Videos:    musicVideos(musicData),
+		HasMore:   musicData["has_more"] == true,
+	}
+	response.Platform, _ = musicData["platform"].(string)
+	if cursor, ok := musicData["cursor"].(float64); ok {
+		response.Cursor = int64(cursor)
+	}
+
+	// Name the MP3 after the sound, or its author for untitled original sounds
+	title := info.Title
+	if title == "" {
+		title = info.Author
+	}
+	name := downloadName{Author: title, ASCII: utils.FilenameBase(title, "", info.ID)}
+	if mp3Link := downloadLink(musicURL, name, "mp3", sourceURL, "mp3", 0, opts.Tenant, opts.LinkTTL, h.Config); mp3Link != "" {
+		response.Downloads = append(response.Downloads, models.DownloadOption{
+			Key:         "mp3",
+			Label:       "Audio (MP3)",
+			Quality:     "audio",
+			URL:         mp3Link,
+			Recommended: true,
+		})
+	} else {
+		response.Warnings = append(response.Warnings, models.Warning{
+			Code:    WarnMusicUnavailable,
+			Message: "music unavailable, mp3 download omitted",
+		})
+	}
+	if response.Cover == "" {
+		response.Warnings = append(response.Warnings, models.Warning{Code: WarnCoverMissing, Message: "cover image unavailable"})
+	}
+	h.stripDisabledMusicFeatures(&response)
+	h.Usage.RecordRequest(opts.Tenant)
+
+	if opts.Fields != nil {
+		filtered, err := filterFields(response, opts.Fields)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": "Error filtering response: " + err.Error()}
+		}
+		return http.StatusOK, filtered
+	}
+	return http.StatusOK, response
+}
+
+// musicVideos converts the posts listed on a sound page
+func musicVideos(musicData map[string]interface{}) []models.MusicVideo {
+	videos := []models.MusicVideo{}
+	items, _ := musicData["videos"].([]interface{})
+	for _, item := range items {
+		video, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		postURL, _ := video["url"].(string)
+		if postURL == "" {
+			continue
+		}
+
+		author, _ := video["author"].(map[string]interface{})
+		nickname, _ := author["nickname"].(string)
+		entry := models.MusicVideo{
+			ID:        utils.GetAwemeID(video),
+			URL:       postURL,
+			Author:    models.Author{Nickname: nickname},
+			PlayCount: utils.GetIntStat(video, "play_count"),
+		}
+		entry.Description, _ = video["desc"].(string)
+		entry.Cover, _ = video["cover"].(string)
+		if entry.Author.Nickname == "" {
+			entry.Author.Nickname, _ = author["unique_id"].(string)
+		}
+		videos = append(videos, entry)
+	}
+	return videos
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiktok-downloader/middleware"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default size of the embedded player, in the 9:16 frame of the posts
+const (
+	oembedPlayerWidth  = 338
+	oembedPlayerHeight = 600
+)
+
+// oembedDocument is an oEmbed 1.0 response of type "video"
+type oembedDocument struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title,omitempty"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age,omitempty"`
+	SourceURL    string `json:"source_url"`
+}
+
+// embedSourceURL returns the post URL of an /oembed or /embed request, or an
+// error body when it is missing or no supported platform serves it
+func embedSourceURL(c *gin.Context) (string, gin.H) {
+	sourceURL := c.Query("url")
+	if sourceURL == "" {
+		return "", gin.H{"error": "URL parameter is required"}
+	}
+	if !utils.IsSupportedURL(sourceURL) {
+		return "", gin.H{"error": utils.UnsupportedURLMessage()}
+	}
+	if utils.IsMusicURL(sourceURL) {
+		return "", gin.H{"error": "Sound pages can't be embedded"}
+	}
+	return sourceURL, nil
+}
+
+// OEmbedHandler handles GET /oembed?url=..., the oEmbed provider endpoint:
+// title, author and cover of a post, with an iframe of the /embed player as
+// html, so CMS platforms can embed posts through this service
+func (h *HandlerContext) OEmbedHandler(c *gin.Context) {
+	// Only the JSON format is provided; the spec asks for 501 otherwise
+	if format := c.Query("format"); format != "" && format != "json" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Only the json format is supported"})
+		return
+	}
+	sourceURL, errBody := embedSourceURL(c)
+	if errBody != nil {
+		c.JSON(http.StatusNotFound, errBody)
+		return
+	}
+	if h.rejectUnsigned(c, sourceURL) {
+		return
+	}
+
+	width, height, err := oembedPlayerSize(c.Query("maxwidth"), c.Query("maxheight"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := tiktokOptions{Client: h.clientFingerprint(c), Tenant: h.tenantForRequest(c), LinkTTL: h.defaultLinkTTL(), Guest: middleware.IsGuest(c)}
+	status, body := h.resolveMetadata(c.Request.Context(), sourceURL, opts)
+	response, ok := body.(models.TikTokResponse)
+	if !ok {
+		c.JSON(status, body)
+		return
+	}
+
+	// The iframe is loaded anonymously, so it carries its own signature
+	playerURL := strings.TrimRight(h.Config.BaseURL, "/") + "/embed?url=" + url.QueryEscape(sourceURL) +
+		h.signedQuery(sourceURL, time.Now().Add(h.Config.EmbedLinkTTL))
+	c.JSON(http.StatusOK, oembedDocument{
+		Version:      "1.0",
+		Type:         "video",
+		ProviderName: "TikTok Downloader",
+		ProviderURL:  h.Config.BaseURL,
+		Title:        response.Title,
+		AuthorName:   response.Author.Nickname,
+		ThumbnailURL: response.Cover,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allow="autoplay; fullscreen" allowfullscreen></iframe>`,
+			html.EscapeString(playerURL), width, height),
+		Width:     width,
+		Height:    height,
+		CacheAge:  3600,
+		SourceURL: sourceURL,
+	})
+}
+
+// oembedPlayerSize fits the default player into the consumer's maxwidth and
+// maxheight, keeping its aspect ratio
+func oembedPlayerSize(maxWidthParam, maxHeightParam string) (int, int, error) {
+	width, height := oembedPlayerWidth, oembedPlayerHeight
+	if maxWidthParam != "" {
+		maxWidth, err := strconv.Atoi(maxWidthParam)
+		if err != nil || maxWidth < 1 {
+			return 0, 0, fmt.Errorf("Invalid maxwidth parameter")
+		}
+		if width > maxWidth {
+			width, height = maxWidth, maxWidth*oembedPlayerHeight/oembedPlayerWidth
+		}
+	}
+	if maxHeightParam != "" {
+		maxHeight, err := strconv.Atoi(maxHeightParam)
+		if err != nil || maxHeight < 1 {
+			return 0, 0, fmt.Errorf("Invalid maxheight parameter")
+		}
+		if height > maxHeight {
+			width, height = maxHeight*oembedPlayerWidth/oembedPlayerHeight, maxHeight
+		}
+	}
+	return max(width, 1), max(height, 1), nil
+}
+
+// embedPlayerTemplate is the page of the /embed player: the post's video, or
+// its photos one below the other
+var embedPlayerTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+html, body { margin: 0; height: 100%; background: #000; }
+video { display: block; width: 100%; height: 100%; object-fit: contain; }
+.photos { height: 100%; overflow-y: auto; scroll-snap-type: y mandatory; }
+.photos img { display: block; width: 100%; height: 100%; object-fit: contain; scroll-snap-align: start; }
+</style>
+</head>
+<body>
+{{if .Video}}<video src="{{.Video}}"{{if .Cover}} poster="{{.Cover}}"{{end}} controls playsinline preload="metadata"></video>
+{{else}}<div class="photos">{{range .Photos}}<img src="{{.}}" alt="" loading="lazy">{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// EmbedPlayerHandler handles GET /embed?url=..., the player page behind the
+// oEmbed html. Media and the poster load through freshly signed download
+// links, so embeds keep working after the links of earlier page loads expire
+// and the page loads nothing from TikTok.
+func (h *HandlerContext) EmbedPlayerHandler(c *gin.Context) {
+	sourceURL, errBody := embedSourceURL(c)
+	if errBody != nil {
+		c.JSON(http.StatusBadRequest, errBody)
+		return
+	}
+	if h.rejectUnsigned(c, sourceURL) {
+		return
+	}
+
+	opts := tiktokOptions{Client: h.clientFingerprint(c), Tenant: h.tenantForRequest(c), LinkTTL: h.defaultLinkTTL(), Guest: middleware.IsGuest(c)}
+	status, body := h.resolveTikTok(c.Request.Context(), sourceURL, opts)
+	response, ok := body.(models.TikTokResponse)
+	if !ok {
+		c.JSON(status, body)
+		return
+	}
+
+	// The poster goes through the proxy too, like the media
+	page := embedPage{Title: response.Title}
+	if response.Cover != "" {
+		name := downloadName{Author: response.Author.Nickname, ASCII: "cover"}
+		page.Cover = downloadLink(response.Cover, name, "image", sourceURL, utils.CoverKey, 0, opts.Tenant, opts.LinkTTL, h.Config)
+	}
+	for _, option := range response.Downloads {
+		if !option.Recommended {
+			continue
+		}
+		if option.Index != nil {
+			page.Photos = append(page.Photos, option.URL)
+		} else if page.Video == "" {
+			page.Video = option.URL
+		}
+	}
+	if page.Video == "" && len(page.Photos) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post has no playable media"})
+		return
+	}
+
+	renderEmbedPlayer(c, page)
+}
+
+// EmbedTokenHandler handles GET /embed/:token, the player of a response's
+// embed_link. The video streams through the Range-enabled /download proxy
+// under a download link issued for this page load, so the page loads
+// nothing from TikTok.
+func (h *HandlerContext) EmbedTokenHandler(c *gin.Context) {
+	token := c.Param("token")
+	var downloadData models.DownloadData
+	if err := utils.VerifyLinkJSON(token, &downloadData); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid embed link: " + err.Error()})
+		return
+	}
+	if downloadData.Audience != models.AudienceEmbed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid embed link: not an embed token"})
+		return
+	}
+	if downloadData.URL == "" || downloadData.Type != "video" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Embed link is not a video"})
+		return
+	}
+
+	downloadData.Audience = ""
+	video := utils.GenerateDownloadLink(downloadData, h.Config, h.defaultLinkTTL())
+	if video == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate the video link"})
+		return
+	}
+	renderEmbedPlayer(c, embedPage{Title: downloadData.Author, Video: video})
+}
+
+// embedPage is what the player page shows
+type embedPage struct {
+	Title  string
+	Cover  string
+	Video  string
+	Photos []string
+}
+
+// renderEmbedPlayer writes the player page. It sends no referrer along, so
+// the media requests don't tell the proxy which site embeds them.
+func renderEmbedPlayer(c *gin.Context, page embedPage) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	if err := embedPlayerTemplate.Execute(c.Writer, page); err != nil {
+		c.Error(err)
+	}
+}
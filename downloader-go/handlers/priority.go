@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"tiktok-downloader/metrics"
+	"tiktok-downloader/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priorityInteractive is the X-Priority / priority value that asks to jump the render queue
+const priorityInteractive = "interactive"
+
+func init() {
+	metrics.Register("tikdownloader_priority_bypass_total", "Requests asking for interactive render priority, by outcome.", metrics.Counter)
+}
+
+// renderPriority returns the render queue priority for a request. Interactive
+// priority is only granted to callers presenting a trusted X-API-Key.
+func (h *HandlerContext) renderPriority(c *gin.Context) int {
+	requested := c.GetHeader("X-Priority")
+	if requested == "" {
+		requested = c.Query("priority")
+	}
+	if requested != priorityInteractive {
+		return queue.PriorityNormal
+	}
+
+	if h.trustedAPIKey(c.GetHeader("X-API-Key")) {
+		metrics.Inc("tikdownloader_priority_bypass_total", metrics.Labels{"result": "granted"})
+		return queue.PriorityInteractive
+	}
+	metrics.Inc("tikdownloader_priority_bypass_total", metrics.Labels{"result": "denied"})
+	return queue.PriorityNormal
+}
+
+// trustedAPIKey reports whether key is one of the configured interactive API keys
+func (h *HandlerContext) trustedAPIKey(key string) bool {
+	return matchAPIKey(key, h.Config.InteractiveAPIKeys)
+}
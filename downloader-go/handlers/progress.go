@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// progressTTL is how long a finished download's progress stays queryable
+const progressTTL = 10 * time.Minute
+
+// downloadProgress is the state of one streamed download, polled through
+// /download-progress while long videos transfer
+type downloadProgress struct {
+	total   int64
+	started time.Time
+	sent    atomic.Int64
+	done    atomic.Bool
+	failed  atomic.Bool
+}
+
+// progressEntries maps progress tokens to their downloads
+var progressEntries sync.Map
+
+// issuedProgressTokens holds the tokens handed out by
+// IssueProgressTokenHandler that no download has claimed yet
+var issuedProgressTokens sync.Map
+
+// progressToken returns the client's ?progress= token if the server issued
+// it and no other download claimed it, or a new one. Tokens are always
+// generated here, so clients cannot pick, guess or reuse another's.
+func progressToken(c *gin.Context) string {
+	if token := c.Query("progress"); token != "" {
+		if _, issued := issuedProgressTokens.LoadAndDelete(token); issued {
+			return token
+		}
+	}
+	return uuid.NewString()
+}
+
+// IssueProgressTokenHandler handles POST /download-progress: a token to pass
+// as ?progress= on a download, for clients that can't read the
+// X-Progress-Token header of a download before it completes (e.g. browser
+// navigations). Unclaimed tokens expire after progressTTL.
+func (h *HandlerContext) IssueProgressTokenHandler(c *gin.Context) {
+	token := uuid.NewString()
+	issuedProgressTokens.Store(token, struct{}{})
+	time.AfterFunc(progressTTL, func() { issuedProgressTokens.Delete(token) })
+	c.JSON(http.StatusOK, gin.H{"token": token, "expires_in": int(progressTTL.Seconds())})
+}
+
+// trackProgress starts tracking a download of total bytes (-1 when unknown)
+// under token
+func trackProgress(token string, total int64) *downloadProgress {
+	progress := &downloadProgress{total: total, started: time.Now()}
+	progressEntries.Store(token, progress)
+	return progress
+}
+
+// Reader wraps body so the bytes read from it are counted as sent
+func (p *downloadProgress) Reader(body io.Reader) io.Reader {
+	return &progressReader{Reader: body, progress: p}
+}
+
+// finish marks the download as done, complete or not, and forgets it after
+// progressTTL
+func (p *downloadProgress) finish(token string) {
+	p.done.Store(true)
+	p.failed.Store(p.total >= 0 && p.sent.Load() < p.total)
+	time.AfterFunc(progressTTL, func() { progressEntries.CompareAndDelete(token, p) })
+}
+
+type progressReader struct {
+	io.Reader
+	progress *downloadProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.progress.sent.Add(int64(n))
+	return n, err
+}
+
+// DownloadProgressHandler handles /download-progress/:token: how much of a
+// streamed download has been sent, its size and average rate
+func (h *HandlerContext) DownloadProgressHandler(c *gin.Context) {
+	value, ok := progressEntries.Load(c.Param("token"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown progress token"})
+		return
+	}
+	progress := value.(*downloadProgress)
+
+	sent := progress.sent.Load()
+	elapsed := time.Since(progress.started).Seconds()
+	body := gin.H{
+		"sent":            sent,
+		"done":            progress.done.Load(),
+		"failed":          progress.failed.Load(),
+		"elapsed_seconds": int64(elapsed),
+	}
+	if elapsed > 0 {
+		body["bytes_per_second"] = int64(float64(sent) / elapsed)
+	}
+	if progress.total >= 0 {
+		body["total"] = progress.total
+		if progress.total > 0 {
+			body["percent"] = float64(sent*1000/progress.total) / 10
+		}
+	}
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// rangeRequestHeaders are the client headers forwarded to the source CDN so
+// interrupted downloads can resume
+var rangeRequestHeaders = []string{"Range", "If-Range"}
+
+// forwardRangeHeaders copies the client's range headers onto an upstream request
+func forwardRangeHeaders(dst *http.Request, src http.Header) {
+	for _, name := range rangeRequestHeaders {
+		if value := src.Get(name); value != "" {
+			dst.Header.Set(name, value)
+		}
+	}
+}
+
+// relayableStatus reports whether an upstream status can be relayed to the
+// client: a full body, a partial one, or an unsatisfiable range
+func relayableStatus(status int) bool {
+	return status == http.StatusOK || status == http.StatusPartialContent || status == http.StatusRequestedRangeNotSatisfiable
+}
+
+// copyRangeHeaders relays the upstream range headers to the client
+func copyRangeHeaders(dst http.Header, src http.Header) {
+	if strings.EqualFold(src.Get("Accept-Ranges"), "bytes") {
+		dst.Set("Accept-Ranges", "bytes")
+	}
+	if contentRange := src.Get("Content-Range"); contentRange != "" {
+		dst.Set("Content-Range", contentRange)
+	}
+}
+
+// startsDownload reports whether a relayed response is a download of its own:
+// a full body, or a partial one from the first byte. Later ranges continue a
+// download counted already, and unsatisfiable ranges serve nothing.
+func startsDownload(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true
+	case http.StatusPartialContent:
+		return strings.HasPrefix(resp.Header.Get("Content-Range"), "bytes 0-")
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+)
+
+// regionRetryEnabled reports whether an alternate region profile is configured
+func (h *HandlerContext) regionRetryEnabled() bool {
+	return h.Config.RegionRetryAPIURL != "" || h.Config.RegionRetryProxy != ""
+}
+
+// regionRetry re-resolves a post through the alternate hybrid API and fetches
+// the same media again, through the alternate proxy when one is set, carrying
+// over the client's range headers
+func (h *HandlerContext) regionRetry(ctx context.Context, downloadData models.DownloadData, clientHeader http.Header) (*http.Response, error) {
+	if downloadData.Source == "" || downloadData.Key == "" {
+		return nil, fmt.Errorf("download link does not identify its post")
+	}
+
+	endpoint := h.Config.RegionRetryAPIURL
+	if endpoint == "" {
+		endpoint = utils.HybridEndpoint()
+	}
+
+	data, err := utils.FetchHybridDataFrom(ctx, endpoint, downloadData.Source, true)
+	if err != nil {
+		return nil, err
+	}
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid data format")
+	}
+
+	mediaURL := utils.ResolveMediaURL(videoData, downloadData.Key, downloadData.Index)
+	if mediaURL == "" {
+		return nil, fmt.Errorf("media %s not found in re-resolved post", downloadData.Key)
+	}
+
+	transport := utils.NewUpstreamTransport()
+	if h.Config.RegionRetryProxy != "" {
+		proxyURL, err := url.Parse(h.Config.RegionRetryProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid region retry proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	forwardRangeHeaders(req, clientHeader)
+	client := &http.Client{Timeout: 60 * time.Second, Transport: utils.WrapSourceTransport(transport)}
+	return client.Do(req)
+}
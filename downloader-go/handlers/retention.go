@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fingerprintPattern matches client fingerprints produced by clientFingerprintFor
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// retentionSweepInterval is how often expired records are purged
+const retentionSweepInterval = time.Hour
+
+// clientFingerprint identifies the requesting client without storing its IP
+func (h *HandlerContext) clientFingerprint(c *gin.Context) string {
+	return h.clientFingerprintFor(c.ClientIP())
+}
+
+// clientFingerprintFor returns a keyed hash of a client IP, so records can be
+// matched to a client on request without keeping the address itself
+func (h *HandlerContext) clientFingerprintFor(ip string) string {
+	mac := hmac.New(sha256.New, []byte(h.Config.EncryptionKey))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// PurgeClientDataHandler deletes every record derived from a client, given
+// either its IP address or its fingerprint
+func (h *HandlerContext) PurgeClientDataHandler(c *gin.Context) {
+	client := c.Query("client")
+	switch {
+	case net.ParseIP(client) != nil:
+		client = h.clientFingerprintFor(client)
+	case !fingerprintPattern.MatchString(client):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "client must be an IP address or client fingerprint"})
+		return
+	}
+
+	purgedJobs := h.Jobs.PurgeClient(client)
+	purgedStates := h.purgeArchiveJobStates(client, time.Time{})
+	purgedDiagnostics := utils.PurgeDiagnostics(h.Config.DiagnosticsDir, client, time.Time{})
+	log.Printf("Purged data for client %s: %d jobs, %d job states, %d diagnostics", client, len(purgedJobs), purgedStates, purgedDiagnostics)
+
+	c.JSON(http.StatusOK, gin.H{
+		"client":      client,
+		"jobs":        len(purgedJobs),
+		"job_states":  purgedStates,
+		"diagnostics": purgedDiagnostics,
+	})
+}
+
+// RunRetention purges job and diagnostics records older than the retention
+// period until ctx is done
+func (h *HandlerContext) RunRetention(ctx context.Context) {
+	if h.Config.DataRetention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(retentionSweepInterval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().Add(-h.Config.DataRetention)
+		jobs := h.Jobs.PurgeFinishedBefore(cutoff)
+		states := h.purgeArchiveJobStates("", cutoff)
+		diagnostics := utils.PurgeDiagnostics(h.Config.DiagnosticsDir, "", cutoff)
+		if jobs+states+diagnostics > 0 {
+			log.Printf("Retention purged %d jobs, %d job states, %d diagnostics", jobs, states, diagnostics)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeArchiveJobStates deletes persisted archive job states that belong to
+// client (when not empty) or finished before cutoff (when not zero)
+func (h *HandlerContext) purgeArchiveJobStates(client string, cutoff time.Time) int {
+	paths, err := filepath.Glob(filepath.Join(h.Config.ArchiveDir, archiveJobsDir, "*.json"))
+	if err != nil {
+		return 0
+	}
+
+	purged := 0
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var state archiveJobState
+		if json.Unmarshal(raw, &state) != nil {
+			continue
+		}
+
+		expired := false
+		if !cutoff.IsZero() && state.Finished {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		if (expired || (client != "" && state.Client == client)) && os.Remove(path) == nil {
+			purged++
+		}
+	}
+	return purged
+}
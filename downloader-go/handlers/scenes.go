@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scene detection defaults and bounds
+const (
+	defaultSceneThreshold = 0.3
+	maxSceneClips         = 100
+)
+
+// ScenesHandler handles /scenes: it downloads the no-watermark video of a
+// post and returns the timestamps of its scenes, or with ?clips=true a ZIP
+// of every scene cut into its own MP4 plus scenes.json. ?threshold= (0.05
+// to 0.95) sets how different frames must be to start a new scene.
+func (h *HandlerContext) ScenesHandler(c *gin.Context) {
+	urlParam := c.Query("url")
+	if urlParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
+		return
+	}
+
+	threshold := defaultSceneThreshold
+	if thresholdParam := c.Query("threshold"); thresholdParam != "" {
+		value, err := strconv.ParseFloat(thresholdParam, 64)
+		if err != nil || !(value >= 0.05 && value <= 0.95) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be between 0.05 and 0.95"})
+			return
+		}
+		threshold = value
+	}
+	clips := c.Query("clips") == "true"
+
+	sourceURL, err := utils.VerifyLink(urlParam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting URL: " + err.Error()})
+		return
+	}
+	tenant := verifyTenant(c.Query("tenant"))
+	ctx := c.Request.Context()
+
+	videoData, videoURL, ok := h.resolvePostVideo(c, sourceURL, "split into scenes")
+	if !ok {
+		return
+	}
+
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, "scenes", utils.GetAwemeID(videoData))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()})
+		return
+	}
+	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+
+	videoPath := filepath.Join(tempDir, "video.mp4")
+	if err := utils.DownloadFile(ctx, videoURL, videoPath); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error downloading video: " + err.Error()})
+		return
+	}
+
+	// Detect, and cut, once a render worker is free
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	zipPath := filepath.Join(tempDir, "scenes.zip")
+	var scenes []utils.Scene
+	var sceneErr error
+	err = h.RenderQueue.Do(renderCtx, filepath.Base(tempDir), h.renderPriority(c), func() {
+		scenes, sceneErr = utils.DetectScenes(renderCtx, videoPath, threshold)
+		if sceneErr != nil || !clips || len(scenes) > maxSceneClips {
+			return
+		}
+
+		clipPaths := make([]string, len(scenes))
+		for i, scene := range scenes {
+			clipPaths[i] = filepath.Join(tempDir, fmt.Sprintf("scene_%03d.mp4", scene.Index+1))
+			if sceneErr = utils.CutScene(renderCtx, videoPath, clipPaths[i], scene); sceneErr != nil {
+				return
+			}
+		}
+		sceneErr = utils.WriteSceneArchive(zipPath, scenes, clipPaths)
+	})
+	if err == nil {
+		err = sceneErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error detecting scenes: " + err.Error()})
+		return
+	}
+
+	if !clips {
+		c.JSON(http.StatusOK, gin.H{"count": len(scenes), "scenes": scenes})
+		return
+	}
+	if len(scenes) > maxSceneClips {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Video has %d scenes, more than the %d that can be cut into clips; raise the threshold", len(scenes), maxSceneClips),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_scenes.zip", renderFilenameBase(videoData))
+	h.serveLocalFile(c, zipPath, filename)
+	h.accountDownload(tenant, h.servedBytes(c, zipPath))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaMapping is the outcome of running the response mapping on a payload
+type schemaMapping struct {
+	Error        string   `json:"error,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+	DownloadKeys []string `json:"download_keys"`
+}
+
+// schemaModeReport is the schema diff of one hybrid API payload mode
+type schemaModeReport struct {
+	Error   string              `json:"error,omitempty"`
+	Type    string              `json:"type,omitempty"`
+	Missing []utils.SchemaField `json:"missing,omitempty"`
+	Present []string            `json:"present,omitempty"`
+	Mapping *schemaMapping      `json:"mapping,omitempty"`
+}
+
+// SchemaDiffHandler fetches a post through both the minimal and the full
+// hybrid API payloads, bypassing the cache, and reports the fields the
+// response mapping expects that each payload lacks, with the similarly named
+// fields they may have been renamed to, and what the mapping makes of each.
+// Posts of extractor platforms are diffed from their extractor's payload.
+func (h *HandlerContext) SchemaDiffHandler(c *gin.Context) {
+	sourceURL := c.Query("url")
+	if sourceURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
+		return
+	}
+	if !utils.IsSupportedURL(sourceURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.UnsupportedURLMessage()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Extractors have one payload, shaped like the minimal one
+	if extractor := utils.ExtractorFor(sourceURL); extractor != nil {
+		report := schemaModeReport{}
+		if data, err := extractor.Fetch(ctx, h.Config, sourceURL); err != nil {
+			report.Error = err.Error()
+		} else {
+			report, _ = h.schemaPayloadDiff(data, sourceURL, true, "")
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"url":       sourceURL,
+			"extractor": extractor.Platform(),
+			"modes":     gin.H{"extractor": report},
+		})
+		return
+	}
+
+	sourceURL = utils.ResolveShareURL(ctx, sourceURL)
+	endpoint := utils.HybridEndpoint()
+	minimal, minimalType := h.schemaModeDiff(ctx, endpoint, sourceURL, true, "")
+	full, _ := h.schemaModeDiff(ctx, endpoint, sourceURL, false, minimalType)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":      sourceURL,
+		"endpoint": endpoint,
+		"modes": gin.H{
+			"minimal": minimal,
+			"full":    full,
+		},
+	})
+}
+
+// schemaModeDiff fetches one payload mode and diffs it. The post type comes
+// from the payload, or postType when the payload doesn't say (the full
+// payload has no type field).
+func (h *HandlerContext) schemaModeDiff(ctx context.Context, endpoint, sourceURL string, minimal bool, postType string) (schemaModeReport, string) {
+	data, err := utils.FetchHybridDataFrom(ctx, endpoint, sourceURL, minimal)
+	if err != nil {
+		return schemaModeReport{Error: err.Error()}, postType
+	}
+	return h.schemaPayloadDiff(data, sourceURL, minimal, postType)
+}
+
+// schemaPayloadDiff diffs a fetched payload against the fields the mapping
+// expects and runs it through the mapping
+func (h *HandlerContext) schemaPayloadDiff(data map[string]interface{}, sourceURL string, minimal bool, postType string) (schemaModeReport, string) {
+	videoData, _ := data["data"].(map[string]interface{})
+	if typeVal, ok := videoData["type"].(string); ok && typeVal != "" {
+		postType = typeVal
+	}
+	if postType == "" {
+		postType = "video"
+	}
+
+	diff := utils.DiffSchema(data, utils.ExpectedSchemaFields(minimal, postType))
+	report := schemaModeReport{Type: postType, Missing: diff.Missing, Present: diff.Present}
+
+	// The full payload goes through the mapping the way the full payload
+	// fallback feeds it, with video_data derived from its video object
+	if !minimal && videoData != nil && postType != "image" {
+		videoData["video_data"] = utils.FullVideoData(videoData)
+		videoData["type"] = postType
+	}
+	mapping := &schemaMapping{DownloadKeys: []string{}}
+	response, err := generateJSONResponse(data, sourceURL, "", 0, h.Config)
+	if err != nil {
+		mapping.Error = err.Error()
+	}
+	for _, warning := range response.Warnings {
+		mapping.Warnings = append(mapping.Warnings, warning.Code+": "+warning.Message)
+	}
+	for key := range response.DownloadLink {
+		mapping.DownloadKeys = append(mapping.DownloadKeys, key)
+	}
+	sort.Strings(mapping.DownloadKeys)
+	report.Mapping = mapping
+	return report, postType
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sendfile modes for handing local files to a front proxy
+const (
+	SendfileOff       = "off"
+	SendfileXAccel    = "x-accel"
+	SendfileXSendfile = "x-sendfile"
+)
+
+// ValidateSendfileMode rejects unknown SENDFILE_MODE values
+func ValidateSendfileMode(mode string) error {
+	switch mode {
+	case "", SendfileOff, SendfileXAccel, SendfileXSendfile:
+		return nil
+	}
+	return fmt.Errorf("unknown sendfile mode %q, expected off, x-accel or x-sendfile", mode)
+}
+
+// serveLocalFile sends a file from the temp directory, either directly or by
+// telling nginx (X-Accel-Redirect) or Apache/lighttpd (X-Sendfile) to send it.
+// A non-empty attachmentName makes the response a download.
+func (h *HandlerContext) serveLocalFile(c *gin.Context, path, attachmentName string) {
+	if attachmentName != "" {
+		c.Header("Content-Disposition", utils.AttachmentDisposition(attachmentName))
+	}
+
+	mode := h.Config.SendfileMode
+	if mode == "" || mode == SendfileOff {
+		c.File(path)
+		return
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+
+	switch mode {
+	case SendfileXAccel:
+		rel, err := filepath.Rel(h.Config.TempDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "File is outside the temp directory"})
+			return
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		c.Header("X-Accel-Redirect", strings.TrimRight(h.Config.AccelRedirectPrefix, "/")+"/"+strings.Join(segments, "/"))
+	case SendfileXSendfile:
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error resolving file path: " + err.Error()})
+			return
+		}
+		c.Header("X-Sendfile", abs)
+	}
+	c.Status(http.StatusOK)
+}
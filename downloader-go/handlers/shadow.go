@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+)
+
+// shadowTimeout bounds one mirrored request to the fiber variant
+const shadowTimeout = time.Minute
+
+// shadowSlots caps the mirrored requests in flight; samples beyond it are
+// skipped so a slow fiber instance cannot pile up goroutines
+var shadowSlots = make(chan struct{}, 8)
+
+func init() {
+	metrics.Register("tikdownloader_shadow_requests_total", "Requests mirrored to the fiber variant, by result.", metrics.Counter)
+	metrics.Register("tikdownloader_shadow_latency_ms_total", "Summed /tiktok latency of mirrored requests, by variant.", metrics.Counter)
+}
+
+// shadowSampled reports whether a /tiktok request is mirrored to the fiber
+// variant. Only plain TikTok and Douyin post lookups are, as the fiber
+// variant knows no sound pages, extractor platforms, metadata-only responses
+// or field selection, and guest trimming, translation and debug output would
+// show up as differences that aren't.
+func (h *HandlerContext) shadowSampled(sourceURL string, opts tiktokOptions) bool {
+	if h.Config.ShadowURL == "" || h.Config.ShadowPercent <= 0 {
+		return false
+	}
+	if utils.IsMusicURL(sourceURL) || utils.ExtractorFor(sourceURL) != nil || opts.MetadataOnly || opts.Fields != nil {
+		return false
+	}
+	if opts.Guest || opts.Translate != "" || opts.Debug {
+		return false
+	}
+	return rand.Intn(100) < h.Config.ShadowPercent
+}
+
+// shadowTikTok sends a served /tiktok request to the fiber variant and logs
+// how its status, response and latency differ from ours. The comparison is in
+// the Node.js schema the fiber variant serves, as the parity command does.
+func (h *HandlerContext) shadowTikTok(sourceURL string, status int, body interface{}, latency time.Duration) {
+	select {
+	case shadowSlots <- struct{}{}:
+		defer func() { <-shadowSlots }()
+	default:
+		metrics.Inc("tikdownloader_shadow_requests_total", metrics.Labels{"result": "skipped"})
+		return
+	}
+
+	start := time.Now()
+	fiberStatus, fiberBody, err := fetchShadowResponse(strings.TrimRight(h.Config.ShadowURL, "/")+"/tiktok", sourceURL)
+	fiberLatency := time.Since(start)
+	if err != nil {
+		logging.Warnf("Shadow %s: fiber: %v", logging.RedactURL(sourceURL), err)
+		metrics.Inc("tikdownloader_shadow_requests_total", metrics.Labels{"result": "error"})
+		return
+	}
+	metrics.Add("tikdownloader_shadow_latency_ms_total", metrics.Labels{"variant": "go"}, float64(latency.Milliseconds()))
+	metrics.Add("tikdownloader_shadow_latency_ms_total", metrics.Labels{"variant": "fiber"}, float64(fiberLatency.Milliseconds()))
+
+	var diffs []string
+	if status != fiberStatus {
+		diffs = append(diffs, fmt.Sprintf("status: go=%d fiber=%d", status, fiberStatus))
+	} else if status == http.StatusOK {
+		switch b := body.(type) {
+		case nodeResponse:
+			diffs = diffShadowBodies(b, fiberBody)
+		case legacyResponse:
+			diffs = diffShadowBodies(nodeCompatResponse(b.TikTokResponse), fiberBody)
+		case models.TikTokResponse:
+			diffs = diffShadowBodies(nodeCompatResponse(b), fiberBody)
+		}
+	}
+
+	result := "match"
+	if len(diffs) > 0 {
+		result = "mismatch"
+	}
+	metrics.Inc("tikdownloader_shadow_requests_total", metrics.Labels{"result": result})
+	logging.Infof("Shadow %s: %s (go %dms, fiber %dms)", logging.RedactURL(sourceURL), result, latency.Milliseconds(), fiberLatency.Milliseconds())
+	for _, diff := range diffs {
+		logging.Infof("Shadow %s: %s", logging.RedactURL(sourceURL), diff)
+	}
+}
+
+// fetchShadowResponse posts a URL to the fiber variant's /tiktok and returns
+// its status and decoded body
+func fetchShadowResponse(endpoint, sourceURL string) (int, interface{}, error) {
+	payload, err := json.Marshal(map[string]string{"url": sourceURL})
+	if err != nil {
+		return 0, nil, err
+	}
+	client := &http.Client{Timeout: shadowTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	var decoded interface{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return 0, nil, err
+		}
+	}
+	return resp.StatusCode, decoded, nil
+}
+
+// diffShadowBodies compares our Node.js-schema response with the fiber one
+func diffShadowBodies(ours nodeResponse, fiber interface{}) []string {
+	raw, err := json.Marshal(ours)
+	if err != nil {
+		return []string{"response: " + err.Error()}
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return []string{"response: " + err.Error()}
+	}
+	return utils.DiffParity(decoded, fiber)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slideshowJobTimeout bounds fetching and rendering a slideshow in the background
+const slideshowJobTimeout = 10 * time.Minute
+
+// slideshowJobFile is the rendered output of a finished slideshow job
+type slideshowJobFile struct {
+	Path     string
+	Filename string
+	Tenant   string
+}
+
+// CreateSlideshowJobHandler handles POST /download-slideshow: it queues the
+// render and answers 202 with a job ID, so slow renders don't hit proxy
+// timeouts. The finished MP4 is served by GET /jobs/:id/file, and the job is
+// POSTed to the optional callback_url when it ends.
+func (h *HandlerContext) CreateSlideshowJobHandler(c *gin.Context) {
+	req, status, body := h.parseSlideshowRequest(c)
+	if body != nil {
+		c.JSON(status, body)
+		return
+	}
+
+	callbackURL := c.Query("callback_url")
+	if callbackURL != "" {
+		if err := utils.ValidateCallbackURL(callbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job := h.Jobs.Create("slideshow", req.Client)
+	// Persisted so a restart can resume or fail the job
+	state := &slideshowJobState{
+		JobID:       job.ID,
+		Client:      req.Client,
+		CreatedAt:   job.CreatedAt,
+		Request:     req,
+		CallbackURL: callbackURL,
+	}
+	if err := h.saveSlideshowJobState(state); err != nil {
+		log.Printf("Error saving slideshow job state %s: %v", job.ID, err)
+	}
+	h.submitJob(job.ID, func() { h.runSlideshowJob(state) })
+
+	h.acceptJob(c, job.ID)
+}
+
+// runSlideshowJob renders a slideshow and records where its file can be
+// fetched, then forgets the job's persisted state and notifies its callback
+func (h *HandlerContext) runSlideshowJob(state *slideshowJobState) {
+	jobID, req := state.JobID, state.Request
+	defer func() {
+		h.removeSlideshowJobState(jobID)
+		go h.notifyJobCallback(jobID, state.CallbackURL)
+	}()
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusProcessing
+	})
+	h.Jobs.Event(jobID, jobs.EventFetchStarted, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), slideshowJobTimeout)
+	slideshow, status, body := h.renderSlideshow(ctx, req)
+	cancel()
+
+	if body != nil {
+		message, _ := body["error"].(string)
+		h.Jobs.Update(jobID, func(job *jobs.Job) {
+			job.Status = jobs.StatusFailed
+			job.Error = message
+			job.Result["status_code"] = status
+			if ref, ok := body["reference_id"]; ok {
+				job.Result["reference_id"] = ref
+			}
+		})
+		h.Jobs.Event(jobID, jobs.EventFailed, "render failed with status %d", status)
+		return
+	}
+
+	// The file lives as long as the temp directory's cleanup window, unless
+	// it was offloaded to storage and is downloaded from there
+	fileURL := slideshow.URL
+	if fileURL == "" {
+		h.slideshowFiles.Store(jobID, slideshowJobFile{
+			Path:     slideshow.OutputPath,
+			Filename: slideshow.Filename,
+			Tenant:   req.Tenant,
+		})
+		time.AfterFunc(time.Hour, func() { h.slideshowFiles.Delete(jobID) })
+		fileURL = fmt.Sprintf("%s/jobs/%s/file", h.Config.BaseURL, jobID)
+	} else {
+		// Local files are accounted when JobFileHandler serves them
+		h.accountDownload(req.Tenant, slideshow.StoredBytes)
+	}
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusCompleted
+		job.Result["file_url"] = fileURL
+		job.Result["filename"] = slideshow.Filename
+		if slideshow.Outputs != nil {
+			job.Result["outputs"] = slideshow.Outputs
+		}
+		for header, value := range slideshow.Headers {
+			// X-Slideshow-Poster becomes slideshow_poster, and so on
+			key := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(header, "X-")), "-", "_")
+			job.Result[key] = value
+		}
+	})
+	h.Jobs.Event(jobID, jobs.EventCompleted, "")
+}
+
+// JobFileHandler streams the file produced by a finished slideshow job
+func (h *HandlerContext) JobFileHandler(c *gin.Context) {
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok || job.Type != "slideshow" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if job.Status != jobs.StatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has no file yet", "status": job.Status})
+		return
+	}
+
+	value, ok := h.slideshowFiles.Load(job.ID)
+	if !ok {
+		c.JSON(http.StatusGone, gin.H{"error": "File expired"})
+		return
+	}
+	file := value.(slideshowJobFile)
+	if _, err := os.Stat(file.Path); err != nil {
+		h.slideshowFiles.Delete(job.ID)
+		c.JSON(http.StatusGone, gin.H{"error": "File expired"})
+		return
+	}
+
+	h.serveLocalFile(c, file.Path, file.Filename)
+	h.accountDownload(file.Tenant, h.servedBytes(c, file.Path))
+}
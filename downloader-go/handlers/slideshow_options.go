@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slideshowOptions builds the encoder options for a slideshow request,
+// applying query overrides within the bounds set by the server config
+func (h *HandlerContext) slideshowOptions(c *gin.Context) (utils.SlideshowOptions, error) {
+	cfg := h.Config
+	opts := utils.SlideshowOptions{
+		Preset:      cfg.SlideshowPreset,
+		CRF:         cfg.SlideshowCRF,
+		FPS:         cfg.SlideshowFPS,
+		PixelFormat: cfg.SlideshowPixelFormat,
+	}
+
+	if !utils.ValidPixelFormat(opts.PixelFormat) {
+		return opts, fmt.Errorf("invalid SLIDESHOW_PIX_FMT %q", opts.PixelFormat)
+	}
+
+	// Server-wide bitrate cap, also the upper bound for request overrides
+	maxBitrate := 0
+	if cfg.SlideshowMaxBitrate != "" {
+		kbps, err := utils.ParseBitrate(cfg.SlideshowMaxBitrate)
+		if err != nil {
+			return opts, fmt.Errorf("invalid SLIDESHOW_MAX_BITRATE: %w", err)
+		}
+		maxBitrate = kbps
+		opts.MaxBitrateKbps = kbps
+	}
+
+	if preset := c.Query("preset"); preset != "" {
+		idx := utils.PresetIndex(preset)
+		if idx < 0 {
+			return opts, fmt.Errorf("unknown preset %q", preset)
+		}
+		if slowest := utils.PresetIndex(cfg.SlideshowSlowestPreset); slowest >= 0 && idx > slowest {
+			return opts, fmt.Errorf("preset %q is slower than the allowed %q", preset, cfg.SlideshowSlowestPreset)
+		}
+		opts.Preset = preset
+	}
+
+	// Output preset: resolution with a CRF and bitrate tier, which explicit
+	// crf and bitrate parameters override
+	if qualityParam := c.Query("quality"); qualityParam != "" {
+		quality, ok := utils.FindSlideshowQuality(qualityParam)
+		if !ok {
+			names := make([]string, len(utils.SlideshowQualities))
+			for i, q := range utils.SlideshowQualities {
+				names[i] = q.Name
+			}
+			return opts, fmt.Errorf("quality must be one of %s", strings.Join(names, ", "))
+		}
+		opts.Resolution = quality.Resolution
+		opts.CRF = min(max(quality.CRF, cfg.SlideshowMinCRF), cfg.SlideshowMaxCRF)
+		opts.MaxBitrateKbps = quality.MaxBitrateKbps
+		if maxBitrate > 0 {
+			opts.MaxBitrateKbps = min(opts.MaxBitrateKbps, maxBitrate)
+		}
+	}
+
+	if crfParam := c.Query("crf"); crfParam != "" {
+		crf, err := strconv.Atoi(crfParam)
+		if err != nil {
+			return opts, fmt.Errorf("invalid crf %q", crfParam)
+		}
+		if crf < cfg.SlideshowMinCRF || crf > cfg.SlideshowMaxCRF {
+			return opts, fmt.Errorf("crf must be between %d and %d", cfg.SlideshowMinCRF, cfg.SlideshowMaxCRF)
+		}
+		opts.CRF = crf
+	}
+
+	if bitrateParam := c.Query("bitrate"); bitrateParam != "" {
+		kbps, err := utils.ParseBitrate(bitrateParam)
+		if err != nil {
+			return opts, err
+		}
+		if maxBitrate > 0 && kbps > maxBitrate {
+			return opts, fmt.Errorf("bitrate must not exceed %dk", maxBitrate)
+		}
+		opts.MaxBitrateKbps = kbps
+	}
+
+	if fpsParam := c.Query("fps"); fpsParam != "" {
+		fps, err := strconv.Atoi(fpsParam)
+		if err != nil || fps < 1 || fps > cfg.SlideshowMaxFPS {
+			return opts, fmt.Errorf("fps must be between 1 and %d", cfg.SlideshowMaxFPS)
+		}
+		opts.FPS = fps
+	}
+
+	if pixFmt := c.Query("pix_fmt"); pixFmt != "" {
+		if !utils.ValidPixelFormat(pixFmt) {
+			return opts, fmt.Errorf("pix_fmt must be one of %s", strings.Join(utils.SlideshowPixelFormats, ", "))
+		}
+		opts.PixelFormat = pixFmt
+	}
+
+	// Seconds each image is shown, e.g. 1.5 for a faster slideshow
+	if durationParam := c.Query("duration"); durationParam != "" {
+		seconds, err := strconv.ParseFloat(durationParam, 64)
+		if err != nil || !(seconds >= float64(cfg.SlideshowMinImageSeconds) && seconds <= float64(cfg.SlideshowMaxImageSeconds)) {
+			return opts, fmt.Errorf("duration must be between %d and %d seconds", cfg.SlideshowMinImageSeconds, cfg.SlideshowMaxImageSeconds)
+		}
+		opts.ImageSeconds = seconds
+	}
+
+	// Blend between images instead of hard cuts, as TikTok's photo mode does
+	if transition := c.Query("transition"); transition != "" {
+		if !utils.ValidTransition(transition) {
+			return opts, fmt.Errorf("transition must be one of %s", strings.Join(utils.SlideshowTransitions, ", "))
+		}
+		opts.Transition = transition
+	}
+
+	// Target file size, e.g. 50 for Telegram or 16 for WhatsApp
+	if sizeParam := c.Query("max_size_mb"); sizeParam != "" {
+		sizeMB, err := strconv.ParseFloat(sizeParam, 64)
+		if err != nil || sizeMB < 1 || sizeMB > 2048 {
+			return opts, fmt.Errorf("max_size_mb must be between 1 and 2048")
+		}
+		opts.MaxSizeBytes = int64(sizeMB * 1024 * 1024)
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/utils"
+)
+
+// Ways to recover slideshow jobs interrupted by a restart
+const (
+	SlideshowRecoveryResume = "resume"
+	SlideshowRecoveryFail   = "fail"
+)
+
+// slideshowJobMaxResumes bounds how often an interrupted job is rendered
+// again, so a render that takes the process down can't crash-loop it
+const slideshowJobMaxResumes = 1
+
+// slideshowJobState is what is persisted of a slideshow job until it ends
+type slideshowJobState struct {
+	JobID       string           `json:"job_id"`
+	Client      string           `json:"client"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Request     slideshowRequest `json:"request"`
+	CallbackURL string           `json:"callback_url,omitempty"`
+	Resumes     int              `json:"resumes"`
+}
+
+// jobWebhook is the body POSTed to a job's callback_url when it ends
+type jobWebhook struct {
+	JobID  string                 `json:"job_id"`
+	Type   string                 `json:"type"`
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Result map[string]interface{} `json:"result,omitempty"`
+}
+
+// RecoverSlideshowJobs finds the slideshow jobs a restart interrupted and,
+// per SLIDESHOW_JOB_RECOVERY, queues them again or marks them failed,
+// notifying their callback URLs of failures
+func (h *HandlerContext) RecoverSlideshowJobs() {
+	paths, err := filepath.Glob(filepath.Join(h.Config.JobStateDir, "slideshow_*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		state := &slideshowJobState{}
+		if err := json.Unmarshal(raw, state); err != nil || state.JobID == "" {
+			log.Printf("Skipping unreadable slideshow job state %s: %v", path, err)
+			continue
+		}
+
+		h.Jobs.Restore(jobs.Job{
+			ID:        state.JobID,
+			Type:      "slideshow",
+			Status:    jobs.StatusQueued,
+			Client:    state.Client,
+			CreatedAt: state.CreatedAt,
+			UpdatedAt: time.Now(),
+		})
+
+		if h.Config.SlideshowJobRecovery == SlideshowRecoveryResume && state.Resumes < slideshowJobMaxResumes {
+			state.Resumes++
+			if err := h.saveSlideshowJobState(state); err != nil {
+				log.Printf("Error saving slideshow job state %s: %v", state.JobID, err)
+			}
+			log.Printf("Resuming slideshow job %s interrupted by a restart", state.JobID)
+			h.Jobs.Event(state.JobID, "resumed", "rendering again after a restart")
+			h.submitJob(state.JobID, func() { h.runSlideshowJob(state) })
+			continue
+		}
+
+		log.Printf("Failing slideshow job %s interrupted by a restart", state.JobID)
+		h.Jobs.Update(state.JobID, func(job *jobs.Job) {
+			job.Status = jobs.StatusFailed
+			job.Error = "interrupted by a server restart"
+			job.Result["status_code"] = http.StatusServiceUnavailable
+		})
+		h.Jobs.Event(state.JobID, jobs.EventFailed, "interrupted by a server restart")
+		h.removeSlideshowJobState(state.JobID)
+		go h.notifyJobCallback(state.JobID, state.CallbackURL)
+	}
+}
+
+// saveSlideshowJobState atomically writes a slideshow job's state
+func (h *HandlerContext) saveSlideshowJobState(state *slideshowJobState) error {
+	if err := os.MkdirAll(h.Config.JobStateDir, os.ModePerm); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(h.Config.JobStateDir, "slideshow_"+state.JobID+".json")
+	if err := os.WriteFile(path+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// removeSlideshowJobState forgets a slideshow job that has ended
+func (h *HandlerContext) removeSlideshowJobState(jobID string) {
+	path := filepath.Join(h.Config.JobStateDir, "slideshow_"+jobID+".json")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing slideshow job state %s: %v", jobID, err)
+	}
+}
+
+// notifyJobCallback POSTs a finished job to its callback URL, if any
+func (h *HandlerContext) notifyJobCallback(jobID, callbackURL string) {
+	if callbackURL == "" {
+		return
+	}
+	job, ok := h.Jobs.Get(jobID)
+	if !ok {
+		return
+	}
+
+	h.Jobs.Event(jobID, "callback_started", "")
+	ctx, cancel := context.WithTimeout(context.Background(), asyncTikTokTimeout)
+	defer cancel()
+	err := utils.PostWebhook(ctx, callbackURL, jobWebhook{
+		JobID:  job.ID,
+		Type:   job.Type,
+		Status: job.Status,
+		Error:  job.Error,
+		Result: job.Result,
+	}, h.Config.WebhookSecret, h.Config.WebhookAllowPrivate)
+	if err != nil {
+		h.Jobs.Event(jobID, "callback_failed", "callback delivery failed: %v", err)
+		return
+	}
+	h.Jobs.Event(jobID, "callback_delivered", "")
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"tiktok-downloader/utils"
+)
+
+// offloadSlideshow uploads a rendered slideshow, its multi-aspect outputs and
+// its previews to remote storage and swaps their links for the storage URLs.
+// The links are only swapped once every upload succeeded, so on error the
+// slideshow can still be served locally.
+func (h *HandlerContext) offloadSlideshow(ctx context.Context, slideshow *renderedSlideshow, outputPaths []string) error {
+	// Objects are grouped by render, under the temp directory's name
+	prefix := filepath.Base(slideshow.TempDir) + "/"
+	put := func(path, contentType, filename string) (string, error) {
+		return h.Storage.Put(ctx, prefix+filepath.Base(path), path, contentType, filename)
+	}
+
+	var link string
+	var outputs map[string]string
+	var size int64
+	if slideshow.Outputs != nil {
+		outputs = make(map[string]string, len(outputPaths))
+		for i, aspect := range utils.SlideshowAspects {
+			url, err := put(outputPaths[i], "video/mp4", filepath.Base(outputPaths[i]))
+			if err != nil {
+				return err
+			}
+			outputs[aspect.Name] = url
+			size += fileSize(outputPaths[i])
+		}
+		// The first aspect stands in for the MP4, as in renderSlideshow
+		link = outputs[utils.SlideshowAspects[0].Name]
+	} else {
+		url, err := put(slideshow.OutputPath, "video/mp4", slideshow.Filename)
+		if err != nil {
+			return err
+		}
+		link = url
+		size = fileSize(slideshow.OutputPath)
+	}
+
+	headers := make(map[string]string, len(slideshow.Headers))
+	for header, value := range slideshow.Headers {
+		headers[header] = value
+	}
+	previews := map[string]struct{ name, contentType string }{
+		"X-Slideshow-Poster":  {slideshowPosterFile, "image/jpeg"},
+		"X-Slideshow-Preview": {slideshowPreviewFile, "video/mp4"},
+	}
+	for header, preview := range previews {
+		if _, ok := headers[header]; !ok {
+			continue
+		}
+		url, err := put(filepath.Join(slideshow.TempDir, preview.name), preview.contentType, "")
+		if err != nil {
+			return err
+		}
+		headers[header] = url
+	}
+
+	slideshow.URL = link
+	slideshow.StoredBytes = size
+	if outputs != nil {
+		slideshow.Outputs = outputs
+	}
+	slideshow.Headers = headers
+	return nil
+}
+
+// fileSize returns the size of a file, or 0 when it can't be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -1,86 +1,395 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
-	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"tiktok-downloader/cache"
 	"tiktok-downloader/config"
+	"tiktok-downloader/cookies"
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/logging"
+	"tiktok-downloader/middleware"
 	"tiktok-downloader/models"
+	"tiktok-downloader/queue"
+	"tiktok-downloader/usage"
 	"tiktok-downloader/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // HandlerContext holds dependencies for handlers
 type HandlerContext struct {
-	Config *config.AppConfig
+	Config        *config.AppConfig
+	Jobs          *jobs.Store
+	AudioCache    *utils.AudioCache
+	Cookies       *cookies.Manager
+	JobQueue      *queue.Queue
+	RenderQueue   *queue.Queue
+	MediaCache    *utils.MediaCache
+	Storage       utils.Storage
+	Translator    utils.Translator
+	ResponseCache cache.Cache
+	Usage         *usage.Ledger
+
+	// slideshowFiles maps finished slideshow job IDs to their slideshowJobFile
+	slideshowFiles sync.Map
 }
 
+// jsonpCallbackPattern restricts JSONP callbacks to plain JavaScript identifiers
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.]{0,63}$`)
+
 // TikTokHandler handles the TikTok endpoint
 func (h *HandlerContext) TikTokHandler(c *gin.Context) {
 	var req models.TikTokRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindTikTokRequest(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
 
+	h.processTikTok(c, req)
+}
+
+// bindTikTokRequest reads the request from an HTML form submission or, for
+// any other content type, from a JSON body
+func bindTikTokRequest(c *gin.Context, req *models.TikTokRequest) error {
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm:
+		// curl -d labels JSON bodies as form data; keep accepting those
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+			return binding.JSON.BindBody(body, req)
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return c.ShouldBindWith(req, binding.Form)
+	case binding.MIMEMultipartPOSTForm:
+		return c.ShouldBindWith(req, binding.FormMultipart)
+	default:
+		return c.ShouldBindJSON(req)
+	}
+}
+
+// TikTokQueryHandler handles GET /tiktok?url=... for userscripts and browser
+// extensions, optionally wrapping the response in a JSONP callback
+func (h *HandlerContext) TikTokQueryHandler(c *gin.Context) {
+	if callback := c.Query("callback"); callback != "" {
+		if !h.Config.JSONPEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSONP is disabled on this server"})
+			return
+		}
+		if !jsonpCallbackPattern.MatchString(callback) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSONP callback name"})
+			return
+		}
+	}
+
+	h.processTikTok(c, models.TikTokRequest{URL: c.Query("url"), AwemeID: c.Query("aweme_id"), Platform: c.Query("platform")})
+}
+
+// tiktokOptions are the per-request settings that shape a /tiktok response
+type tiktokOptions struct {
+	Verify bool
+	Compat string
+	Fields []string
+	Client string
+	Tenant string
+
+	// MusicCursor and MusicCount page the post list of a sound page
+	MusicCursor int64
+	MusicCount  int
+
+	// MetadataOnly skips media resolution, see resolveMetadata
+	MetadataOnly bool
+
+	// BudgetResets is when the spent egress budget resets, for responses
+	// forced to metadata only until then
+	BudgetResets time.Time
+
+	// Translate adds the description translated to this language
+	Translate string
+
+	// Debug adds the timing and resolver breakdown of the response
+	Debug bool
+
+	// Guest trims the response for callers without an API key, see
+	// restrictGuestResponse
+	Guest bool
+
+	// LinkTTL is how long the response's links stay valid, in seconds
+	LinkTTL int
+}
+
+// processTikTok resolves a TikTok/Douyin URL and writes the response
+func (h *HandlerContext) processTikTok(c *gin.Context, req models.TikTokRequest) {
+	// Anonymous callers need a signed request when INFO_SIGNING_KEY is set
+	subject := req.URL
+	if subject == "" {
+		subject = req.AwemeID
+	}
+	if err := h.verifySignedRequest(c, subject); err != nil {
+		h.respond(c, http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Posts may be given by ID instead of URL
+	if req.URL == "" && req.AwemeID != "" {
+		postURL, err := utils.PostURLForID(req.Platform, req.AwemeID)
+		if err != nil {
+			h.respond(c, http.StatusBadRequest, gin.H{"error": "Invalid aweme_id: " + err.Error()})
+			return
+		}
+		req.URL = postURL
+	}
+
 	// Validate URL
 	if req.URL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
+		h.respond(c, http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
 		return
 	}
 
-	// Check if URL is from TikTok or Douyin
-	if !strings.Contains(req.URL, "tiktok.com") && !strings.Contains(req.URL, "douyin.com") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only TikTok and Douyin URLs are supported"})
+	// Check if URL is from TikTok, Douyin or a platform with an extractor
+	if !utils.IsSupportedURL(req.URL) {
+		h.respond(c, http.StatusBadRequest, gin.H{"error": utils.UnsupportedURLMessage()})
 		return
 	}
 
-	// Fetch data from the hybrid API
-	apiURL := fmt.Sprintf("%s?url=%s&minimal=true", h.Config.HybridAPIURL, url.QueryEscape(req.URL))
-	httpClient := &http.Client{Timeout: 30 * time.Second}
+	opts := tiktokOptions{
+		Verify:       req.Verify || c.Query("verify") == "true",
+		Debug:        req.Debug || c.Query("debug") == "true",
+		Client:       h.clientFingerprint(c),
+		Tenant:       h.tenantForRequest(c),
+		MetadataOnly: req.MetadataOnly || c.Query("metadata_only") == "true",
+		Guest:        middleware.IsGuest(c),
+	}
 
-	resp, err := httpClient.Get(apiURL)
+	// Optionally trim the response to the requested top-level fields
+	fields, err := requestedFields(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data: " + err.Error()})
+		h.respond(c, http.StatusBadRequest, gin.H{"error": "Invalid fields parameter: " + err.Error()})
 		return
 	}
-	defer resp.Body.Close()
+	opts.Fields = fields
 
-	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("External API returned error: %d", resp.StatusCode),
-		})
+	// Links live DOWNLOAD_LINK_TTL unless the caller asks otherwise
+	opts.LinkTTL, err = h.linkTTL(c, req)
+	if err != nil {
+		h.respond(c, http.StatusBadRequest, gin.H{"error": "Invalid link_ttl parameter: " + err.Error()})
 		return
 	}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error parsing response: " + err.Error()})
+	// Optionally match the schema of the original Node.js implementation
+	opts.Compat, err = h.compatMode(c)
+	if err != nil {
+		h.respond(c, http.StatusBadRequest, gin.H{"error": "Invalid compat parameter: " + err.Error()})
+		return
+	}
+
+	// Optionally translate the description
+	opts.Translate = req.Translate
+	if opts.Translate == "" {
+		opts.Translate = c.Query("translate")
+	}
+	if opts.Translate != "" {
+		if err := h.checkTranslation(opts.Translate); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errTranslationDisabled) {
+				status = http.StatusServiceUnavailable
+			}
+			h.respond(c, status, gin.H{"error": "Invalid translate parameter: " + err.Error()})
+			return
+		}
+	}
+
+	// Share short links are followed here, so sound pages behind them are
+	// recognised and the response cache sees the post URL
+	req.URL = utils.ResolveShareURL(c.Request.Context(), req.URL)
+
+	if utils.IsMusicURL(req.URL) {
+		opts.MusicCursor, opts.MusicCount, err = musicPaging(c, req)
+		if err != nil {
+			h.respond(c, http.StatusBadRequest, gin.H{"error": "Invalid paging parameter: " + err.Error()})
+			return
+		}
+	}
+
+	// Resolve in the background and deliver the response by webhook
+	if req.Async || c.Query("async") == "true" {
+		h.acceptAsyncTikTok(c, req, opts)
 		return
 	}
 
+	start := time.Now()
+	status, body := h.resolveTikTok(c.Request.Context(), req.URL, opts)
+	h.respond(c, status, body)
+
+	// Compare a sample of traffic against the fiber variant
+	if h.shadowSampled(req.URL, opts) {
+		go h.shadowTikTok(req.URL, status, body, time.Since(start))
+	}
+}
+
+// linkTTL returns how long a response's links stay valid, in seconds: the
+// link_ttl query or body field, bounded by DOWNLOAD_LINK_MAX_TTL, for API
+// consumers that cache responses for longer, and DOWNLOAD_LINK_TTL otherwise
+func (h *HandlerContext) linkTTL(c *gin.Context, req models.TikTokRequest) (int, error) {
+	ttl := req.LinkTTL
+	if value := c.Query("link_ttl"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("link_ttl must be an integer number of seconds")
+		}
+		ttl = parsed
+	}
+	if ttl == 0 {
+		return h.defaultLinkTTL(), nil
+	}
+	if maxTTL := int(h.Config.DownloadLinkMaxTTL / time.Second); ttl < 1 || ttl > maxTTL {
+		return 0, fmt.Errorf("link_ttl must be between 1 and %d seconds", maxTTL)
+	}
+	return ttl, nil
+}
+
+// defaultLinkTTL is DOWNLOAD_LINK_TTL in seconds
+func (h *HandlerContext) defaultLinkTTL() int {
+	return int(h.Config.DownloadLinkTTL / time.Second)
+}
+
+// resolveTikTok fetches a post from the hybrid API and builds the response
+// body, returning the HTTP status to send it with
+func (h *HandlerContext) resolveTikTok(ctx context.Context, sourceURL string, opts tiktokOptions) (int, interface{}) {
+	// Sound pages list the sound and the posts using it instead of one post
+	if utils.IsMusicURL(sourceURL) {
+		return h.resolveMusic(ctx, sourceURL, opts)
+	}
+
+	// Past the egress budget links are disabled until the window resets
+	if exhausted, resets := utils.EgressExhausted(); exhausted {
+		opts.MetadataOnly = true
+		opts.BudgetResets = resets
+	}
+
+	// Title, author, cover and counts only, without the hybrid API when possible
+	if opts.MetadataOnly {
+		return h.resolveMetadata(ctx, sourceURL, opts)
+	}
+
+	// Fetch the post from the hybrid API, or its platform's extractor
+	upstreamStart := time.Now()
+	data, err := utils.FetchPostData(ctx, h.Config, sourceURL)
+	if err != nil {
+		return upstreamErrorResponse(err)
+	}
+
+	// Some posts come back without video URLs under minimal=true
+	utils.CompleteVideoData(ctx, h.Config, sourceURL, data)
+	upstreamTime := time.Since(upstreamStart)
+
 	// Generate JSON response
-	response, err := generateJSONResponse(data, req.URL, h.Config)
+	linksStart := time.Now()
+	response, err := generateJSONResponse(data, sourceURL, opts.Tenant, opts.LinkTTL, h.Config)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing response: " + err.Error()})
-		return
+		body := gin.H{"error": "Error processing response: " + err.Error()}
+		if ref := utils.CaptureDiagnostics(h.Config.DiagnosticsDir, sourceURL, opts.Client, data, err); ref != "" {
+			body["reference_id"] = ref
+		}
+		return http.StatusInternalServerError, body
 	}
+	h.Usage.RecordRequest(opts.Tenant)
+
+	// Serve media already in the S3 cache straight from CloudFront
+	h.applyCachedMediaLinks(ctx, &response, sourceURL)
+
+	// Deployment-specific additions and rewrites
+	runResponseHooks(ctx, sourceURL, &response)
+	h.stripDisabledFeatures(&response)
 
-	c.JSON(http.StatusOK, response)
+	if opts.Guest {
+		restrictGuestResponse(&response)
+	}
+
+	// Let integrators attribute latency without server logs
+	if opts.Debug {
+		cacheResult, _ := data[utils.HybridCacheKey].(string)
+		resolver, _ := data[utils.HybridEndpointKey].(string)
+		response.Meta = &models.ResponseMeta{
+			UpstreamMS: upstreamTime.Milliseconds(),
+			LinksMS:    time.Since(linksStart).Milliseconds(),
+			Cache:      cacheResult,
+			Resolver:   resolver,
+		}
+	}
+
+	if opts.Translate != "" {
+		h.translateResponse(ctx, &response, opts.Translate)
+	}
+
+	// Optionally check that each media URL is still reachable
+	if opts.Verify {
+		response.LinkStatus = utils.VerifyMediaLinks(ctx, response.MediaSources)
+	}
+
+	var body interface{}
+	switch opts.Compat {
+	case compatNode:
+		body = nodeCompatResponse(response)
+	case compatLegacy:
+		body = legacyResponse{TikTokResponse: response, DownloadLink: response.DownloadLink}
+	default:
+		response.Downloads = downloadOptions(response)
+		body = response
+	}
+
+	if opts.Fields != nil {
+		filtered, err := filterFields(body, opts.Fields)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": "Error filtering response: " + err.Error()}
+		}
+		return http.StatusOK, filtered
+	}
+
+	return http.StatusOK, body
 }
 
-// generateJSONResponse processes the API response data and generates a structured response
-func generateJSONResponse(data map[string]interface{}, url string, cfg *config.AppConfig) (models.TikTokResponse, error) {
+// upstreamErrorResponse maps a hybrid API failure to a status and body,
+// giving private, deleted and restricted posts their own code and status
+func upstreamErrorResponse(err error) (int, gin.H) {
+	var unavailable *utils.PostUnavailableError
+	if errors.As(err, &unavailable) {
+		return unavailable.HTTPStatus(), gin.H{"error": unavailable.Error(), "code": unavailable.Code}
+	}
+	return http.StatusInternalServerError, gin.H{"error": err.Error()}
+}
+
+// respond writes a JSON response, or JSONP when a GET request carries a callback
+func (h *HandlerContext) respond(c *gin.Context, status int, obj interface{}) {
+	if c.Request.Method == http.MethodGet && h.Config.JSONPEnabled && c.Query("callback") != "" {
+		c.JSONP(status, obj)
+		return
+	}
+	c.JSON(status, obj)
+}
+
+// generateJSONResponse processes the API response data and generates a structured
+// response whose links are issued to tenant, which may be empty, for ttl seconds
+func generateJSONResponse(data map[string]interface{}, url, tenant string, ttl int, cfg *config.AppConfig) (models.TikTokResponse, error) {
 	response := models.TikTokResponse{
 		Photos:       []models.PhotoItem{},
 		DownloadLink: make(map[string]interface{}),
 		Statistics:   models.Statistics{},
+		MediaSources: make(map[string]interface{}),
 	}
 
 	// Extract and validate data
@@ -89,6 +398,14 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 		return response, fmt.Errorf("invalid data format")
 	}
 
+	// Flag posts resolved while the primary hybrid API was down
+	if _, ok := data[utils.FallbackUpstreamKey]; ok {
+		addWarning(&response, WarnFallbackUpstream, "resolved by a fallback hybrid API instance while the primary is down")
+	}
+	if _, ok := data[utils.FullPayloadFallbackKey]; ok {
+		addWarning(&response, WarnFullPayloadFallback, "video URLs missing from the minimal payload were taken from the full payload")
+	}
+
 	// Check content type
 	isImage := false
 	if typeVal, ok := videoData["type"].(string); ok {
@@ -113,18 +430,29 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 		Avatar:    utils.GetFirstFromNestedList(author, []string{"avatar_thumb", "url_list"}, ""),
 	}
 
-	// Extract statistics
-	statistics := make(map[string]interface{})
-	if statsVal, ok := videoData["statistics"].(map[string]interface{}); ok {
-		statistics = statsVal
+	// Extract statistics, whichever names and types the platform used
+	statistics := utils.PostStatistics(videoData)
+	if statistics == nil {
+		addWarning(&response, WarnStatisticsMissing, "statistics missing, counts are reported as 0")
 	}
 
 	// Convert statistics values
+	var missing []string
+	count := func(key string) int {
+		value, ok := utils.LookupIntStat(statistics, key)
+		if !ok && statistics != nil {
+			missing = append(missing, key)
+		}
+		return value
+	}
 	response.Statistics = models.Statistics{
-		RepostCount:  utils.GetIntStat(statistics, "repost_count"),
-		CommentCount: utils.GetIntStat(statistics, "comment_count"),
-		DiggCount:    utils.GetIntStat(statistics, "digg_count"),
-		PlayCount:    utils.GetIntStat(statistics, "play_count"),
+		RepostCount:  count("repost_count"),
+		CommentCount: count("comment_count"),
+		DiggCount:    count("digg_count"),
+		PlayCount:    count("play_count"),
+	}
+	if len(missing) > 0 {
+		addWarning(&response, WarnStatisticsMissing, strings.Join(missing, ", ")+" missing, reported as 0")
 	}
 
 	// Extract music data
@@ -133,15 +461,11 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 		music = musicVal
 	} else {
 		// Handle case where music data is missing
-		log.Printf("Warning: No music data found for URL: %s", url)
+		logging.Warnf("No music data found for URL: %s", url)
 	}
 
-	musicURL := ""
-	if uri, ok := utils.GetNestedValue(music, []string{"play_url", "uri"}, "").(string); ok && uri != "" {
-		musicURL = uri
-	} else if url, ok := utils.GetNestedValue(music, []string{"play_url", "url"}, "").(string); ok {
-		musicURL = url
-	}
+	musicURL := utils.GetMusicURL(music)
+	name := postDownloadName(videoData, authorNickname)
 
 	// Build basic metadata
 	response.Title = fmt.Sprintf("%v", utils.GetNestedValue(videoData, []string{"desc"}, ""))
@@ -149,6 +473,9 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 	response.Artist = authorNickname
 	response.Cover = utils.GetFirstFromNestedList(videoData, []string{"cover_data", "cover", "url_list"}, "")
 	response.Audio = musicURL
+	if response.Cover == "" {
+		addWarning(&response, WarnCoverMissing, "cover image unavailable")
+	}
 	
 	// Duration
 	if durVal, ok := videoData["duration"].(float64); ok {
@@ -159,23 +486,52 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 	if musicDurVal, ok := music["duration"].(float64); ok {
 		response.MusicDuration = int(musicDurVal)
 	}
+	if len(music) > 0 {
+		response.Music = buildMusicInfo(music, response.MusicDuration)
+	}
 
 	// Process MP3 download link
-	mp3Link := utils.GenerateEncryptedDownloadLink(
-		musicURL, authorNickname, "mp3", cfg, 360,
-	)
+	mp3Link := downloadLink(musicURL, name, "mp3", url, "mp3", 0, tenant, ttl, cfg)
 	if mp3Link != "" {
 		response.DownloadLink["mp3"] = mp3Link
+		response.DownloadLink["mp3_original"] = mp3Link
+		response.MediaSources["mp3"] = musicURL
+		response.MediaSources["mp3_original"] = musicURL
+	} else if videoURL := utils.ResolveMediaURL(videoData, utils.ExtractedAudioKey, 0); !isImage && videoURL != "" {
+		// Without a music URL the mp3 is extracted from the video on download
+		mp3Link = downloadLink(videoURL, name, "mp3", url, utils.ExtractedAudioKey, 0, tenant, ttl, cfg)
+		response.DownloadLink["mp3"] = mp3Link
+		response.DownloadLink["mp3_original"] = mp3Link
+		addWarning(&response, WarnMP3Extracted, "music unavailable, mp3 is extracted from the video's audio track")
+	} else {
+		message := "music unavailable, mp3 download omitted"
+		if isImage {
+			message = "music unavailable, mp3 download omitted and the slideshow cannot be rendered"
+		}
+		addWarning(&response, WarnMusicUnavailable, message)
+	}
+
+	// Offer the full licensed track when the sound was matched to one
+	if fullSongURL, songTitle := utils.GetFullSong(music); fullSongURL != "" && fullSongURL != musicURL {
+		songName := name
+		if songTitle != "" {
+			songName = postDownloadName(videoData, songTitle)
+		}
+		fullLink := downloadLink(fullSongURL, songName, "mp3", url, "mp3_full", 0, tenant, ttl, cfg)
+		if fullLink != "" {
+			response.DownloadLink["mp3_full"] = fullLink
+			response.MediaSources["mp3_full"] = fullSongURL
+		}
 	}
 
 	// Process based on content type
 	if isImage {
-		if err := processImageResponse(videoData, authorNickname, url, &response, cfg); err != nil {
+		if err := processImageResponse(videoData, name, url, tenant, ttl, &response, cfg); err != nil {
 			return response, fmt.Errorf("error processing image data: %w", err)
 		}
 		response.Status = "picker"
 	} else {
-		if err := processVideoResponse(videoData, authorNickname, musicURL, mp3Link, &response, cfg); err != nil {
+		if err := processVideoResponse(videoData, name, url, musicURL, mp3Link, tenant, ttl, &response, cfg); err != nil {
 			return response, fmt.Errorf("error processing video data: %w", err)
 		}
 		response.Status = "tunnel"
@@ -184,8 +540,70 @@ func generateJSONResponse(data map[string]interface{}, url string, cfg *config.A
 	return response, nil
 }
 
+// downloadName is how the downloads of a post are named: the nickname as-is,
+// and an ASCII fallback for clients that can't take a UTF-8 filename
+type downloadName struct {
+	Author string
+	ASCII  string
+	PostID string
+}
+
+// postDownloadName names downloads after nickname, falling back to the
+// author's UID and the post ID when the nickname can't be transliterated
+func postDownloadName(videoData map[string]interface{}, nickname string) downloadName {
+	uid, _ := utils.GetNestedValue(videoData, []string{"author", "uid"}, "").(string)
+	return downloadName{
+		Author: nickname,
+		ASCII:  utils.FilenameBase(nickname, uid, utils.GetAwemeID(videoData)),
+		PostID: utils.GetAwemeID(videoData),
+	}
+}
+
+// downloadLink generates an encrypted download link, valid for ttl seconds,
+// that remembers which post and media key it came from, so it can be
+// re-resolved later, and which tenant it was issued to
+func downloadLink(mediaURL string, name downloadName, mediaType, sourceURL, key string, index int, tenant string, ttl int, cfg *config.AppConfig) string {
+	return utils.GenerateDownloadLink(models.DownloadData{
+		URL:      mediaURL,
+		Author:   name.Author,
+		Type:     mediaType,
+		Source:   sourceURL,
+		Key:      key,
+		Index:    index,
+		Tenant:   tenant,
+		Filename: name.ASCII,
+		PostID:   name.PostID,
+	}, cfg, ttl)
+}
+
+// buildMusicInfo extracts the structured music metadata from hybrid API music data
+func buildMusicInfo(music map[string]interface{}, duration int) *models.Music {
+	info := &models.Music{
+		ID:       utils.GetMusicID(music),
+		Duration: duration,
+	}
+	info.Title, _ = music["title"].(string)
+	info.Author, _ = music["author"].(string)
+	info.Album, _ = music["album"].(string)
+
+	for _, key := range []string{"cover_large", "cover_hd", "cover_medium", "cover_thumb"} {
+		if cover := utils.GetFirstFromNestedList(music, []string{key, "url_list"}, ""); cover != "" {
+			info.Cover = cover
+			break
+		}
+	}
+
+	if original, ok := music["is_original_sound"].(bool); ok {
+		info.OriginalSound = original
+	} else if original, ok := music["is_original"].(bool); ok {
+		info.OriginalSound = original
+	}
+
+	return info
+}
+
 // processImageResponse handles image-specific response processing
-func processImageResponse(videoData map[string]interface{}, authorNickname, url string, response *models.TikTokResponse, cfg *config.AppConfig) error {
+func processImageResponse(videoData map[string]interface{}, name downloadName, url, tenant string, ttl int, response *models.TikTokResponse, cfg *config.AppConfig) error {
 	// Get image list
 	imageData := make(map[string]interface{})
 	if imgDataVal, ok := videoData["image_data"].(map[string]interface{}); ok {
@@ -217,31 +635,35 @@ func processImageResponse(videoData map[string]interface{}, authorNickname, url
 
 	// Generate image download links
 	var encryptedImageLinks []string
-	for _, imgURL := range noWatermarkImages {
-		link := utils.GenerateEncryptedDownloadLink(
-			imgURL, authorNickname, "image", cfg, 360,
-		)
+	var imageSources []string
+	for i, imgURL := range noWatermarkImages {
+		link := downloadLink(imgURL, name, "image", url, "no_watermark", i, tenant, ttl, cfg)
 		if link != "" {
 			encryptedImageLinks = append(encryptedImageLinks, link)
+			imageSources = append(imageSources, imgURL)
 		}
 	}
 
 	if len(encryptedImageLinks) > 0 {
 		response.DownloadLink["no_watermark"] = encryptedImageLinks
+		response.MediaSources["no_watermark"] = imageSources
 	}
 
 	// Add slideshow download link
-	encryptedURL, err := utils.Encrypt(url, cfg.EncryptionKey, 360) // Menambahkan parameter TTL (360 detik)
+	encryptedURL, err := utils.SignLink(url, ttl)
 	if err != nil {
 		return fmt.Errorf("error encrypting URL for slideshow: %w", err)
 	}
 	response.SlideshowDownLink = fmt.Sprintf("%s/download-slideshow?url=%s", cfg.BaseURL, encryptedURL)
+	if tenantToken := signTenant(tenant, ttl); tenantToken != "" {
+		response.SlideshowDownLink += "&tenant=" + tenantToken
+	}
 
 	return nil
 }
 
 // processVideoResponse handles video-specific response processing
-func processVideoResponse(videoData map[string]interface{}, authorNickname, musicURL, mp3Link string, response *models.TikTokResponse, cfg *config.AppConfig) error {
+func processVideoResponse(videoData map[string]interface{}, name downloadName, sourceURL, musicURL, mp3Link, tenant string, ttl int, response *models.TikTokResponse, cfg *config.AppConfig) error {
 	// Video-specific processing
 	videoURLs := make(map[string]interface{})
 	if videoDataVal, ok := videoData["video_data"].(map[string]interface{}); ok {
@@ -256,11 +678,10 @@ func processVideoResponse(videoData map[string]interface{}, authorNickname, musi
 	// Helper function to add download link if URL exists
 	addLink := func(key, urlKey, mediaType string) {
 		if urlVal, ok := videoURLs[urlKey].(string); ok && urlVal != "" {
-			link := utils.GenerateEncryptedDownloadLink(
-				urlVal, authorNickname, mediaType, cfg, 360,
-			)
+			link := downloadLink(urlVal, name, mediaType, sourceURL, key, 0, tenant, ttl, cfg)
 			if link != "" {
 				downloadLinks[key] = link
+				response.MediaSources[key] = urlVal
 			}
 		}
 	}
@@ -280,10 +701,90 @@ func processVideoResponse(videoData map[string]interface{}, authorNickname, musi
 		return fmt.Errorf("no valid video URLs found")
 	}
 
+	response.MediaSizes = videoSizes(videoData)
+
+	// Flag missing variants instead of silently omitting their keys
+	_, hasNoWatermark := downloadLinks["no_watermark"]
+	_, hasNoWatermarkHD := downloadLinks["no_watermark_hd"]
+	if !hasNoWatermark {
+		addWarning(response, WarnNoWatermarkMissing, "no-watermark variant unavailable, only the watermarked video is offered")
+	}
+
+	// Without any clean URL, optionally offer the watermarked video with the
+	// watermark blurred out
+	if !hasNoWatermark && !hasNoWatermarkHD && cfg.WatermarkRemoval {
+		if wmURL, ok := videoURLs["wm_video_url"].(string); ok && wmURL != "" {
+			if link := downloadLink(wmURL, name, "video", sourceURL, utils.ProcessedNoWatermarkKey, 0, tenant, ttl, cfg); link != "" {
+				downloadLinks[utils.ProcessedNoWatermarkKey] = link
+				response.MediaSources[utils.ProcessedNoWatermarkKey] = wmURL
+				addWarning(response, WarnWatermarkProcessed, "no_watermark_processed is the watermarked video with the watermark blurred out, a best-effort result")
+			}
+		}
+	}
+	if _, ok := downloadLinks["no_watermark_hd"]; !ok {
+		addWarning(response, WarnHQUnavailable, "HQ variant unavailable")
+	}
+
 	// Add to response
 	for k, v := range downloadLinks {
 		response.DownloadLink[k] = v
 	}
 
+	// Offer the no-watermark video as an animated GIF, and split into scenes
+	if _, ok := downloadLinks["no_watermark"]; ok {
+		encryptedURL, err := utils.SignLink(sourceURL, ttl)
+		if err != nil {
+			return fmt.Errorf("error encrypting URL for GIF conversion: %w", err)
+		}
+		response.GifDownLink = fmt.Sprintf("%s/convert/gif?url=%s", cfg.BaseURL, encryptedURL)
+		response.ScenesLink = fmt.Sprintf("%s/scenes?url=%s", cfg.BaseURL, encryptedURL)
+		if tenantToken := signTenant(tenant, ttl); tenantToken != "" {
+			response.GifDownLink += "&tenant=" + tenantToken
+			response.ScenesLink += "&tenant=" + tenantToken
+		}
+	}
+
+	// Offer the audio's waveform peaks for scrubbers
+	if mp3Link != "" {
+		encryptedURL, err := utils.SignLink(sourceURL, ttl)
+		if err != nil {
+			return fmt.Errorf("error encrypting URL for waveform: %w", err)
+		}
+		response.WaveformLink = fmt.Sprintf("%s/waveform?url=%s", cfg.BaseURL, encryptedURL)
+		if tenantToken := signTenant(tenant, ttl); tenantToken != "" {
+			response.WaveformLink += "&tenant=" + tenantToken
+		}
+	}
+
+	// Link the embeddable player to the best variant
+	for _, key := range []string{"no_watermark_hd", "no_watermark", "watermark_hd", "watermark"} {
+		if mediaURL, ok := response.MediaSources[key].(string); ok {
+			response.EmbedLink = embedLink(mediaURL, name, sourceURL, key, tenant, cfg)
+			break
+		}
+	}
+
 	return nil
+}
+
+// embedLink returns the /embed/:token player link of a video; its token
+// carries the download data for the embed audience, signed for
+// EMBED_LINK_TTL, so it outlives the links of the response but can't be used
+// as a download link itself
+func embedLink(mediaURL string, name downloadName, sourceURL, key, tenant string, cfg *config.AppConfig) string {
+	token, err := utils.SignLinkJSON(models.DownloadData{
+		URL:      mediaURL,
+		Author:   name.Author,
+		Type:     "video",
+		Source:   sourceURL,
+		Key:      key,
+		Tenant:   tenant,
+		Filename: name.ASCII,
+		PostID:   name.PostID,
+		Audience: models.AudienceEmbed,
+	}, int(cfg.EmbedLinkTTL/time.Second))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/embed/%s", cfg.BaseURL, token)
 }
\ No newline at end of file
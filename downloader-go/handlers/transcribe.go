@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transcribeJobTimeout bounds fetching, extracting and transcribing a post's audio
+const transcribeJobTimeout = 10 * time.Minute
+
+// languagePattern matches ISO-639-1 (and -3) language codes
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// TranscribeHandler handles POST /transcribe: it queues a job that sends the
+// audio of a post to the configured Whisper-compatible endpoint, as captions
+// are often missing upstream. The job result holds the transcript, its timed
+// segments and the same as SRT subtitles, and with "translate" set all of
+// them translated to that language as well.
+func (h *HandlerContext) TranscribeHandler(c *gin.Context) {
+	if h.Config.TranscribeURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Transcription is not configured on this server"})
+		return
+	}
+
+	var req models.TranscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if !utils.IsSupportedURL(req.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.UnsupportedURLMessage()})
+		return
+	}
+	if req.Language != "" && !languagePattern.MatchString(req.Language) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "language must be an ISO-639-1 code such as \"en\""})
+		return
+	}
+	if req.Translate != "" {
+		if err := h.checkTranslation(req.Translate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job := h.Jobs.Create("transcribe", h.clientFingerprint(c))
+	priority := h.renderPriority(c)
+	h.submitJob(job.ID, func() { h.runTranscribeJob(job.ID, req, priority) })
+
+	h.acceptJob(c, job.ID)
+}
+
+// runTranscribeJob transcribes a post and stores the transcript on the job
+func (h *HandlerContext) runTranscribeJob(jobID string, req models.TranscribeRequest, priority int) {
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusProcessing
+		job.Result["url"] = req.URL
+	})
+	h.Jobs.Event(jobID, jobs.EventFetchStarted, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcribeJobTimeout)
+	transcript, status, body := h.transcribePost(ctx, jobID, req, priority)
+	cancel()
+
+	if body != nil {
+		message, _ := body["error"].(string)
+		h.Jobs.Update(jobID, func(job *jobs.Job) {
+			job.Status = jobs.StatusFailed
+			job.Error = message
+			job.Result["status_code"] = status
+			if ref, ok := body["reference_id"]; ok {
+				job.Result["reference_id"] = ref
+			}
+		})
+		h.Jobs.Event(jobID, jobs.EventFailed, "transcription failed with status %d", status)
+		return
+	}
+
+	// A failed translation still completes the job with the original
+	var translation *utils.Transcript
+	var translateErr error
+	if req.Translate != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), transcribeJobTimeout)
+		translation, translateErr = h.translateTranscript(ctx, transcript, req.Translate)
+		cancel()
+	}
+
+	h.Jobs.Update(jobID, func(job *jobs.Job) {
+		job.Status = jobs.StatusCompleted
+		job.Result["transcript"] = transcript.Text
+		job.Result["language"] = transcript.Language
+		job.Result["segments"] = transcript.Segments
+		job.Result["srt"] = transcript.SRT()
+		if translation != nil {
+			job.Result["translation"] = gin.H{
+				"language":   translation.Language,
+				"transcript": translation.Text,
+				"segments":   translation.Segments,
+				"srt":        translation.SRT(),
+			}
+		}
+		if translateErr != nil {
+			job.Result["translation_error"] = translateErr.Error()
+		}
+	})
+	h.Jobs.Event(jobID, jobs.EventCompleted, "")
+}
+
+// transcribePost extracts the audio of a post and transcribes it, returning
+// an error status and body on failure. Videos are transcribed from their own
+// audio track, which carries the speech; image posts from their sound.
+func (h *HandlerContext) transcribePost(ctx context.Context, jobID string, req models.TranscribeRequest, priority int) (*utils.Transcript, int, gin.H) {
+	data, err := utils.FetchPostData(ctx, h.Config, req.URL)
+	if err != nil {
+		status, body := upstreamErrorResponse(err)
+		return nil, status, body
+	}
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		return nil, http.StatusInternalServerError, h.diagnosticBody(req.URL, "", data, "Invalid data format")
+	}
+
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, "transcribe", utils.GetAwemeID(videoData))
+	if err != nil {
+		return nil, http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()}
+	}
+	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+
+	audioPath := filepath.Join(tempDir, "audio.mp3")
+	music, _ := videoData["music"].(map[string]interface{})
+	typeVal, _ := videoData["type"].(string)
+	if videoURL := utils.ResolveMediaURL(videoData, utils.ExtractedAudioKey, 0); typeVal != "image" && videoURL != "" {
+		videoPath := filepath.Join(tempDir, "video.mp4")
+		if err := utils.DownloadFile(ctx, videoURL, videoPath); err != nil {
+			return nil, http.StatusBadGateway, gin.H{"error": "Error downloading video: " + err.Error()}
+		}
+
+		// Extract the audio track once a render worker is free
+		var extractErr error
+		err = h.RenderQueue.Do(ctx, filepath.Base(tempDir), priority, func() {
+			extractErr = utils.ExtractAudio(ctx, videoPath, audioPath)
+		})
+		if err == nil {
+			err = extractErr
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, gin.H{"error": "Error extracting audio: " + err.Error()}
+		}
+	} else if musicURL := utils.ResolveMediaURL(videoData, "mp3", 0); musicURL != "" {
+		if err := h.AudioCache.Fetch(ctx, utils.GetMusicID(music), musicURL, audioPath); err != nil {
+			return nil, http.StatusBadGateway, gin.H{"error": "Error downloading audio: " + err.Error()}
+		}
+	} else {
+		return nil, http.StatusNotFound, gin.H{"error": "Post has no audio"}
+	}
+
+	h.Jobs.Event(jobID, "transcription_started", "")
+	transcript, err := utils.Transcribe(ctx, h.Config.TranscribeURL, h.Config.TranscribeAPIKey, h.Config.TranscribeModel, req.Language, audioPath)
+	if err != nil {
+		return nil, http.StatusBadGateway, gin.H{"error": "Error transcribing audio: " + err.Error()}
+	}
+	return transcript, http.StatusOK, nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"tiktok-downloader/models"
+	"tiktok-downloader/utils"
+)
+
+// targetLanguagePattern matches translation targets: an ISO-639-1 code,
+// optionally with a region or script such as pt-BR or zh-Hans
+var targetLanguagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z]{2,4})?$`)
+
+// Reasons a translation request is refused
+var (
+	errTranslationDisabled = errors.New("translation is not configured on this server")
+	errTranslationLanguage = errors.New(`translate must be a language code such as "en" or "pt-BR"`)
+)
+
+// checkTranslation validates a requested translation target
+func (h *HandlerContext) checkTranslation(target string) error {
+	if h.Translator == nil {
+		return errTranslationDisabled
+	}
+	if !targetLanguagePattern.MatchString(target) {
+		return errTranslationLanguage
+	}
+	return nil
+}
+
+// translateResponse adds the post's description translated to target. A
+// failing provider leaves the response untranslated with a warning rather
+// than failing it.
+func (h *HandlerContext) translateResponse(ctx context.Context, response *models.TikTokResponse, target string) {
+	translation := &models.Translation{Language: target}
+	if response.Description != "" {
+		translated, err := h.Translator.Translate(ctx, []string{response.Description}, target)
+		if err != nil {
+			addWarning(response, WarnTranslationFailed, "description could not be translated: "+err.Error())
+			return
+		}
+		translation.Description = translated[0]
+	}
+	response.Translation = translation
+}
+
+// translateTranscript returns a transcript translated to target, keeping its
+// timing; the whole text and every segment go out in one request
+func (h *HandlerContext) translateTranscript(ctx context.Context, transcript *utils.Transcript, target string) (*utils.Transcript, error) {
+	texts := []string{transcript.Text}
+	for _, segment := range transcript.Segments {
+		texts = append(texts, segment.Text)
+	}
+	translated, err := h.Translator.Translate(ctx, texts, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &utils.Transcript{
+		Language: target,
+		Duration: transcript.Duration,
+		Text:     translated[0],
+		Segments: make([]utils.TranscriptSegment, len(transcript.Segments)),
+	}
+	for i, segment := range transcript.Segments {
+		segment.Text = translated[i+1]
+		result.Segments[i] = segment
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"tiktok-downloader/metrics"
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageDays is how many days of daily buckets a usage report includes
+const defaultUsageDays = 30
+
+func init() {
+	metrics.Register("tikdownloader_tenant_bytes_total", "Bytes streamed to clients, by the tenant that issued the link.", metrics.Counter)
+	metrics.Register("tikdownloader_tenant_downloads_total", "Downloads served, by the tenant that issued the link.", metrics.Counter)
+}
+
+// tenantForRequest returns the tenant owning the request's X-API-Key, or ""
+func (h *HandlerContext) tenantForRequest(c *gin.Context) string {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		return ""
+	}
+	tenant := ""
+	for name, candidate := range h.Config.TenantAPIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			tenant = name
+		}
+	}
+	return tenant
+}
+
+// signTenant signs a tenant name for the slideshow link, valid for ttl
+// seconds, or returns "" for anonymous requests
+func signTenant(tenant string, ttl int) string {
+	if tenant == "" {
+		return ""
+	}
+	token, err := utils.SignLink(tenant, ttl)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// verifyTenant returns the tenant carried by a slideshow link's tenant
+// parameter; tampered or missing tokens account to nobody
+func verifyTenant(token string) string {
+	if token == "" {
+		return ""
+	}
+	tenant, err := utils.VerifyLink(token)
+	if err != nil {
+		return ""
+	}
+	return tenant
+}
+
+// accountDownload attributes a served download of n bytes to tenant, and
+// counts it against the egress budget whoever it was issued to
+func (h *HandlerContext) accountDownload(tenant string, n int64) {
+	utils.RecordEgress(n)
+	if tenant == "" {
+		return
+	}
+	h.Usage.RecordDownload(tenant, n)
+	metrics.Inc("tikdownloader_tenant_downloads_total", metrics.Labels{"tenant": tenant})
+	metrics.Add("tikdownloader_tenant_bytes_total", metrics.Labels{"tenant": tenant}, float64(n))
+}
+
+// accountBytes attributes n bytes continuing a download already accounted,
+// such as a later Range request of a video, to tenant and the egress budget
+func (h *HandlerContext) accountBytes(tenant string, n int64) {
+	utils.RecordEgressBytes(n)
+	if tenant == "" {
+		return
+	}
+	h.Usage.RecordBytes(tenant, n)
+	metrics.Add("tikdownloader_tenant_bytes_total", metrics.Labels{"tenant": tenant}, float64(n))
+}
+
+// servedBytes returns how many bytes of a local file were sent; in sendfile
+// modes the front proxy sends it, so the whole file is counted
+func (h *HandlerContext) servedBytes(c *gin.Context, path string) int64 {
+	mode := h.Config.SendfileMode
+	if mode == "" || mode == SendfileOff {
+		return int64(c.Writer.Size())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// usageSince returns the first day to include in a usage report, from the
+// since (YYYY-MM-DD) or days query parameter
+func usageSince(c *gin.Context) (time.Time, bool) {
+	if since := c.Query("since"); since != "" {
+		day, err := time.Parse("2006-01-02", since)
+		return day, err == nil
+	}
+	days := defaultUsageDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return time.Time{}, false
+		}
+		days = parsed
+	}
+	return time.Now().UTC().AddDate(0, 0, 1-days), true
+}
+
+// UsageReportsHandler reports the bandwidth accounted to every tenant
+func (h *HandlerContext) UsageReportsHandler(c *gin.Context) {
+	since, ok := usageSince(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since or days parameter"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": h.Usage.Reports(since)})
+}
+
+// TenantUsageHandler reports the bandwidth accounted to one tenant
+func (h *HandlerContext) TenantUsageHandler(c *gin.Context) {
+	since, ok := usageSince(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since or days parameter"})
+		return
+	}
+	report, ok := h.Usage.Report(c.Param("tenant"), since)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No usage recorded for this tenant"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import "tiktok-downloader/models"
+
+// Warning codes reported when a post resolves with pieces missing
+const (
+	WarnStatisticsMissing   = "statistics_missing"
+	WarnMusicUnavailable    = "music_unavailable"
+	WarnMP3Extracted        = "mp3_extracted"
+	WarnHQUnavailable       = "hq_unavailable"
+	WarnNoWatermarkMissing  = "no_watermark_unavailable"
+	WarnWatermarkProcessed  = "no_watermark_processed"
+	WarnCoverMissing        = "cover_missing"
+	WarnFallbackUpstream    = "fallback_upstream"
+	WarnFullPayloadFallback = "full_payload_fallback"
+	WarnTranslationFailed   = "translation_failed"
+	WarnEgressBudget        = "egress_budget_exhausted"
+)
+
+// addWarning records a partial-success warning on the response
+func addWarning(response *models.TikTokResponse, code, message string) {
+	response.Warnings = append(response.Warnings, models.Warning{Code: code, Message: message})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Waveform defaults and bounds
+const (
+	defaultWaveformPeaks = 200
+	maxWaveformPeaks     = 2000
+	defaultWaveformWidth = 1200
+	maxWaveformWidth     = 4000
+)
+
+// WaveformHandler handles /waveform: the normalized peaks of a post's audio
+// as JSON, so frontends can draw a scrubber without decoding the MP3, or
+// with ?format=png the waveform drawn as an image. ?peaks= sets the number
+// of peaks, ?width= and ?height= the image size.
+func (h *HandlerContext) WaveformHandler(c *gin.Context) {
+	urlParam := c.Query("url")
+	if urlParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL parameter is required"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "png" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `format must be "json" or "png"`})
+		return
+	}
+	count, err := boundedIntQuery(c, "peaks", defaultWaveformPeaks, 10, maxWaveformPeaks)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	width, err := boundedIntQuery(c, "width", defaultWaveformWidth, 100, maxWaveformWidth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	height, err := boundedIntQuery(c, "height", width/6, 20, maxWaveformWidth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sourceURL, err := utils.VerifyLink(urlParam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decrypting URL: " + err.Error()})
+		return
+	}
+	tenant := verifyTenant(c.Query("tenant"))
+	client := h.clientFingerprint(c)
+	ctx := c.Request.Context()
+
+	data, err := utils.FetchPostData(ctx, h.Config, sourceURL)
+	if err != nil {
+		c.JSON(upstreamErrorResponse(err))
+		return
+	}
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusInternalServerError, h.diagnosticBody(sourceURL, client, data, "Invalid data format"))
+		return
+	}
+
+	tempDir, err := utils.NewTempDir(h.Config.TempDir, "waveform", utils.GetAwemeID(videoData))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating temp directory: " + err.Error()})
+		return
+	}
+	defer utils.ScheduleCleanup(tempDir, 5*time.Minute)
+
+	// The post's sound, or the video's own audio track when it has none
+	audioPath := filepath.Join(tempDir, "audio")
+	music, _ := videoData["music"].(map[string]interface{})
+	if musicURL := utils.ResolveMediaURL(videoData, "mp3", 0); musicURL != "" {
+		err = h.AudioCache.Fetch(ctx, utils.GetMusicID(music), musicURL, audioPath)
+	} else if videoURL := utils.ResolveMediaURL(videoData, utils.ExtractedAudioKey, 0); videoURL != "" {
+		err = utils.DownloadFile(ctx, videoURL, audioPath)
+	} else {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post has no audio"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error downloading audio: " + err.Error()})
+		return
+	}
+
+	// Decode once a render worker is free
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	outputPath := filepath.Join(tempDir, "waveform.png")
+	var waveform *utils.Waveform
+	var waveformErr error
+	err = h.RenderQueue.Do(renderCtx, filepath.Base(tempDir), h.renderPriority(c), func() {
+		if format == "png" {
+			waveformErr = utils.RenderWaveform(renderCtx, audioPath, outputPath, width, height)
+			return
+		}
+		waveform, waveformErr = utils.ComputeWaveform(renderCtx, audioPath, count)
+	})
+	if err == nil {
+		err = waveformErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating waveform: " + err.Error()})
+		return
+	}
+
+	if format == "png" {
+		h.serveLocalFile(c, outputPath, fmt.Sprintf("%s_waveform.png", renderFilenameBase(videoData)))
+		h.accountDownload(tenant, h.servedBytes(c, outputPath))
+		return
+	}
+	c.JSON(http.StatusOK, waveform)
+}
+
+// boundedIntQuery reads an integer query parameter between min and max,
+// returning def when it is absent
+func boundedIntQuery(c *gin.Context, name string, def, min, max int) (int, error) {
+	param := c.Query(name)
+	if param == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return value, nil
+}
@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job status values
+const (
+	StatusQueued     = "queued"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Common job event types; jobs may record other types for their own stages
+const (
+	EventQueued       = "queued"
+	EventStarted      = "started"
+	EventFetchStarted = "fetch_started"
+	EventItemDone     = "item_completed"
+	EventItemFailed   = "item_failed"
+	EventCompleted    = "completed"
+	EventFailed       = "failed"
+)
+
+// maxEventsPerJob bounds the timeline of long jobs; the oldest events are dropped first
+const maxEventsPerJob = 1000
+
+// Event is a single entry in a job's timeline
+type Event struct {
+	At        time.Time `json:"at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// Job represents a background unit of work tracked by the jobs API
+type Job struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Client    string                 `json:"-"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+
+	// Queue details, filled in while the job waits for a worker
+	QueuePosition int      `json:"queue_position,omitempty"`
+	ETASeconds    *float64 `json:"eta_seconds,omitempty"`
+}
+
+// Store keeps track of jobs in memory
+type Store struct {
+	sync.RWMutex
+	jobs   map[string]*Job
+	events map[string][]Event
+}
+
+// NewStore creates an empty job store
+func NewStore() *Store {
+	return &Store{jobs: make(map[string]*Job), events: make(map[string][]Event)}
+}
+
+// Create registers a new queued job of the given type on behalf of a client fingerprint
+func (s *Store) Create(jobType, client string) Job {
+	now := time.Now()
+	job := &Job{
+		ID:        NewID(),
+		Type:      jobType,
+		Client:    client,
+		Status:    StatusQueued,
+		Result:    make(map[string]interface{}),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.jobs[job.ID] = job
+	s.appendEvent(job, EventQueued, "")
+	return job.snapshot()
+}
+
+// Restore registers a job recovered from persistent state, keeping its ID
+func (s *Store) Restore(job Job) {
+	if job.Result == nil {
+		job.Result = make(map[string]interface{})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.jobs[job.ID] = &job
+	s.appendEvent(&job, "restored", "recovered from persisted state")
+}
+
+// Get returns a copy of the job with the given ID
+func (s *Store) Get(id string) (Job, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// Update applies fn to the job with the given ID under the store lock
+func (s *Store) Update(id string, fn func(job *Job)) bool {
+	s.Lock()
+	defer s.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	fn(job)
+	job.UpdatedAt = time.Now()
+	return true
+}
+
+// PurgeClient deletes every job created by a client and returns their IDs
+func (s *Store) PurgeClient(client string) []string {
+	s.Lock()
+	defer s.Unlock()
+	var purged []string
+	for id, job := range s.jobs {
+		if job.Client == client {
+			delete(s.jobs, id)
+			delete(s.events, id)
+			purged = append(purged, id)
+		}
+	}
+	return purged
+}
+
+// PurgeFinishedBefore deletes completed and failed jobs last updated before cutoff
+func (s *Store) PurgeFinishedBefore(cutoff time.Time) int {
+	s.Lock()
+	defer s.Unlock()
+	purged := 0
+	for id, job := range s.jobs {
+		finished := job.Status == StatusCompleted || job.Status == StatusFailed
+		if finished && job.UpdatedAt.Before(cutoff) {
+			delete(s.jobs, id)
+			delete(s.events, id)
+			purged++
+		}
+	}
+	return purged
+}
+
+// Event appends an entry to the job's timeline
+func (s *Store) Event(id, eventType, format string, args ...interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	s.appendEvent(job, eventType, message)
+}
+
+// Events returns a copy of the job's timeline
+func (s *Store) Events(id string) ([]Event, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if _, ok := s.jobs[id]; !ok {
+		return nil, false
+	}
+	return append([]Event{}, s.events[id]...), true
+}
+
+// appendEvent records an event; callers must hold the store lock
+func (s *Store) appendEvent(job *Job, eventType, message string) {
+	now := time.Now()
+	events := append(s.events[job.ID], Event{
+		At:        now,
+		ElapsedMs: now.Sub(job.CreatedAt).Milliseconds(),
+		Type:      eventType,
+		Message:   message,
+	})
+	if len(events) > maxEventsPerJob {
+		events = events[len(events)-maxEventsPerJob:]
+	}
+	s.events[job.ID] = events
+}
+
+// snapshot returns a copy of the job that is safe to use outside the lock
+func (j *Job) snapshot() Job {
+	cp := *j
+	cp.Result = make(map[string]interface{}, len(j.Result))
+	for k, v := range j.Result {
+		cp.Result[k] = v
+	}
+	return cp
+}
+
+// NewID generates a random hex identifier
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("20060102150405.000000")))
+	}
+	return hex.EncodeToString(b)
+}
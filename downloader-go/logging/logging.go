@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Log levels in increasing order of severity
+const (
+	LevelDebug int32 = iota
+	LevelInfo
+	LevelWarn
+)
+
+var levelNames = map[int32]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+}
+
+// current is the active log level
+var current atomic.Int32
+
+// payloadDumps maps aweme IDs to the time their upstream payload dumping expires
+var payloadDumps = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+func init() {
+	current.Store(LevelInfo)
+}
+
+// ParseLevel converts a level name to its numeric value
+func ParseLevel(name string) (int32, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info or warn", name)
+}
+
+// SetLevel changes the active log level
+func SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	current.Store(level)
+	return nil
+}
+
+// Level returns the name of the active log level
+func Level() string {
+	return levelNames[current.Load()]
+}
+
+// Enabled reports whether messages at the given level are logged
+func Enabled(level int32) bool {
+	return level >= current.Load()
+}
+
+// Debugf logs a message at debug level
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		log.Printf("[DEBUG] "+format, args...)
+	}
+}
+
+// Infof logs a message at info level
+func Infof(format string, args ...interface{}) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf logs a message at warn level
+func Warnf(format string, args ...interface{}) {
+	if Enabled(LevelWarn) {
+		log.Printf("[WARN] "+format, args...)
+	}
+}
+
+// EnablePayloadDump turns on verbose upstream payload logging for one post
+func EnablePayloadDump(awemeID string, duration time.Duration) time.Time {
+	until := time.Now().Add(duration)
+	payloadDumps.Lock()
+	defer payloadDumps.Unlock()
+	payloadDumps.until[awemeID] = until
+	return until
+}
+
+// ShouldDumpPayload reports whether the upstream payload of a post should be logged
+func ShouldDumpPayload(awemeID string) bool {
+	payloadDumps.Lock()
+	defer payloadDumps.Unlock()
+	until, ok := payloadDumps.until[awemeID]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(payloadDumps.until, awemeID)
+		return false
+	}
+	return true
+}
+
+// PayloadDumps returns the active payload dumps and their expiry times
+func PayloadDumps() map[string]time.Time {
+	payloadDumps.Lock()
+	defer payloadDumps.Unlock()
+	active := make(map[string]time.Time, len(payloadDumps.until))
+	now := time.Now()
+	for id, until := range payloadDumps.until {
+		if now.Before(until) {
+			active[id] = until
+		} else {
+			delete(payloadDumps.until, id)
+		}
+	}
+	return active
+}
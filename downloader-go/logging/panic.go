@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// Panic logs a recovered panic value with the current goroutine's stack
+// trace under a new reference ID, which is returned so the failure can be
+// reported to clients without exposing the trace
+func Panic(value interface{}) string {
+	ref := panicRef()
+	log.Printf("[PANIC] %s: %v\n%s", ref, value, debug.Stack())
+	return ref
+}
+
+// panicRef returns a short random reference for a panic log entry
+func panicRef() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "panic-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "panic-" + hex.EncodeToString(b)
+}
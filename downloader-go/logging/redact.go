@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Redaction modes for URLs, tokens and client IPs in log output
+const (
+	RedactOff      = "off"
+	RedactTruncate = "truncate"
+	RedactHash     = "hash"
+)
+
+// truncatedPathLen is how much of a URL path survives truncation
+const truncatedPathLen = 16
+
+var (
+	// absoluteURLPattern matches http(s) URLs embedded in log lines
+	absoluteURLPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	// queryPattern matches the query string of request URIs such as /download?data=...
+	queryPattern = regexp.MustCompile(`(/[A-Za-z0-9_\-./:]*)\?[^\s"'<>]+`)
+	// ipv4Pattern and ipv6Pattern find IP address candidates, validated with net.ParseIP
+	ipv4Pattern = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+)
+
+// redactMode is the active redaction mode
+var redactMode atomic.Value
+
+func init() {
+	redactMode.Store(RedactOff)
+}
+
+// SetRedaction changes how URLs, tokens and IPs are written to the logs
+func SetRedaction(mode string) error {
+	switch mode {
+	case RedactOff, RedactTruncate, RedactHash:
+		redactMode.Store(mode)
+		return nil
+	}
+	return fmt.Errorf("unknown log redaction mode %q, expected off, truncate or hash", mode)
+}
+
+// Redaction returns the active redaction mode
+func Redaction() string {
+	return redactMode.Load().(string)
+}
+
+// RedactURL truncates or hashes a URL, always dropping its query string,
+// which carries signatures and encrypted tokens. The host is kept.
+func RedactURL(raw string) string {
+	mode := Redaction()
+	if mode == RedactOff {
+		return raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return "[url:" + digest(raw) + "]"
+	}
+	prefix := parsed.Scheme + "://" + parsed.Host
+	if mode == RedactHash {
+		return prefix + "/#" + digest(raw)
+	}
+
+	path := parsed.EscapedPath()
+	if len(path) > truncatedPathLen {
+		path = path[:truncatedPathLen] + "…"
+	}
+	if parsed.RawQuery != "" {
+		path += "?…"
+	}
+	return prefix + path
+}
+
+// RedactIP truncates an IP to its network (/24 or /48) or hashes it
+func RedactIP(raw string) string {
+	mode := Redaction()
+	ip := net.ParseIP(raw)
+	if mode == RedactOff || ip == nil {
+		return raw
+	}
+	if mode == RedactHash {
+		return "ip-" + digest(ip.String())
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// Redact applies the redaction policy to every URL, request query and IP in s
+func Redact(s string) string {
+	mode := Redaction()
+	if mode == RedactOff {
+		return s
+	}
+
+	s = absoluteURLPattern.ReplaceAllStringFunc(s, RedactURL)
+	s = queryPattern.ReplaceAllStringFunc(s, func(uri string) string {
+		path, query, _ := strings.Cut(uri, "?")
+		if query == "…" {
+			return uri
+		}
+		if mode == RedactHash {
+			return path + "?#" + digest(query)
+		}
+		return path + "?…"
+	})
+	s = ipv4Pattern.ReplaceAllStringFunc(s, RedactIP)
+	return ipv6Pattern.ReplaceAllStringFunc(s, func(candidate string) string {
+		if strings.Count(candidate, ":") < 2 || net.ParseIP(candidate) == nil {
+			return candidate
+		}
+		return RedactIP(candidate)
+	})
+}
+
+// digest returns a short, stable fingerprint of s for correlating log lines
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+// redactingWriter applies the redaction policy to everything written through it
+type redactingWriter struct {
+	w io.Writer
+}
+
+// RedactingWriter wraps w so log lines written to it are redacted
+func RedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+// Write implements io.Writer
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if Redaction() == RedactOff {
+		return r.w.Write(p)
+	}
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -1,29 +1,137 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"tiktok-downloader/buildinfo"
+	"tiktok-downloader/cache"
 	"tiktok-downloader/config"
+	"tiktok-downloader/cookies"
+	"tiktok-downloader/discovery"
 	"tiktok-downloader/handlers"
+	"tiktok-downloader/jobs"
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
 	"tiktok-downloader/middleware"
+	"tiktok-downloader/queue"
+	"tiktok-downloader/supervisor"
+	"tiktok-downloader/usage"
 	"tiktok-downloader/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Compare this variant against the fiber variant instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "parity" {
+		os.Exit(runParityCommand(os.Args[2:]))
+	}
+
 	// Initialize app config
 	cfg := config.LoadConfig()
 
+	// Apply the configured log level
+	if err := logging.SetLevel(cfg.LogLevel); err != nil {
+		log.Printf("Invalid LOG_LEVEL, using %s: %v", logging.Level(), err)
+	}
+
+	// Keep signed media URLs, tokens and client IPs out of the logs
+	if err := logging.SetRedaction(cfg.LogRedaction); err != nil {
+		log.Fatalf("Invalid LOG_REDACTION: %v", err)
+	}
+	log.SetOutput(logging.RedactingWriter(os.Stderr))
+	gin.DefaultWriter = logging.RedactingWriter(os.Stdout)
+	gin.DefaultErrorWriter = logging.RedactingWriter(os.Stderr)
+
+	// Apply settings shared with the fiber variant
+	utils.SetHybridTimeout(cfg.HybridAPITimeout)
+	utils.SetHybridMaxPayload(int64(cfg.HybridMaxPayloadMB) << 20)
+	utils.SetSlideshowImageSeconds(cfg.SlideshowImageSeconds)
+	utils.SetIntroFont(cfg.SlideshowIntroFont)
+	utils.SetSourceRetry(utils.RetryPolicy{
+		MaxAttempts: cfg.SourceRetryAttempts,
+		BaseDelay:   cfg.SourceRetryBackoff,
+		MaxDelay:    cfg.SourceRetryMaxBackoff,
+	})
+	utils.SetSourceStreaming(utils.SourceStreaming{
+		ChunkSize:     int64(cfg.SourceChunkSize),
+		StallTimeout:  cfg.SourceStallTimeout,
+		MinThroughput: int64(cfg.SourceMinThroughput),
+	})
+
+	// Route upstream fetches through a proxy, e.g. when the server IP is geo-blocked
+	if err := utils.SetUpstreamProxy(cfg.ProxyURL, cfg.ProxyBypass); err != nil {
+		log.Fatalf("Invalid PROXY_URL: %v", err)
+	}
+
+	// Encode slideshows on the GPU when configured and available
+	if err := utils.SetHWAccel(context.Background(), cfg.FFmpegHWAccel, cfg.FFmpegHWAccelDevice); err != nil {
+		log.Fatalf("Invalid FFMPEG_HWACCEL: %v", err)
+	}
+
+	// Sign download links with the configured scheme
+	signer, err := utils.NewLinkSigner(cfg.LinkSigner, cfg.EncryptionKey)
+	if err != nil {
+		log.Fatalf("Invalid LINK_SIGNER: %v", err)
+	}
+	utils.SetLinkSigner(signer)
+
+	// Issue /download links encrypted or as signed plaintext
+	if err := utils.SetDownloadLinkMode(cfg.DownloadLinkMode, cfg.EncryptionKey); err != nil {
+		log.Fatalf("Invalid DOWNLOAD_LINK_MODE: %v", err)
+	}
+
+	if err := handlers.ValidateSendfileMode(cfg.SendfileMode); err != nil {
+		log.Fatalf("Invalid SENDFILE_MODE: %v", err)
+	}
+
+	// Run the bundled hybrid API ourselves when configured, so a single
+	// container needs no separate upstream service
+	hybridAPI, err := supervisor.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure embedded hybrid API: %v", err)
+	}
+	supervisorCtx, stopSupervisor := context.WithCancel(context.Background())
+	defer stopSupervisor()
+	supervisorDone := make(chan struct{})
+	if hybridAPI == nil {
+		close(supervisorDone)
+	} else {
+		cfg.HybridAPIURL = hybridAPI.Endpoint()
+		cfg.HybridAPIURLs = []string{cfg.HybridAPIURL}
+		go func() {
+			hybridAPI.Run(supervisorCtx)
+			close(supervisorDone)
+		}()
+		if err := hybridAPI.WaitReady(supervisorCtx, time.Minute); err != nil {
+			log.Printf("Embedded hybrid API is not ready yet: %v", err)
+		}
+	}
+
+	// Spread requests over the hybrid API backends, failing over between them
+	// and to public instances while all of them are down
+	utils.SetHybridBackends(cfg.HybridAPIURLs)
+	utils.SetHybridFallbacks(cfg.HybridFallbackURLs)
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	go utils.MonitorHybridHealth(healthCtx, cfg.HybridHealthInterval)
+
 	// Create temp directory if it doesn't exist
 	if err := utils.InitTempDir(cfg.TempDir); err != nil {
 		log.Fatalf("Failed to create temp directory: %v", err)
 	}
 
 	// Start background cleanup goroutine
-	go utils.CleanupTempFiles(cfg.TempDir)
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	go utils.CleanupTempFiles(cleanupCtx, cfg.TempDir, int64(cfg.TempDirMaxMB)<<20, cfg.TempQuarantineRetention)
 
 	// Set release mode for production
 	gin.SetMode(gin.ReleaseMode)
@@ -31,25 +139,185 @@ func main() {
 	// Create a new gin engine
 	router := gin.New()
 
+	// Publish build metadata as a metric so dashboards can tell releases apart
+	build := buildinfo.Get()
+	metrics.Register("tikdownloader_build_info", "Build information of the running service.", metrics.Gauge)
+	metrics.Set("tikdownloader_build_info", metrics.Labels{
+		"variant":    build.Variant,
+		"version":    build.Version,
+		"commit":     build.Commit,
+		"build_date": build.BuildDate,
+	}, 1)
+
 	// Add middleware
 	router.Use(gin.Recovery())
 	router.Use(gin.Logger())
+	router.Use(metrics.RequestCounter())
 	
 	// Add CORS middleware
-	router.Use(middleware.CorsMiddleware())
+	router.Use(middleware.CorsMiddleware(cfg))
 	
+	// Limit each client and tell it how much budget is left
+	if rateLimit := middleware.RateLimit(cfg); rateLimit != nil {
+		router.Use(rateLimit)
+	}
+
+	// Serve requests without an API key as guests on public instances
+	if guest := middleware.Guest(cfg); guest != nil {
+		router.Use(guest)
+	}
+
 	// Add GZIP compression middleware
 	router.Use(middleware.GzipMiddleware())
 
+	// Mimic Chrome's TLS fingerprint for the configured platforms
+	if err := utils.SetTLSImpersonation(cfg.TLSImpersonatePlatforms); err != nil {
+		log.Fatalf("Invalid TLS impersonation config: %v", err)
+	}
+
+	// Optional post-processing of responses by an external command; forks
+	// can register their own handlers.ResponseHook here as well
+	if cfg.ResponseHookCommand != "" {
+		handlers.AddResponseHook(handlers.NewExecResponseHook(cfg.ResponseHookCommand, cfg.ResponseHookTimeout))
+	}
+
+	// Platforms served by their own extractor instead of the hybrid API
+	if cfg.InstagramEnabled {
+		utils.RegisterExtractor(utils.InstagramExtractor{})
+	}
+	if cfg.YouTubeEnabled {
+		utils.RegisterExtractor(utils.YouTubeExtractor{})
+	}
+	if cfg.KuaishouEnabled {
+		utils.RegisterExtractor(utils.KuaishouExtractor{})
+	}
+
+	// Cap the bandwidth served per window, see middleware.EgressBudget
+	utils.SetEgressBudget(utils.NewEgressBudget(int64(cfg.EgressBudgetGB)<<30, cfg.EgressBudgetDownloads, cfg.EgressBudgetWindow))
+
+	// Space out requests to each upstream host
+	utils.SetHybridPacer(utils.NewPacer(cfg.PacingHybridInterval, cfg.PacingJitter))
+	if cdnPacer := utils.NewPacer(cfg.PacingCDNInterval, cfg.PacingJitter); cdnPacer != nil {
+		utils.AddSourceHook(cdnPacer)
+	}
+
+	// Load the Douyin cookie jar and attach it to CDN fetches
+	cookieManager := cookies.NewManager(cfg.DouyinCookieFile, cfg.DouyinCookieRefreshURL, cfg.DouyinCookieRefreshInterval)
+	if err := cookieManager.Load(); err != nil {
+		log.Printf("Error loading Douyin cookies: %v", err)
+	}
+	utils.AddSourceHook(cookieManager)
+	cookieCtx, stopCookieRefresh := context.WithCancel(context.Background())
+	defer stopCookieRefresh()
+	go cookieManager.Run(cookieCtx)
+
+	// Cache hybrid API responses so repeated URLs skip the upstream call,
+	// in Redis when configured and in memory otherwise
+	var responseCache cache.Cache
+	if cfg.RedisURL != "" {
+		redisCache, err := cache.NewRedis(cfg.RedisURL, cfg.RedisPrefix)
+		if err != nil {
+			log.Fatalf("Failed to configure Redis cache: %v", err)
+		}
+		responseCache = redisCache
+	} else if cfg.MemoryCacheSize > 0 {
+		responseCache = cache.NewLRU(cfg.MemoryCacheSize)
+	}
+	if responseCache != nil {
+		utils.SetHybridCache(responseCache, cfg.MetadataCacheTTL)
+	}
+
+	// Short download links keep their data in Redis when configured, so any
+	// instance can serve them, and in memory otherwise
+	if cfg.DownloadLinkMode == utils.DownloadLinksShort {
+		if redisCache, ok := responseCache.(*cache.Redis); ok {
+			utils.SetShortLinkStore(redisCache)
+		} else {
+			utils.SetShortLinkStore(cache.NewLRU(cfg.ShortLinkCacheSize))
+		}
+	}
+
+	// Optional S3 media cache behind CloudFront
+	mediaCache, err := utils.NewMediaCache(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure media cache: %v", err)
+	}
+
+	// Where finished slideshows are kept
+	storage, err := utils.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+
+	// Optional translation of descriptions and transcripts
+	translator, err := utils.NewTranslator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure translation: %v", err)
+	}
+
+	// Account streamed bytes to the tenant that issued each download link
+	usageLedger := usage.NewLedger(cfg.UsageFile)
+	if err := usageLedger.Load(); err != nil {
+		log.Printf("Error loading usage ledger: %v", err)
+	}
+	usageCtx, stopUsageSaves := context.WithCancel(context.Background())
+	defer stopUsageSaves()
+	go usageLedger.Run(usageCtx, cfg.UsageSaveInterval)
+
 	// Create handler context with dependencies
 	handlerContext := &handlers.HandlerContext{
-		Config: cfg,
+		Config:        cfg,
+		Jobs:          jobs.NewStore(),
+		AudioCache:    utils.NewAudioCache(cfg.AudioCacheDir, cfg.AudioCacheTTL),
+		Cookies:       cookieManager,
+		JobQueue:      queue.New("jobs", cfg.JobWorkers),
+		RenderQueue:   queue.New("render", cfg.RenderWorkers),
+		MediaCache:    mediaCache,
+		Storage:       storage,
+		Translator:    translator,
+		ResponseCache: responseCache,
+		Usage:         usageLedger,
 	}
 
+	// Run a one-off backfill instead of the server when asked to
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		os.Exit(runBackfillCommand(handlerContext, os.Args[2:]))
+	}
+
+	// Pick up archive jobs interrupted by a restart or crash
+	handlerContext.ResumeArchiveJobs()
+	handlerContext.RecoverSlideshowJobs()
+
+	// Purge job and diagnostics records past the retention period
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go handlerContext.RunRetention(retentionCtx)
+
 	// Register routes
 	router.POST("/tiktok", handlerContext.TikTokHandler)
-	router.GET("/download", handlerContext.DownloadHandler)
-	router.GET("/download-slideshow", handlerContext.DownloadSlideshowHandler)
+	router.GET("/tiktok", handlerContext.TikTokQueryHandler)
+	router.GET("/info", handlerContext.InfoHandler)
+	// Downloads are refused while the egress budget is spent
+	budget := middleware.EgressBudget()
+	router.GET("/download", budget, handlerContext.DownloadHandler)
+	router.GET("/d/:code", budget, handlerContext.ShortDownloadHandler)
+	router.POST("/download-progress", handlerContext.IssueProgressTokenHandler)
+	router.GET("/download-progress/:token", handlerContext.DownloadProgressHandler)
+	slideshows := middleware.Feature(cfg.DisableSlideshow, "Slideshow rendering")
+	router.GET("/download-slideshow", slideshows, middleware.NoGuests("Slideshow rendering"), budget, handlerContext.DownloadSlideshowHandler)
+	router.POST("/download-slideshow", slideshows, middleware.NoGuests("Slideshow rendering"), budget, handlerContext.CreateSlideshowJobHandler)
+	router.GET("/slideshow-assets/:id/:file", slideshows, budget, handlerContext.SlideshowAssetHandler)
+	router.GET("/convert/gif", middleware.Feature(cfg.DisableGIF, "GIF conversion"), middleware.NoGuests("GIF conversion"), budget, handlerContext.ConvertGIFHandler)
+	router.GET("/scenes", middleware.Feature(cfg.DisableScenes, "Scene detection"), middleware.NoGuests("Scene detection"), budget, handlerContext.ScenesHandler)
+	router.GET("/waveform", middleware.Feature(cfg.DisableWaveform, "Waveforms"), budget, handlerContext.WaveformHandler)
+	router.GET("/oembed", handlerContext.OEmbedHandler)
+	router.GET("/embed", handlerContext.EmbedPlayerHandler)
+	router.GET("/embed/:token", handlerContext.EmbedTokenHandler)
+	router.POST("/archive", middleware.Feature(cfg.DisableArchive, "Archiving"), middleware.AdminAuth(cfg), handlerContext.ArchiveHandler)
+	router.POST("/transcribe", middleware.Feature(cfg.DisableTranscribe, "Transcription"), handlerContext.TranscribeHandler)
+	router.GET("/jobs/:id", handlerContext.JobStatusHandler)
+	router.GET("/jobs/:id/events", handlerContext.JobEventsHandler)
+	router.GET("/jobs/:id/file", budget, handlerContext.JobFileHandler)
 	
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -59,27 +327,96 @@ func main() {
 		})
 	})
 
+	// Build information and metrics endpoints
+	router.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, buildinfo.Get())
+	})
+	router.GET("/metrics", metrics.Handler())
+
+	// Admin endpoints (require ADMIN_TOKEN)
+	admin := router.Group("/admin", middleware.AdminAuth(cfg))
+	admin.GET("/log-level", handlerContext.GetLogLevelHandler)
+	admin.PUT("/log-level", handlerContext.SetLogLevelHandler)
+	admin.GET("/diagnostics/:ref", handlerContext.DiagnosticsHandler)
+	admin.GET("/cookies", handlerContext.GetCookiesHandler)
+	admin.PUT("/cookies", handlerContext.SetCookiesHandler)
+	admin.POST("/cookies/refresh", handlerContext.RefreshCookiesHandler)
+	admin.POST("/backfill", handlerContext.BackfillHandler)
+	admin.DELETE("/data", handlerContext.PurgeClientDataHandler)
+	admin.GET("/cache", handlerContext.CacheStatsHandler)
+	admin.GET("/cleanup/preview", handlerContext.CleanupPreviewHandler)
+	admin.GET("/schema-diff", handlerContext.SchemaDiffHandler)
+	admin.GET("/usage", handlerContext.UsageReportsHandler)
+	admin.GET("/usage/:tenant", handlerContext.TenantUsageHandler)
+
 	// Get port from environment variable or use default
 	addr := ":" + cfg.Port
 
-	// Create and configure the HTTP server
+	// Create and configure the HTTP server. There is no write timeout: it
+	// would cut off large downloads, whose source streams are bounded by
+	// utils.SourceDeadline and the stall timeout instead.
 	server := &http.Server{
-		Addr:         addr,
-		Handler:      router,
-		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Addr:        addr,
+		Handler:     router,
+		ReadTimeout: 60 * time.Second,
+		IdleTimeout: 120 * time.Second,
 	}
 
 	// Log configuration info
+	log.Printf("Starting %s %s (commit %s, built %s)", build.Variant, build.Version, build.Commit, build.BuildDate)
 	log.Printf("Starting server with configuration:")
 	log.Printf("- Base URL: %s", cfg.BaseURL)
 	log.Printf("- Temp directory: %s", cfg.TempDir)
-	log.Printf("- Hybrid API URL: %s", cfg.HybridAPIURL)
+	log.Printf("- Hybrid API URLs: %s", strings.Join(cfg.HybridAPIURLs, ", "))
+	log.Printf("- Archive directory: %s (naming: %s)", cfg.ArchiveDir, cfg.ArchiveNaming)
 
 	// Start the server
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Failed to start server: %v", err)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Register with service discovery once the listener is up
+	registrar, err := discovery.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure service discovery: %v", err)
+	}
+	registerCtx, stopRegistration := context.WithCancel(context.Background())
+	if registrar != nil {
+		if err := registrar.Register(registerCtx); err != nil {
+			log.Printf("Service discovery registration failed: %v", err)
+		}
 	}
-}
\ No newline at end of file
+
+	// Wait for a shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Printf("Shutting down server...")
+
+	// Deregister first so no new traffic is routed here while draining
+	stopRegistration()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if registrar != nil {
+		if err := registrar.Deregister(shutdownCtx); err != nil {
+			log.Printf("Service discovery deregistration failed: %v", err)
+		}
+	}
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+
+	// Persist the usage of downloads that finished while draining
+	stopUsageSaves()
+	if err := usageLedger.Save(); err != nil {
+		log.Printf("Error saving usage ledger: %v", err)
+	}
+
+	// Stop the embedded hybrid API once no request can reach it
+	stopSupervisor()
+	<-supervisorDone
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metric types understood by Prometheus
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+// Labels is a set of metric labels
+type Labels map[string]string
+
+type family struct {
+	help    string
+	kind    string
+	samples map[string]float64
+}
+
+// registry holds every metric family exposed on /metrics
+var registry = struct {
+	sync.Mutex
+	families map[string]*family
+}{families: make(map[string]*family)}
+
+// Register declares a metric family with its help text and type
+func Register(name, help, kind string) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.families[name]; !ok {
+		registry.families[name] = &family{help: help, kind: kind, samples: make(map[string]float64)}
+	}
+}
+
+// Inc increments a counter by one
+func Inc(name string, labels Labels) {
+	Add(name, labels, 1)
+}
+
+// Add adds a value to a counter or gauge
+func Add(name string, labels Labels, value float64) {
+	registry.Lock()
+	defer registry.Unlock()
+	f := lookup(name)
+	f.samples[formatLabels(labels)] += value
+}
+
+// Set sets a gauge to a value
+func Set(name string, labels Labels, value float64) {
+	registry.Lock()
+	defer registry.Unlock()
+	f := lookup(name)
+	f.samples[formatLabels(labels)] = value
+}
+
+// lookup returns the family for name, creating an untyped one if needed
+func lookup(name string) *family {
+	f, ok := registry.families[name]
+	if !ok {
+		f = &family{kind: "untyped", samples: make(map[string]float64)}
+		registry.families[name] = f
+	}
+	return f
+}
+
+// formatLabels renders labels in Prometheus exposition format with a stable order
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[k])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry.Lock()
+		names := make([]string, 0, len(registry.families))
+		for name := range registry.families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		for _, name := range names {
+			f := registry.families[name]
+			if f.help != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+
+			series := make([]string, 0, len(f.samples))
+			for labels := range f.samples {
+				series = append(series, labels)
+			}
+			sort.Strings(series)
+			for _, labels := range series {
+				fmt.Fprintf(&b, "%s%s %v\n", name, labels, f.samples[labels])
+			}
+		}
+		registry.Unlock()
+
+		c.Data(200, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	}
+}
+
+// RequestCounter counts handled requests by route and status code
+func RequestCounter() gin.HandlerFunc {
+	Register("tikdownloader_http_requests_total", "Total HTTP requests handled.", Counter)
+	return func(c *gin.Context) {
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		Inc("tikdownloader_http_requests_total", Labels{
+			"method": c.Request.Method,
+			"route":  route,
+			"status": fmt.Sprintf("%d", c.Writer.Status()),
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiktok-downloader/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EgressBudget refuses downloads with 503 while the egress budget is spent,
+// telling clients when the window resets
+func EgressBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exhausted, resets := utils.EgressExhausted()
+		if !exhausted {
+			c.Next()
+			return
+		}
+		retryAfter := int(math.Ceil(time.Until(resets).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":     "Download bandwidth budget exhausted; downloads resume when the window resets",
+			"resets_at": resets.Format(time.RFC3339),
+		})
+	}
+}
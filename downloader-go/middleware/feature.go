@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Feature gates the routes of a feature the operator can switch off: when
+// disabled they answer 501 instead of reaching their handler
+func Feature(disabled bool, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if disabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": name + " is disabled on this server"})
+			return
+		}
+		c.Next()
+	}
+}
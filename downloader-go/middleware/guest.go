@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GuestContextKey is set on the gin context of requests served as a guest
+const GuestContextKey = "guest"
+
+// guestUnmetered reports whether a path doesn't count against a guest's
+// budget: fetches of links the API already issued (and metered), /download
+// exactly and short links under /d/, and polls of an issued progress token.
+// Issuing progress tokens and /download-slideshow are metered like any other
+// endpoint.
+func guestUnmetered(path string) bool {
+	return path == "/download" || strings.HasPrefix(path, "/d/") || strings.HasPrefix(path, "/download-progress/")
+}
+
+// guestUsage is the guest budget state of one client IP
+type guestUsage struct {
+	quotaStart  time.Time
+	quotaUsed   int
+	windowStart time.Time
+	windowUsed  int
+}
+
+// guestTier enforces the guest rate limit, shrinking with the quota used
+type guestTier struct {
+	cfg *config.AppConfig
+
+	mu        sync.Mutex
+	clients   map[string]*guestUsage
+	lastSweep time.Time
+}
+
+// Guest returns a middleware that serves requests without a known X-API-Key
+// as guests, or nil when GUEST_MODE is off. Guests are limited to
+// GUEST_RATE_LIMIT_REQUESTS per window, scaled down by the share of their
+// GUEST_QUOTA already used, and need a key once the quota is gone.
+func Guest(cfg *config.AppConfig) gin.HandlerFunc {
+	if !cfg.GuestMode {
+		return nil
+	}
+	metrics.Register("tikdownloader_guest_requests_total", "Guest requests, by whether they were served or rejected.", metrics.Counter)
+
+	g := &guestTier{cfg: cfg, clients: make(map[string]*guestUsage)}
+	return g.handle
+}
+
+// IsGuest reports whether a request is served as a guest
+func IsGuest(c *gin.Context) bool {
+	return c.GetBool(GuestContextKey)
+}
+
+// NoGuests rejects guests from features reserved to API key holders
+func NoGuests(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsGuest(c) {
+			metrics.Inc("tikdownloader_guest_requests_total", metrics.Labels{"result": "feature_denied"})
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": feature + " requires an API key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handle marks guests and admits or rejects them
+func (g *guestTier) handle(c *gin.Context) {
+	if rateLimitExempt[c.Request.URL.Path] || c.Request.Method == http.MethodOptions || g.cfg.KnownAPIKey(c.GetHeader("X-API-Key")) {
+		c.Next()
+		return
+	}
+	c.Set(GuestContextKey, true)
+	if guestUnmetered(c.Request.URL.Path) {
+		c.Next()
+		return
+	}
+
+	client := c.ClientIP()
+	now := time.Now()
+	quotaWindow, window := g.windows()
+
+	g.mu.Lock()
+	g.sweep(now, quotaWindow)
+	usage, ok := g.clients[client]
+	if !ok {
+		usage = &guestUsage{quotaStart: now, windowStart: now}
+		g.clients[client] = usage
+	}
+	if now.Sub(usage.quotaStart) >= quotaWindow {
+		usage.quotaStart = now
+		usage.quotaUsed = 0
+	}
+	if now.Sub(usage.windowStart) >= window {
+		usage.windowStart = now
+		usage.windowUsed = 0
+	}
+	limit := g.windowLimit(usage.quotaUsed)
+
+	reason, retryAfter := "", 0
+	switch {
+	case g.cfg.GuestQuota > 0 && usage.quotaUsed >= g.cfg.GuestQuota:
+		reason = "quota"
+		retryAfter = int(math.Ceil(usage.quotaStart.Add(quotaWindow).Sub(now).Seconds()))
+	case limit > 0 && usage.windowUsed >= limit:
+		reason = "rate"
+		retryAfter = int(math.Ceil(usage.windowStart.Add(window).Sub(now).Seconds()))
+	default:
+		usage.quotaUsed++
+		usage.windowUsed++
+	}
+	quotaLeft := g.cfg.GuestQuota - usage.quotaUsed
+	g.mu.Unlock()
+
+	if g.cfg.GuestQuota > 0 {
+		c.Header("X-Guest-Quota-Remaining", strconv.Itoa(max(quotaLeft, 0)))
+	}
+
+	switch reason {
+	case "quota":
+		metrics.Inc("tikdownloader_guest_requests_total", metrics.Labels{"result": "quota_exhausted"})
+		c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Guest quota used up, an API key is required"})
+	case "rate":
+		metrics.Inc("tikdownloader_guest_requests_total", metrics.Labels{"result": "rate_limited"})
+		c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many guest requests, retry later or use an API key"})
+	default:
+		metrics.Inc("tikdownloader_guest_requests_total", metrics.Labels{"result": "served"})
+		c.Next()
+	}
+}
+
+// windows returns the quota and rate windows, defaulting unset ones
+func (g *guestTier) windows() (time.Duration, time.Duration) {
+	quotaWindow, window := g.cfg.GuestQuotaWindow, g.cfg.GuestRateLimitWindow
+	if quotaWindow <= 0 {
+		quotaWindow = 24 * time.Hour
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return quotaWindow, window
+}
+
+// windowLimit is the guest rate limit scaled by the share of the quota left,
+// so heavy guests slow down progressively; it never drops below one request
+func (g *guestTier) windowLimit(quotaUsed int) int {
+	limit := g.cfg.GuestRateLimitRequests
+	if limit <= 0 || g.cfg.GuestQuota <= 0 {
+		return limit
+	}
+	left := float64(g.cfg.GuestQuota-quotaUsed) / float64(g.cfg.GuestQuota)
+	return max(int(math.Ceil(float64(limit)*left)), 1)
+}
+
+// sweep drops clients whose quota window has ended, at most once a minute
+func (g *guestTier) sweep(now time.Time, quotaWindow time.Duration) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+	for client, usage := range g.clients {
+		if now.Sub(usage.quotaStart) >= quotaWindow {
+			delete(g.clients, client)
+		}
+	}
+}
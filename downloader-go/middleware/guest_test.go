@@ -0,0 +1,24 @@
+package middleware
+
+import "testing"
+
+func TestGuestUnmetered(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/download", true},
+		{"/d/abc123", true},
+		{"/download-progress", false},
+		{"/download-progress/token", true},
+		{"/download-slideshow", false},
+		{"/downloads", false},
+		{"/d", false},
+		{"/tiktok", false},
+	}
+	for _, tt := range tests {
+		if got := guestUnmetered(tt.path); got != tt.want {
+			t.Errorf("guestUnmetered(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
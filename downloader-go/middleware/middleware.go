@@ -1,23 +1,60 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"tiktok-downloader/config"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 )
 
-// CorsMiddleware returns a CORS middleware with appropriate configuration
-func CorsMiddleware() gin.HandlerFunc {
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"}
-	config.ExposeHeaders = []string{"Content-Disposition", "X-Filename"}
-	
-	return cors.New(config)
+// CorsMiddleware returns a CORS middleware configured from the app config.
+// Origins may contain wildcards such as "https://*.tiktok.com" so that
+// userscripts and extensions can call the API from TikTok pages.
+func CorsMiddleware(cfg *config.AppConfig) gin.HandlerFunc {
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = len(cfg.CorsAllowOrigins) == 0 || cfg.CorsAllowOrigins[0] == "*"
+	if !corsConfig.AllowAllOrigins {
+		corsConfig.AllowOrigins = cfg.CorsAllowOrigins
+		corsConfig.AllowWildcard = strings.Contains(strings.Join(cfg.CorsAllowOrigins, ","), "*")
+	}
+	corsConfig.AllowMethods = []string{"GET", "POST", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "X-API-Key", "X-Priority"}
+	corsConfig.ExposeHeaders = []string{"Content-Disposition", "X-Filename", "X-Slideshow-Poster", "X-Slideshow-Preview", "X-Removed-Indices", "X-Skipped-Indices", "X-HDR-Tonemapped", "X-Progress-Token",
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Concurrency-Remaining", "X-Guest-Quota-Remaining", "Retry-After"}
+	corsConfig.AllowPrivateNetwork = cfg.CorsPrivateNet
+	corsConfig.MaxAge = cfg.CorsMaxAge
+
+	return cors.New(corsConfig)
 }
 
 // GzipMiddleware returns a GZIP compression middleware
 func GzipMiddleware() gin.HandlerFunc {
 	return gzip.Gzip(gzip.DefaultCompression)
-}
\ No newline at end of file
+}
+
+// AdminAuth protects admin endpoints with the ADMIN_TOKEN bearer token.
+// Admin endpoints are disabled entirely when no token is configured.
+func AdminAuth(cfg *config.AppConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.AdminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			token = c.GetHeader("X-Admin-Token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitExempt lists paths probed by infrastructure, which are never limited
+var rateLimitExempt = map[string]bool{
+	"/health":  true,
+	"/version": true,
+	"/metrics": true,
+}
+
+// clientLimits is the limiter state of one client IP
+type clientLimits struct {
+	windowStart time.Time
+	requests    int
+	active      int
+}
+
+// limiter enforces a per-client request budget per window and a hard cap on
+// in-flight requests. The soft concurrency limit is only advertised: requests
+// past it are still served, up to the hard limit.
+type limiter struct {
+	requests int
+	window   time.Duration
+	soft     int
+	hard     int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimits
+	lastSweep time.Time
+}
+
+// RateLimit returns a middleware that limits each client IP and reports the
+// remaining budget in X-RateLimit-* and X-Concurrency-Remaining headers on
+// every response, or nil when no limit is configured
+func RateLimit(cfg *config.AppConfig) gin.HandlerFunc {
+	if cfg.RateLimitRequests <= 0 && cfg.ConcurrencyHardLimit <= 0 {
+		return nil
+	}
+	metrics.Register("tikdownloader_rate_limited_total", "Requests rejected with 429, by exceeded limit.", metrics.Counter)
+
+	l := &limiter{
+		requests: cfg.RateLimitRequests,
+		window:   cfg.RateLimitWindow,
+		soft:     cfg.ConcurrencySoftLimit,
+		hard:     cfg.ConcurrencyHardLimit,
+		clients:  make(map[string]*clientLimits),
+	}
+	if l.window <= 0 {
+		l.window = time.Minute
+	}
+	if l.soft <= 0 || (l.hard > 0 && l.soft > l.hard) {
+		l.soft = l.hard
+	}
+
+	return l.handle
+}
+
+// handle admits or rejects a request and writes the limit headers
+func (l *limiter) handle(c *gin.Context) {
+	if rateLimitExempt[c.Request.URL.Path] || c.Request.Method == http.MethodOptions {
+		c.Next()
+		return
+	}
+
+	client := c.ClientIP()
+	now := time.Now()
+
+	l.mu.Lock()
+	l.sweep(now)
+	state, ok := l.clients[client]
+	if !ok {
+		state = &clientLimits{windowStart: now}
+		l.clients[client] = state
+	}
+	if now.Sub(state.windowStart) >= l.window {
+		state.windowStart = now
+		state.requests = 0
+	}
+	reset := int(math.Ceil(state.windowStart.Add(l.window).Sub(now).Seconds()))
+
+	reason := ""
+	switch {
+	case l.requests > 0 && state.requests >= l.requests:
+		reason = "rate"
+	case l.hard > 0 && state.active >= l.hard:
+		reason = "concurrency"
+	default:
+		state.requests++
+		state.active++
+	}
+	requestsLeft, activeLeft := l.requests-state.requests, l.soft-state.active
+	l.mu.Unlock()
+
+	if l.requests > 0 {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(l.requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(max(requestsLeft, 0)))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(reset))
+	}
+	if l.soft > 0 {
+		c.Header("X-Concurrency-Remaining", strconv.Itoa(max(activeLeft, 0)))
+	}
+
+	if reason != "" {
+		metrics.Inc("tikdownloader_rate_limited_total", metrics.Labels{"limit": reason})
+		retryAfter := 1
+		if reason == "rate" {
+			retryAfter = max(reset, 1)
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		message := "Too many requests, retry after the window resets"
+		if reason == "concurrency" {
+			message = "Too many concurrent requests, wait for one to finish"
+		}
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+		return
+	}
+
+	defer func() {
+		l.mu.Lock()
+		state.active--
+		l.mu.Unlock()
+	}()
+	c.Next()
+}
+
+// sweep drops idle clients whose window has ended, at most once per window
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for client, state := range l.clients {
+		if state.active == 0 && now.Sub(state.windowStart) >= l.window {
+			delete(l.clients, client)
+		}
+	}
+}
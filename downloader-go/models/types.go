@@ -2,7 +2,26 @@ package models
 
 // TikTokRequest represents the request for TikTok URL processing
 type TikTokRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL    string `json:"url" form:"url"`
+	Verify bool   `json:"verify" form:"verify"`
+	// AwemeID and Platform ("tiktok" or "douyin") identify the post instead
+	// of URL, for clients that store post IDs rather than links
+	AwemeID  string `json:"aweme_id" form:"aweme_id"`
+	Platform string `json:"platform" form:"platform"`
+	// Async resolves the post in the background and POSTs the response to CallbackURL
+	Async       bool   `json:"async" form:"async"`
+	CallbackURL string `json:"callback_url" form:"callback_url"`
+	// Cursor and Count page through the posts of a sound page URL
+	Cursor int64 `json:"cursor" form:"cursor"`
+	Count  int   `json:"count" form:"count"`
+	// MetadataOnly returns title, author, cover and counts without download links
+	MetadataOnly bool `json:"metadata_only" form:"metadata_only"`
+	// Translate adds the description translated to this language
+	Translate string `json:"translate" form:"translate"`
+	// Debug adds the timing and resolver breakdown in Meta
+	Debug bool `json:"debug" form:"debug"`
+	// LinkTTL overrides how long the response's links stay valid, in seconds
+	LinkTTL int `json:"link_ttl" form:"link_ttl"`
 }
 
 // DownloadData represents the data encrypted for download links
@@ -10,8 +29,25 @@ type DownloadData struct {
 	URL    string `json:"url"`
 	Author string `json:"author"`
 	Type   string `json:"type"`
+	// Source, Key and Index identify the media within its post so it can be re-resolved
+	Source string `json:"source,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Index  int    `json:"index,omitempty"`
+	// Tenant owns the API key the link was issued to; its bytes are accounted to it
+	Tenant string `json:"tenant,omitempty"`
+	// Filename is the ASCII name, without extension, for clients that can't
+	// take Author as a UTF-8 filename
+	Filename string `json:"filename,omitempty"`
+	// PostID is the aweme ID, embedded with the source into attributed images
+	PostID string `json:"post_id,omitempty"`
+	// Audience restricts the token to one endpoint; /download only takes
+	// tokens without one
+	Audience string `json:"aud,omitempty"`
 }
 
+// AudienceEmbed marks the tokens of /embed/:token player links
+const AudienceEmbed = "embed"
+
 // Author represents the creator of TikTok content
 type Author struct {
 	Nickname  string `json:"nickname"`
@@ -27,12 +63,57 @@ type Statistics struct {
 	PlayCount    int `json:"play_count"`
 }
 
+// Music describes the sound used by a post
+type Music struct {
+	ID            string `json:"id,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Author        string `json:"author,omitempty"`
+	Album         string `json:"album,omitempty"`
+	Cover         string `json:"cover,omitempty"`
+	Duration      int    `json:"duration"`
+	OriginalSound bool   `json:"original_sound"`
+}
+
 // PhotoItem represents a single photo in an image gallery
 type PhotoItem struct {
 	Type string `json:"type"`
 	URL  string `json:"url"`
 }
 
+// Warning flags part of a post that could not be resolved, so clients can
+// tell a partial success from a complete one
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Translation is the description of a post translated to Language
+type Translation struct {
+	Language    string `json:"language"`
+	Description string `json:"description"`
+}
+
+// ResponseMeta breaks down how a debug=true response was produced: time
+// spent fetching from the hybrid API and building the links, whether the
+// hybrid API response came from the cache, and the endpoint that served it
+type ResponseMeta struct {
+	UpstreamMS int64  `json:"upstream_ms"`
+	LinksMS    int64  `json:"links_ms"`
+	Cache      string `json:"cache"`
+	Resolver   string `json:"resolver"`
+}
+
+// DownloadOption is one entry of the ordered download picker
+type DownloadOption struct {
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	Quality     string `json:"quality"`
+	URL         string `json:"url"`
+	Index       *int   `json:"index,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Recommended bool   `json:"recommended"`
+}
+
 // TikTokResponse is the response sent back to the client
 type TikTokResponse struct {
 	Status            string                 `json:"status"`
@@ -45,7 +126,86 @@ type TikTokResponse struct {
 	Duration          int                    `json:"duration"`
 	Audio             string                 `json:"audio,omitempty"`
 	MusicDuration     int                    `json:"music_duration"`
+	Music             *Music                 `json:"music,omitempty"`
 	Author            Author                 `json:"author"`
-	DownloadLink      map[string]interface{} `json:"download_link"`
+	Downloads         []DownloadOption       `json:"download_link"`
 	SlideshowDownLink string                 `json:"download_slideshow_link,omitempty"`
-}
\ No newline at end of file
+	GifDownLink       string                 `json:"download_gif_link,omitempty"`
+	ScenesLink        string                 `json:"scenes_link,omitempty"`
+	WaveformLink      string                 `json:"waveform_link,omitempty"`
+	Translation       *Translation           `json:"translation,omitempty"`
+	Meta              *ResponseMeta          `json:"meta,omitempty"`
+	EmbedLink         string                 `json:"embed_link,omitempty"`
+	LinkStatus        map[string]interface{} `json:"link_status,omitempty"`
+	Warnings          []Warning              `json:"warnings,omitempty"`
+
+	// Extra holds deployment-specific fields added by response hooks
+	Extra map[string]interface{} `json:"extra,omitempty"`
+
+	// DownloadLink holds the signed links keyed by media key; it is rendered
+	// as Downloads, or as-is under the legacy compat mode
+	DownloadLink map[string]interface{} `json:"-"`
+
+	// MediaSources holds the upstream URL behind each download link, keyed like DownloadLink
+	MediaSources map[string]interface{} `json:"-"`
+
+	// MediaSizes holds the byte size of each download, when upstream reports it
+	MediaSizes map[string]int64 `json:"-"`
+}
+
+// MusicVideo is a post listed on a sound page
+type MusicVideo struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	Author      Author `json:"author"`
+	Cover       string `json:"cover,omitempty"`
+	PlayCount   int    `json:"play_count"`
+}
+
+// MusicResponse is the response sent back for a sound page URL
+type MusicResponse struct {
+	Status    string           `json:"status"`
+	Platform  string           `json:"platform,omitempty"`
+	Music     *Music           `json:"music"`
+	Cover     string           `json:"cover,omitempty"`
+	Audio     string           `json:"audio,omitempty"`
+	UserCount int              `json:"user_count"`
+	Downloads []DownloadOption `json:"download_link"`
+	Videos    []MusicVideo     `json:"videos"`
+	Cursor    int64            `json:"cursor"`
+	HasMore   bool             `json:"has_more"`
+	Warnings  []Warning        `json:"warnings,omitempty"`
+}
+
+// ArchiveRequest represents a request to archive one or more posts to disk
+type ArchiveRequest struct {
+	URLs   []string `json:"urls" binding:"required"`
+	Naming string   `json:"naming"`
+}
+
+// TranscribeRequest represents a request to transcribe the speech of a post,
+// with an optional ISO-639-1 language hint
+type TranscribeRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Language string `json:"language"`
+	// Translate adds the transcript translated to this language
+	Translate string `json:"translate"`
+}
+
+// BackfillRequest represents a request to archive every URL listed in a manifest
+type BackfillRequest struct {
+	Source  string `json:"source" binding:"required"`
+	Naming  string `json:"naming"`
+	Restart bool   `json:"restart"`
+}
+
+// ArchiveItem reports the outcome of archiving a single post
+type ArchiveItem struct {
+	URL     string   `json:"url"`
+	State   string   `json:"state"`
+	ID      string   `json:"id,omitempty"`
+	Files   []string `json:"files,omitempty"`
+	Skipped bool     `json:"skipped,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
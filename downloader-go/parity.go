@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"tiktok-downloader/utils"
+)
+
+// runParityCommand implements `tiktok-downloader parity [flags] <post URL>...`,
+// comparing /tiktok responses of this variant and the fiber variant, and
+// returns the process exit code
+func runParityCommand(args []string) int {
+	fs := flag.NewFlagSet("parity", flag.ContinueOnError)
+	goURL := fs.String("go", "http://127.0.0.1:3021", "base URL of the downloader-go instance")
+	fiberURL := fs.String("fiber", "http://127.0.0.1:6075", "base URL of the downloader-fiber instance")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tiktok-downloader parity [flags] <post URL>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	// The fiber variant serves the Node.js schema, which this variant emits under compat=node
+	client := &http.Client{Timeout: 2 * time.Minute}
+	mismatches := 0
+	for _, postURL := range fs.Args() {
+		goResp, err := fetchParityResponse(client, strings.TrimRight(*goURL, "/")+"/tiktok?compat=node", postURL)
+		if err != nil {
+			log.Printf("%s: go: %v", postURL, err)
+			mismatches++
+			continue
+		}
+		fiberResp, err := fetchParityResponse(client, strings.TrimRight(*fiberURL, "/")+"/tiktok", postURL)
+		if err != nil {
+			log.Printf("%s: fiber: %v", postURL, err)
+			mismatches++
+			continue
+		}
+
+		diffs := utils.DiffParity(goResp, fiberResp)
+		for _, diff := range diffs {
+			log.Printf("%s: %s", postURL, diff)
+		}
+		if len(diffs) == 0 {
+			log.Printf("%s: responses match", postURL)
+		}
+		mismatches += len(diffs)
+	}
+
+	if mismatches > 0 {
+		log.Printf("Parity check found %d differences", mismatches)
+		return 1
+	}
+	return 0
+}
+
+// fetchParityResponse posts a URL to a /tiktok endpoint and decodes the reply
+func fetchParityResponse(client *http.Client, endpoint, postURL string) (interface{}, error) {
+	body, err := json.Marshal(map[string]string{"url": postURL})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, raw)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
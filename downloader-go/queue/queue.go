@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+)
+
+// durationWindow is how many recent task durations feed the rolling average
+const durationWindow = 20
+
+// Task priorities; higher priorities are dequeued first, FIFO within a priority
+const (
+	PriorityBatch       = 0
+	PriorityNormal      = 1
+	PriorityInteractive = 2
+)
+
+// task is a unit of work waiting for or holding a worker
+type task struct {
+	id       string
+	priority int
+	fn       func()
+}
+
+// Queue runs submitted tasks on a fixed number of workers in FIFO order and
+// estimates waiting times from the rolling average task duration
+type Queue struct {
+	name    string
+	workers int
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	waiting   []*task
+	durations []time.Duration
+}
+
+// New creates a queue and starts its workers
+func New(name string, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{name: name, workers: workers}
+	q.cond = sync.NewCond(&q.mu)
+
+	metrics.Register("tikdownloader_queue_depth", "Tasks waiting for a worker, by queue.", metrics.Gauge)
+	metrics.Register("tikdownloader_queue_workers", "Workers serving each queue.", metrics.Gauge)
+	metrics.Register("tikdownloader_queue_panics_total", "Tasks that panicked, by queue.", metrics.Counter)
+	metrics.Set("tikdownloader_queue_workers", metrics.Labels{"queue": name}, float64(workers))
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Submit enqueues fn under id at normal priority and returns its 1-based queue position
+func (q *Queue) Submit(id string, fn func()) int {
+	return q.SubmitPriority(id, PriorityNormal, fn)
+}
+
+// SubmitPriority enqueues fn ahead of every waiting task with a lower
+// priority and returns its 1-based queue position
+func (q *Queue) SubmitPriority(id string, priority int, fn func()) int {
+	return q.enqueue(&task{id: id, priority: priority, fn: fn})
+}
+
+// Do runs fn on a worker at the given priority and waits for it to finish.
+// If ctx ends while fn is still waiting, it is dropped from the queue and
+// ctx's error is returned. A panic in fn is returned as an error.
+func (q *Queue) Do(ctx context.Context, id string, priority int, fn func()) error {
+	done := make(chan struct{})
+	ran := false
+	var panicErr error
+	t := &task{id: id, priority: priority, fn: func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr = q.recovered(r)
+			}
+		}()
+		if ctx.Err() == nil {
+			ran = true
+			fn()
+		}
+	}}
+	q.enqueue(t)
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if q.withdraw(t) {
+			return ctx.Err()
+		}
+		// Already running; fn observes ctx itself, so wait for it to return
+		<-done
+	}
+
+	if !ran {
+		return ctx.Err()
+	}
+	return panicErr
+}
+
+// enqueue inserts t after every waiting task of equal or higher priority
+func (q *Queue) enqueue(t *task) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pos := len(q.waiting)
+	for i, waiting := range q.waiting {
+		if waiting.priority < t.priority {
+			pos = i
+			break
+		}
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[pos+1:], q.waiting[pos:])
+	q.waiting[pos] = t
+
+	q.updateDepth()
+	q.cond.Signal()
+	return pos + 1
+}
+
+// withdraw removes a task that no worker has picked up yet
+func (q *Queue) withdraw(t *task) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, waiting := range q.waiting {
+		if waiting == t {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.updateDepth()
+			return true
+		}
+	}
+	return false
+}
+
+// Position returns the 1-based position of a waiting task, or false once it has started
+func (q *Queue) Position(id string) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, t := range q.waiting {
+		if t.id == id {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// ETA estimates how long until a task at position finishes, or false while
+// there is no duration history to base it on
+func (q *Queue) ETA(position int) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.durations) == 0 {
+		return 0, false
+	}
+
+	var total time.Duration
+	for _, d := range q.durations {
+		total += d
+	}
+	average := total / time.Duration(len(q.durations))
+
+	// Tasks ahead run in batches of q.workers, then this task runs
+	batchesAhead := (position - 1) / q.workers
+	return time.Duration(batchesAhead+1) * average, true
+}
+
+// work runs tasks until the process exits
+func (q *Queue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.waiting) == 0 {
+			q.cond.Wait()
+		}
+		t := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.updateDepth()
+		q.mu.Unlock()
+
+		start := time.Now()
+		q.run(t)
+		q.record(time.Since(start))
+	}
+}
+
+// run calls a task's function, keeping a panic from taking down the worker
+func (q *Queue) run(t *task) {
+	defer func() {
+		if r := recover(); r != nil {
+			q.recovered(r)
+		}
+	}()
+	t.fn()
+}
+
+// recovered logs a task panic and returns it as an error carrying the log reference
+func (q *Queue) recovered(value interface{}) error {
+	metrics.Inc("tikdownloader_queue_panics_total", metrics.Labels{"queue": q.name})
+	return fmt.Errorf("internal error (reference %s)", logging.Panic(value))
+}
+
+// record adds a finished task's duration to the rolling window
+func (q *Queue) record(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.durations = append(q.durations, d)
+	if len(q.durations) > durationWindow {
+		q.durations = q.durations[len(q.durations)-durationWindow:]
+	}
+}
+
+// updateDepth publishes the queue depth; callers must hold q.mu
+func (q *Queue) updateDepth() {
+	metrics.Set("tikdownloader_queue_depth", metrics.Labels{"queue": q.name}, float64(len(q.waiting)))
+}
@@ -0,0 +1,141 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"tiktok-downloader/config"
+)
+
+// Restart backoff bounds; a process that stayed up for stableAfter resets the backoff
+const (
+	minBackoff  = time.Second
+	maxBackoff  = 30 * time.Second
+	stableAfter = time.Minute
+	stopTimeout = 10 * time.Second
+)
+
+// HybridAPI runs the bundled Python hybrid API as a managed subprocess on a
+// local port, restarting it whenever it exits
+type HybridAPI struct {
+	args []string
+	dir  string
+	port int
+}
+
+// New returns a supervisor for the configured hybrid API command, or nil when
+// embedding is disabled. "{port}" in the command is replaced by the port the
+// subprocess must listen on, which is also passed as the PORT env var.
+func New(cfg *config.AppConfig) (*HybridAPI, error) {
+	if cfg.HybridEmbedCommand == "" {
+		return nil, nil
+	}
+
+	port := cfg.HybridEmbedPort
+	if port == 0 {
+		var err error
+		if port, err = freePort(); err != nil {
+			return nil, fmt.Errorf("could not allocate a port for the hybrid API: %w", err)
+		}
+	}
+
+	args := strings.Fields(strings.ReplaceAll(cfg.HybridEmbedCommand, "{port}", strconv.Itoa(port)))
+	return &HybridAPI{args: args, dir: cfg.HybridEmbedDir, port: port}, nil
+}
+
+// freePort asks the kernel for an unused loopback port
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Endpoint returns the hybrid API video data URL served by the subprocess
+func (s *HybridAPI) Endpoint() string {
+	return fmt.Sprintf("http://127.0.0.1:%d/api/hybrid/video_data", s.port)
+}
+
+// Run starts the subprocess and restarts it with backoff until ctx is done,
+// then stops it gracefully
+func (s *HybridAPI) Run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > stableAfter {
+			backoff = minBackoff
+		}
+		log.Printf("Embedded hybrid API exited (%v), restarting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// runOnce runs the subprocess until it exits or ctx is done
+func (s *HybridAPI) runOnce(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, s.args[0], s.args[1:]...)
+	cmd.Dir = s.dir
+	cmd.Env = append(os.Environ(), "PORT="+strconv.Itoa(s.port))
+	// Ask for a clean shutdown first and only kill if it doesn't exit in time
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = stopTimeout
+
+	output := logWriter{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	log.Printf("Starting embedded hybrid API on port %d: %s", s.port, strings.Join(s.args, " "))
+	return cmd.Run()
+}
+
+// logWriter copies subprocess output into the service log, one entry per line
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		log.Printf("[hybrid-api] %s", line)
+	}
+	return len(p), nil
+}
+
+// WaitReady blocks until the subprocess accepts connections or timeout elapses
+func (s *HybridAPI) WaitReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(s.port))
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("hybrid API not listening on %s after %s", address, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dayFormat keys the daily buckets, in UTC
+const dayFormat = "2006-01-02"
+
+// Day is the traffic of a tenant on one UTC day
+type Day struct {
+	Date      string `json:"date"`
+	Bytes     int64  `json:"bytes"`
+	Downloads int64  `json:"downloads"`
+	Requests  int64  `json:"requests"`
+}
+
+// Report is the accumulated traffic of a tenant
+type Report struct {
+	Tenant    string    `json:"tenant"`
+	Bytes     int64     `json:"bytes"`
+	Downloads int64     `json:"downloads"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Days      []Day     `json:"days,omitempty"`
+}
+
+// tenantUsage is the ledger entry of one tenant, also its on-disk format
+type tenantUsage struct {
+	Bytes     int64           `json:"bytes"`
+	Downloads int64           `json:"downloads"`
+	Requests  int64           `json:"requests"`
+	FirstSeen time.Time       `json:"first_seen"`
+	LastSeen  time.Time       `json:"last_seen"`
+	Days      map[string]*Day `json:"days"`
+}
+
+// Ledger accounts requests and streamed bytes to the tenant that issued each
+// download token, optionally persisted to a JSON file
+type Ledger struct {
+	path string
+
+	mu      sync.Mutex
+	tenants map[string]*tenantUsage
+	dirty   bool
+}
+
+// NewLedger creates a ledger backed by path; an empty path keeps it in memory only
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path, tenants: make(map[string]*tenantUsage)}
+}
+
+// Load reads the ledger from disk; a missing file leaves it empty
+func (l *Ledger) Load() error {
+	if l.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tenants := make(map[string]*tenantUsage)
+	if err := json.Unmarshal(raw, &tenants); err != nil {
+		return fmt.Errorf("invalid usage file %s: %w", l.path, err)
+	}
+	l.mu.Lock()
+	l.tenants = tenants
+	l.mu.Unlock()
+	return nil
+}
+
+// RecordRequest counts a /tiktok request that issued tokens for tenant
+func (l *Ledger) RecordRequest(tenant string) {
+	l.record(tenant, func(u *tenantUsage, day *Day) {
+		u.Requests++
+		day.Requests++
+	})
+}
+
+// RecordDownload accounts a download of n bytes to tenant
+func (l *Ledger) RecordDownload(tenant string, n int64) {
+	if n < 0 {
+		n = 0
+	}
+	l.record(tenant, func(u *tenantUsage, day *Day) {
+		u.Downloads++
+		u.Bytes += n
+		day.Downloads++
+		day.Bytes += n
+	})
+}
+
+// RecordBytes accounts n bytes to tenant that continue a download already
+// counted, such as a later Range request of a video
+func (l *Ledger) RecordBytes(tenant string, n int64) {
+	if n <= 0 {
+		return
+	}
+	l.record(tenant, func(u *tenantUsage, day *Day) {
+		u.Bytes += n
+		day.Bytes += n
+	})
+}
+
+// record applies update to the tenant's totals and today's bucket
+func (l *Ledger) record(tenant string, update func(*tenantUsage, *Day)) {
+	if tenant == "" {
+		return
+	}
+	now := time.Now()
+	date := now.UTC().Format(dayFormat)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.tenants[tenant]
+	if !ok {
+		u = &tenantUsage{FirstSeen: now, Days: make(map[string]*Day)}
+		l.tenants[tenant] = u
+	}
+	if u.Days == nil {
+		u.Days = make(map[string]*Day)
+	}
+	day, ok := u.Days[date]
+	if !ok {
+		day = &Day{Date: date}
+		u.Days[date] = day
+	}
+	u.LastSeen = now
+	update(u, day)
+	l.dirty = true
+}
+
+// Report returns the usage of one tenant with its daily buckets since the
+// given day (UTC), or false when the tenant has no recorded traffic
+func (l *Ledger) Report(tenant string, since time.Time) (Report, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.tenants[tenant]
+	if !ok {
+		return Report{}, false
+	}
+	return u.report(tenant, since), true
+}
+
+// Reports returns the usage of every tenant, sorted by name
+func (l *Ledger) Reports(since time.Time) []Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reports := make([]Report, 0, len(l.tenants))
+	for tenant, u := range l.tenants {
+		reports = append(reports, u.report(tenant, since))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Tenant < reports[j].Tenant })
+	return reports
+}
+
+// report copies the totals and the daily buckets on or after since
+func (u *tenantUsage) report(tenant string, since time.Time) Report {
+	report := Report{
+		Tenant:    tenant,
+		Bytes:     u.Bytes,
+		Downloads: u.Downloads,
+		Requests:  u.Requests,
+		FirstSeen: u.FirstSeen,
+		LastSeen:  u.LastSeen,
+	}
+	from := since.UTC().Format(dayFormat)
+	for date, day := range u.Days {
+		if date >= from {
+			report.Days = append(report.Days, *day)
+		}
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	return report
+}
+
+// Save writes the ledger to disk if it changed since the last save
+func (l *Ledger) Save() error {
+	if l.path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	raw, err := json.MarshalIndent(l.tenants, "", "  ")
+	l.dirty = false
+	l.mu.Unlock()
+	if err == nil {
+		err = writeFile(l.path, raw)
+	}
+	if err != nil {
+		// Keep the changes pending so the next save retries them
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// writeFile replaces path atomically with raw
+func writeFile(path string, raw []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Run saves the ledger every interval until ctx is done
+func (l *Ledger) Run(ctx context.Context, interval time.Duration) {
+	if l.path == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Save(); err != nil {
+				log.Printf("Error saving usage ledger: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Attribution identifies where a served photo came from, so it stays
+// attributable after leaving the service
+type Attribution struct {
+	SourceURL string
+	Author    string
+	PostID    string
+	Time      time.Time
+}
+
+// ErrAttributionUnsupported is returned for images whose format can't carry
+// embedded attribution, e.g. HEIF and PNG
+var ErrAttributionUnsupported = errors.New("image format does not support embedded attribution")
+
+// APP1 payload prefixes of EXIF and XMP segments in a JPEG
+const (
+	jpegExifPrefix = "Exif\x00\x00"
+	jpegXMPPrefix  = "http://ns.adobe.com/xap/1.0/\x00"
+)
+
+// EmbedAttribution returns a copy of a JPEG or WebP image carrying a as EXIF
+// (source URL as ImageDescription, author as Artist, time as DateTime) and
+// XMP (Dublin Core source, creator and identifier). Existing EXIF and XMP
+// metadata is replaced.
+func EmbedAttribution(img []byte, a Attribution) ([]byte, error) {
+	exif := attributionEXIF(a)
+	xmp := attributionXMP(a)
+
+	switch DetectImageFormat(img) {
+	case ImageFormatJPEG:
+		return embedJPEGMetadata(img, exif, xmp)
+	case ImageFormatWebP:
+		return embedWebPMetadata(img, exif, xmp)
+	}
+	return nil, ErrAttributionUnsupported
+}
+
+// attributionEXIF builds a little-endian TIFF structure holding a single
+// IFD with the attribution tags
+func attributionEXIF(a Attribution) []byte {
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	// IFD entries must be sorted by tag
+	var entries []entry
+	if a.SourceURL != "" {
+		entries = append(entries, entry{0x010E, a.SourceURL}) // ImageDescription
+	}
+	if !a.Time.IsZero() {
+		entries = append(entries, entry{0x0132, a.Time.Format("2006:01:02 15:04:05")}) // DateTime
+	}
+	if a.Author != "" {
+		entries = append(entries, entry{0x013B, a.Author}) // Artist
+	}
+
+	const headerSize = 8
+	ifdSize := 2 + 12*len(entries) + 4
+	var ifd, values bytes.Buffer
+	binary.Write(&ifd, binary.LittleEndian, uint16(len(entries)))
+	for _, e := range entries {
+		value := append([]byte(e.value), 0)
+		binary.Write(&ifd, binary.LittleEndian, e.tag)
+		binary.Write(&ifd, binary.LittleEndian, uint16(2)) // ASCII
+		binary.Write(&ifd, binary.LittleEndian, uint32(len(value)))
+		if len(value) <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, value)
+			ifd.Write(inline)
+			continue
+		}
+		binary.Write(&ifd, binary.LittleEndian, uint32(headerSize+ifdSize+values.Len()))
+		values.Write(value)
+		if values.Len()%2 == 1 {
+			values.WriteByte(0) // values start on word boundaries
+		}
+	}
+	binary.Write(&ifd, binary.LittleEndian, uint32(0)) // no next IFD
+
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, binary.LittleEndian, uint32(headerSize))
+	tiff.Write(ifd.Bytes())
+	tiff.Write(values.Bytes())
+	return tiff.Bytes()
+}
+
+// attributionXMP builds an XMP packet with the attribution as Dublin Core
+// properties and the time as xmp:MetadataDate
+func attributionXMP(a Attribution) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/">` + "\n")
+	property := func(name, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(&b, "   <%s>", name)
+		xml.EscapeText(&b, []byte(value))
+		fmt.Fprintf(&b, "</%s>\n", name)
+	}
+	property("dc:source", a.SourceURL)
+	property("dc:identifier", a.PostID)
+	if a.Author != "" {
+		b.WriteString("   <dc:creator><rdf:Seq><rdf:li>")
+		xml.EscapeText(&b, []byte(a.Author))
+		b.WriteString("</rdf:li></rdf:Seq></dc:creator>\n")
+	}
+	if !a.Time.IsZero() {
+		property("xmp:MetadataDate", a.Time.Format(time.RFC3339))
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
+
+// jpegSegment encodes an APP1 segment with the given payload
+func jpegSegment(prefix string, payload []byte) ([]byte, error) {
+	length := 2 + len(prefix) + len(payload)
+	if length > 0xFFFF {
+		return nil, fmt.Errorf("metadata segment too large: %d bytes", length)
+	}
+	segment := []byte{0xFF, 0xE1, byte(length >> 8), byte(length)}
+	segment = append(segment, prefix...)
+	return append(segment, payload...), nil
+}
+
+// embedJPEGMetadata inserts EXIF and XMP APP1 segments after the SOI marker
+// and any JFIF APP0 segment, dropping existing EXIF and XMP segments
+func embedJPEGMetadata(img, exif, xmp []byte) ([]byte, error) {
+	exifSegment, err := jpegSegment(jpegExifPrefix, exif)
+	if err != nil {
+		return nil, err
+	}
+	xmpSegment, err := jpegSegment(jpegXMPPrefix, xmp)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(img) + len(exifSegment) + len(xmpSegment))
+	out.Write(img[:2])
+	inserted := false
+	insert := func() {
+		if !inserted {
+			out.Write(exifSegment)
+			out.Write(xmpSegment)
+			inserted = true
+		}
+	}
+
+	// Walk the APPn and COM segments that precede the image data
+	pos := 2
+	for pos+4 <= len(img) && img[pos] == 0xFF {
+		marker := img[pos+1]
+		if (marker < 0xE0 || marker > 0xEF) && marker != 0xFE {
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(img[pos+2:pos+4]))
+		if end < pos+4 || end > len(img) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		if marker != 0xE0 {
+			insert()
+		}
+		payload := img[pos+4 : end]
+		if marker != 0xE1 || !(bytes.HasPrefix(payload, []byte(jpegExifPrefix)) || bytes.HasPrefix(payload, []byte(jpegXMPPrefix))) {
+			out.Write(img[pos:end])
+		}
+		pos = end
+	}
+	insert()
+	out.Write(img[pos:])
+	return out.Bytes(), nil
+}
+
+// VP8X feature flags
+const (
+	webpFlagAlpha = 0x10
+	webpFlagEXIF  = 0x08
+	webpFlagXMP   = 0x04
+)
+
+// embedWebPMetadata rewrites a WebP file in the extended format with EXIF
+// and XMP chunks, converting simple (VP8/VP8L only) files as needed
+func embedWebPMetadata(img, exif, xmp []byte) ([]byte, error) {
+	var vp8x []byte
+	var chunks bytes.Buffer
+
+	for pos := 12; pos < len(img); {
+		if pos+8 > len(img) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", pos)
+		}
+		fourCC := string(img[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(img[pos+4 : pos+8]))
+		dataEnd := pos + 8 + size
+		if size < 0 || dataEnd > len(img) {
+			return nil, fmt.Errorf("truncated WebP chunk %q", fourCC)
+		}
+		data := img[pos+8 : dataEnd]
+
+		switch fourCC {
+		case "VP8X":
+			if size < 10 {
+				return nil, fmt.Errorf("invalid VP8X chunk")
+			}
+			vp8x = append([]byte(nil), data[:10]...)
+		case "EXIF", "XMP ":
+			// Replaced below
+		default:
+			if vp8x == nil {
+				header, err := webpExtendedHeader(fourCC, data)
+				if err != nil {
+					return nil, err
+				}
+				vp8x = header
+			}
+			chunks.Write(img[pos:dataEnd])
+			if size%2 == 1 {
+				chunks.WriteByte(0)
+			}
+		}
+		// Odd-sized chunks are padded, though some writers omit the last pad
+		pos = min(dataEnd+size%2, len(img))
+	}
+	if vp8x == nil {
+		return nil, fmt.Errorf("WebP file has no image data")
+	}
+	vp8x[0] |= webpFlagEXIF | webpFlagXMP
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	writeChunk := func(fourCC string, data []byte) {
+		body.WriteString(fourCC)
+		binary.Write(&body, binary.LittleEndian, uint32(len(data)))
+		body.Write(data)
+		if len(data)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+	writeChunk("VP8X", vp8x)
+	body.Write(chunks.Bytes())
+	writeChunk("EXIF", exif)
+	writeChunk("XMP ", xmp)
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// webpExtendedHeader builds the VP8X chunk data for a simple WebP file from
+// its VP8 or VP8L bitstream header
+func webpExtendedHeader(fourCC string, data []byte) ([]byte, error) {
+	var width, height int
+	var flags byte
+	switch fourCC {
+	case "VP8 ":
+		// 3-byte frame tag, 3-byte start code, then 14-bit dimensions
+		if len(data) < 10 || !bytes.Equal(data[3:6], []byte{0x9D, 0x01, 0x2A}) {
+			return nil, fmt.Errorf("invalid VP8 bitstream")
+		}
+		width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3FFF)
+		height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3FFF)
+	case "VP8L":
+		// Signature byte, then 14-bit width-1, 14-bit height-1 and the alpha hint
+		if len(data) < 5 || data[0] != 0x2F {
+			return nil, fmt.Errorf("invalid VP8L bitstream")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		width = int(bits&0x3FFF) + 1
+		height = int(bits>>14&0x3FFF) + 1
+		if bits>>28&1 == 1 {
+			flags |= webpFlagAlpha
+		}
+	default:
+		return nil, fmt.Errorf("unexpected WebP chunk %q before image data", fourCC)
+	}
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("invalid WebP dimensions %dx%d", width, height)
+	}
+
+	header := make([]byte, 10)
+	header[0] = flags
+	putUint24(header[4:7], width-1)
+	putUint24(header[7:10], height-1)
+	return header, nil
+}
+
+// putUint24 writes v as a 24-bit little-endian integer
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
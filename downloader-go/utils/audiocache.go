@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"tiktok-downloader/metrics"
+)
+
+// unsafeCacheKey matches characters not allowed in cache file names
+var unsafeCacheKey = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// AudioCache stores downloaded sounds by music ID so galleries sharing the
+// same sound don't fetch it again
+type AudioCache struct {
+	dir string
+	ttl time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewAudioCache creates an audio cache in dir; an empty dir disables caching
+func NewAudioCache(dir string, ttl time.Duration) *AudioCache {
+	metrics.Register("tikdownloader_audio_cache_requests_total", "Audio cache lookups by result.", metrics.Counter)
+	return &AudioCache{dir: dir, ttl: ttl, locks: make(map[string]*sync.Mutex)}
+}
+
+// GetMusicID returns the sound ID from hybrid API music data, or "" when absent
+func GetMusicID(music map[string]interface{}) string {
+	for _, key := range []string{"id_str", "id", "mid"} {
+		switch v := music[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
+
+// Fetch places the audio for musicID at outputPath, downloading it from url
+// only when no fresh cached copy exists
+func (a *AudioCache) Fetch(ctx context.Context, musicID, url, outputPath string) error {
+	if a == nil || a.dir == "" || musicID == "" {
+		return DownloadFile(ctx, url, outputPath)
+	}
+
+	key := unsafeCacheKey.ReplaceAllString(musicID, "_")
+	cachePath := filepath.Join(a.dir, key+".mp3")
+
+	// Serialize fetches of the same sound so concurrent jobs share one download
+	lock := a.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if info, err := os.Stat(cachePath); err == nil && info.Size() > 0 && time.Since(info.ModTime()) < a.ttl {
+		metrics.Inc("tikdownloader_audio_cache_requests_total", metrics.Labels{"result": "hit"})
+		return copyFile(cachePath, outputPath)
+	}
+	metrics.Inc("tikdownloader_audio_cache_requests_total", metrics.Labels{"result": "miss"})
+
+	if err := os.MkdirAll(a.dir, os.ModePerm); err != nil {
+		return err
+	}
+	tmpPath := cachePath + ".part"
+	if err := DownloadFile(ctx, url, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return copyFile(cachePath, outputPath)
+}
+
+// lock returns the mutex guarding a cache key
+func (a *AudioCache) lock(key string) *sync.Mutex {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l, ok := a.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		a.locks[key] = l
+	}
+	return l
+}
+
+// copyFile copies src to dst, hard-linking when both are on the same filesystem
+func copyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
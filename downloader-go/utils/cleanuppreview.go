@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Actions the next cleanup pass takes on a folder
+const (
+	CleanupQuarantine = "quarantine"
+	CleanupDelete     = "delete"
+	CleanupPurge      = "purge"
+)
+
+// CleanupCandidate is a folder the next cleanup pass will act on
+type CleanupCandidate struct {
+	Path       string `json:"path"`
+	Action     string `json:"action"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Bytes      int64  `json:"bytes"`
+	Owner      string `json:"owner,omitempty"`
+}
+
+// CleanupPreview describes what the next cleanup pass would do, without
+// doing it
+type CleanupPreview struct {
+	NextRun    time.Time
+	Candidates []CleanupCandidate
+	// DeleteBytes is what the pass would reclaim; quarantined folders keep
+	// their space until purged
+	DeleteBytes int64
+}
+
+// PreviewCleanup lists the folders the next reaper pass over tempDir would
+// quarantine, delete or purge from quarantine, with their ages, sizes and
+// owners. Ages are as of the next pass, so the list matches what it will do.
+func PreviewCleanup(tempDir string, quarantineRetention time.Duration) CleanupPreview {
+	nextRun := time.Unix(nextTempCleanup.Load(), 0)
+	if nextRun.Before(time.Now()) {
+		nextRun = time.Now()
+	}
+	preview := CleanupPreview{NextRun: nextRun, Candidates: []CleanupCandidate{}}
+
+	action := CleanupDelete
+	if quarantineRetention > 0 {
+		action = CleanupQuarantine
+	}
+	for _, stale := range findStaleTempDirs(tempDir, nextRun) {
+		candidate := CleanupCandidate{
+			Path:       stale.path,
+			Action:     action,
+			AgeSeconds: int64(stale.age / time.Second),
+			Bytes:      dirSize(stale.path),
+			Owner:      TempFiles.ownerOf(stale.path),
+		}
+		if action == CleanupDelete {
+			preview.DeleteBytes += candidate.Bytes
+		}
+		preview.Candidates = append(preview.Candidates, candidate)
+	}
+
+	for _, expired := range expiredQuarantine(filepath.Join(tempDir, QuarantineDirName), quarantineRetention, nextRun) {
+		candidate := CleanupCandidate{
+			Path:       expired.path,
+			Action:     CleanupPurge,
+			AgeSeconds: int64(expired.age / time.Second),
+			Bytes:      dirSize(expired.path),
+		}
+		preview.DeleteBytes += candidate.Bytes
+		preview.Candidates = append(preview.Candidates, candidate)
+	}
+	return preview
+}
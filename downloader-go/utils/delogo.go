@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// ProcessedNoWatermarkKey is the media key of best-effort no-watermark links
+// whose URL is the watermarked video: the watermark is blurred out on
+// download, for posts that come back without a clean video URL
+const ProcessedNoWatermarkKey = "no_watermark_processed"
+
+// watermarkRegion is where the TikTok/Douyin watermark (logo and @username)
+// sits, as fractions of the frame size
+type watermarkRegion struct {
+	X, Y, W, H float64
+}
+
+// The watermark sits top left for the first half of a video and bottom
+// right for the second half
+var (
+	watermarkFirstHalf  = watermarkRegion{X: 0.02, Y: 0.04, W: 0.42, H: 0.09}
+	watermarkSecondHalf = watermarkRegion{X: 0.56, Y: 0.84, W: 0.42, H: 0.09}
+)
+
+// delogoFilter returns a delogo filter covering region of a width x height
+// frame, enabled by the given timeline expression
+func delogoFilter(region watermarkRegion, width, height int, enable string) string {
+	x := max(1, int(region.X*float64(width)))
+	y := max(1, int(region.Y*float64(height)))
+	w := min(int(region.W*float64(width)), width-x-1)
+	h := min(int(region.H*float64(height)), height-y-1)
+	return fmt.Sprintf("delogo=x=%d:y=%d:w=%d:h=%d:enable='%s'", x, y, w, h, enable)
+}
+
+// RemoveWatermark re-encodes a watermarked video with its watermark blurred
+// out by delogo at the positions TikTok and Douyin draw it. This is a best
+// effort: the area stays visibly smudged, and watermarks drawn elsewhere are
+// left alone. toneMap, when set, tone-maps HDR sources with that algorithm.
+func RemoveWatermark(ctx context.Context, videoPath, outputPath, toneMap string) error {
+	width, height, duration, err := probeVideo(ctx, videoPath)
+	if err != nil {
+		return err
+	}
+	half := formatSeconds(duration / 2)
+
+	filter := delogoFilter(watermarkFirstHalf, width, height, "lt(t,"+half+")") + "," +
+		delogoFilter(watermarkSecondHalf, width, height, "gte(t,"+half+")")
+	if toneMap != "" {
+		filter = ToneMapFilter(toneMap) + "," + filter
+	}
+
+	return runFFmpeg(ctx, []string{"-y",
+		"-i", videoPath,
+		"-vf", filter,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		outputPath,
+	})
+}
+
+// probeVideo returns the frame size of the first video stream of a file and
+// the file's duration in seconds
+func probeVideo(ctx context.Context, path string) (int, int, float64, error) {
+	output, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height:format=duration",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("ffprobe error: %v", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, 0, 0, fmt.Errorf("error parsing ffprobe output: %v", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Width < 16 || probe.Streams[0].Height < 16 {
+		return 0, 0, 0, fmt.Errorf("no video stream found")
+	}
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil || duration <= 0 {
+		return 0, 0, 0, fmt.Errorf("unknown video duration")
+	}
+	return probe.Streams[0].Width, probe.Streams[0].Height, duration, nil
+}
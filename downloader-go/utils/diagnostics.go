@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sensitiveKeys are payload keys whose values are dropped from diagnostics
+var sensitiveKeys = map[string]bool{
+	"cookie":  true,
+	"cookies": true,
+	"token":   true,
+	"msToken": true,
+	"x_bogus": true,
+	"a_bogus": true,
+}
+
+// diagnosticRefPattern matches reference IDs produced by CaptureDiagnostics
+var diagnosticRefPattern = regexp.MustCompile(`^diag-[0-9]{8}T[0-9]{6}-[0-9a-f]{8}$`)
+
+// CaptureDiagnostics stores a sanitized copy of an upstream payload that
+// failed response shaping and returns a reference ID for bug reports.
+// client is the requesting client's fingerprint, kept so the record can be purged.
+// It returns an empty string when diagnostics capture is disabled.
+func CaptureDiagnostics(dir, sourceURL, client string, payload interface{}, cause error) string {
+	if dir == "" {
+		return ""
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return ""
+	}
+	ref := fmt.Sprintf("diag-%s-%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+
+	record := map[string]interface{}{
+		"reference_id": ref,
+		"captured_at":  time.Now().UTC().Format(time.RFC3339),
+		"source_url":   sanitizeValue(sourceURL),
+		"client":       client,
+		"error":        cause.Error(),
+		"payload":      sanitizeValue(payload),
+	}
+
+	content, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return ""
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return ""
+	}
+	if err := os.WriteFile(filepath.Join(dir, ref+".json"), content, 0644); err != nil {
+		return ""
+	}
+
+	return ref
+}
+
+// DiagnosticsPath returns the file path of a captured payload, validating the reference ID
+func DiagnosticsPath(dir, ref string) (string, bool) {
+	if dir == "" || !diagnosticRefPattern.MatchString(ref) {
+		return "", false
+	}
+	return filepath.Join(dir, ref+".json"), true
+}
+
+// PurgeDiagnostics deletes captured payloads that belong to client (when not
+// empty) or were captured before cutoff (when not zero), returning the count
+func PurgeDiagnostics(dir, client string, cutoff time.Time) int {
+	if dir == "" {
+		return 0
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "diag-*.json"))
+	if err != nil {
+		return 0
+	}
+
+	purged := 0
+	for _, path := range paths {
+		match := false
+		if !cutoff.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				match = true
+			}
+		}
+		if client != "" && !match {
+			var record struct {
+				Client string `json:"client"`
+			}
+			if raw, err := os.ReadFile(path); err == nil && json.Unmarshal(raw, &record) == nil {
+				match = record.Client == client
+			}
+		}
+		if match && os.Remove(path) == nil {
+			purged++
+		}
+	}
+	return purged
+}
+
+// sanitizeValue strips signed query strings from URLs and drops credential-like keys
+func sanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clean := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if sensitiveKeys[key] {
+				clean[key] = "[redacted]"
+				continue
+			}
+			clean[key] = sanitizeValue(val)
+		}
+		return clean
+	case []interface{}:
+		clean := make([]interface{}, len(v))
+		for i, val := range v {
+			clean[i] = sanitizeValue(val)
+		}
+		return clean
+	case string:
+		if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+			if parsed, err := url.Parse(v); err == nil && parsed.RawQuery != "" {
+				parsed.RawQuery = "redacted"
+				return parsed.String()
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
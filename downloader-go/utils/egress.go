@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+)
+
+func init() {
+	metrics.Register("tikdownloader_egress_bytes", "Bytes served to clients in the current egress budget window.", metrics.Gauge)
+	metrics.Register("tikdownloader_egress_budget_exhausted_total", "Egress budget windows that ran out before resetting.", metrics.Counter)
+}
+
+// EgressBudget caps the bytes and downloads served per window. Windows are
+// aligned to the clock in UTC, so a 24h budget resets at midnight UTC and a
+// 1h one on the hour. Once either cap is reached links are disabled until the
+// window resets.
+type EgressBudget struct {
+	maxBytes     int64
+	maxDownloads int
+	window       time.Duration
+
+	mu        sync.Mutex
+	start     time.Time
+	bytes     int64
+	downloads int
+	exhausted bool
+}
+
+// egressBudget is the budget downloads are accounted against, or nil
+var egressBudget *EgressBudget
+
+// NewEgressBudget returns a budget of maxBytes and maxDownloads per window,
+// a zero cap being unlimited, or nil when both are unlimited
+func NewEgressBudget(maxBytes int64, maxDownloads int, window time.Duration) *EgressBudget {
+	if (maxBytes <= 0 && maxDownloads <= 0) || window <= 0 {
+		return nil
+	}
+	return &EgressBudget{
+		maxBytes:     maxBytes,
+		maxDownloads: maxDownloads,
+		window:       window,
+		start:        time.Now().UTC().Truncate(window),
+	}
+}
+
+// SetEgressBudget accounts served downloads against b
+func SetEgressBudget(b *EgressBudget) {
+	egressBudget = b
+}
+
+// RecordEgress accounts a served download of n bytes against the budget
+func RecordEgress(n int64) {
+	egressBudget.record(n, true)
+}
+
+// RecordEgressBytes accounts n bytes against the budget that continue a
+// download already counted, such as a later Range request of a video
+func RecordEgressBytes(n int64) {
+	egressBudget.record(n, false)
+}
+
+// EgressExhausted reports whether the budget of the current window is spent,
+// and when the window resets
+func EgressExhausted() (bool, time.Time) {
+	return egressBudget.check()
+}
+
+func (b *EgressBudget) record(n int64, download bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(time.Now())
+	b.bytes += n
+	if download {
+		b.downloads++
+	}
+	metrics.Set("tikdownloader_egress_bytes", nil, float64(b.bytes))
+	if !b.exhausted && b.spent() {
+		b.exhausted = true
+		metrics.Inc("tikdownloader_egress_budget_exhausted_total", nil)
+		logging.Warnf("Egress budget exhausted (%d bytes, %d downloads): links disabled until %s",
+			b.bytes, b.downloads, b.start.Add(b.window).Format(time.RFC3339))
+	}
+}
+
+func (b *EgressBudget) check() (bool, time.Time) {
+	if b == nil {
+		return false, time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(time.Now())
+	return b.exhausted, b.start.Add(b.window)
+}
+
+// roll starts a new window once the current one has ended
+func (b *EgressBudget) roll(now time.Time) {
+	if now.Before(b.start.Add(b.window)) {
+		return
+	}
+	if b.exhausted {
+		logging.Infof("Egress budget window reset: links enabled again")
+	}
+	b.start = now.UTC().Truncate(b.window)
+	b.bytes = 0
+	b.downloads = 0
+	b.exhausted = false
+	metrics.Set("tikdownloader_egress_bytes", nil, 0)
+}
+
+// spent reports whether either cap has been reached
+func (b *EgressBudget) spent() bool {
+	return (b.maxBytes > 0 && b.bytes >= b.maxBytes) ||
+		(b.maxDownloads > 0 && b.downloads >= b.maxDownloads)
+}
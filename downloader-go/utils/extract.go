@@ -0,0 +1,19 @@
+package utils
+
+import "context"
+
+// ExtractedAudioKey is the media key of MP3 links whose URL is the post's
+// video: the audio track is extracted from it on download, for posts that
+// come back without a music URL
+const ExtractedAudioKey = "mp3_extracted"
+
+// ExtractAudio extracts the audio track of a video into an MP3
+func ExtractAudio(ctx context.Context, videoPath, outputPath string) error {
+	return runFFmpeg(ctx, []string{"-y",
+		"-i", videoPath,
+		"-vn",
+		"-c:a", "libmp3lame",
+		"-q:a", "2",
+		outputPath,
+	})
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"tiktok-downloader/config"
+)
+
+// Extractor resolves the posts of one platform into data shaped like the
+// hybrid API's ({"data": {...}} with the type, desc, author, statistics,
+// music, cover_data, video_data and image_data fields), so every platform
+// shares the response, download link and streaming code
+type Extractor interface {
+	// Platform is the platform name recorded in the post data
+	Platform() string
+	// Match reports whether a post URL belongs to the extractor's platform
+	Match(sourceURL string) bool
+	// Fetch resolves a post
+	Fetch(ctx context.Context, cfg *config.AppConfig, sourceURL string) (map[string]interface{}, error)
+}
+
+// extractors are tried in order before the hybrid API, which serves TikTok
+// and Douyin and everything no extractor matches
+var extractors []Extractor
+
+// RegisterExtractor adds an extractor for another platform; extractors are
+// registered once at startup
+func RegisterExtractor(extractor Extractor) {
+	extractors = append(extractors, extractor)
+}
+
+// ExtractorFor returns the extractor serving a post URL, or nil when the
+// hybrid API does
+func ExtractorFor(sourceURL string) Extractor {
+	for _, extractor := range extractors {
+		if extractor.Match(sourceURL) {
+			return extractor
+		}
+	}
+	return nil
+}
+
+// UnsupportedURLMessage is the error for URLs no platform serves, naming
+// TikTok and Douyin and the platforms of the registered extractors
+func UnsupportedURLMessage() string {
+	names := []string{platformDisplayNames[PlatformTikTok], platformDisplayNames[PlatformDouyin]}
+	for _, extractor := range extractors {
+		name := platformDisplayNames[extractor.Platform()]
+		if name == "" {
+			name = extractor.Platform()
+		}
+		names = append(names, name)
+	}
+	return "Unsupported URL: only " + strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1] + " posts are supported"
+}
+
+// FetchPostData resolves a post through the extractor of its platform, or
+// the hybrid API (minimal payload) for TikTok and Douyin
+func FetchPostData(ctx context.Context, cfg *config.AppConfig, sourceURL string) (map[string]interface{}, error) {
+	if extractor := ExtractorFor(sourceURL); extractor != nil {
+		return extractor.Fetch(ctx, cfg, sourceURL)
+	}
+	return FetchHybridData(ctx, cfg, sourceURL, true)
+}
@@ -1,40 +1,104 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"tiktok-downloader/metrics"
+
+	"github.com/google/uuid"
+)
+
+// tempCleanupInterval is how often the reaper runs, removing folders older
+// than tempStaleAge
+const (
+	tempCleanupInterval = 15 * time.Minute
+	tempStaleAge        = time.Hour
 )
 
-// TempFileTracker tracks temporary files with timestamps
+// nextTempCleanup is the Unix time of the reaper's next pass
+var nextTempCleanup atomic.Int64
+
+// tempQuotaInterval is how often the temp directory is checked against its quota
+const tempQuotaInterval = 30 * time.Second
+
+// tempQuotaMinAge spares folders this recently used from eviction, as their
+// downloads and renders are likely still being written
+const tempQuotaMinAge = time.Minute
+
+// QuarantineDirName is the folder of the temp directory that holds the temp
+// folders of crashed requests and jobs until their quarantine retention ends
+const QuarantineDirName = ".quarantine"
+
+// tempQuotaCheck asks the cleanup loop for a quota check ahead of its interval
+var tempQuotaCheck = make(chan struct{}, 1)
+
+func init() {
+	metrics.Register("tikdownloader_temp_dir_bytes", "Size of the temp directory at its last quota check.", metrics.Gauge)
+	metrics.Register("tikdownloader_temp_evictions_total", "Temp folders evicted to keep the temp directory under its quota.", metrics.Counter)
+	metrics.Register("tikdownloader_temp_quarantined_total", "Stale temp folders moved to quarantine instead of being deleted.", metrics.Counter)
+	metrics.Register("tikdownloader_temp_cleanup_reclaimed_bytes", "Bytes reclaimed by the last temp cleanup pass.", metrics.Gauge)
+	metrics.Register("tikdownloader_temp_reclaimed_bytes_total", "Bytes reclaimed by temp cleanup passes.", metrics.Counter)
+}
+
+// TempFileTracker tracks temporary files with timestamps and the request
+// that owns them
 type TempFileTracker struct {
 	sync.RWMutex
-	files map[string]time.Time
+	files map[string]tempFile
+}
+
+// tempFile is a tracked temp path
+type tempFile struct {
+	created time.Time
+	owner   string
 }
 
 // Global instance of temp file tracker
 var TempFiles = TempFileTracker{
-	files: make(map[string]time.Time),
+	files: make(map[string]tempFile),
 }
 
 // Add adds a path to the tracker
 func (t *TempFileTracker) Add(path string) {
 	t.Lock()
 	defer t.Unlock()
-	t.files[path] = time.Now()
+	t.files[path] = tempFile{created: time.Now()}
+}
+
+// Claim registers a path to an owner, failing if the path is already tracked
+func (t *TempFileTracker) Claim(path, owner string) bool {
+	t.Lock()
+	defer t.Unlock()
+	if _, exists := t.files[path]; exists {
+		return false
+	}
+	t.files[path] = tempFile{created: time.Now(), owner: owner}
+	return true
 }
 
 // Get gets a path's timestamp from the tracker
 func (t *TempFileTracker) Get(path string) (time.Time, bool) {
 	t.RLock()
 	defer t.RUnlock()
-	timestamp, ok := t.files[path]
-	return timestamp, ok
+	file, ok := t.files[path]
+	return file.created, ok
+}
+
+// ownerOf returns the owner a path was claimed by, if any
+func (t *TempFileTracker) ownerOf(path string) string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.files[path].owner
 }
 
 // Delete deletes a path from the tracker
@@ -49,6 +113,36 @@ func InitTempDir(tempDir string) error {
 	return os.MkdirAll(tempDir, os.ModePerm)
 }
 
+// NewTempDir creates a uniquely named directory under tempDir and claims it
+// for owner (e.g. the post being rendered). Names are a kind prefix and a
+// random UUID, and the directory is created exclusively, so concurrent
+// requests never share one.
+func NewTempDir(tempDir, kind, owner string) (string, error) {
+	if err := SafePathComponent(kind); err != nil {
+		return "", err
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		path := filepath.Join(tempDir, kind+"_"+uuid.NewString())
+		if err := os.Mkdir(path, os.ModePerm); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if !TempFiles.Claim(path, owner) {
+			// A stale tracker entry still holds this name; drop the directory
+			// just made for it and leave the entry to its owner
+			if err := os.RemoveAll(path); err != nil {
+				return "", err
+			}
+			continue
+		}
+		requestQuotaCheck()
+		return path, nil
+	}
+	return "", fmt.Errorf("could not allocate a unique temp directory")
+}
+
 // ScheduleCleanup schedules a cleanup of a temporary directory
 func ScheduleCleanup(path string, delay time.Duration) {
 	go func() {
@@ -58,84 +152,306 @@ func ScheduleCleanup(path string, delay time.Duration) {
 		} else {
 			log.Printf("Cleaned up temp directory: %s", path)
 		}
+		if owner := TempFiles.ownerOf(path); owner != "" {
+			log.Printf("Released temp directory %s owned by %s", filepath.Base(path), owner)
+		}
 		TempFiles.Delete(path)
 	}()
 }
 
-// CleanupTempFiles continuously cleans up temporary files
-func CleanupTempFiles(tempDir string) {
+// CleanupTempFiles cleans up temporary files every 15 minutes until ctx is
+// done. Folders still there after an hour outlived the request or job that
+// should have removed them; with quarantineRetention set they are moved to
+// the quarantine folder for inspection and deleted once that has passed.
+// With maxBytes set, the temp directory is also kept under that size,
+// checked every 30 seconds and whenever a temp directory is created.
+func CleanupTempFiles(ctx context.Context, tempDir string, maxBytes int64, quarantineRetention time.Duration) {
+	ticker := time.NewTicker(tempCleanupInterval)
+	defer ticker.Stop()
+	nextTempCleanup.Store(time.Now().Add(tempCleanupInterval).Unix())
+
+	var quotaTick <-chan time.Time
+	var quotaCheck <-chan struct{}
+	if maxBytes > 0 {
+		quotaTicker := time.NewTicker(tempQuotaInterval)
+		defer quotaTicker.Stop()
+		quotaTick, quotaCheck = quotaTicker.C, tempQuotaCheck
+	}
+
 	for {
-		// Sleep for 15 minutes
-		time.Sleep(15 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-quotaTick:
+			enforceTempQuota(tempDir, maxBytes)
+			continue
+		case <-quotaCheck:
+			enforceTempQuota(tempDir, maxBytes)
+			continue
+		case <-ticker.C:
+		}
 
-		// Get current time
-		currentTime := time.Now()
+		runTempCleanup(tempDir, quarantineRetention)
+	}
+}
 
-		// Find directories to clean
-		toClean := []string{}
+// runTempCleanup is one pass of the reaper: stale folders are quarantined
+// or deleted and quarantined folders past their retention are purged
+func runTempCleanup(tempDir string, quarantineRetention time.Duration) {
+	quarantineDir := filepath.Join(tempDir, QuarantineDirName)
+	var reclaimed int64
 
-		err := filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
+	// Clean the directories, keeping them in quarantine for a while
+	for _, stale := range findStaleTempDirs(tempDir, time.Now()) {
+		path := stale.path
+		if quarantineRetention > 0 {
+			if err := quarantineTempDir(quarantineDir, path); err != nil {
+				log.Printf("Error quarantining directory %s, removing it: %v", path, err)
+			} else {
+				continue
 			}
+		}
+		size := dirSize(path)
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Error removing directory %s: %v", path, err)
+		} else {
+			log.Printf("Removed old temp directory: %s", path)
+			TempFiles.Delete(path)
+			reclaimed += size
+		}
+	}
+	reclaimed += purgeQuarantine(quarantineDir, quarantineRetention)
+
+	metrics.Set("tikdownloader_temp_cleanup_reclaimed_bytes", nil, float64(reclaimed))
+	metrics.Add("tikdownloader_temp_reclaimed_bytes_total", nil, float64(reclaimed))
+	nextTempCleanup.Store(time.Now().Add(tempCleanupInterval).Unix())
+}
+
+// staleTempDir is a temp folder the reaper will remove
+type staleTempDir struct {
+	path string
+	age  time.Duration
+}
+
+// findStaleTempDirs lists the folders under tempDir older than
+// tempStaleAge, outside the quarantine folder
+func findStaleTempDirs(tempDir string, currentTime time.Time) []staleTempDir {
+	var stale []staleTempDir
+	quarantineDir := filepath.Join(tempDir, QuarantineDirName)
+
+	err := filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the root directory
+		if path == tempDir {
+			return nil
+		}
+
+		// Quarantined folders have their own retention
+		if path == quarantineDir {
+			return filepath.SkipDir
+		}
 
-			// Skip the root directory
-			if path == tempDir {
-				return nil
+		// If it's a directory
+		if info.IsDir() {
+			// Get timestamp from tracker or use file modification time
+			timestamp, ok := TempFiles.Get(path)
+			if !ok {
+				timestamp = info.ModTime()
 			}
 
-			// If it's a directory
-			if info.IsDir() {
-				// Get timestamp from tracker or use file modification time
-				timestamp, ok := TempFiles.Get(path)
-				if !ok {
-					timestamp = info.ModTime()
-				}
-
-				// If older than 1 hour, add to cleanup list
-				if currentTime.Sub(timestamp) > time.Hour {
-					toClean = append(toClean, path)
-					return filepath.SkipDir // Skip subfolders
-				}
+			// If older than 1 hour, add to cleanup list
+			if age := currentTime.Sub(timestamp); age > tempStaleAge {
+				stale = append(stale, staleTempDir{path: path, age: age})
+				return filepath.SkipDir // Skip subfolders
 			}
+		}
 
-			return nil
-		})
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Error in cleanup task: %v", err)
+	}
+	return stale
+}
+
+// quarantineTempDir moves a stale temp folder into the quarantine folder,
+// restarting its clock so the retention counts from now
+func quarantineTempDir(quarantineDir, path string) error {
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return err
+	}
+	target := filepath.Join(quarantineDir, filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	now := time.Now()
+	os.Chtimes(target, now, now)
+
+	owner := TempFiles.ownerOf(path)
+	if owner == "" {
+		owner = "unknown"
+	}
+	TempFiles.Delete(path)
+	metrics.Inc("tikdownloader_temp_quarantined_total", nil)
+	log.Printf("Quarantined stale temp directory %s (owner %s) as %s", filepath.Base(path), owner, target)
+	return nil
+}
 
+// purgeQuarantine deletes quarantined folders older than retention and
+// returns the bytes reclaimed
+func purgeQuarantine(quarantineDir string, retention time.Duration) int64 {
+	var reclaimed int64
+	for _, entry := range expiredQuarantine(quarantineDir, retention, time.Now()) {
+		size := dirSize(entry.path)
+		if err := os.RemoveAll(entry.path); err != nil {
+			log.Printf("Error removing quarantined directory %s: %v", entry.path, err)
+		} else {
+			log.Printf("Removed quarantined temp directory: %s", entry.path)
+			reclaimed += size
+		}
+	}
+	return reclaimed
+}
+
+// expiredQuarantine lists the quarantined folders older than retention
+func expiredQuarantine(quarantineDir string, retention time.Duration, currentTime time.Time) []staleTempDir {
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading quarantine directory: %v", err)
+		}
+		return nil
+	}
+	var expired []staleTempDir
+	for _, entry := range entries {
+		info, err := entry.Info()
 		if err != nil {
-			log.Printf("Error in cleanup task: %v", err)
+			continue
+		}
+		if age := currentTime.Sub(info.ModTime()); age > retention {
+			expired = append(expired, staleTempDir{path: filepath.Join(quarantineDir, entry.Name()), age: age})
 		}
+	}
+	return expired
+}
 
-		// Clean the directories
-		for _, path := range toClean {
-			if err := os.RemoveAll(path); err != nil {
-				log.Printf("Error removing directory %s: %v", path, err)
-			} else {
-				log.Printf("Removed old temp directory: %s", path)
-				TempFiles.Delete(path)
-			}
+// requestQuotaCheck asks for a quota check without waiting for it
+func requestQuotaCheck() {
+	select {
+	case tempQuotaCheck <- struct{}{}:
+	default:
+	}
+}
+
+// tempFolder is a top-level folder of the temp directory
+type tempFolder struct {
+	path     string
+	lastUsed time.Time
+	size     int64
+}
+
+// enforceTempQuota evicts temp folders, least recently used first, until the
+// temp directory fits in maxBytes. A folder was last used when it was
+// created or last had a file added, whichever is later.
+func enforceTempQuota(tempDir string, maxBytes int64) {
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		log.Printf("Error reading temp directory for quota check: %v", err)
+		return
+	}
+
+	var folders []tempFolder
+	var total int64
+	for _, entry := range entries {
+		path := filepath.Join(tempDir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if !entry.IsDir() {
+			total += info.Size()
+			continue
+		}
+
+		folder := tempFolder{path: path, lastUsed: info.ModTime(), size: dirSize(path)}
+		if created, ok := TempFiles.Get(path); ok && created.After(folder.lastUsed) {
+			folder.lastUsed = created
+		}
+		if entry.Name() == QuarantineDirName {
+			// Kept for inspection only, so it is the first to go
+			folder.lastUsed = time.Time{}
+		}
+		folders = append(folders, folder)
+		total += folder.size
+	}
+	metrics.Set("tikdownloader_temp_dir_bytes", nil, float64(total))
+	if total <= maxBytes {
+		return
+	}
+
+	sort.Slice(folders, func(i, j int) bool { return folders[i].lastUsed.Before(folders[j].lastUsed) })
+	for _, folder := range folders {
+		if total <= maxBytes || time.Since(folder.lastUsed) < tempQuotaMinAge {
+			break
+		}
+		if err := os.RemoveAll(folder.path); err != nil {
+			log.Printf("Error evicting temp directory %s: %v", folder.path, err)
+			continue
 		}
+		TempFiles.Delete(folder.path)
+		total -= folder.size
+		metrics.Inc("tikdownloader_temp_evictions_total", nil)
+		log.Printf("Evicted temp directory %s (%d bytes) to stay under the temp quota", folder.path, folder.size)
 	}
+	if total > maxBytes {
+		log.Printf("Temp directory is still %d bytes over its quota; the rest is in active use", total-maxBytes)
+	}
+}
+
+// dirSize returns the total size of the files under a directory
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
 }
 
-// DownloadFile downloads a file from a URL to a local path
-func DownloadFile(url, outputPath string) error {
-	resp, err := http.Get(url)
+// DownloadFile downloads a file from a URL to a local path, giving up when
+// ctx ends. The request is retried per the source retry policy, the body is
+// fetched in resumable chunks (see OpenSource) and a partial file is removed
+// on failure.
+func DownloadFile(ctx context.Context, url, outputPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := OpenSource(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file: %d", resp.StatusCode)
+		return sourceStatusError{resp.StatusCode}
 	}
 
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
-	return err
-}
\ No newline at end of file
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stallingSource serves the first bytes of a body and then blocks until the
+// client goes away, like a source that stops sending mid-download
+func stallingSource(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 4096))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadFileCancelled(t *testing.T) {
+	server := stallingSource(t)
+	outputPath := filepath.Join(t.TempDir(), "video.mp4")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- DownloadFile(ctx, server.URL, outputPath) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("DownloadFile succeeded after its context was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DownloadFile kept downloading after its context was cancelled")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("partial file %s left behind after cancellation", outputPath)
+	}
+}
+
+func TestDownloadFileCancelledBeforeStart(t *testing.T) {
+	server := stallingSource(t)
+	outputPath := filepath.Join(t.TempDir(), "video.mp4")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := DownloadFile(ctx, server.URL, outputPath); err == nil {
+		t.Fatal("DownloadFile succeeded with a cancelled context")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("file %s created for a cancelled download", outputPath)
+	}
+}
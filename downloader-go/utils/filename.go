@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations maps letters of non-Latin alphabets that have a common
+// romanization to ASCII; Latin letters with diacritics are folded separately
+var transliterations = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	// Arabic
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "a", 'ب': "b", 'ت': "t", 'ث': "th", 'ج': "j",
+	'ح': "h", 'خ': "kh", 'د': "d", 'ذ': "dh", 'ر': "r", 'ز': "z", 'س': "s", 'ش': "sh",
+	'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z", 'ع': "a", 'غ': "gh", 'ف': "f", 'ق': "q",
+	'ك': "k", 'ل': "l", 'م': "m", 'ن': "n", 'ه': "h", 'و': "w", 'ي': "y", 'ى': "a",
+	'ة': "a", 'ء': "", 'ئ': "y", 'ؤ': "w",
+	// Latin letters that don't decompose
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'đ': "d", 'ł': "l", 'þ': "th", 'ð': "d",
+}
+
+// Transliterate romanizes a name into ASCII letters, digits and underscores,
+// reporting how many of its letters had no romanization (CJK, for one)
+func Transliterate(name string) (string, int) {
+	var b strings.Builder
+	unknown := 0
+	underscore := false
+	write := func(s string) {
+		b.WriteString(s)
+		underscore = false
+	}
+
+	for _, r := range name {
+		lower := unicode.ToLower(r)
+		if latin, ok := transliterations[lower]; ok {
+			if lower != r && latin != "" {
+				// Keep the capitalization of the first letter
+				latin = strings.ToUpper(latin[:1]) + latin[1:]
+			}
+			write(latin)
+			continue
+		}
+
+		// NFKD splits accented letters into a base letter and combining
+		// marks, and folds full-width forms to ASCII
+		folded := ""
+		for _, d := range norm.NFKD.String(string(r)) {
+			if d < utf8.RuneSelf && isAlnum(byte(d)) {
+				folded += string(d)
+			}
+		}
+		if folded != "" {
+			write(folded)
+			continue
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			unknown++
+		}
+		if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimRight(b.String(), "_"), unknown
+}
+
+// FilenameBase returns an ASCII filename (without extension) for media by an
+// author: the transliterated nickname, or the author's UID and the post ID
+// when most of the nickname has no romanization
+func FilenameBase(nickname, uid, awemeID string) string {
+	name, unknown := Transliterate(nickname)
+	known := 0
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			known++
+		}
+	}
+	if name != "" && unknown <= known {
+		return name
+	}
+
+	parts := []string{}
+	for _, part := range []string{name, uid, awemeID} {
+		if part != "" && part != "unknown" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "download"
+	}
+	return strings.Join(parts, "_")
+}
+
+// ContentDisposition builds an attachment Content-Disposition header: the
+// original UTF-8 name in filename* (RFC 5987), and an ASCII fallback in
+// filename for clients that don't support it
+func ContentDisposition(filename, asciiFallback string) string {
+	asciiFallback = strings.NewReplacer(`"`, "", `\`, "").Replace(asciiFallback)
+	return fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", asciiFallback, EncodeRFC5987(filename))
+}
+
+// AttachmentDisposition builds the Content-Disposition of a download named
+// name, with a transliterated ASCII fallback
+func AttachmentDisposition(name string) string {
+	ext := filepath.Ext(name)
+	return ContentDisposition(name, FilenameBase(strings.TrimSuffix(name, ext), "", "")+ext)
+}
+
+// EncodeRFC5987 percent-encodes everything but RFC 5987 attr-chars, so
+// spaces become %20 rather than the + of query encoding
+func EncodeRFC5987(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < utf8.RuneSelf && (isAlnum(c) || strings.IndexByte("!#$&+-.^_`|~", c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// ErrUnsafePath is returned for name components that could escape their directory
+var ErrUnsafePath = errors.New("unsafe path component")
+
+// SafePathComponent checks that a client- or upstream-influenced name is a
+// single path element: not empty, "." or "..", and free of separators and NULs
+func SafePathComponent(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	return nil
+}
+
+// isAlnum reports whether an ASCII byte is a letter or digit
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/logging"
+)
+
+// FullPayloadFallbackKey is set on hybrid API data whose video URLs had to be
+// taken from the full (minimal=false) payload
+const FullPayloadFallbackKey = "_full_payload_fallback"
+
+// CompleteVideoData fills in the video_data of a minimal video payload that
+// came back without any URL, using the full payload of the same post. It
+// reports whether the full payload was used; data is updated in place.
+func CompleteVideoData(ctx context.Context, cfg *config.AppConfig, sourceURL string, data map[string]interface{}) bool {
+	videoData, ok := data["data"].(map[string]interface{})
+	if !ok || videoData["type"] == "image" || hasVideoURL(videoData) {
+		return false
+	}
+
+	full, err := FetchHybridData(ctx, cfg, sourceURL, false)
+	if err != nil {
+		logging.Warnf("Full payload retry failed for %s: %v", sourceURL, err)
+		return false
+	}
+	fullData, _ := full["data"].(map[string]interface{})
+	video, _ := fullData["video"].(map[string]interface{})
+	urls := videoURLsFromFull(video)
+	if len(urls) == 0 {
+		return false
+	}
+
+	videoData["video_data"] = urls
+	if _, ok := videoData["video"]; !ok {
+		// Lets callers read sizes and bitrates, which only the full payload has
+		videoData["video"] = video
+	}
+	data[FullPayloadFallbackKey] = true
+	return true
+}
+
+// FullVideoData returns the video_data fields CompleteVideoData derives
+// from the video object of full payload post data
+func FullVideoData(videoData map[string]interface{}) map[string]interface{} {
+	video, _ := videoData["video"].(map[string]interface{})
+	return videoURLsFromFull(video)
+}
+
+// hasVideoURL reports whether minimal video data carries at least one video URL
+func hasVideoURL(videoData map[string]interface{}) bool {
+	urls, _ := videoData["video_data"].(map[string]interface{})
+	for _, field := range videoURLKeys {
+		if url, _ := urls[field].(string); url != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// videoURLsFromFull derives the minimal video_data fields from the video
+// object of a full payload, taking the first usable URL of each list
+func videoURLsFromFull(video map[string]interface{}) map[string]interface{} {
+	play := firstURL(video, "play_addr")
+	download := firstURL(video, "download_addr")
+	hq := ""
+	if bitRates, ok := video["bit_rate"].([]interface{}); ok {
+		for _, bitRate := range bitRates {
+			if entry, ok := bitRate.(map[string]interface{}); ok {
+				if hq = firstURL(entry, "play_addr"); hq != "" {
+					break
+				}
+			}
+		}
+	}
+
+	// Douyin play URLs carry the watermark under /playwm/
+	noWatermark := strings.Replace(play, "/playwm/", "/play/", 1)
+	if hq == "" {
+		hq = noWatermark
+	}
+
+	urls := make(map[string]interface{})
+	for field, url := range map[string]string{
+		"nwm_video_url":    noWatermark,
+		"nwm_video_url_HQ": hq,
+		"wm_video_url":     download,
+		"wm_video_url_HQ":  download,
+	} {
+		if url != "" {
+			urls[field] = url
+		}
+	}
+	return urls
+}
+
+// firstURL returns the first non-empty entry of addr.url_list in video
+func firstURL(video map[string]interface{}, addr string) string {
+	list, _ := GetNestedValue(video, []string{addr, "url_list"}, nil).([]interface{})
+	for _, item := range list {
+		if url, _ := item.(string); url != "" {
+			return url
+		}
+	}
+	return ""
+}
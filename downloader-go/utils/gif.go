@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// GifOptions controls how a video is converted to an animated GIF
+type GifOptions struct {
+	FPS        int    // output frame rate
+	Width      int    // output width in pixels; the height keeps the aspect ratio
+	Palette    bool   // build an optimized palette (palettegen) instead of the default one
+	MaxSeconds int    // length of video converted, 0 for all of it
+	ToneMap    string // tonemap algorithm for HDR sources, empty to leave colors alone
+}
+
+// CreateGIF converts a video into an animated GIF that loops forever
+func CreateGIF(ctx context.Context, videoPath, outputPath string, opts GifOptions) error {
+	filter := fmt.Sprintf("fps=%d,scale=%d:-1:flags=lanczos", opts.FPS, opts.Width)
+	if opts.ToneMap != "" {
+		filter = ToneMapFilter(opts.ToneMap) + "," + filter
+	}
+	if opts.Palette {
+		// A palette built from the video's own colors avoids the banding of
+		// the stock 256-color palette, at the cost of a second pass
+		filter += ",split[s0][s1];[s0]palettegen=stats_mode=diff[p];[s1][p]paletteuse=dither=bayer:bayer_scale=3"
+	}
+
+	args := []string{"-y"}
+	if opts.MaxSeconds > 0 {
+		args = append(args, "-t", fmt.Sprintf("%d", opts.MaxSeconds))
+	}
+	args = append(args,
+		"-i", videoPath,
+		"-filter_complex", filter,
+		"-an",
+		"-loop", "0",
+		outputPath,
+	)
+	return runFFmpeg(ctx, args)
+}
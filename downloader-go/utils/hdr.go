@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// hdrTransfers are the transfer characteristics of HDR video: PQ (HDR10)
+// and HLG, which TikTok serves as bytevc1 (HEVC) in 10-bit
+var hdrTransfers = map[string]bool{
+	"smpte2084":    true,
+	"arib-std-b67": true,
+}
+
+// hdrFormats lists the output formats that can carry HDR; everything else is
+// tone-mapped to SDR whatever the request asks for
+var hdrFormats = map[string]bool{
+	"mp4": true,
+	"mkv": true,
+}
+
+// ToneMapAlgorithms lists the tonemap filter algorithms accepted in config
+var ToneMapAlgorithms = []string{"hable", "mobius", "reinhard", "clip", "linear", "gamma"}
+
+// IsHDR reports whether the first video stream of a file is HDR. Probe
+// failures report SDR, so conversion goes ahead untouched.
+func IsHDR(ctx context.Context, path string) bool {
+	output, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=color_transfer",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return false
+	}
+
+	var probe struct {
+		Streams []struct {
+			ColorTransfer string `json:"color_transfer"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil || len(probe.Streams) == 0 {
+		return false
+	}
+	return hdrTransfers[probe.Streams[0].ColorTransfer]
+}
+
+// FormatKeepsHDR reports whether an output format can carry HDR
+func FormatKeepsHDR(format string) bool {
+	return hdrFormats[format]
+}
+
+// ToneMapFilter returns the filter chain that converts HDR (PQ or HLG) video
+// to BT.709 SDR, so re-encoded output isn't washed out. It needs an FFmpeg
+// built with libzimg for zscale.
+func ToneMapFilter(algorithm string) string {
+	return fmt.Sprintf("zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,tonemap=tonemap=%s:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p", algorithm)
+}
@@ -31,11 +31,9 @@ func GetFirstFromNestedList(data map[string]interface{}, keys []string, defaultV
 	return defaultVal
 }
 
-// GetIntStat extracts an integer statistic from a map with type checking
+// GetIntStat extracts an integer statistic from a map, under its own name or
+// one of its aliases, or 0 when it is missing (see LookupIntStat)
 func GetIntStat(statistics map[string]interface{}, key string) int {
-	val, ok := statistics[key].(float64)
-	if ok {
-		return int(val)
-	}
-	return 0
+	count, _ := LookupIntStat(statistics, key)
+	return count
 }
\ No newline at end of file
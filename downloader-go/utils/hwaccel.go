@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HWAccelEncoders maps the FFMPEG_HWACCEL modes to their H.264 encoders
+var HWAccelEncoders = map[string]string{
+	"nvenc":        "h264_nvenc",
+	"vaapi":        "h264_vaapi",
+	"qsv":          "h264_qsv",
+	"videotoolbox": "h264_videotoolbox",
+}
+
+// hwAccel is the hardware encoder mode slideshows use, empty for libx264,
+// and hwAccelDevice the VAAPI render node
+var (
+	hwAccel       string
+	hwAccelDevice = "/dev/dri/renderD128"
+)
+
+// hwAccelProbeTimeout bounds the test encode run at startup
+const hwAccelProbeTimeout = 30 * time.Second
+
+// SetHWAccel enables a hardware encoder for slideshows after checking with a
+// short test encode that it works on this host; when the probe fails
+// slideshows keep using libx264. An empty mode disables hardware encoding.
+func SetHWAccel(ctx context.Context, mode, device string) error {
+	hwAccel = ""
+	if mode == "" {
+		return nil
+	}
+	if _, ok := HWAccelEncoders[mode]; !ok {
+		return fmt.Errorf("unknown hardware encoder %q", mode)
+	}
+	if device != "" {
+		hwAccelDevice = device
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, hwAccelProbeTimeout)
+	defer cancel()
+	args := append([]string{"-y"}, hwAccelGlobalArgs(mode)...)
+	args = append(args, "-f", "lavfi", "-i", "color=c=black:s=256x256:d=0.2")
+	args = append(args, "-vf", strings.TrimPrefix(hwAccelUploadFilter(mode), ","))
+	args = append(args, hwEncoderArgs(mode, SlideshowOptions{Preset: "medium", CRF: 23})...)
+	args = append(args, "-f", "null", "-")
+	if err := runFFmpeg(probeCtx, args); err != nil {
+		log.Printf("Hardware encoder %s unavailable, using libx264: %v", HWAccelEncoders[mode], err)
+		return nil
+	}
+
+	hwAccel = mode
+	log.Printf("Encoding slideshows with %s", HWAccelEncoders[mode])
+	return nil
+}
+
+// hwAccelGlobalArgs returns the options placed before the inputs, e.g. the
+// VAAPI device
+func hwAccelGlobalArgs(mode string) []string {
+	if mode == "vaapi" {
+		return []string{"-vaapi_device", hwAccelDevice}
+	}
+	return nil
+}
+
+// hwAccelUploadFilter returns the filters that hand frames to the encoder:
+// VAAPI encodes from GPU surfaces, the others take NV12 system memory frames
+func hwAccelUploadFilter(mode string) string {
+	if mode == "vaapi" {
+		return ",format=nv12,hwupload"
+	}
+	return ",format=nv12"
+}
+
+// hwEncoderArgs returns the encoder options of a hardware encoder. Presets
+// map onto the encoder's speed levels and CRF onto its constant quality mode.
+func hwEncoderArgs(mode string, opts SlideshowOptions) []string {
+	args := []string{"-c:v", HWAccelEncoders[mode]}
+	quality := strconv.Itoa(opts.CRF)
+	speed := max(PresetIndex(opts.Preset), 0) // 0 (ultrafast) to 8 (veryslow)
+
+	switch mode {
+	case "nvenc":
+		// p1 (fastest) to p7 (slowest)
+		args = append(args, "-preset", fmt.Sprintf("p%d", 1+speed*6/(len(X264Presets)-1)))
+		if opts.TargetBitrateKbps == 0 {
+			args = append(args, "-rc", "vbr", "-cq", quality, "-b:v", "0")
+		}
+	case "qsv":
+		// QSV names its levels like x264, from veryfast to veryslow
+		args = append(args, "-preset", X264Presets[max(speed, PresetIndex("veryfast"))])
+		if opts.TargetBitrateKbps == 0 {
+			args = append(args, "-global_quality", quality)
+		}
+	case "vaapi":
+		if opts.TargetBitrateKbps == 0 {
+			args = append(args, "-rc_mode", "CQP", "-qp", quality)
+		}
+	case "videotoolbox":
+		// -q:v runs from 1 to 100, higher is better; CRF 23 lands near 54
+		if opts.TargetBitrateKbps == 0 {
+			args = append(args, "-q:v", strconv.Itoa(min(max(100-2*opts.CRF, 1), 100)))
+		}
+	}
+	return args
+}
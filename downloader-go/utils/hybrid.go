@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tiktok-downloader/cache"
+	"tiktok-downloader/config"
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+)
+
+// HybridCacheKey is set on hybrid API data to "hit" when it was served from
+// the response cache and "miss" when it was fetched
+const HybridCacheKey = "_hybrid_cache"
+
+// hybridCache holds recent hybrid API responses; nil disables caching
+var (
+	hybridCache    cache.Cache
+	hybridCacheTTL time.Duration
+)
+
+func init() {
+	metrics.Register("tikdownloader_hybrid_cache_requests_total", "Hybrid API response cache lookups by result.", metrics.Counter)
+}
+
+// SetHybridCache caches hybrid API responses in c for ttl
+func SetHybridCache(c cache.Cache, ttl time.Duration) {
+	hybridCache = c
+	hybridCacheTTL = ttl
+}
+
+// FetchHybridData fetches post data for a TikTok/Douyin URL from the hybrid
+// API, serving repeated requests for the same post from the cache
+func FetchHybridData(ctx context.Context, cfg *config.AppConfig, sourceURL string, minimal bool) (map[string]interface{}, error) {
+	sourceURL = ResolveShareURL(ctx, sourceURL)
+	if hybridCache == nil {
+		data, err := fetchHybridWithFallback(ctx, sourceURL, minimal)
+		if err != nil {
+			return nil, err
+		}
+		data[HybridCacheKey] = "miss"
+		return data, nil
+	}
+
+	key := fmt.Sprintf("hybrid:%s:minimal=%t", CanonicalURL(sourceURL), minimal)
+	if raw, ok := hybridCache.Get(ctx, key); ok {
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err == nil {
+			metrics.Inc("tikdownloader_hybrid_cache_requests_total", metrics.Labels{"result": "hit"})
+			data[HybridCacheKey] = "hit"
+			return data, nil
+		}
+	}
+	metrics.Inc("tikdownloader_hybrid_cache_requests_total", metrics.Labels{"result": "miss"})
+
+	data, err := fetchHybridWithFallback(ctx, sourceURL, minimal)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := json.Marshal(data); err == nil {
+		hybridCache.Set(ctx, key, raw, hybridCacheTTL)
+	}
+	data[HybridCacheKey] = "miss"
+	return data, nil
+}
+
+// CanonicalURL normalizes a post URL so trivially different links to the
+// same post share a cache entry: the scheme and host are lowercased and the
+// query string, fragment and trailing slash are dropped
+func CanonicalURL(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "m.")
+	host = strings.TrimPrefix(host, "www.")
+	return "https://" + host + strings.TrimRight(parsed.Path, "/")
+}
+
+// hybridTimeout bounds each hybrid API request
+var hybridTimeout = 30 * time.Second
+
+// SetHybridTimeout sets the timeout of hybrid API requests
+func SetHybridTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		hybridTimeout = timeout
+	}
+}
+
+// FetchHybridDataFrom fetches post data from a specific hybrid API endpoint
+func FetchHybridDataFrom(ctx context.Context, endpoint, sourceURL string, minimal bool) (map[string]interface{}, error) {
+	apiURL := fmt.Sprintf("%s?url=%s&minimal=%t", endpoint, url.QueryEscape(sourceURL), minimal)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch data: %v", err)
+	}
+	if err := hybridPacer.Wait(ctx, req.URL.Host); err != nil {
+		return nil, fmt.Errorf("Failed to fetch data: %v", err)
+	}
+
+	httpClient := &http.Client{Timeout: hybridTimeout, Transport: upstreamTransport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamDownError{fmt.Errorf("Failed to fetch data: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, upstreamDownError{fmt.Errorf("External API returned error: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Tell private, deleted and restricted posts apart when upstream says why
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if unavailable := classifyErrorBody(body); unavailable != nil {
+			return nil, unavailable
+		}
+		return nil, fmt.Errorf("External API returned error: %d", resp.StatusCode)
+	}
+
+	data, err := DecodeHybridPayload(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+	if unavailable := classifyPayload(data); unavailable != nil {
+		return nil, unavailable
+	}
+	data[HybridEndpointKey] = endpoint
+
+	if videoData, ok := data["data"].(map[string]interface{}); ok {
+		awemeID := GetAwemeID(videoData)
+		logging.Debugf("Hybrid API resolved %s (minimal=%t) to aweme %s", sourceURL, minimal, awemeID)
+		if logging.ShouldDumpPayload(awemeID) {
+			if payload, err := json.MarshalIndent(data, "", "  "); err == nil {
+				log.Printf("Upstream payload for aweme %s:\n%s", awemeID, payload)
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// GetAwemeID returns the post ID from hybrid API video data
+func GetAwemeID(videoData map[string]interface{}) string {
+	for _, key := range []string{"aweme_id", "video_id"} {
+		switch v := videoData[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return "unknown"
+}
+
+// GetImageURLs returns the no-watermark image URLs of an image post
+func GetImageURLs(videoData map[string]interface{}) []string {
+	var imageURLs []string
+	if imageData, ok := videoData["image_data"].(map[string]interface{}); ok {
+		if nwImages, ok := imageData["no_watermark_image_list"].([]interface{}); ok {
+			for _, img := range nwImages {
+				if imgStr, ok := img.(string); ok {
+					imageURLs = append(imageURLs, imgStr)
+				}
+			}
+		}
+	}
+	return imageURLs
+}
+
+// fullSongKeys lists the music fields that may carry the full licensed track
+// behind a clipped original sound
+var fullSongKeys = []string{"matched_song", "matched_pgc_sound", "song"}
+
+// GetFullSong returns the play URL and title of the full licensed track for a
+// post's sound, or empty strings when the platform didn't match one
+func GetFullSong(music map[string]interface{}) (string, string) {
+	for _, key := range fullSongKeys {
+		song, ok := music[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		songURL := GetFirstFromNestedList(song, []string{"play_url", "url_list"}, "")
+		if songURL == "" {
+			if uri, ok := GetNestedValue(song, []string{"play_url", "uri"}, "").(string); ok {
+				songURL = uri
+			}
+		}
+		if songURL == "" {
+			continue
+		}
+		title, _ := song["title"].(string)
+		return songURL, title
+	}
+	return "", ""
+}
+
+// GetMusicURL returns the play URL of a post's original sound
+func GetMusicURL(music map[string]interface{}) string {
+	if uri, ok := GetNestedValue(music, []string{"play_url", "uri"}, "").(string); ok && uri != "" {
+		return uri
+	}
+	if url, ok := GetNestedValue(music, []string{"play_url", "url"}, "").(string); ok {
+		return url
+	}
+	return ""
+}
+
+// videoURLKeys maps download link keys to their video_data fields
+var videoURLKeys = map[string]string{
+	"watermark":       "wm_video_url",
+	"watermark_hd":    "wm_video_url_HQ",
+	"no_watermark":    "nwm_video_url",
+	"no_watermark_hd": "nwm_video_url_HQ",
+}
+
+// CoverKey is the download link key of a post's cover image
+const CoverKey = "cover"
+
+// ResolveMediaURL finds the media URL for a download link key in freshly
+// fetched video data, returning "" when the post no longer carries it
+func ResolveMediaURL(videoData map[string]interface{}, key string, index int) string {
+	music, _ := videoData["music"].(map[string]interface{})
+
+	switch key {
+	case "mp3", "mp3_original":
+		return GetMusicURL(music)
+	case "mp3_full":
+		songURL, _ := GetFullSong(music)
+		return songURL
+	case ExtractedAudioKey:
+		if mediaURL := ResolveMediaURL(videoData, "no_watermark", 0); mediaURL != "" {
+			return mediaURL
+		}
+		return ResolveMediaURL(videoData, "no_watermark_hd", 0)
+	case ProcessedNoWatermarkKey:
+		return ResolveMediaURL(videoData, "watermark", 0)
+	case CoverKey:
+		return GetFirstFromNestedList(videoData, []string{"cover_data", "cover", "url_list"}, "")
+	}
+
+	if typeVal, _ := videoData["type"].(string); typeVal == "image" {
+		if key != "no_watermark" {
+			return ""
+		}
+		images := GetImageURLs(videoData)
+		if index < 0 || index >= len(images) {
+			return ""
+		}
+		return images[index]
+	}
+
+	field, ok := videoURLKeys[key]
+	if !ok {
+		return ""
+	}
+	mediaURL, _ := GetNestedValue(videoData, []string{"video_data", field}, "").(string)
+	return mediaURL
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// Image formats recognised by DetectImageFormat
+const (
+	ImageFormatJPEG = "jpeg"
+	ImageFormatPNG  = "png"
+	ImageFormatWebP = "webp"
+	ImageFormatHEIF = "heif"
+)
+
+// DetectImageFormat identifies an image format from its leading magic bytes
+func DetectImageFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return ImageFormatJPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return ImageFormatPNG
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return ImageFormatWebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		switch string(header[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1", "avif":
+			return ImageFormatHEIF
+		}
+	}
+	return ""
+}
+
+// ValidateImage checks that a downloaded image is non-empty, has a known
+// format and, for formats Go can decode, decodes completely. It returns the
+// detected format.
+func ValidateImage(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(file, header)
+	if n == 0 {
+		return "", fmt.Errorf("image is empty")
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	format := DetectImageFormat(header[:n])
+	switch format {
+	case "":
+		return "", fmt.Errorf("unrecognised image format")
+	case ImageFormatHEIF:
+		// Not decodable in Go; ffmpeg handles it during conversion
+		return format, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, _, err := image.Decode(file); err != nil {
+		return format, fmt.Errorf("corrupt %s image: %w", format, err)
+	}
+	return format, nil
+}
+
+// DownloadImage downloads an image and validates it, re-fetching once when
+// the first copy is truncated or corrupt
+func DownloadImage(ctx context.Context, url, outputPath string) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2 && ctx.Err() == nil; attempt++ {
+		if err := DownloadFile(ctx, url, outputPath); err != nil {
+			lastErr = err
+			continue
+		}
+
+		format, err := ValidateImage(outputPath)
+		if err == nil {
+			return format, nil
+		}
+		lastErr = err
+	}
+
+	os.Remove(outputPath)
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return "", lastErr
+}
+
+// imageExtensions maps detected formats to the file extension ffmpeg expects
+var imageExtensions = map[string]string{
+	ImageFormatJPEG: ".jpg",
+	ImageFormatPNG:  ".png",
+	ImageFormatWebP: ".webp",
+}
+
+// PrepareImage renames a validated image so its extension matches its real
+// format, pre-converting HEIF stills to JPEG. It returns the new path.
+func PrepareImage(ctx context.Context, path, format string) (string, error) {
+	if format == ImageFormatHEIF {
+		jpegPath := path + ".jpg"
+		err := runFFmpeg(ctx, []string{"-y",
+			"-i", path,
+			"-frames:v", "1",
+			"-q:v", "2",
+			jpegPath,
+		})
+		os.Remove(path)
+		if err != nil {
+			return "", fmt.Errorf("error converting HEIF image: %w", err)
+		}
+		return jpegPath, nil
+	}
+
+	ext, ok := imageExtensions[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported image format %q", format)
+	}
+	newPath := path + ext
+	if err := os.Rename(path, newPath); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
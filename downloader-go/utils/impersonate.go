@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// impersonation holds the platforms whose CDN fetches use a Chrome TLS fingerprint
+var impersonation struct {
+	sync.RWMutex
+	platforms map[string]bool
+}
+
+// chromeTransport speaks HTTP/1.1 over a TLS handshake that mimics Chrome.
+// ALPN is pinned to http/1.1 because net/http can't run HTTP/2 over a uTLS conn.
+var chromeTransport = &http.Transport{
+	DialTLSContext:        dialChromeTLS,
+	ForceAttemptHTTP2:     false,
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// SetTLSImpersonation enables the Chrome TLS fingerprint for the given platforms
+func SetTLSImpersonation(platforms []string) error {
+	enabled := make(map[string]bool, len(platforms))
+	for _, platform := range platforms {
+		if _, ok := platformHostSuffixes[platform]; !ok {
+			return fmt.Errorf("unknown platform %q for TLS impersonation", platform)
+		}
+		enabled[platform] = true
+	}
+
+	impersonation.Lock()
+	impersonation.platforms = enabled
+	impersonation.Unlock()
+	return nil
+}
+
+// impersonated reports whether a platform uses the Chrome TLS fingerprint
+func impersonated(platform string) bool {
+	if platform == "" {
+		return false
+	}
+	impersonation.RLock()
+	defer impersonation.RUnlock()
+	return impersonation.platforms[platform]
+}
+
+// dialChromeTLS dials addr and performs a TLS handshake with Chrome's ClientHello
+func dialChromeTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	conn, err := dialUpstream(ctx, dialer, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := utls.UTLSIdToSpec(utls.HelloChrome_Auto)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, ext := range spec.Extensions {
+		if alpn, ok := ext.(*utls.ALPNExtension); ok {
+			alpn.AlpnProtocols = []string{"http/1.1"}
+		}
+	}
+
+	tlsConn := utls.UClient(conn, &utls.Config{ServerName: host}, utls.HelloCustom)
+	if err := tlsConn.ApplyPreset(&spec); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/logging"
+)
+
+// instagramGraphQLURL is the endpoint instagram.com loads post pages from
+const instagramGraphQLURL = "https://www.instagram.com/graphql/query"
+
+// instagramAppID is the web app ID instagram.com sends with its API calls
+const instagramAppID = "936619743392459"
+
+// instagramUserAgent is sent with GraphQL requests, which Instagram refuses
+// to clients that don't look like a browser
+const instagramUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// instagramPathPattern matches post paths and captures their shortcode:
+// /reel/<code>, /reels/<code>, /p/<code> and /tv/<code>, optionally after
+// the author's handle
+var instagramPathPattern = regexp.MustCompile(`^/(?:[A-Za-z0-9_.]+/)?(?:reels?|p|tv)/([A-Za-z0-9_-]+)`)
+
+// InstagramExtractor resolves Instagram Reels and posts through the
+// GraphQL query behind instagram.com's post pages
+type InstagramExtractor struct{}
+
+// Platform implements Extractor
+func (InstagramExtractor) Platform() string {
+	return PlatformInstagram
+}
+
+// Match implements Extractor
+func (InstagramExtractor) Match(sourceURL string) bool {
+	return instagramShortcode(sourceURL) != ""
+}
+
+// instagramShortcode returns the shortcode of an Instagram post URL, or ""
+func instagramShortcode(sourceURL string) string {
+	parsed, err := parsePostURL(sourceURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "instagram.com" && !strings.HasSuffix(host, ".instagram.com") {
+		return ""
+	}
+	match := instagramPathPattern.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Fetch implements Extractor, serving repeated requests for a post from the
+// hybrid API response cache
+func (InstagramExtractor) Fetch(ctx context.Context, cfg *config.AppConfig, sourceURL string) (map[string]interface{}, error) {
+	shortcode := instagramShortcode(sourceURL)
+	if shortcode == "" {
+		return nil, fmt.Errorf("not an Instagram post URL")
+	}
+
+	key := "instagram:" + shortcode
+	if hybridCache != nil {
+		if raw, ok := hybridCache.Get(ctx, key); ok {
+			var data map[string]interface{}
+			if err := json.Unmarshal(raw, &data); err == nil {
+				data[HybridCacheKey] = "hit"
+				return data, nil
+			}
+		}
+	}
+
+	media, err := fetchInstagramMedia(ctx, cfg, shortcode)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{
+		"status": "success",
+		"data":   instagramVideoData(media, shortcode),
+	}
+	logging.Debugf("Instagram resolved %s to media %v", shortcode, media["id"])
+
+	if hybridCache != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			hybridCache.Set(ctx, key, raw, hybridCacheTTL)
+		}
+	}
+	data[HybridCacheKey] = "miss"
+	return data, nil
+}
+
+// fetchInstagramMedia runs the post page GraphQL query for a shortcode and
+// returns its xdt_shortcode_media object
+func fetchInstagramMedia(ctx context.Context, cfg *config.AppConfig, shortcode string) (map[string]interface{}, error) {
+	variables, _ := json.Marshal(map[string]interface{}{
+		"shortcode":               shortcode,
+		"fetch_tagged_user_count": nil,
+		"hoisted_comment_id":      nil,
+		"hoisted_reply_id":        nil,
+	})
+	form := url.Values{
+		"variables": {string(variables)},
+		"doc_id":    {cfg.InstagramDocID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, instagramGraphQLURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch data: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", instagramUserAgent)
+	req.Header.Set("X-IG-App-ID", instagramAppID)
+	req.Header.Set("X-FB-Friendly-Name", "PolarisPostActionLoadPostQueryQuery")
+	req.Header.Set("Referer", "https://www.instagram.com/reel/"+shortcode+"/")
+	if cfg.InstagramSessionID != "" {
+		// Some posts are only served to logged-in sessions
+		req.AddCookie(&http.Cookie{Name: "sessionid", Value: cfg.InstagramSessionID})
+	}
+
+	httpClient := &http.Client{Timeout: hybridTimeout, Transport: upstreamTransport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamDownError{fmt.Errorf("Failed to fetch data: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, upstreamDownError{fmt.Errorf("Instagram returned error: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("Instagram returned error: %d", resp.StatusCode)
+	}
+
+	payload, err := DecodeHybridPayload(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+	media, ok := GetNestedValue(payload, []string{"data", "xdt_shortcode_media"}, nil).(map[string]interface{})
+	if !ok {
+		// A null media is what Instagram answers for private, deleted and
+		// login-walled posts alike
+		return nil, &PostUnavailableError{Code: PostPrivate, Detail: "Instagram returned no media"}
+	}
+	return media, nil
+}
+
+// instagramVideoData converts an Instagram media object to hybrid API post
+// data. Carousels become image posts of their slides' pictures.
+func instagramVideoData(media map[string]interface{}, shortcode string) map[string]interface{} {
+	owner, _ := media["owner"].(map[string]interface{})
+	username, _ := owner["username"].(string)
+	nickname, _ := owner["full_name"].(string)
+	if nickname == "" {
+		nickname = username
+	}
+	ownerID, _ := owner["id"].(string)
+	avatar, _ := owner["profile_pic_url"].(string)
+
+	desc := ""
+	if captions, ok := GetNestedValue(media, []string{"edge_media_to_caption", "edges"}, nil).([]interface{}); ok && len(captions) > 0 {
+		caption, _ := captions[0].(map[string]interface{})
+		desc, _ = GetNestedValue(caption, []string{"node", "text"}, "").(string)
+	}
+
+	mediaID, _ := media["id"].(string)
+	if mediaID == "" {
+		mediaID = shortcode
+	}
+	cover, _ := media["display_url"].(string)
+
+	videoData := map[string]interface{}{
+		"platform": PlatformInstagram,
+		"aweme_id": mediaID,
+		"desc":     desc,
+		"author": map[string]interface{}{
+			"nickname":     nickname,
+			"unique_id":    username,
+			"uid":          ownerID,
+			"avatar_thumb": map[string]interface{}{"url_list": []interface{}{avatar}},
+		},
+		"statistics": map[string]interface{}{
+			"digg_count":    GetNestedValue(media, []string{"edge_media_preview_like", "count"}, nil),
+			"comment_count": GetNestedValue(media, []string{"edge_media_to_comment", "count"}, nil),
+			"play_count":    firstPresent(media, "video_play_count", "video_view_count"),
+			// Instagram has no reposts
+			"repost_count": 0,
+		},
+		"cover_data": map[string]interface{}{
+			"cover": map[string]interface{}{"url_list": []interface{}{cover}},
+		},
+	}
+	if created, ok := media["taken_at_timestamp"].(float64); ok {
+		videoData["create_time"] = created
+	}
+
+	// Reels credit their sound but carry no audio URL of it, so the mp3 is
+	// extracted from the video's audio track
+	if song, ok := media["clips_music_attribution_info"].(map[string]interface{}); ok {
+		videoData["music"] = map[string]interface{}{
+			"title":  song["song_name"],
+			"author": song["artist_name"],
+		}
+	}
+
+	if isVideo, _ := media["is_video"].(bool); isVideo {
+		videoURL, _ := media["video_url"].(string)
+		videoData["type"] = "video"
+		videoData["video_data"] = map[string]interface{}{
+			"nwm_video_url":    videoURL,
+			"nwm_video_url_HQ": videoURL,
+		}
+		if seconds, ok := media["video_duration"].(float64); ok {
+			videoData["duration"] = seconds * 1000
+		}
+		return videoData
+	}
+
+	images := []interface{}{}
+	if slides, ok := GetNestedValue(media, []string{"edge_sidecar_to_children", "edges"}, nil).([]interface{}); ok {
+		for _, slide := range slides {
+			node, _ := slide.(map[string]interface{})
+			if image, ok := GetNestedValue(node, []string{"node", "display_url"}, "").(string); ok && image != "" {
+				images = append(images, image)
+			}
+		}
+	}
+	if len(images) == 0 && cover != "" {
+		images = append(images, cover)
+	}
+	videoData["type"] = "image"
+	videoData["image_data"] = map[string]interface{}{"no_watermark_image_list": images}
+	return videoData
+}
+
+// firstPresent returns the first of keys set in m, or nil
+func firstPresent(m map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if v, ok := m[key]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// IntroCard is the post context burned into the intro frame of a slideshow
+type IntroCard struct {
+	Nickname   string
+	Caption    string
+	Likes      int
+	Comments   int
+	AvatarPath string // optional downloaded avatar image
+}
+
+// Layout of the 1080x1920 intro frame
+const (
+	introWidth        = 1080
+	introHeight       = 1920
+	introAvatarSize   = 320
+	introCaptionWidth = 36 // characters per caption line at the caption font size
+	introCaptionLines = 8
+)
+
+// introFont selects the drawtext font: a font file when set, else the
+// fontconfig "Sans" family
+var introFont string
+
+// SetIntroFont sets the font file used for slideshow intro frames; CJK
+// captions need a font that covers them, e.g. Noto Sans CJK
+func SetIntroFont(path string) {
+	introFont = path
+}
+
+// CompactCount formats a count the way the apps show it: 987, 12.3K, 4.5M
+func CompactCount(n int) string {
+	switch {
+	case n >= 1_000_000_000:
+		return trimDecimal(float64(n)/1e9) + "B"
+	case n >= 1_000_000:
+		return trimDecimal(float64(n)/1e6) + "M"
+	case n >= 10_000:
+		return trimDecimal(float64(n)/1e3) + "K"
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// trimDecimal formats f with one decimal, dropping a trailing ".0"
+func trimDecimal(f float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", f), ".0")
+}
+
+// WrapCaption breaks a caption into at most maxLines lines of about width
+// characters, ellipsizing the rest
+func WrapCaption(caption string, width, maxLines int) []string {
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if line.Len() > 0 {
+			lines = append(lines, strings.TrimSpace(line.String()))
+			line.Reset()
+		}
+	}
+
+	for _, word := range strings.Fields(caption) {
+		// Break words longer than a line, e.g. unspaced CJK text
+		for utf8.RuneCountInString(word) > width {
+			flush()
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		if line.Len() > 0 && utf8.RuneCountInString(line.String())+1+utf8.RuneCountInString(word) > width {
+			flush()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	flush()
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		if len(last) > width-1 {
+			last = last[:width-1]
+		}
+		lines[maxLines-1] = string(last) + "…"
+	}
+	return lines
+}
+
+// Escaping of filter option values, and of filter descriptions in a filtergraph
+var (
+	optionEscaper      = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	filtergraphEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `,`, `\,`, `;`, `\;`, `[`, `\[`, `]`, `\]`)
+)
+
+// drawtextFont returns the drawtext option selecting the configured font
+func drawtextFont() string {
+	if introFont != "" {
+		return "fontfile=" + drawtextEscape(introFont)
+	}
+	return "font=Sans"
+}
+
+// drawtextEscape escapes a value, e.g. a path, for use as a filter option
+// inside a -filter_complex graph
+func drawtextEscape(value string) string {
+	return filtergraphEscaper.Replace(optionEscaper.Replace(value))
+}
+
+// CreateIntroFrame renders the intro frame of a slideshow as a JPEG: the
+// author's avatar, nickname, like and comment counts and the caption on a
+// dark background. Texts are passed to drawtext through files in workDir so
+// captions need no escaping.
+func CreateIntroFrame(ctx context.Context, card IntroCard, workDir, outputPath string) error {
+	font := drawtextFont()
+
+	texts := map[string]string{
+		"nickname": card.Nickname,
+		"stats":    fmt.Sprintf("%s likes   %s comments", CompactCount(card.Likes), CompactCount(card.Comments)),
+		"caption":  strings.Join(WrapCaption(card.Caption, introCaptionWidth, introCaptionLines), "\n"),
+	}
+	textFiles := make(map[string]string, len(texts))
+	for name, text := range texts {
+		path := filepath.Join(workDir, "intro_"+name+".txt")
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			return err
+		}
+		textFiles[name] = drawtextEscape(path)
+	}
+
+	textTop := 360
+	args := []string{"-y",
+		"-f", "lavfi", "-i", fmt.Sprintf("color=c=0x121212:s=%dx%d:d=1", introWidth, introHeight),
+	}
+	filters := []string{}
+	base := "[0:v]"
+	if card.AvatarPath != "" {
+		// Round avatar, centered above the texts
+		args = append(args, "-i", card.AvatarPath)
+		r := introAvatarSize / 2
+		filters = append(filters,
+			fmt.Sprintf("[1:v]scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,format=rgba,"+
+				"geq=r='r(X,Y)':g='g(X,Y)':b='b(X,Y)':a='if(lte(hypot(X-%d,Y-%d),%d),255,0)'[avatar]",
+				introAvatarSize, introAvatarSize, introAvatarSize, introAvatarSize, r, r, r),
+			fmt.Sprintf("[0:v][avatar]overlay=(W-w)/2:%d[bg]", textTop),
+		)
+		base = "[bg]"
+		textTop += introAvatarSize + 60
+	} else {
+		textTop += 200
+	}
+
+	filters = append(filters, fmt.Sprintf("%s"+
+		"drawtext=%s:textfile=%s:fontsize=64:fontcolor=white:x=(w-text_w)/2:y=%d,"+
+		"drawtext=%s:textfile=%s:fontsize=44:fontcolor=0xbbbbbb:x=(w-text_w)/2:y=%d,"+
+		"drawtext=%s:textfile=%s:fontsize=44:fontcolor=white:line_spacing=18:x=(w-text_w)/2:y=%d[out]",
+		base,
+		font, textFiles["nickname"], textTop,
+		font, textFiles["stats"], textTop+100,
+		font, textFiles["caption"], textTop+220,
+	))
+
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[out]",
+		"-frames:v", "1",
+		"-q:v", "2",
+		outputPath,
+	)
+	return runFFmpeg(ctx, args)
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"tiktok-downloader/metrics"
+)
+
+// ErrPayloadTooLarge is returned for hybrid API responses over the size cap
+var ErrPayloadTooLarge = errors.New("upstream response too large")
+
+// hybridMaxPayload caps how many bytes of a hybrid API response are read
+var hybridMaxPayload int64 = 32 << 20
+
+func init() {
+	metrics.Register("tikdownloader_hybrid_payload_too_large_total", "Hybrid API responses rejected for exceeding HYBRID_MAX_PAYLOAD_MB.", metrics.Counter)
+	metrics.Register("tikdownloader_hybrid_payload_skipped_fields_total", "Bulky hybrid API fields skipped while decoding, by field.", metrics.Counter)
+}
+
+// SetHybridMaxPayload sets the largest hybrid API response read, in bytes
+func SetHybridMaxPayload(maxBytes int64) {
+	if maxBytes > 0 {
+		hybridMaxPayload = maxBytes
+	}
+}
+
+// hybridSkippedFields lists post fields of full payloads that can run to
+// megabytes (comment expansions, stickers, ads) and that nothing reads, so
+// they are skipped token by token instead of being decoded
+var hybridSkippedFields = map[string]bool{
+	"comments":             true,
+	"comment_list":         true,
+	"top_comments":         true,
+	"interaction_stickers": true,
+	"anchors":              true,
+	"commerce_info":        true,
+	"ad_info":              true,
+	"danmaku_info":         true,
+}
+
+// cappedReader fails with ErrPayloadTooLarge once more than remaining bytes
+// have been read
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		return 0, ErrPayloadTooLarge
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining < 0 {
+		return n, ErrPayloadTooLarge
+	}
+	return n, err
+}
+
+// DecodeHybridPayload decodes a hybrid API response as it streams in, reading
+// at most hybridMaxPayload bytes and skipping the bulky fields of its post
+// data without building them in memory
+func DecodeHybridPayload(r io.Reader) (map[string]interface{}, error) {
+	dec := json.NewDecoder(&cappedReader{r: r, remaining: hybridMaxPayload})
+
+	payload, err := decodeStreamed(dec, func(key string, dec *json.Decoder) (interface{}, bool, error) {
+		if key != "data" {
+			v, err := decodeValue(dec)
+			return v, true, err
+		}
+		v, err := decodeStreamed(dec, decodePostField)
+		return v, true, err
+	})
+	if errors.Is(err, ErrPayloadTooLarge) {
+		metrics.Inc("tikdownloader_hybrid_payload_too_large_total", nil)
+		return nil, fmt.Errorf("%w (over %d MB)", ErrPayloadTooLarge, hybridMaxPayload>>20)
+	}
+	if err != nil {
+		return nil, err
+	}
+	object, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON object, got %T", payload)
+	}
+	return object, nil
+}
+
+// decodePostField decodes a field of a payload's post data, skipping those in
+// hybridSkippedFields
+func decodePostField(key string, dec *json.Decoder) (interface{}, bool, error) {
+	if !hybridSkippedFields[key] {
+		v, err := decodeValue(dec)
+		return v, true, err
+	}
+	metrics.Inc("tikdownloader_hybrid_payload_skipped_fields_total", metrics.Labels{"field": key})
+	return nil, false, skipValue(dec)
+}
+
+// decodeStreamed reads the next value. Objects are read key by key, letting
+// field decode each value or skip it by reporting keep=false; anything else
+// is decoded whole.
+func decodeStreamed(dec *json.Decoder, field func(key string, dec *json.Decoder) (v interface{}, keep bool, err error)) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	if delim == '[' {
+		array := []interface{}{}
+		for dec.More() {
+			item, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+		_, err := dec.Token()
+		return array, err
+	}
+
+	object := make(map[string]interface{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		v, keep, err := field(key, dec)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			object[key] = v
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// decodeValue decodes the next value whole
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// skipValue consumes the next value without keeping any of it
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
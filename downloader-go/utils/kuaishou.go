@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/logging"
+)
+
+// kuaishouPageURL is the desktop page of a video, which embeds its data
+const kuaishouPageURL = "https://www.kuaishou.com/short-video/"
+
+// kuaishouUserAgent is sent with page requests; mobile clients are served a
+// page without the embedded data
+const kuaishouUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// kuaishouStateMarker precedes the Apollo cache embedded in video pages
+const kuaishouStateMarker = "window.__APOLLO_STATE__="
+
+// kuaishouHDHeight is the smallest height offered as the HD download
+const kuaishouHDHeight = 720
+
+// kuaishouPathPattern matches video paths and captures their photo ID:
+// /short-video/<id> on kuaishou.com and /fw/photo/<id> on the mobile share
+// pages (v.m.chenzhongtech.com, m.gifshow.com) short links redirect to
+var kuaishouPathPattern = regexp.MustCompile(`^/(?:short-video|fw/photo|fw/long-video|photo/[^/]+)/([A-Za-z0-9]+)`)
+
+// kuaishouHosts lists the domains of Kuaishou video pages
+var kuaishouHosts = []string{"kuaishou.com", "chenzhongtech.com", "gifshow.com"}
+
+// KuaishouExtractor resolves Kuaishou videos from the data embedded in
+// their kuaishou.com pages. Share short links are resolved to the video
+// first. Image posts are not supported.
+type KuaishouExtractor struct{}
+
+// Platform implements Extractor
+func (KuaishouExtractor) Platform() string {
+	return PlatformKuaishou
+}
+
+// Match implements Extractor
+func (KuaishouExtractor) Match(sourceURL string) bool {
+	return isKuaishouShortLink(sourceURL) || kuaishouPhotoID(sourceURL) != ""
+}
+
+// isKuaishouShortLink reports whether a URL is a Kuaishou share short link
+// (v.kuaishou.com/<code> or www.kuaishou.com/f/<code>)
+func isKuaishouShortLink(sourceURL string) bool {
+	parsed, err := parsePostURL(sourceURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return IsShareURL(sourceURL) && (host == "kuaishou.com" || strings.HasSuffix(host, ".kuaishou.com"))
+}
+
+// kuaishouPhotoID returns the photo ID of a Kuaishou video URL, or ""
+func kuaishouPhotoID(sourceURL string) string {
+	parsed, err := parsePostURL(sourceURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, suffix := range kuaishouHosts {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			if match := kuaishouPathPattern.FindStringSubmatch(parsed.Path); match != nil {
+				return match[1]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// Fetch implements Extractor, serving repeated requests for a video from the
+// hybrid API response cache
+func (KuaishouExtractor) Fetch(ctx context.Context, cfg *config.AppConfig, sourceURL string) (map[string]interface{}, error) {
+	photoID := kuaishouPhotoID(ResolveShareURL(ctx, sourceURL))
+	if photoID == "" {
+		return nil, fmt.Errorf("not a Kuaishou video URL")
+	}
+
+	key := "kuaishou:" + photoID
+	if hybridCache != nil {
+		if raw, ok := hybridCache.Get(ctx, key); ok {
+			var data map[string]interface{}
+			if err := json.Unmarshal(raw, &data); err == nil {
+				data[HybridCacheKey] = "hit"
+				return data, nil
+			}
+		}
+	}
+
+	state, err := fetchKuaishouState(ctx, cfg, photoID)
+	if err != nil {
+		return nil, err
+	}
+	videoData, err := kuaishouVideoData(state, photoID)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{
+		"status": "success",
+		"data":   videoData,
+	}
+	logging.Debugf("Kuaishou resolved photo %s", photoID)
+
+	if hybridCache != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			hybridCache.Set(ctx, key, raw, hybridCacheTTL)
+		}
+	}
+	data[HybridCacheKey] = "miss"
+	return data, nil
+}
+
+// fetchKuaishouState loads the page of a video and returns the Apollo cache
+// embedded in it
+func fetchKuaishouState(ctx context.Context, cfg *config.AppConfig, photoID string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kuaishouPageURL+photoID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch data: %v", err)
+	}
+	req.Header.Set("User-Agent", kuaishouUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	if cfg.KuaishouCookie != "" {
+		// Without a did cookie Kuaishou tends to answer with a captcha page
+		req.Header.Set("Cookie", cfg.KuaishouCookie)
+	}
+
+	httpClient := &http.Client{Timeout: hybridTimeout, Transport: upstreamTransport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamDownError{fmt.Errorf("Failed to fetch data: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, upstreamDownError{fmt.Errorf("Kuaishou returned error: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("Kuaishou returned error: %d", resp.StatusCode)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, hybridMaxPayload))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response: %v", err)
+	}
+	return parseKuaishouState(string(page))
+}
+
+// parseKuaishouState decodes the Apollo cache assigned in a video page's
+// script, which goes on with more code after the object
+func parseKuaishouState(page string) (map[string]interface{}, error) {
+	start := strings.Index(page, kuaishouStateMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("Kuaishou page carries no video data (captcha? set KUAISHOU_COOKIE)")
+	}
+	var state map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(page[start+len(kuaishouStateMarker):]))
+	if err := decoder.Decode(&state); err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+	if client, ok := state["defaultClient"].(map[string]interface{}); ok {
+		return client, nil
+	}
+	return state, nil
+}
+
+// kuaishouVideoData converts the Apollo cache of a video page to hybrid API
+// post data
+func kuaishouVideoData(state map[string]interface{}, photoID string) (map[string]interface{}, error) {
+	photo, _ := state["VisionVideoDetailPhoto:"+photoID].(map[string]interface{})
+	if photo == nil {
+		photo = kuaishouEntity(state, "VisionVideoDetailPhoto:")
+	}
+	if photo == nil {
+		return nil, &PostUnavailableError{Code: PostDeleted, Detail: "Kuaishou returned no video"}
+	}
+	videoURL, _ := photo["photoUrl"].(string)
+	if videoURL == "" {
+		return nil, fmt.Errorf("Kuaishou post %s has no video; image posts are not supported", photoID)
+	}
+	author := kuaishouEntity(state, "VisionVideoDetailAuthor:")
+	if author == nil {
+		author = map[string]interface{}{}
+	}
+	cover, _ := photo["coverUrl"].(string)
+
+	urls := map[string]interface{}{"nwm_video_url": videoURL}
+	if hd := kuaishouHDURL(photo["manifest"]); hd != "" {
+		urls["nwm_video_url_HQ"] = hd
+	}
+
+	videoData := map[string]interface{}{
+		"type":     "video",
+		"platform": PlatformKuaishou,
+		"aweme_id": photoID,
+		"desc":     photo["caption"],
+		"author": map[string]interface{}{
+			"nickname":     author["name"],
+			"uid":          author["id"],
+			"avatar_thumb": map[string]interface{}{"url_list": []interface{}{author["headerUrl"]}},
+		},
+		// Counts may be abbreviated ("1.2万"); comments and shares are not on
+		// the page and come back flagged as missing
+		"statistics": map[string]interface{}{
+			"digg_count": firstPresent(photo, "realLikeCount", "likeCount"),
+			"play_count": photo["viewCount"],
+		},
+		"cover_data": map[string]interface{}{
+			"cover": map[string]interface{}{"url_list": []interface{}{cover}},
+		},
+		"video_data": urls,
+	}
+	if duration, ok := photo["duration"].(float64); ok {
+		videoData["duration"] = duration
+	}
+	if created, ok := photo["timestamp"].(float64); ok {
+		videoData["create_time"] = created / 1000
+	}
+	return videoData, nil
+}
+
+// kuaishouEntity returns the first entity of the Apollo cache whose key has
+// the prefix, or nil
+func kuaishouEntity(state map[string]interface{}, prefix string) map[string]interface{} {
+	for key, value := range state {
+		if entity, ok := value.(map[string]interface{}); ok && strings.HasPrefix(key, prefix) {
+			return entity
+		}
+	}
+	return nil
+}
+
+// kuaishouHDURL returns the tallest representation of a video's manifest
+// when it reaches 720p, or ""
+func kuaishouHDURL(manifest interface{}) string {
+	if wrapped, ok := manifest.(map[string]interface{}); ok && wrapped["type"] == "json" {
+		// Apollo wraps embedded JSON values as {"type": "json", "json": ...}
+		manifest = wrapped["json"]
+	}
+	fields, _ := manifest.(map[string]interface{})
+	sets, _ := fields["adaptationSet"].([]interface{})
+	best, bestHeight := "", 0.0
+	for _, set := range sets {
+		adaptation, _ := set.(map[string]interface{})
+		representations, _ := adaptation["representation"].([]interface{})
+		for _, representation := range representations {
+			entry, _ := representation.(map[string]interface{})
+			url, _ := entry["url"].(string)
+			width, _ := entry["width"].(float64)
+			height, _ := entry["height"].(float64)
+			if url != "" && min(width, height) >= kuaishouHDHeight && height > bestHeight {
+				best, bestHeight = url, height
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Link status values reported by VerifyMediaLinks
+const (
+	LinkOK          = "ok"
+	LinkForbidden   = "forbidden"
+	LinkExpired     = "expired"
+	LinkUnreachable = "unreachable"
+)
+
+// linkCheckTimeout bounds each media URL check
+const linkCheckTimeout = 5 * time.Second
+
+// expiryParams are the query parameters TikTok and Douyin CDNs use for URL expiry
+var expiryParams = []string{"x-expires", "expire", "expires", "x-expire"}
+
+// VerifyMediaLinks checks every media URL in parallel and returns the status
+// of each, keyed and shaped like the sources (a string or a list of strings)
+func VerifyMediaLinks(ctx context.Context, sources map[string]interface{}) map[string]interface{} {
+	client := NewSourceClient(linkCheckTimeout)
+	statuses := make(map[string]interface{}, len(sources))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for key, source := range sources {
+		switch v := source.(type) {
+		case string:
+			wg.Add(1)
+			go func(key, mediaURL string) {
+				defer wg.Done()
+				status := CheckMediaURL(ctx, client, mediaURL)
+				mu.Lock()
+				statuses[key] = status
+				mu.Unlock()
+			}(key, v)
+		case []string:
+			list := make([]string, len(v))
+			statuses[key] = list
+			for i, mediaURL := range v {
+				wg.Add(1)
+				go func(i int, mediaURL string) {
+					defer wg.Done()
+					list[i] = CheckMediaURL(ctx, client, mediaURL)
+				}(i, mediaURL)
+			}
+		}
+	}
+
+	wg.Wait()
+	return statuses
+}
+
+// CheckMediaURL probes a media URL with HEAD, falling back to a one-byte
+// ranged GET for CDNs that reject HEAD
+func CheckMediaURL(ctx context.Context, client *http.Client, mediaURL string) string {
+	resp, err := probe(ctx, client, http.MethodHead, mediaURL)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp, err = probe(ctx, client, http.MethodGet, mediaURL)
+	}
+	if err != nil {
+		return LinkUnreachable
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return LinkOK
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return LinkExpired
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		if urlExpired(mediaURL) {
+			return LinkExpired
+		}
+		return LinkForbidden
+	default:
+		return LinkUnreachable
+	}
+}
+
+// probe sends a single request and discards the body
+func probe(ctx context.Context, client *http.Client, method, mediaURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, mediaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// urlExpired reports whether a signed CDN URL carries an expiry in the past
+func urlExpired(mediaURL string) bool {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil {
+		return false
+	}
+	query := parsed.Query()
+	for _, param := range expiryParams {
+		if value := query.Get(param); value != "" {
+			if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return time.Unix(ts, 0).Before(time.Now())
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"tiktok-downloader/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// OpenManifest opens a newline-delimited URL list from a local path,
+// a file:// URL or an s3://bucket/key object
+func OpenManifest(ctx context.Context, cfg *config.AppConfig, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "s3://") {
+		return os.Open(strings.TrimPrefix(source, "file://"))
+	}
+
+	bucket, key, err := ParseS3URL(source)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat surfaces missing objects and bad credentials up front
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/metrics"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// mediaCacheStatTimeout bounds each cache lookup made while building a response
+const mediaCacheStatTimeout = 2 * time.Second
+
+// How long lookup results are remembered, so hot posts don't stat S3 on every request
+const (
+	mediaCacheHitTTL  = 5 * time.Minute
+	mediaCacheMissTTL = 30 * time.Second
+)
+
+// MediaCache keeps downloaded media in an S3 bucket and, when CloudFront
+// fronts the bucket, hands out signed CloudFront URLs for cached objects
+type MediaCache struct {
+	client *minio.Client
+	bucket string
+	prefix string
+
+	cdnDomain string
+	signer    *CloudFrontSigner
+	urlTTL    time.Duration
+
+	mu      sync.Mutex
+	lookups map[string]mediaCacheLookup
+}
+
+// mediaCacheLookup is a remembered existence check
+type mediaCacheLookup struct {
+	exists  bool
+	expires time.Time
+}
+
+// NewMediaCache creates the S3 media cache; it returns nil when MEDIA_CACHE_S3_BUCKET is unset
+func NewMediaCache(cfg *config.AppConfig) (*MediaCache, error) {
+	if cfg.MediaCacheBucket == "" {
+		return nil, nil
+	}
+
+	client, err := NewS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.Register("tikdownloader_media_cache_requests_total", "S3 media cache lookups by result.", metrics.Counter)
+	cache := &MediaCache{
+		client:  client,
+		bucket:  cfg.MediaCacheBucket,
+		prefix:  cfg.MediaCachePrefix,
+		urlTTL:  cfg.CloudFrontURLTTL,
+		lookups: make(map[string]mediaCacheLookup),
+	}
+
+	if cfg.CloudFrontDomain != "" {
+		signer, err := NewCloudFrontSigner(cfg.CloudFrontKeyPairID, cfg.CloudFrontPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cache.cdnDomain = strings.TrimRight(cfg.CloudFrontDomain, "/")
+		cache.signer = signer
+	}
+	return cache, nil
+}
+
+// ObjectKey returns the object key of a media item, identified by its post
+// URL, download link key and index within the post
+func (m *MediaCache) ObjectKey(sourceURL, key string, index int) string {
+	sum := sha1.Sum([]byte(sourceURL))
+	name := key
+	if index > 0 {
+		name = fmt.Sprintf("%s_%d", key, index)
+	}
+	return m.prefix + hex.EncodeToString(sum[:8]) + "/" + name
+}
+
+// SignedURL returns a CloudFront signed URL for a cached object, or false
+// when CloudFront isn't configured or the object isn't cached
+func (m *MediaCache) SignedURL(ctx context.Context, objectKey string) (string, bool) {
+	if m == nil || m.signer == nil || !m.exists(ctx, objectKey) {
+		return "", false
+	}
+
+	signed, err := m.signer.SignURL(m.cdnDomain+"/"+objectKey, time.Now().Add(m.urlTTL))
+	if err != nil {
+		log.Printf("Error signing CloudFront URL for %s: %v", objectKey, err)
+		return "", false
+	}
+	return signed, true
+}
+
+// Store uploads a downloaded media file to the cache
+func (m *MediaCache) Store(ctx context.Context, objectKey, path, contentType, filename string) error {
+	if m == nil {
+		return nil
+	}
+	_, err := m.client.FPutObject(ctx, m.bucket, objectKey, path, minio.PutObjectOptions{
+		ContentType:        contentType,
+		ContentDisposition: fmt.Sprintf("attachment; filename*=UTF-8''%s", filename),
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.lookups[objectKey] = mediaCacheLookup{exists: true, expires: time.Now().Add(mediaCacheHitTTL)}
+	m.mu.Unlock()
+	return nil
+}
+
+// StoreInBackground uploads path to the cache and removes it afterwards
+func (m *MediaCache) StoreInBackground(objectKey, path, contentType, filename string) {
+	go func() {
+		defer os.Remove(path)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := m.Store(ctx, objectKey, path, contentType, filename); err != nil {
+			log.Printf("Error caching %s in S3: %v", objectKey, err)
+		}
+	}()
+}
+
+// exists reports whether an object is cached, remembering the answer for a while
+func (m *MediaCache) exists(ctx context.Context, objectKey string) bool {
+	m.mu.Lock()
+	lookup, ok := m.lookups[objectKey]
+	m.mu.Unlock()
+	if ok && time.Now().Before(lookup.expires) {
+		return lookup.exists
+	}
+
+	statCtx, cancel := context.WithTimeout(ctx, mediaCacheStatTimeout)
+	defer cancel()
+	_, err := m.client.StatObject(statCtx, m.bucket, objectKey, minio.StatObjectOptions{})
+	exists := err == nil
+	if err != nil && minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		// Don't remember transient errors
+		log.Printf("Media cache lookup of %s failed: %v", objectKey, err)
+		return false
+	}
+
+	result, ttl := "miss", mediaCacheMissTTL
+	if exists {
+		result, ttl = "hit", mediaCacheHitTTL
+	}
+	metrics.Inc("tikdownloader_media_cache_requests_total", metrics.Labels{"result": result})
+
+	m.mu.Lock()
+	m.lookups[objectKey] = mediaCacheLookup{exists: exists, expires: time.Now().Add(ttl)}
+	m.mu.Unlock()
+	return exists
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/metrics"
+)
+
+// musicPathPattern matches sound pages: tiktok.com/music/<title>-<id>,
+// douyin.com/music/<id> and iesdouyin.com/share/music/<id>
+var musicPathPattern = regexp.MustCompile(`/music/(?:[^/?#]*-)?\d+`)
+
+// IsMusicURL reports whether a TikTok/Douyin URL points to a sound page
+// rather than a post. Share short links can't be told apart until
+// ResolveShareURL has followed them, so they are treated as posts.
+func IsMusicURL(raw string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Host)
+	if !strings.HasSuffix(host, "tiktok.com") && !strings.HasSuffix(host, "douyin.com") {
+		return false
+	}
+	return musicPathPattern.MatchString(parsed.Path)
+}
+
+// MusicEndpoint returns the hybrid API music endpoint next to a video_data endpoint
+func MusicEndpoint(videoEndpoint string) string {
+	return strings.TrimSuffix(videoEndpoint, "/video_data") + "/music_data"
+}
+
+// FetchMusicData fetches a sound's metadata and one page of the posts using
+// it from the hybrid API backends, serving repeated requests from the cache.
+// Fallback instances are not tried, since public ones may not serve music_data.
+func FetchMusicData(ctx context.Context, cfg *config.AppConfig, sourceURL string, cursor int64, count int) (map[string]interface{}, error) {
+	fetch := func(endpoint string) (map[string]interface{}, error) {
+		return fetchMusicData(ctx, MusicEndpoint(endpoint), sourceURL, cursor, count)
+	}
+	if hybridCache == nil {
+		return fetchFromBackends(ctx, false, fetch)
+	}
+
+	key := fmt.Sprintf("music:%s:cursor=%d:count=%d", CanonicalURL(sourceURL), cursor, count)
+	if raw, ok := hybridCache.Get(ctx, key); ok {
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err == nil {
+			metrics.Inc("tikdownloader_hybrid_cache_requests_total", metrics.Labels{"result": "hit"})
+			return data, nil
+		}
+	}
+	metrics.Inc("tikdownloader_hybrid_cache_requests_total", metrics.Labels{"result": "miss"})
+
+	data, err := fetchFromBackends(ctx, false, fetch)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := json.Marshal(data); err == nil {
+		hybridCache.Set(ctx, key, raw, hybridCacheTTL)
+	}
+	return data, nil
+}
+
+// fetchMusicData requests one page of a sound from a hybrid API music endpoint
+func fetchMusicData(ctx context.Context, endpoint, sourceURL string, cursor int64, count int) (map[string]interface{}, error) {
+	apiURL := fmt.Sprintf("%s?url=%s&cursor=%d&count=%d", endpoint, url.QueryEscape(sourceURL), cursor, count)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch music data: %v", err)
+	}
+	if err := hybridPacer.Wait(ctx, req.URL.Host); err != nil {
+		return nil, fmt.Errorf("Failed to fetch music data: %v", err)
+	}
+
+	httpClient := &http.Client{Timeout: hybridTimeout, Transport: upstreamTransport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamDownError{fmt.Errorf("Failed to fetch music data: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, upstreamDownError{fmt.Errorf("External API returned error: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if unavailable := classifyErrorBody(body); unavailable != nil {
+			return nil, unavailable
+		}
+		return nil, fmt.Errorf("External API returned error: %d", resp.StatusCode)
+	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+	return data, nil
+}
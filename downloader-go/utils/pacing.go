@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"tiktok-downloader/metrics"
+)
+
+// maxPacedHosts bounds the per-host schedule before stale entries are pruned
+const maxPacedHosts = 1024
+
+// Pacer spaces out requests to each upstream host by a minimum interval plus
+// random jitter, so bulk jobs don't trip upstream rate limits
+type Pacer struct {
+	interval time.Duration
+	jitter   time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// hybridPacer paces requests to the hybrid API; nil disables pacing
+var hybridPacer *Pacer
+
+// NewPacer creates a pacer, or returns nil when interval and jitter are both zero
+func NewPacer(interval, jitter time.Duration) *Pacer {
+	if interval <= 0 && jitter <= 0 {
+		return nil
+	}
+	metrics.Register("tikdownloader_upstream_pacing_wait_seconds_total", "Time requests spent waiting for their upstream pacing slot.", metrics.Counter)
+	return &Pacer{interval: interval, jitter: jitter, next: make(map[string]time.Time)}
+}
+
+// SetHybridPacer paces hybrid API requests with p
+func SetHybridPacer(p *Pacer) {
+	hybridPacer = p
+}
+
+// Wait blocks until the host's next request slot or until ctx is done
+func (p *Pacer) Wait(ctx context.Context, host string) error {
+	if p == nil {
+		return nil
+	}
+
+	delay := p.reserve(host)
+	if delay <= 0 {
+		return nil
+	}
+	metrics.Add("tikdownloader_upstream_pacing_wait_seconds_total", metrics.Labels{"host": host}, delay.Seconds())
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve claims the host's next slot and returns how long to wait for it
+func (p *Pacer) reserve(host string) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if len(p.next) > maxPacedHosts {
+		for h, t := range p.next {
+			if t.Before(now) {
+				delete(p.next, h)
+			}
+		}
+	}
+
+	slot := p.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	gap := p.interval
+	if p.jitter > 0 {
+		gap += time.Duration(rand.Int63n(int64(p.jitter)))
+	}
+	p.next[host] = slot.Add(gap)
+	return slot.Sub(now)
+}
+
+// Before waits for the request's host slot; it implements SourceHook
+func (p *Pacer) Before(req *http.Request) {
+	p.Wait(req.Context(), req.URL.Host)
+}
+
+// After implements SourceHook
+func (p *Pacer) After(req *http.Request, resp *http.Response) {}
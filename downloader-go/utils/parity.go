@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// parityVolatileKeys hold encrypted, time-stamped links whose values always
+// differ between requests; only their presence and type are compared
+var parityVolatileKeys = map[string]bool{
+	"download_link":           true,
+	"download_slideshow_link": true,
+}
+
+// DiffParity lists the differences between decoded /tiktok responses of
+// this variant (a) and the fiber variant (b), both in the Node.js schema
+func DiffParity(a, b interface{}) []string {
+	return diffParity("", a, b)
+}
+
+// diffParity lists the differences between two decoded JSON values
+func diffParity(path string, a, b interface{}) []string {
+	name := path
+	if name == "" {
+		name = "response"
+	}
+
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make(map[string]bool)
+		for key := range aMap {
+			keys[key] = true
+		}
+		for key := range bMap {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, key := range sorted {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			aVal, inA := aMap[key]
+			bVal, inB := bMap[key]
+			switch {
+			case !inB:
+				diffs = append(diffs, fmt.Sprintf("%s: only in go", child))
+			case !inA:
+				diffs = append(diffs, fmt.Sprintf("%s: only in fiber", child))
+			case parityVolatileKeys[key]:
+				diffs = append(diffs, diffParityShape(child, aVal, bVal)...)
+			default:
+				diffs = append(diffs, diffParity(child, aVal, bVal)...)
+			}
+		}
+		return diffs
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		return []string{fmt.Sprintf("%s: go=%v fiber=%v", name, a, b)}
+	}
+	return nil
+}
+
+// diffParityShape compares the keys and types of two values, ignoring scalar values
+func diffParityShape(path string, a, b interface{}) []string {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		var diffs []string
+		for key, aVal := range aMap {
+			if bVal, ok := bMap[key]; !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: only in go", path, key))
+			} else {
+				diffs = append(diffs, diffParityShape(path+"."+key, aVal, bVal)...)
+			}
+		}
+		for key := range bMap {
+			if _, ok := aMap[key]; !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: only in fiber", path, key))
+			}
+		}
+		sort.Strings(diffs)
+		return diffs
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList && len(aList) != len(bList) {
+		return []string{fmt.Sprintf("%s: go has %d entries, fiber has %d", path, len(aList), len(bList))}
+	}
+
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return []string{fmt.Sprintf("%s: go is %T, fiber is %T", path, a, b)}
+	}
+	return nil
+}
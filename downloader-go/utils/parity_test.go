@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffParity(t *testing.T) {
+	tests := []struct {
+		name      string
+		goBody    string
+		fiberBody string
+		want      []string
+	}{
+		{
+			name:      "identical",
+			goBody:    `{"status":"tunnel","title":"a","statistics":{"play_count":3}}`,
+			fiberBody: `{"status":"tunnel","title":"a","statistics":{"play_count":3}}`,
+			want:      nil,
+		},
+		{
+			name:      "scalar differs",
+			goBody:    `{"title":"a"}`,
+			fiberBody: `{"title":"b"}`,
+			want:      []string{"title: go=a fiber=b"},
+		},
+		{
+			name:      "nested value differs",
+			goBody:    `{"statistics":{"play_count":3,"digg_count":1}}`,
+			fiberBody: `{"statistics":{"play_count":4,"digg_count":1}}`,
+			want:      []string{"statistics.play_count: go=3 fiber=4"},
+		},
+		{
+			name:      "keys on one side only, sorted",
+			goBody:    `{"cover":"c","title":"a"}`,
+			fiberBody: `{"title":"a","music_duration":0}`,
+			want:      []string{"cover: only in go", "music_duration: only in fiber"},
+		},
+		{
+			name:      "volatile links compare by shape only",
+			goBody:    `{"download_link":{"no_watermark":"https://a/download?data=x","mp3":"https://a/download?data=y"}}`,
+			fiberBody: `{"download_link":{"no_watermark":"https://b/download?data=z","mp3":"https://b/download?data=w"}}`,
+			want:      nil,
+		},
+		{
+			name:      "volatile link missing",
+			goBody:    `{"download_link":{"no_watermark":"x","mp3":"y"}}`,
+			fiberBody: `{"download_link":{"no_watermark":"z"}}`,
+			want:      []string{"download_link.mp3: only in go"},
+		},
+		{
+			name:      "volatile photo lists differ in length",
+			goBody:    `{"download_link":{"no_watermark":["a","b"]}}`,
+			fiberBody: `{"download_link":{"no_watermark":["c"]}}`,
+			want:      []string{"download_link.no_watermark: go has 2 entries, fiber has 1"},
+		},
+		{
+			name:      "volatile value changes type",
+			goBody:    `{"download_slideshow_link":"https://a/download-slideshow?url=x"}`,
+			fiberBody: `{"download_slideshow_link":null}`,
+			want:      []string{"download_slideshow_link: go is string, fiber is <nil>"},
+		},
+		{
+			name:      "lists compare by value",
+			goBody:    `{"photos":[{"url":"a"}]}`,
+			fiberBody: `{"photos":[{"url":"b"}]}`,
+			want:      []string{"photos: go=[map[url:a]] fiber=[map[url:b]]"},
+		},
+		{
+			name:      "top-level type differs",
+			goBody:    `{"status":"tunnel"}`,
+			fiberBody: `null`,
+			want:      []string{"response: go=map[status:tunnel] fiber=<nil>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, b interface{}
+			if err := json.Unmarshal([]byte(tt.goBody), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.fiberBody), &b); err != nil {
+				t.Fatal(err)
+			}
+			if got := DiffParity(a, b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffParity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
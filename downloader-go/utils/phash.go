@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"image"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"math/bits"
+	"os"
+
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+// DefaultDedupeThreshold is the maximum Hamming distance between two
+// difference hashes for the images to be considered near-identical
+const DefaultDedupeThreshold = 5
+
+// DifferenceHash computes a 64-bit dHash of an image: the image is reduced to
+// a 9x8 grayscale grid and each bit records whether a cell is brighter than
+// its right-hand neighbour
+func DifferenceHash(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return 0, image.ErrFormat
+	}
+
+	// Average the luminance of each grid cell
+	var grid [8][9]float64
+	for y := 0; y < 8; y++ {
+		y0 := bounds.Min.Y + y*height/8
+		y1 := bounds.Min.Y + (y+1)*height/8
+		for x := 0; x < 9; x++ {
+			x0 := bounds.Min.X + x*width/9
+			x1 := bounds.Min.X + (x+1)*width/9
+			grid[y][x] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// averageLuminance samples a rectangle of the image on a coarse grid
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	stepX := (x1-x0)/8 + 1
+	stepY := (y1-y0)/8 + 1
+	var sum float64
+	var count int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+	return sum / float64(count)
+}
+
+// DedupeImages drops images that are near-identical to an earlier image and
+// returns the kept paths along with the indices of the removed ones. Images
+// that cannot be decoded are always kept.
+func DedupeImages(paths []string, threshold int) ([]string, []int) {
+	kept := make([]string, 0, len(paths))
+	removed := []int{}
+	var keptHashes []uint64
+
+	for i, path := range paths {
+		hash, err := DifferenceHash(path)
+		if err != nil {
+			kept = append(kept, path)
+			continue
+		}
+
+		duplicate := false
+		for _, other := range keptHashes {
+			if bits.OnesCount64(hash^other) <= threshold {
+				duplicate = true
+				break
+			}
+		}
+
+		if duplicate {
+			removed = append(removed, i)
+			continue
+		}
+		keptHashes = append(keptHashes, hash)
+		kept = append(kept, path)
+	}
+
+	return kept, removed
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// awemeIDPattern matches post IDs, which are all digits
+var awemeIDPattern = regexp.MustCompile(`^\d{6,25}$`)
+
+// PostURLForID builds the canonical URL of a post from its aweme ID, for
+// clients that store IDs rather than links. platform is "tiktok" (the
+// default when empty) or "douyin".
+func PostURLForID(platform, awemeID string) (string, error) {
+	awemeID = strings.TrimSpace(awemeID)
+	if !awemeIDPattern.MatchString(awemeID) {
+		return "", fmt.Errorf("aweme_id must be a numeric post ID")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(platform)) {
+	case "", PlatformTikTok:
+		// TikTok redirects a video path with an empty handle to the post,
+		// photo posts included
+		return "https://www.tiktok.com/@/video/" + awemeID, nil
+	case PlatformDouyin:
+		return "https://www.douyin.com/video/" + awemeID, nil
+	default:
+		return "", fmt.Errorf("platform must be %q or %q", PlatformTikTok, PlatformDouyin)
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// upstreamProxy picks the proxy for an outbound request, or nil to connect
+// directly. It is set once at startup by SetUpstreamProxy.
+var upstreamProxy = func(*url.URL) (*url.URL, error) { return nil, nil }
+
+// SetUpstreamProxy routes hybrid API requests and CDN fetches through an
+// HTTP, HTTPS or SOCKS5 proxy. Loopback hosts and the hosts in bypass
+// (NO_PROXY syntax) connect directly. An empty rawURL disables the proxy.
+func SetUpstreamProxy(rawURL string, bypass []string) error {
+	if rawURL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return fmt.Errorf("proxy URL %q has no host", proxyURL.Redacted())
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  rawURL,
+		HTTPSProxy: rawURL,
+		NoProxy:    strings.Join(bypass, ","),
+	}).ProxyFunc()
+	upstreamProxy = proxyFunc
+	return nil
+}
+
+// proxyForRequest is the Proxy func of upstream transports
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	return upstreamProxy(req.URL)
+}
+
+// upstreamTransport is the default transport with the upstream proxy applied
+var upstreamTransport = NewUpstreamTransport()
+
+// NewUpstreamTransport returns a copy of the default transport that uses the
+// upstream proxy; callers may override its Proxy
+func NewUpstreamTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyForRequest
+	return transport
+}
+
+// dialUpstream opens a TCP connection to addr, tunnelling through the
+// upstream proxy when one applies. Transports with a custom TLS dialer use it,
+// since net/http only honours Proxy for connections it dials itself.
+func dialUpstream(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	proxyURL, err := upstreamProxy(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	default:
+		return dialConnect(ctx, dialer, proxyURL, addr)
+	}
+}
+
+// dialConnect opens a tunnel to addr through an HTTP(S) proxy with CONNECT
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// Abort the handshake if the context ends while waiting on the proxy
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The proxy sends nothing past its reply until the client speaks, so the
+	// buffered reader holds no tunnelled bytes
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s", addr, resp.Status)
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	return conn, nil
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"tiktok-downloader/metrics"
+)
+
+// RetryPolicy controls how source downloads are retried: up to MaxAttempts
+// tries in total, waiting an exponentially growing, jittered delay starting
+// at BaseDelay and capped at MaxDelay between them
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// sourceRetry is the policy applied to CDN fetches
+var sourceRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+func init() {
+	metrics.Register("tikdownloader_source_retries_total", "Source downloads retried after a transient failure, by cause.", metrics.Counter)
+}
+
+// SetSourceRetry sets the retry policy of source downloads; one attempt
+// disables retries
+func SetSourceRetry(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	sourceRetry = policy
+}
+
+// RetryableStatus reports whether a source status is worth retrying: CDN
+// URLs intermittently answer 403, and 408, 429 and 5xx are transient
+func RetryableStatus(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusRequestTimeout ||
+		code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// sourceStatusError is a source response with an unexpected status
+type sourceStatusError struct {
+	code int
+}
+
+func (e sourceStatusError) Error() string {
+	return fmt.Sprintf("failed to download file: %d", e.code)
+}
+
+// retryableError reports whether a failed attempt is worth retrying, and the
+// cause recorded in metrics. Cancellation of the caller's context never is.
+func retryableError(ctx context.Context, err error) (string, bool) {
+	if ctx.Err() != nil {
+		return "", false
+	}
+	var statusErr sourceStatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("status_%d", statusErr.code), RetryableStatus(statusErr.code)
+	}
+	// Timeouts, resets and bodies truncated mid-transfer; local errors such
+	// as a failed file create are not retried
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout", true
+		}
+		return "network", true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return "network", true
+	}
+	return "", false
+}
+
+// retryDelay returns the jittered backoff before retry number attempt (1-based)
+func (p RetryPolicy) retryDelay(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Spread retries of concurrent downloads over the upper half of the window
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retrySource runs attempt until it succeeds, fails permanently or the source
+// retry policy is exhausted, backing off between tries. attempt is told when
+// it is the last try.
+func retrySource(ctx context.Context, sourceURL string, attempt func(last bool) error) error {
+	policy := sourceRetry
+	var err error
+	for try := 1; ; try++ {
+		if err = attempt(try >= policy.MaxAttempts); err == nil {
+			return nil
+		}
+		cause, retry := retryableError(ctx, err)
+		if !retry || try >= policy.MaxAttempts {
+			return err
+		}
+
+		metrics.Inc("tikdownloader_source_retries_total", metrics.Labels{"cause": cause})
+		delay := policy.retryDelay(try)
+		log.Printf("Retrying %s in %s after attempt %d failed: %v", sourceURL, delay, try, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// DoSourceRequest sends a bodiless source request, retrying transient
+// failures and retryable statuses per the source retry policy. Once retries
+// are exhausted the last response is returned whatever its status, so callers
+// can still fall back on it (e.g. the region retry on 403).
+func DoSourceRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	err := retrySource(req.Context(), req.URL.String(), func(last bool) error {
+		var err error
+		resp, err = client.Do(req.Clone(req.Context()))
+		if err != nil {
+			return err
+		}
+		if !last && RetryableStatus(resp.StatusCode) {
+			resp.Body.Close()
+			return sourceStatusError{resp.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
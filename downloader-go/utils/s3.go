@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"tiktok-downloader/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// NewS3Client creates an S3/MinIO client from the S3_* settings
+func NewS3Client(cfg *config.AppConfig) (*minio.Client, error) {
+	if cfg.S3Endpoint == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT is not configured")
+	}
+
+	creds := credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, "")
+	if cfg.S3AccessKey == "" {
+		creds = credentials.NewEnvAWS()
+	}
+	return minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+}
+
+// ParseS3URL splits an s3://bucket/key URL into bucket and key
+func ParseS3URL(raw string) (string, string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "s3" {
+		return "", "", fmt.Errorf("invalid S3 URL %q", raw)
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Host == "" || key == "" {
+		return "", "", fmt.Errorf("S3 URL %q must name a bucket and key", raw)
+	}
+	return parsed.Host, key, nil
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Scene is a continuous shot of a video, in seconds from its start
+type Scene struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// minSceneSeconds merges cuts closer together than this into one scene, so
+// flashes and fast transitions don't produce slivers
+const minSceneSeconds = 0.5
+
+// showinfoPTSPattern reads the timestamp of the frames showinfo logs
+var showinfoPTSPattern = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// DetectScenes splits a video into scenes with FFmpeg's scene change score:
+// a new scene starts at every frame scoring above threshold (0 to 1, lower
+// finds more cuts)
+func DetectScenes(ctx context.Context, videoPath string, threshold float64) ([]Scene, error) {
+	_, _, duration, err := probeVideo(ctx, videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-i", videoPath,
+		"-an",
+		"-filter:v", fmt.Sprintf("select='gt(scene,%s)',showinfo", strconv.FormatFloat(threshold, 'f', -1, 64)),
+		"-f", "null", "-",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("FFmpeg error: %v - %s", err, string(output))
+	}
+
+	var cuts []float64
+	for _, match := range showinfoPTSPattern.FindAllSubmatch(output, -1) {
+		if t, err := strconv.ParseFloat(string(match[1]), 64); err == nil {
+			cuts = append(cuts, t)
+		}
+	}
+	return scenesFromCuts(cuts, duration), nil
+}
+
+// scenesFromCuts turns scene change timestamps into the scenes between them
+func scenesFromCuts(cuts []float64, duration float64) []Scene {
+	sort.Float64s(cuts)
+	scenes := []Scene{}
+	start := 0.0
+	for _, cut := range cuts {
+		if cut-start < minSceneSeconds || duration-cut < minSceneSeconds {
+			continue
+		}
+		scenes = append(scenes, Scene{Index: len(scenes), Start: start, End: cut})
+		start = cut
+	}
+	return append(scenes, Scene{Index: len(scenes), Start: start, End: duration})
+}
+
+// CutScene re-encodes one scene of a video into its own MP4, so the clip
+// starts exactly at the cut instead of the previous keyframe
+func CutScene(ctx context.Context, videoPath, outputPath string, scene Scene) error {
+	return runFFmpeg(ctx, []string{"-y",
+		"-ss", formatSeconds(scene.Start),
+		"-i", videoPath,
+		"-t", formatSeconds(scene.End - scene.Start),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-movflags", "+faststart",
+		outputPath,
+	})
+}
+
+// WriteSceneArchive writes a ZIP of the cut clips, named by their scene
+// index, and a scenes.json listing their timestamps
+func WriteSceneArchive(zipPath string, scenes []Scene, clipPaths []string) error {
+	file, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	index, err := archive.Create("scenes.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(index).Encode(scenes); err != nil {
+		return err
+	}
+
+	for _, clipPath := range clipPaths {
+		// Clips are already compressed, so they are stored as-is
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: filepath.Base(clipPath), Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		clip, err := os.Open(clipPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, clip)
+		clip.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// schemaMaxDepth bounds how deep payloads are walked for rename candidates
+const schemaMaxDepth = 8
+
+// schemaCommonFields are the fields the response mapping reads from both the
+// minimal and the full hybrid API payloads
+var schemaCommonFields = []string{
+	"data.aweme_id",
+	"data.desc",
+	"data.author.nickname",
+	"data.author.uid",
+	"data.author.avatar_thumb.url_list",
+	"data.statistics.digg_count",
+	"data.statistics.comment_count",
+	"data.statistics.play_count",
+	"data.statistics.repost_count",
+	"data.music.title",
+	"data.music.author",
+	"data.music.duration",
+	"data.music.play_url.url_list",
+}
+
+// schemaModeFields are the fields read from one payload mode and post type:
+// the minimal payload's video_data and image_data, and the video object the
+// full payload fallback derives video_data from
+var schemaModeFields = map[string][]string{
+	"minimal/video": {
+		"data.type",
+		"data.duration",
+		"data.cover_data.cover.url_list",
+		"data.video_data.nwm_video_url",
+		"data.video_data.nwm_video_url_HQ",
+		"data.video_data.wm_video_url",
+		"data.video_data.wm_video_url_HQ",
+	},
+	"minimal/image": {
+		"data.type",
+		"data.cover_data.cover.url_list",
+		"data.image_data.no_watermark_image_list",
+	},
+	"full/video": {
+		"data.video.play_addr.url_list",
+		"data.video.play_addr.data_size",
+		"data.video.download_addr.url_list",
+		"data.video.bit_rate.play_addr.url_list",
+	},
+	"full/image": {},
+}
+
+// schemaGenericKeys are expected keys found under many parents, which only
+// point at a rename under a parent of the same name
+var schemaGenericKeys = map[string]bool{
+	"url_list": true,
+	"title":    true,
+	"author":   true,
+	"duration": true,
+}
+
+// SchemaField is an expected payload field that was not found, with the
+// paths of similarly named fields it may have been renamed to
+type SchemaField struct {
+	Field      string   `json:"field"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// SchemaDiff reports which expected fields a hybrid API payload carries
+type SchemaDiff struct {
+	Present []string      `json:"present"`
+	Missing []SchemaField `json:"missing"`
+}
+
+// ExpectedSchemaFields returns the fields the response mapping reads from a
+// payload of the given mode for a post type ("video" or "image")
+func ExpectedSchemaFields(minimal bool, postType string) []string {
+	mode := "full/"
+	if minimal {
+		mode = "minimal/"
+	}
+	if postType != "image" {
+		postType = "video"
+	}
+	return append(append([]string{}, schemaCommonFields...), schemaModeFields[mode+postType]...)
+}
+
+// DiffSchema checks a payload for the expected fields. Missing fields are
+// matched against every field of the payload named alike (ignoring case and
+// underscores) or by a known counter alias, to point at renames.
+func DiffSchema(payload map[string]interface{}, expected []string) SchemaDiff {
+	diff := SchemaDiff{Present: []string{}, Missing: []SchemaField{}}
+	var paths []string
+	for _, field := range expected {
+		if schemaValuePresent(payload, strings.Split(field, ".")) {
+			diff.Present = append(diff.Present, field)
+			continue
+		}
+		if paths == nil {
+			paths = schemaPaths(payload, "", 0)
+			sort.Strings(paths)
+		}
+		diff.Missing = append(diff.Missing, SchemaField{Field: field, Candidates: schemaCandidates(field, paths)})
+	}
+	return diff
+}
+
+// schemaValuePresent reports whether a path leads to a non-empty value;
+// lists along the way are followed through their first element
+func schemaValuePresent(value interface{}, keys []string) bool {
+	for _, key := range keys {
+		if list, ok := value.([]interface{}); ok {
+			if len(list) == 0 {
+				return false
+			}
+			value = list[0]
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		value = fields[key]
+	}
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// schemaPaths lists the dotted paths of every field of a payload, lists
+// being walked through their first element like schemaValuePresent does
+func schemaPaths(value interface{}, prefix string, depth int) []string {
+	if depth > schemaMaxDepth {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		if len(list) == 0 {
+			return nil
+		}
+		value = list[0]
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var paths []string
+	for key, child := range fields {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		paths = append(paths, path)
+		paths = append(paths, schemaPaths(child, path, depth+1)...)
+	}
+	return paths
+}
+
+// schemaCandidates returns the payload paths whose last key names the same
+// thing as the expected field's; keys as common as url_list must also sit
+// under a parent of the same name
+func schemaCandidates(field string, paths []string) []string {
+	parent, leaf := schemaSplit(field)
+	names := map[string]bool{schemaKeyName(leaf): true}
+	for _, alias := range statAliases[leaf] {
+		names[schemaKeyName(alias)] = true
+	}
+
+	var candidates []string
+	for _, path := range paths {
+		pathParent, pathLeaf := schemaSplit(path)
+		if path == field || !names[schemaKeyName(pathLeaf)] {
+			continue
+		}
+		if schemaGenericKeys[leaf] && schemaKeyName(pathParent) != schemaKeyName(parent) {
+			continue
+		}
+		candidates = append(candidates, path)
+	}
+	return candidates
+}
+
+// schemaSplit returns the last two keys of a dotted path
+func schemaSplit(path string) (string, string) {
+	keys := strings.Split(path, ".")
+	if len(keys) < 2 {
+		return "", keys[0]
+	}
+	return keys[len(keys)-2], keys[len(keys)-1]
+}
+
+// schemaKeyName folds a key's case and underscores, so nwm_video_url and
+// nwmVideoUrl compare equal
+func schemaKeyName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tiktok-downloader/logging"
+	"tiktok-downloader/metrics"
+)
+
+// shareURLTimeout bounds resolving a share short link, all redirects included
+const shareURLTimeout = 10 * time.Second
+
+// shareURLCacheTTL is how long a resolved short link is cached; the codes
+// are permanent, so this only bounds the cache
+const shareURLCacheTTL = 24 * time.Hour
+
+// shareURLMaxHops bounds the redirects followed from a short link
+const shareURLMaxHops = 5
+
+// shareURLUserAgent is sent when resolving short links, which redirect
+// clients that don't look like a browser to the app store instead of the post
+const shareURLUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+
+// supportedHostSuffixes lists the domains of post URLs accepted by the API
+var supportedHostSuffixes = []string{"tiktok.com", "douyin.com", "iesdouyin.com"}
+
+// shareHosts lists the hosts of share short links
+var shareHosts = map[string]bool{
+	"vm.tiktok.com":  true,
+	"vt.tiktok.com":  true,
+	"v.douyin.com":   true,
+	"v.kuaishou.com": true,
+}
+
+func init() {
+	metrics.Register("tikdownloader_share_url_resolutions_total", "Share short link resolutions by result.", metrics.Counter)
+}
+
+// withScheme defaults a post URL to https when the scheme was left out, as
+// in links pasted from share sheets
+func withScheme(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		return "https://" + raw
+	}
+	return raw
+}
+
+// parsePostURL parses a post URL that may lack its scheme
+func parsePostURL(raw string) (*url.URL, error) {
+	return url.Parse(withScheme(raw))
+}
+
+// IsSupportedURL reports whether a URL points to TikTok or Douyin, share
+// short links (vm.tiktok.com, vt.tiktok.com, v.douyin.com) included, or to
+// a post of a platform with a registered extractor
+func IsSupportedURL(raw string) bool {
+	if ExtractorFor(raw) != nil {
+		return true
+	}
+	parsed, err := parsePostURL(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, suffix := range supportedHostSuffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShareURL reports whether a URL is a share short link, including the
+// www.tiktok.com/t/<code> and www.kuaishou.com/f/<code> forms
+func IsShareURL(raw string) bool {
+	parsed, err := parsePostURL(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if shareHosts[host] {
+		return true
+	}
+	switch host {
+	case "tiktok.com", "www.tiktok.com":
+		return strings.HasPrefix(parsed.Path, "/t/")
+	case "kuaishou.com", "www.kuaishou.com":
+		return strings.HasPrefix(parsed.Path, "/f/")
+	}
+	return false
+}
+
+// ResolveShareURL follows the redirects of a share short link to the post
+// URL it stands for, so the hybrid API, extractors and the response cache
+// see the canonical post. Other URLs, and links that fail to resolve, are returned
+// unchanged; the hybrid API then resolves them itself.
+func ResolveShareURL(ctx context.Context, raw string) string {
+	if !IsShareURL(raw) {
+		return raw
+	}
+
+	key := "share:" + CanonicalURL(withScheme(raw))
+	if hybridCache != nil {
+		if cached, ok := hybridCache.Get(ctx, key); ok {
+			metrics.Inc("tikdownloader_share_url_resolutions_total", metrics.Labels{"result": "hit"})
+			return string(cached)
+		}
+	}
+
+	resolved, err := followShareRedirects(ctx, raw)
+	if err != nil {
+		metrics.Inc("tikdownloader_share_url_resolutions_total", metrics.Labels{"result": "error"})
+		logging.Warnf("Resolving share link %s failed, passing it on as is: %v", logging.RedactURL(raw), err)
+		return raw
+	}
+	metrics.Inc("tikdownloader_share_url_resolutions_total", metrics.Labels{"result": "resolved"})
+	logging.Debugf("Share link %s resolved to %s", logging.RedactURL(raw), logging.RedactURL(resolved))
+
+	if hybridCache != nil {
+		hybridCache.Set(ctx, key, []byte(resolved), shareURLCacheTTL)
+	}
+	return resolved
+}
+
+// followShareRedirects follows redirects one at a time until they leave the
+// short link hosts, without downloading the post page they end at
+func followShareRedirects(ctx context.Context, raw string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, shareURLTimeout)
+	defer cancel()
+
+	current, err := parsePostURL(raw)
+	if err != nil {
+		return "", err
+	}
+	httpClient := &http.Client{
+		Transport: upstreamTransport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for hop := 0; hop < shareURLMaxHops; hop++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", shareURLUserAgent)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return "", fmt.Errorf("share link answered %d without a redirect", resp.StatusCode)
+		}
+		next, err := current.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect %q: %v", location, err)
+		}
+		if !IsShareURL(next.String()) {
+			if !IsSupportedURL(next.String()) {
+				return "", fmt.Errorf("share link redirected off the supported platforms to %s", next.Host)
+			}
+			return next.String(), nil
+		}
+		current = next
+	}
+	return "", fmt.Errorf("share link redirected more than %d times", shareURLMaxHops)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"tiktok-downloader/cache"
+	"tiktok-downloader/models"
+)
+
+// shortLinkKeyPrefix namespaces short link codes in the store
+const shortLinkKeyPrefix = "shortlink:"
+
+// shortCodePattern matches the codes StoreShortLink issues: 8 random bytes
+// in unpadded base64url
+var shortCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// ErrShortLinkNotFound is returned for unknown and expired short link codes
+var ErrShortLinkNotFound = errors.New("Link not found or expired.")
+
+// shortLinkStore holds the download data behind short links; set at startup
+var shortLinkStore cache.Cache
+
+// SetShortLinkStore sets where the download data of short links is kept
+func SetShortLinkStore(store cache.Cache) {
+	shortLinkStore = store
+}
+
+// StoreShortLink keeps download data in the store for ttlInSeconds and
+// returns the code of its /d/:code link
+func StoreShortLink(ctx context.Context, data models.DownloadData, ttlInSeconds int) (string, error) {
+	if shortLinkStore == nil {
+		return "", errors.New("short link store not configured")
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+	shortLinkStore.Set(ctx, shortLinkKeyPrefix+code, value, time.Duration(ttlInSeconds)*time.Second)
+	return code, nil
+}
+
+// ResolveShortLink returns the download data stored under a short link code
+func ResolveShortLink(ctx context.Context, code string) (models.DownloadData, error) {
+	var data models.DownloadData
+	if shortLinkStore == nil || !shortCodePattern.MatchString(code) {
+		return data, ErrShortLinkNotFound
+	}
+	value, ok := shortLinkStore.Get(ctx, shortLinkKeyPrefix+code)
+	if !ok {
+		return data, ErrShortLinkNotFound
+	}
+	if err := json.Unmarshal(value, &data); err != nil {
+		return data, ErrShortLinkNotFound
+	}
+	return data, nil
+}
@@ -2,73 +2,550 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// X264Presets lists the libx264 presets from fastest to slowest
+var X264Presets = []string{
+	"ultrafast", "superfast", "veryfast", "faster", "fast",
+	"medium", "slow", "slower", "veryslow",
+}
+
+// SlideshowOptions controls how a slideshow is encoded
+type SlideshowOptions struct {
+	Preset            string  // libx264 preset
+	CRF               int     // constant rate factor, lower is better quality
+	MaxBitrateKbps    int     // optional VBV cap on the video bitrate, 0 for none
+	MaxSizeBytes      int64   // optional output size limit, 0 for none
+	TargetBitrateKbps int     // when set, encode two-pass towards this bitrate instead of CRF
+	FPS               int     // output frame rate, 0 for the FFmpeg default of 25
+	PixelFormat       string  // output pixel format, empty for yuv420p
+	ImageSeconds      float64 // how long each image is shown, 0 for the server default
+	Transition        string  // xfade transition between images, empty for hard cuts
+	Resolution        int     // short side of the output frame, 0 for 1080
+	Watermark         SlideshowWatermark
+}
+
+// SlideshowWatermark is a branding overlay composited onto every frame of a
+// slideshow: a PNG in the bottom right corner or a text, such as the author's
+// handle, in the bottom left. Both empty means no overlay.
+type SlideshowWatermark struct {
+	ImagePath string
+	TextPath  string // file holding the text, so it needs no escaping
+}
+
+// enabled reports whether the watermark draws anything
+func (w SlideshowWatermark) enabled() bool {
+	return w.ImagePath != "" || w.TextPath != ""
+}
+
+// SlideshowQuality is an output preset for slideshows: a resolution with the
+// CRF and bitrate cap that suit it
+type SlideshowQuality struct {
+	Name           string
+	Resolution     int
+	CRF            int
+	MaxBitrateKbps int
+}
+
+// SlideshowQualities lists the output presets, smallest first
+var SlideshowQualities = []SlideshowQuality{
+	{Name: "480p", Resolution: 480, CRF: 28, MaxBitrateKbps: 1200},
+	{Name: "720p", Resolution: 720, CRF: 25, MaxBitrateKbps: 2500},
+	{Name: "1080p", Resolution: 1080, CRF: 23, MaxBitrateKbps: 5000},
+	{Name: "1080p_hq", Resolution: 1080, CRF: 18, MaxBitrateKbps: 10000},
+}
+
+// FindSlideshowQuality returns the output preset with the given name
+func FindSlideshowQuality(name string) (SlideshowQuality, bool) {
+	for _, q := range SlideshowQualities {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return SlideshowQuality{}, false
+}
+
+// scaleFrame scales a frame size given for 1080p to the output resolution,
+// keeping both sides even as yuv420p requires
+func (o SlideshowOptions) scaleFrame(width, height int) (int, int) {
+	if o.Resolution <= 0 || o.Resolution == 1080 {
+		return width, height
+	}
+	scale := float64(o.Resolution) / 1080
+	even := func(v float64) int { return int(math.Round(v/2)) * 2 }
+	return even(float64(width) * scale), even(float64(height) * scale)
+}
+
+// SlideshowTransitions lists the FFmpeg xfade transitions accepted for slideshows
+var SlideshowTransitions = []string{"fade", "slideleft", "circleopen"}
+
+// ValidTransition reports whether transition is one of SlideshowTransitions
+func ValidTransition(transition string) bool {
+	for _, t := range SlideshowTransitions {
+		if t == transition {
+			return true
+		}
+	}
+	return false
+}
+
+// slideshowTransitionSeconds is how long a transition between two images takes
+const slideshowTransitionSeconds = 0.5
+
+// SlideshowPixelFormats lists the pixel formats accepted for slideshows;
+// yuv420p is the only one every player supports
+var SlideshowPixelFormats = []string{"yuv420p", "yuvj420p", "yuv422p", "yuv444p", "yuv420p10le"}
+
+// ValidPixelFormat reports whether format is one of SlideshowPixelFormats
+func ValidPixelFormat(format string) bool {
+	for _, f := range SlideshowPixelFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// PresetIndex returns the position of a preset in X264Presets, or -1 if unknown
+func PresetIndex(preset string) int {
+	for i, p := range X264Presets {
+		if p == preset {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseBitrate parses bitrates such as "2500k", "4M" or "3000" into kbps
+func ParseBitrate(value string) (int, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "m"):
+		multiplier = 1000
+		value = strings.TrimSuffix(value, "m")
+	case strings.HasSuffix(value, "k"):
+		value = strings.TrimSuffix(value, "k")
+	}
+
+	kbps, err := strconv.Atoi(value)
+	if err != nil || kbps <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", value)
+	}
+	return kbps * multiplier, nil
+}
+
+// slideshowImageSeconds is how long each image is shown in a slideshow
+var slideshowImageSeconds = 3
+
+// SetSlideshowImageSeconds sets how long each image is shown in a slideshow
+func SetSlideshowImageSeconds(seconds int) {
+	if seconds > 0 {
+		slideshowImageSeconds = seconds
+	}
+}
+
+// imageSeconds returns how long each image of the slideshow is shown
+func (o SlideshowOptions) imageSeconds() float64 {
+	if o.ImageSeconds > 0 {
+		return o.ImageSeconds
+	}
+	return float64(slideshowImageSeconds)
+}
+
+// transitionSeconds returns how long each transition takes, at most half an
+// image so every image is shown on its own for a moment, or 0 for hard cuts
+func (o SlideshowOptions) transitionSeconds() float64 {
+	if o.Transition == "" {
+		return 0
+	}
+	return min(slideshowTransitionSeconds, o.imageSeconds()/2)
+}
+
+// slideshowAudioKbps is the AAC bitrate used for slideshow audio
+const slideshowAudioKbps = 192
+
+// SlideshowDuration returns the length in seconds of a slideshow with n images
+func SlideshowDuration(n int, opts SlideshowOptions) float64 {
+	return float64(n) * opts.imageSeconds()
+}
+
+// formatSeconds formats a duration in seconds for FFmpeg options
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
+
+// SizeBudgetKbps returns the video bitrate that keeps a slideshow of the given
+// duration within maxBytes, leaving headroom for audio and container overhead
+func SizeBudgetKbps(maxBytes int64, durationSeconds float64) int {
+	if durationSeconds <= 0 {
+		return 0
+	}
+	totalKbps := float64(maxBytes) * 8 / 1000 / durationSeconds * 0.95
+	return int(totalKbps) - slideshowAudioKbps
+}
+
+// minSizeBudgetKbps is the lowest video bitrate considered watchable at 1080x1920
+const minSizeBudgetKbps = 150
+
+// ErrSizeBudget is returned when a slideshow cannot be encoded within MaxSizeBytes
+var ErrSizeBudget = errors.New("slideshow cannot fit within the requested size")
+
 // CreateSlideshow creates a slideshow from images and audio
-func CreateSlideshow(ctx context.Context, images []string, audioPath, outputPath string) error {
-	// Prepare FFmpeg command
-	args := []string{}
+func CreateSlideshow(ctx context.Context, images []string, audioPath, outputPath string, opts SlideshowOptions) error {
+	if opts.MaxSizeBytes > 0 && opts.TargetBitrateKbps == 0 {
+		return createSlideshowWithinSize(ctx, images, audioPath, outputPath, opts)
+	}
 
-	// Add input images with loop and duration
-	for _, image := range images {
-		args = append(args, "-loop", "1", "-t", "3", "-i", image)
+	inputArgs := slideshowInputArgs(images, audioPath, opts)
+
+	// Constrained two-pass encode towards a target bitrate; hardware
+	// encoders make a single ABR pass instead
+	if opts.TargetBitrateKbps > 0 && hwAccel == "" {
+		passLog := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_pass"
+		pass1 := append(append([]string{"-y"}, inputArgs...), encoderArgs(opts)...)
+		pass1 = append(pass1, "-pass", "1", "-passlogfile", passLog, "-an", "-f", "mp4", os.DevNull)
+		if err := runFFmpeg(ctx, pass1); err != nil {
+			return err
+		}
+
+		pass2 := append(append([]string{"-y"}, inputArgs...), encoderArgs(opts)...)
+		pass2 = append(pass2, "-pass", "2", "-passlogfile", passLog)
+		pass2 = append(pass2, audioArgs(outputPath)...)
+		return runFFmpeg(ctx, pass2)
 	}
 
-	// Add audio with loop
-	args = append(args, "-stream_loop", "-1", "-i", audioPath)
+	args := append(append([]string{"-y"}, inputArgs...), encoderArgs(opts)...)
+	args = append(args, audioArgs(outputPath)...)
+	return runFFmpeg(ctx, args)
+}
+
+// createSlideshowWithinSize first encodes with CRF capped at the size budget
+// and falls back to a constrained two-pass encode if the result is too large
+func createSlideshowWithinSize(ctx context.Context, images []string, audioPath, outputPath string, opts SlideshowOptions) error {
+	budget := SizeBudgetKbps(opts.MaxSizeBytes, SlideshowDuration(len(images), opts))
+	if budget < minSizeBudgetKbps {
+		return fmt.Errorf("%w: %d images need at least %d kbps", ErrSizeBudget, len(images), minSizeBudgetKbps)
+	}
+
+	capped := opts
+	capped.MaxSizeBytes = 0
+	if capped.MaxBitrateKbps == 0 || budget < capped.MaxBitrateKbps {
+		capped.MaxBitrateKbps = budget
+	}
+	if err := CreateSlideshow(ctx, images, audioPath, outputPath, capped); err != nil {
+		return err
+	}
+	if fitsSize(outputPath, opts.MaxSizeBytes) {
+		return nil
+	}
+
+	twoPass := capped
+	twoPass.TargetBitrateKbps = budget * 9 / 10
+	if err := CreateSlideshow(ctx, images, audioPath, outputPath, twoPass); err != nil {
+		return err
+	}
+	if !fitsSize(outputPath, opts.MaxSizeBytes) {
+		return fmt.Errorf("%w: output still exceeds %d bytes", ErrSizeBudget, opts.MaxSizeBytes)
+	}
+	return nil
+}
+
+// fitsSize reports whether the file at path is no larger than maxBytes
+func fitsSize(path string, maxBytes int64) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() <= maxBytes
+}
+
+// slideshowInputArgs builds the inputs, filter graph and stream mapping of a slideshow
+func slideshowInputArgs(images []string, audioPath string, opts SlideshowOptions) []string {
+	args := slideshowInputs(images, audioPath, opts)
 
 	// Build filter complex
 	filterComplex := []string{}
 
 	// Scale and pad each image
+	width, height := opts.scaleFrame(1080, 1920)
+	frames := make([]string, len(images))
 	for i := range images {
-		filterComplex = append(
-			filterComplex,
-			fmt.Sprintf("[%d:v]scale=w=1080:h=1920:force_original_aspect_ratio=decrease,"+
-				"pad=1080:1920:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1[v%d]", i, i),
-		)
+		frames[i] = fmt.Sprintf("[v%d]", i)
+		filterComplex = append(filterComplex, fmt.Sprintf("[%d:v]%s%s%s", i, fitFrameFilter(width, height), transitionInputFilter(opts), frames[i]))
 	}
 
-	// Concatenate all scaled/padded video streams
-	var concatInputs string
-	for i := range images {
-		concatInputs += fmt.Sprintf("[v%d]", i)
-	}
-	filterComplex = append(
-		filterComplex,
-		fmt.Sprintf("%sconcat=n=%d:v=1:a=0[vout]", concatInputs, len(images)),
-	)
+	// Join all scaled/padded video streams; a watermark image is the input
+	// after the audio
+	filterComplex = append(filterComplex, joinVideo(frames, "[vout]", width, height, len(images)+1, opts)...)
 
 	// Calculate the total duration of the video
-	videoDuration := len(images) * 3 // 3 seconds per image
+	videoDuration := SlideshowDuration(len(images), opts)
 
 	// Add audio filter to trim the looping audio to the video duration
 	filterComplex = append(
 		filterComplex,
-		fmt.Sprintf("[%d:a]atrim=0:%d[aout]", len(images), videoDuration),
+		fmt.Sprintf("[%d:a]atrim=0:%s[aout]", len(images), formatSeconds(videoDuration)),
 	)
 
 	// Add filter complex to args
 	args = append(args, "-filter_complex", strings.Join(filterComplex, ";"))
 
-	// Add mapping and output options
-	args = append(args,
-		"-map", "[vout]",
-		"-map", "[aout]",
-		"-pix_fmt", "yuv420p",
-		"-preset", "medium",
-		"-c:v", "libx264",
+	// Add mapping
+	return append(args, "-map", "[vout]", "-map", "[aout]")
+}
+
+// slideshowInputs returns the input arguments of a slideshow: each image
+// looped for its display time at the output frame rate, then the looped audio.
+// With transitions every image but the last runs on for the transition into
+// the next one, keeping the slideshow duration unchanged.
+func slideshowInputs(images []string, audioPath string, opts SlideshowOptions) []string {
+	args := hwAccelGlobalArgs(hwAccel)
+	for i, image := range images {
+		if opts.FPS > 0 {
+			args = append(args, "-framerate", strconv.Itoa(opts.FPS))
+		}
+		seconds := opts.imageSeconds()
+		if i < len(images)-1 {
+			seconds += opts.transitionSeconds()
+		}
+		args = append(args, "-loop", "1", "-t", formatSeconds(seconds), "-i", image)
+	}
+	args = append(args, "-stream_loop", "-1", "-i", audioPath)
+	if opts.Watermark.ImagePath != "" {
+		args = append(args, "-i", opts.Watermark.ImagePath)
+	}
+	return args
+}
+
+// transitionInputFilter returns the filters appended to each fitted image
+// when transitions are on: xfade needs inputs of one frame rate and pixel
+// format, and images may be decoded as yuvj420p, yuv420p or rgb24
+func transitionInputFilter(opts SlideshowOptions) string {
+	if opts.transitionSeconds() == 0 {
+		return ""
+	}
+	fps := opts.FPS
+	if fps <= 0 {
+		fps = 25
+	}
+	pixelFormat := opts.PixelFormat
+	if pixelFormat == "" {
+		pixelFormat = "yuv420p"
+	}
+	return fmt.Sprintf(",fps=%d,format=%s", fps, pixelFormat)
+}
+
+// joinVideo joins the fitted width x height image streams into output like
+// joinFrames, then composites the watermark, whose image is input
+// watermarkInput, and hands the frames to the hardware encoder when those
+// are enabled
+func joinVideo(frames []string, output string, width, height, watermarkInput int, opts SlideshowOptions) []string {
+	if !opts.Watermark.enabled() && hwAccel == "" {
+		return joinFrames(frames, output, opts)
+	}
+	name := strings.Trim(output, "[]")
+	current := "[j" + name + "]"
+	filters := joinFrames(frames, current, opts)
+
+	if opts.Watermark.enabled() {
+		next := output
+		if hwAccel != "" {
+			next = "[w" + name + "]"
+		}
+		filters = append(filters, watermarkFilters(current, next, name, width, height, watermarkInput, opts.Watermark)...)
+		current = next
+	}
+	if hwAccel != "" {
+		filters = append(filters, current+strings.TrimPrefix(hwAccelUploadFilter(hwAccel), ",")+output)
+	}
+	return filters
+}
+
+// watermarkFilters composites a watermark onto the width x height video
+// labeled in, writing it to out. name keeps the labels of multi-aspect
+// renders apart.
+func watermarkFilters(in, out, name string, width, height, imageInput int, watermark SlideshowWatermark) []string {
+	margin := height / 40
+	if watermark.ImagePath != "" {
+		// A fifth of the frame width, slightly translucent
+		logo := "[logo" + name + "]"
+		return []string{
+			fmt.Sprintf("[%d:v]scale=%d:-1,format=rgba,colorchannelmixer=aa=0.8%s", imageInput, max(width/5, 2), logo),
+			fmt.Sprintf("%s%soverlay=W-w-%d:H-h-%d%s", in, logo, margin, margin, out),
+		}
+	}
+	return []string{fmt.Sprintf("%sdrawtext=%s:textfile=%s:fontsize=%d:fontcolor=white@0.85:"+
+		"shadowcolor=black@0.6:shadowx=2:shadowy=2:x=%d:y=h-text_h-%d%s",
+		in, drawtextFont(), drawtextEscape(watermark.TextPath), max(height/40, 8), margin, margin, out)}
+}
+
+// joinFrames returns the filters that join the fitted image streams into
+// output: a concat for hard cuts, else a chain of xfade filters, the k-th
+// starting k image durations in
+func joinFrames(frames []string, output string, opts SlideshowOptions) []string {
+	transition := opts.transitionSeconds()
+	if transition == 0 || len(frames) < 2 {
+		return []string{fmt.Sprintf("%sconcat=n=%d:v=1:a=0%s", strings.Join(frames, ""), len(frames), output)}
+	}
+
+	filters := []string{}
+	previous := frames[0]
+	for k := 1; k < len(frames); k++ {
+		next := fmt.Sprintf("[x%s%d]", strings.Trim(output, "[]"), k)
+		if k == len(frames)-1 {
+			next = output
+		}
+		filters = append(filters, fmt.Sprintf("%s%sxfade=transition=%s:duration=%s:offset=%s%s",
+			previous, frames[k], opts.Transition, formatSeconds(transition), formatSeconds(float64(k)*opts.imageSeconds()), next))
+		previous = next
+	}
+	return filters
+}
+
+// fitFrameFilter scales an image to fit a width x height frame and pads the
+// rest with black
+func fitFrameFilter(width, height int) string {
+	return fmt.Sprintf("scale=w=%d:h=%d:force_original_aspect_ratio=decrease,"+
+		"pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1", width, height, width, height)
+}
+
+// SlideshowAspect is an output frame of a multi-aspect slideshow render
+type SlideshowAspect struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// SlideshowAspects are the frames rendered by CreateSlideshowAspects:
+// vertical for TikTok/Reels/Shorts, square for feeds, horizontal for YouTube
+var SlideshowAspects = []SlideshowAspect{
+	{Name: "vertical", Width: 1080, Height: 1920},
+	{Name: "square", Width: 1080, Height: 1080},
+	{Name: "horizontal", Width: 1920, Height: 1080},
+}
+
+// CreateSlideshowAspects renders a slideshow in every SlideshowAspects frame,
+// scaled to the output resolution, with a single FFmpeg run: each image is split and fitted to every frame,
+// and each frame gets its own encoder and output. outputPaths holds one path
+// per aspect, in order. Size budgets and two-pass encodes are not supported.
+func CreateSlideshowAspects(ctx context.Context, images []string, audioPath string, outputPaths []string, opts SlideshowOptions) error {
+	if len(outputPaths) != len(SlideshowAspects) {
+		return fmt.Errorf("need %d output paths, got %d", len(SlideshowAspects), len(outputPaths))
+	}
+	if opts.MaxSizeBytes > 0 || opts.TargetBitrateKbps > 0 {
+		return errors.New("size budgets apply to single-aspect slideshows only")
+	}
+
+	n := len(SlideshowAspects)
+	args := append([]string{"-y"}, slideshowInputs(images, audioPath, opts)...)
+	filterComplex := []string{}
+
+	watermarkInput := len(images) + 1
+	for i := range images {
+		// [0:v]split=3[i0a0][i0a1][i0a2], then one fitted copy per aspect
+		splits := ""
+		for a := 0; a < n; a++ {
+			splits += fmt.Sprintf("[i%da%d]", i, a)
+		}
+		filterComplex = append(filterComplex, fmt.Sprintf("[%d:v]split=%d%s", i, n, splits))
+		for a, aspect := range SlideshowAspects {
+			filterComplex = append(filterComplex,
+				fmt.Sprintf("[i%da%d]%s%s[v%da%d]", i, a, fitFrameFilter(opts.scaleFrame(aspect.Width, aspect.Height)), transitionInputFilter(opts), i, a))
+		}
+	}
+
+	for a, aspect := range SlideshowAspects {
+		frames := make([]string, len(images))
+		for i := range images {
+			frames[i] = fmt.Sprintf("[v%da%d]", i, a)
+		}
+		width, height := opts.scaleFrame(aspect.Width, aspect.Height)
+		filterComplex = append(filterComplex, joinVideo(frames, fmt.Sprintf("[vout%d]", a), width, height, watermarkInput, opts)...)
+	}
+
+	// Trim the looping audio once and share it between the outputs
+	audioOuts := ""
+	for a := 0; a < n; a++ {
+		audioOuts += fmt.Sprintf("[aout%d]", a)
+	}
+	filterComplex = append(filterComplex,
+		fmt.Sprintf("[%d:a]atrim=0:%s,asplit=%d%s", len(images), formatSeconds(SlideshowDuration(len(images), opts)), n, audioOuts))
+	args = append(args, "-filter_complex", strings.Join(filterComplex, ";"))
+
+	for a, outputPath := range outputPaths {
+		args = append(args, "-map", fmt.Sprintf("[vout%d]", a), "-map", fmt.Sprintf("[aout%d]", a))
+		args = append(args, encoderArgs(opts)...)
+		args = append(args, audioArgs(outputPath)...)
+	}
+	return runFFmpeg(ctx, args)
+}
+
+// encoderArgs returns the video encoder options: libx264, or the enabled
+// hardware encoder, which always outputs 8-bit 4:2:0
+func encoderArgs(opts SlideshowOptions) []string {
+	var args []string
+	if hwAccel != "" {
+		args = hwEncoderArgs(hwAccel, opts)
+	} else {
+		pixelFormat := opts.PixelFormat
+		if pixelFormat == "" {
+			pixelFormat = "yuv420p"
+		}
+		args = []string{
+			"-pix_fmt", pixelFormat,
+			"-preset", opts.Preset,
+			"-c:v", "libx264",
+			"-tune", "stillimage",
+		}
+	}
+
+	// One keyframe per image: frames within an image are identical, so a
+	// longer GOP costs nothing in seeking and saves most of the bitrate
+	if opts.FPS > 0 {
+		keyint := max(1, int(math.Round(float64(opts.FPS)*opts.imageSeconds())))
+		args = append(args, "-r", strconv.Itoa(opts.FPS), "-g", strconv.Itoa(keyint), "-keyint_min", strconv.Itoa(min(opts.FPS, keyint)))
+	}
+
+	if opts.TargetBitrateKbps > 0 {
+		// Average bitrate with a VBV cap keeps the file close to the size budget
+		return append(args,
+			"-b:v", fmt.Sprintf("%dk", opts.TargetBitrateKbps),
+			"-maxrate", fmt.Sprintf("%dk", opts.TargetBitrateKbps*3/2),
+			"-bufsize", fmt.Sprintf("%dk", opts.TargetBitrateKbps*2),
+		)
+	}
+
+	if hwAccel == "" {
+		args = append(args, "-crf", strconv.Itoa(opts.CRF))
+	}
+	if opts.MaxBitrateKbps > 0 {
+		args = append(args,
+			"-maxrate", fmt.Sprintf("%dk", opts.MaxBitrateKbps),
+			"-bufsize", fmt.Sprintf("%dk", opts.MaxBitrateKbps*2),
+		)
+	}
+	return args
+}
+
+// audioArgs returns the audio encoder options and the output path
+func audioArgs(outputPath string) []string {
+	return []string{
 		"-c:a", "aac",
 		"-strict", "experimental",
-		"-b:a", "192k",
+		"-b:a", fmt.Sprintf("%dk", slideshowAudioKbps),
 		"-shortest",
 		outputPath,
-	)
+	}
+}
 
-	// Run FFmpeg command
+// runFFmpeg runs FFmpeg with the given arguments
+func runFFmpeg(ctx context.Context, args []string) error {
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -76,4 +553,32 @@ func CreateSlideshow(ctx context.Context, images []string, audioPath, outputPath
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// CreatePoster extracts the first frame of a video as a JPEG poster image
+func CreatePoster(ctx context.Context, videoPath, posterPath string) error {
+	return runFFmpeg(ctx, []string{"-y",
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-vf", "scale=540:-2",
+		"-q:v", "3",
+		posterPath,
+	})
+}
+
+// CreatePreview cuts a short, low-bitrate preview clip from the start of a video
+func CreatePreview(ctx context.Context, videoPath, previewPath string, seconds int) error {
+	return runFFmpeg(ctx, []string{"-y",
+		"-t", fmt.Sprintf("%d", seconds),
+		"-i", videoPath,
+		"-vf", "scale=540:-2",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "30",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "96k",
+		"-movflags", "+faststart",
+		previewPath,
+	})
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg puts an ffmpeg on PATH that runs until it is killed
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexec sleep 30\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunFFmpegCancelled(t *testing.T) {
+	fakeFFmpeg(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := runFFmpeg(ctx, []string{"-i", "input.mp4", "output.mp4"})
+	if err == nil {
+		t.Fatal("runFFmpeg succeeded after its context was cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("ffmpeg ran for %s after its context was cancelled", elapsed)
+	}
+}
+
+func TestExtractAudioCancelled(t *testing.T) {
+	fakeFFmpeg(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- ExtractAudio(ctx, "video.mp4", filepath.Join(t.TempDir(), "audio.mp3")) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("ExtractAudio succeeded after its context was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExtractAudio kept ffmpeg running after its context was cancelled")
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upstream platforms recognised by PlatformForHost
+const (
+	PlatformTikTok    = "tiktok"
+	PlatformDouyin    = "douyin"
+	PlatformInstagram = "instagram"
+	PlatformYouTube   = "youtube"
+	PlatformKuaishou  = "kuaishou"
+)
+
+// platformDisplayNames are the platform names shown in error messages
+var platformDisplayNames = map[string]string{
+	PlatformTikTok:    "TikTok",
+	PlatformDouyin:    "Douyin",
+	PlatformInstagram: "Instagram",
+	PlatformYouTube:   "YouTube",
+	PlatformKuaishou:  "Kuaishou",
+}
+
+// platformHostSuffixes maps each platform to the domains serving its media
+var platformHostSuffixes = map[string][]string{
+	PlatformTikTok: {
+		"tiktok.com",
+		"tiktokcdn.com",
+		"tiktokcdn-us.com",
+		"tiktokv.com",
+		"ibyteimg.com",
+		"byteoversea.com",
+		"muscdn.com",
+	},
+	PlatformDouyin: {
+		"douyin.com",
+		"douyinvod.com",
+		"douyincdn.com",
+		"douyinpic.com",
+		"douyinstatic.com",
+		"amemv.com",
+		"snssdk.com",
+		"zjcdn.com",
+	},
+	PlatformInstagram: {
+		"instagram.com",
+		"cdninstagram.com",
+		"fbcdn.net",
+	},
+	PlatformYouTube: {
+		"youtube.com",
+		"youtu.be",
+		"googlevideo.com",
+		"ytimg.com",
+	},
+	PlatformKuaishou: {
+		"kuaishou.com",
+		"chenzhongtech.com",
+		"gifshow.com",
+		"kwimgs.com",
+		"kwaicdn.com",
+		"yximgs.com",
+	},
+}
+
+// PlatformForHost returns the platform serving a host, or "" when unknown
+func PlatformForHost(host string) string {
+	host = strings.ToLower(host)
+	if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	for platform, suffixes := range platformHostSuffixes {
+		for _, suffix := range suffixes {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return platform
+			}
+		}
+	}
+	return ""
+}
+
+// SourceHook customizes requests to upstream media hosts (CDNs) and observes
+// their responses. Hooks are registered once at startup.
+type SourceHook interface {
+	// Before is called with a private copy of each outgoing request
+	Before(req *http.Request)
+	// After is called with the response of each successful round trip
+	After(req *http.Request, resp *http.Response)
+}
+
+var sourceHooks struct {
+	sync.RWMutex
+	hooks []SourceHook
+}
+
+// AddSourceHook registers a hook applied to every source fetch
+func AddSourceHook(hook SourceHook) {
+	sourceHooks.Lock()
+	defer sourceHooks.Unlock()
+	sourceHooks.hooks = append(sourceHooks.hooks, hook)
+}
+
+// sourceTransport applies the registered hooks around a base transport
+type sourceTransport struct {
+	base http.RoundTripper
+	// impersonate routes platforms configured for TLS impersonation through the Chrome transport
+	impersonate bool
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *sourceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sourceHooks.RLock()
+	hooks := sourceHooks.hooks
+	sourceHooks.RUnlock()
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for _, hook := range hooks {
+		hook.Before(req)
+	}
+
+	base := t.base
+	if t.impersonate && req.URL.Scheme == "https" && impersonated(PlatformForHost(req.URL.Host)) {
+		base = chromeTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range hooks {
+		hook.After(req, resp)
+	}
+	return resp, nil
+}
+
+// WrapSourceTransport wraps a transport so requests made through it get the
+// source hooks. A nil base uses the upstream transport with TLS impersonation.
+func WrapSourceTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		return &sourceTransport{base: upstreamTransport, impersonate: true}
+	}
+	return &sourceTransport{base: base}
+}
+
+// NewSourceClient returns an HTTP client for fetching media from upstream
+// CDNs; a zero timeout means no timeout
+func NewSourceClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: WrapSourceTransport(nil)}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// statisticsKeys lists, in order of preference, where post data may carry
+// its counters: the hybrid API's statistics, and the stats/statsV2 objects
+// of TikTok web payloads
+var statisticsKeys = []string{"statistics", "stats", "statsV2"}
+
+// statAliases maps each counter to the other names platforms use for it.
+// Douyin and TikTok web payloads spell some counters differently
+// (like_count for digg_count, forward_count for repost_count) or in
+// camelCase.
+var statAliases = map[string][]string{
+	"digg_count":    {"diggCount", "like_count", "likeCount", "digg"},
+	"play_count":    {"playCount", "view_count", "viewCount", "play"},
+	"comment_count": {"commentCount", "comment"},
+	"share_count":   {"shareCount", "share"},
+	"repost_count":  {"repostCount", "forward_count", "forwardCount"},
+	"collect_count": {"collectCount", "favorite_count", "favoriteCount"},
+}
+
+// statSuffixes are the multipliers of abbreviated counts such as "1.2K",
+// "3M" or Douyin's "1.5万" (ten thousand) and "2亿" (hundred million)
+var statSuffixes = map[string]float64{
+	"k": 1e3, "m": 1e6, "b": 1e9,
+	"w": 1e4, "万": 1e4, "亿": 1e8,
+}
+
+// PostStatistics returns the counters object of post video data, or nil
+// when the post has none
+func PostStatistics(videoData map[string]interface{}) map[string]interface{} {
+	for _, key := range statisticsKeys {
+		if statistics, ok := videoData[key].(map[string]interface{}); ok {
+			return statistics
+		}
+	}
+	return nil
+}
+
+// LookupIntStat returns a counter under its own name or one of its aliases,
+// whether it is a number, a numeric string or an abbreviated count, and
+// reports whether it was found
+func LookupIntStat(statistics map[string]interface{}, key string) (int, bool) {
+	for _, name := range append([]string{key}, statAliases[key]...) {
+		if count, ok := parseStat(statistics[name]); ok {
+			return count, true
+		}
+	}
+	return 0, false
+}
+
+// parseStat converts a counter value of any of the shapes platforms return
+func parseStat(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return int(f), err == nil
+	case string:
+		return parseStatString(v)
+	}
+	return 0, false
+}
+
+// parseStatString parses "12345", "12,345", "1.2K" or "1.5万"
+func parseStatString(s string) (int, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, false
+	}
+	multiplier := 1.0
+	for suffix, m := range statSuffixes {
+		if strings.HasSuffix(strings.ToLower(s), suffix) {
+			s = strings.TrimSpace(s[:len(s)-len(suffix)])
+			multiplier = m
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	// Rounded, as "1.13万" is 11299.999… in floating point
+	return int(math.Round(f * multiplier)), true
+}
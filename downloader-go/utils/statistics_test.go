@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadPostData reads the video data of a payload under testdata/statistics
+func loadPostData(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "statistics", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatal(err)
+	}
+	videoData, ok := payload["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s has no data object", name)
+	}
+	return videoData
+}
+
+func TestPostStatisticsPayloads(t *testing.T) {
+	// A counter missing from want must not be found
+	tests := []struct {
+		payload string
+		want    map[string]int
+	}{
+		{
+			payload: "douyin_hybrid.json",
+			want: map[string]int{
+				"digg_count": 14825, "comment_count": 309, "share_count": 2214,
+				"collect_count": 1052, "play_count": 0,
+			},
+		},
+		{
+			payload: "douyin_web_strings.json",
+			want: map[string]int{
+				"digg_count": 23000, "comment_count": 1204, "repost_count": 315,
+				"play_count": 110000000,
+			},
+		},
+		{
+			payload: "tiktok_hybrid.json",
+			want: map[string]int{
+				"digg_count": 912345, "comment_count": 4021, "share_count": 23456,
+				"collect_count": 51234, "play_count": 10456789, "repost_count": 0,
+			},
+		},
+		{
+			payload: "tiktok_web.json",
+			want: map[string]int{
+				"digg_count": 45600, "comment_count": 388, "share_count": 912,
+				"collect_count": 2101, "play_count": 1200000,
+			},
+		},
+		{
+			payload: "tiktok_missing.json",
+			want:    map[string]int{"digg_count": 3400},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			statistics := PostStatistics(loadPostData(t, tt.payload))
+			if statistics == nil {
+				t.Fatal("PostStatistics() = nil")
+			}
+			for key := range statAliases {
+				got, found := LookupIntStat(statistics, key)
+				want, expected := tt.want[key]
+				switch {
+				case expected && !found:
+					t.Errorf("%s not found, want %d", key, want)
+				case !expected && found:
+					t.Errorf("%s = %d, want it reported missing", key, got)
+				case got != want:
+					t.Errorf("%s = %d, want %d", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPostStatisticsMissing(t *testing.T) {
+	if statistics := PostStatistics(loadPostData(t, "douyin_no_statistics.json")); statistics != nil {
+		t.Errorf("PostStatistics() = %v, want nil", statistics)
+	}
+	if count, found := LookupIntStat(nil, "digg_count"); found || count != 0 {
+		t.Errorf("LookupIntStat(nil) = %d, %t, want 0, false", count, found)
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int
+		ok    bool
+	}{
+		{float64(1234), 1234, true},
+		{json.Number("42"), 42, true},
+		{"12345", 12345, true},
+		{" 12,345 ", 12345, true},
+		{"2.3K", 2300, true},
+		{"1.2k", 1200, true},
+		{"3M", 3000000, true},
+		{"1.5B", 1500000000, true},
+		{"1.5万", 15000, true},
+		{"4.35w", 43500, true},
+		{"1.13万", 11300, true},
+		{"2亿", 200000000, true},
+		{"0", 0, true},
+		{"", 0, false},
+		{"n/a", 0, false},
+		{nil, 0, false},
+		{true, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseStat(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseStat(%#v) = %d, %t, want %d, %t", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tiktok-downloader/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Storage backends for rendered files
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// Storage keeps rendered files where clients can download them directly, so
+// any instance can answer for a render made by another one
+type Storage interface {
+	// Put uploads the file at path under key and returns a URL it can be
+	// fetched from until it expires; a filename makes the URL a download
+	Put(ctx context.Context, key, path, contentType, filename string) (string, error)
+}
+
+// NewStorage creates the storage backend selected by STORAGE_BACKEND; it
+// returns nil for local storage, where files are served from TempDir
+func NewStorage(cfg *config.AppConfig) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", StorageLocal:
+		return nil, nil
+	case StorageS3:
+		if cfg.StorageBucket == "" {
+			return nil, fmt.Errorf("STORAGE_S3_BUCKET is required for the s3 storage backend")
+		}
+		client, err := NewS3Client(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// Presigned URLs can't outlive a week
+		ttl := min(max(cfg.StorageURLTTL, time.Minute), 7*24*time.Hour)
+		return &S3Storage{client: client, bucket: cfg.StorageBucket, prefix: cfg.StoragePrefix, urlTTL: ttl}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, must be %q or %q", cfg.StorageBackend, StorageLocal, StorageS3)
+	}
+}
+
+// S3Storage uploads rendered files to an S3/MinIO bucket and links them with
+// presigned URLs. Objects aren't deleted; expire them with a bucket
+// lifecycle rule on the prefix.
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	urlTTL time.Duration
+}
+
+// Put uploads a file and presigns a download URL for it
+func (s *S3Storage) Put(ctx context.Context, key, path, contentType, filename string) (string, error) {
+	objectKey := s.prefix + key
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if filename != "" {
+		opts.ContentDisposition = AttachmentDisposition(filename)
+	}
+	if _, err := s.client.FPutObject(ctx, s.bucket, objectKey, path, opts); err != nil {
+		return "", err
+	}
+
+	presigned, err := s.client.PresignedGetObject(ctx, s.bucket, objectKey, s.urlTTL, nil)
+	if err != nil {
+		return "", err
+	}
+	return presigned.String(), nil
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tiktok-downloader/metrics"
+)
+
+// SourceStreaming controls how large source bodies are fetched: in ranges
+// of ChunkSize bytes (0 fetches in one request), resuming from the current
+// offset when no byte arrives for StallTimeout, within a deadline that
+// allows MinThroughput bytes per second on top of HeaderTimeout
+type SourceStreaming struct {
+	ChunkSize     int64
+	StallTimeout  time.Duration
+	MinThroughput int64
+	HeaderTimeout time.Duration
+}
+
+// sourceStreaming is the policy applied by OpenSource
+var sourceStreaming = SourceStreaming{
+	ChunkSize:     16 << 20,
+	StallTimeout:  30 * time.Second,
+	MinThroughput: 128 << 10,
+	HeaderTimeout: 60 * time.Second,
+}
+
+func init() {
+	metrics.Register("tikdownloader_source_resumes_total", "Source streams resumed from their offset after a failed or stalled connection.", metrics.Counter)
+}
+
+// SetSourceStreaming sets the policy of size-aware source streaming
+func SetSourceStreaming(policy SourceStreaming) {
+	if policy.ChunkSize < 0 {
+		policy.ChunkSize = 0
+	}
+	if policy.HeaderTimeout <= 0 {
+		policy.HeaderTimeout = sourceStreaming.HeaderTimeout
+	}
+	sourceStreaming = policy
+}
+
+// SourceDeadline returns how long a source body of size bytes may take in
+// total: the header timeout plus the time to transfer it at the minimum
+// throughput. Unknown sizes and a zero minimum throughput have no deadline.
+func SourceDeadline(size int64) time.Duration {
+	policy := sourceStreaming
+	if size <= 0 || policy.MinThroughput <= 0 {
+		return 0
+	}
+	return policy.HeaderTimeout + time.Duration(size/policy.MinThroughput)*time.Second
+}
+
+// OpenSource sends a bodiless source request like DoSourceRequest and
+// returns its response. Once headers arrive the body gets a deadline sized
+// to its length; bodies of hosts that accept ranges are fetched in chunks
+// and resumed from their offset when a connection fails or stalls, so large
+// (1GB+) videos neither restart nor hold one connection for their whole
+// transfer. The response body must be closed.
+func OpenSource(req *http.Request) (*http.Response, error) {
+	policy := sourceStreaming
+	ctx, cancel := context.WithCancel(req.Context())
+	connCtx, cancelConn := context.WithCancel(ctx)
+
+	headerTimer := time.AfterFunc(policy.HeaderTimeout, cancelConn)
+	resp, err := DoSourceRequest(NewSourceClient(0), req.WithContext(connCtx))
+	headerTimer.Stop()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	start, end, ok := bodyRange(resp)
+	if !ok {
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+
+	stream := &sourceStream{
+		ctx:        ctx,
+		cancel:     cancel,
+		req:        req,
+		policy:     policy,
+		resumable:  resp.StatusCode == http.StatusPartialContent || strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+		validator:  resp.Header.Get("ETag"),
+		body:       resp.Body,
+		cancelConn: cancelConn,
+		offset:     start,
+		end:        end,
+		segmentEnd: end,
+	}
+	if stream.resumable && policy.ChunkSize > 0 {
+		stream.segmentEnd = min(end, start+policy.ChunkSize-1)
+	}
+	if deadline := SourceDeadline(end - start + 1); deadline > 0 {
+		stream.deadline = time.AfterFunc(deadline, cancel)
+	}
+	if policy.StallTimeout > 0 {
+		stream.stall = time.AfterFunc(time.Hour, stream.abortConnection)
+		stream.stall.Stop()
+	}
+	resp.Body = stream
+	return resp, nil
+}
+
+// bodyRange returns the absolute byte range a successful response carries:
+// the whole file for a 200 of known length, the Content-Range of a 206
+func bodyRange(resp *http.Response) (start, end int64, ok bool) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength <= 0 {
+			return 0, 0, false
+		}
+		return 0, resp.ContentLength - 1, true
+	case http.StatusPartialContent:
+		var total string
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &total); err != nil || end < start {
+			return 0, 0, false
+		}
+		return start, end, true
+	}
+	return 0, 0, false
+}
+
+// cancelOnClose releases a request context once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// sourceStream reads the byte range [offset, end] of a source one segment
+// (connection) at a time
+type sourceStream struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	req       *http.Request
+	policy    SourceStreaming
+	resumable bool
+	validator string
+	deadline  *time.Timer
+	stall     *time.Timer
+
+	mu         sync.Mutex
+	body       io.ReadCloser
+	cancelConn context.CancelFunc
+	stalled    bool
+
+	offset     int64 // next byte to read
+	end        int64 // last byte of the range
+	segmentEnd int64 // last byte of the current connection
+	failures   int
+}
+
+// abortConnection drops the current connection, e.g. once it stalls
+func (s *sourceStream) abortConnection() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelConn != nil {
+		s.stalled = true
+		s.cancelConn()
+	}
+}
+
+// closeConnection closes the current connection's body
+func (s *sourceStream) closeConnection() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	if s.cancelConn != nil {
+		s.cancelConn()
+		s.cancelConn = nil
+	}
+	s.stalled = false
+}
+
+// openSegment requests the next chunk of the range from the current offset
+func (s *sourceStream) openSegment() error {
+	segmentEnd := s.end
+	if s.policy.ChunkSize > 0 {
+		segmentEnd = min(s.end, s.offset+s.policy.ChunkSize-1)
+	}
+
+	connCtx, cancelConn := context.WithCancel(s.ctx)
+	req := s.req.Clone(connCtx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", s.offset, segmentEnd))
+	req.Header.Del("If-Range")
+	if s.validator != "" {
+		// A changed source answers with the full new file instead
+		req.Header.Set("If-Range", s.validator)
+	}
+
+	headerTimer := time.AfterFunc(s.policy.HeaderTimeout, cancelConn)
+	resp, err := DoSourceRequest(NewSourceClient(0), req)
+	headerTimer.Stop()
+	if err != nil {
+		cancelConn()
+		return err
+	}
+	start, _, ok := bodyRange(resp)
+	if resp.StatusCode != http.StatusPartialContent || !ok || start != s.offset {
+		resp.Body.Close()
+		cancelConn()
+		return fmt.Errorf("source can't resume at byte %d: %s", s.offset, resp.Status)
+	}
+
+	s.mu.Lock()
+	s.body, s.cancelConn = resp.Body, cancelConn
+	s.mu.Unlock()
+	s.segmentEnd = segmentEnd
+	return nil
+}
+
+// Read implements io.Reader, moving to the next segment at the end of each
+// one and resuming from the offset when a connection fails
+func (s *sourceStream) Read(p []byte) (int, error) {
+	for {
+		if s.offset > s.end {
+			return 0, io.EOF
+		}
+		if s.body == nil {
+			if err := s.openSegment(); err != nil {
+				if s.ctx.Err() == nil && s.backoff(err) {
+					continue
+				}
+				return 0, err
+			}
+		}
+
+		if remaining := s.segmentEnd - s.offset + 1; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		if s.stall != nil {
+			s.stall.Reset(s.policy.StallTimeout)
+		}
+		n, err := s.body.Read(p)
+		if s.stall != nil {
+			s.stall.Stop()
+		}
+		s.offset += int64(n)
+
+		if s.offset > s.segmentEnd {
+			// Segment complete; the next Read opens the following one
+			s.closeConnection()
+			s.failures = 0
+			return n, nil
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			s.mu.Lock()
+			if s.stalled {
+				err = fmt.Errorf("no data for %s", s.policy.StallTimeout)
+			}
+			s.mu.Unlock()
+			s.closeConnection()
+			if !s.resumable || s.ctx.Err() != nil || !s.backoff(err) {
+				return n, err
+			}
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// backoff records a failed connection and waits before the next one,
+// reporting false once the source retry policy is exhausted
+func (s *sourceStream) backoff(err error) bool {
+	retry := sourceRetry
+	s.failures++
+	if s.failures >= retry.MaxAttempts {
+		return false
+	}
+	metrics.Inc("tikdownloader_source_resumes_total", nil)
+	delay := retry.retryDelay(s.failures)
+	log.Printf("Resuming %s at byte %d in %s: %v", s.req.URL.Redacted(), s.offset, delay, err)
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// Close implements io.Closer
+func (s *sourceStream) Close() error {
+	s.closeConnection()
+	if s.deadline != nil {
+		s.deadline.Stop()
+	}
+	s.cancel()
+	return nil
+}
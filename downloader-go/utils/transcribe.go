@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// transcribeTimeout bounds one transcription request; long posts can take a
+// while on CPU-only Whisper servers
+const transcribeTimeout = 5 * time.Minute
+
+// Transcript is the speech-to-text result of a post's audio
+type Transcript struct {
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"`
+	Text     string              `json:"text"`
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+}
+
+// TranscriptSegment is a timed span of a transcript, in seconds
+type TranscriptSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Transcribe sends an audio file to a Whisper-compatible endpoint, i.e. one
+// speaking OpenAI's POST /v1/audio/transcriptions, and returns its timed
+// transcript. language is an optional ISO-639-1 hint.
+func Transcribe(ctx context.Context, endpoint, apiKey, model, language, audioPath string) (*Transcript, error) {
+	audio, err := os.Open(audioPath)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{"model": model, "response_format": "verbose_json"}
+	if language != "" {
+		fields["language"] = language
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	client := &http.Client{Timeout: transcribeTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("transcription request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("transcription endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var transcript Transcript
+	if err := json.NewDecoder(resp.Body).Decode(&transcript); err != nil {
+		return nil, fmt.Errorf("error parsing transcription response: %v", err)
+	}
+	transcript.Text = strings.TrimSpace(transcript.Text)
+	for i := range transcript.Segments {
+		transcript.Segments[i].Text = strings.TrimSpace(transcript.Segments[i].Text)
+	}
+	return &transcript, nil
+}
+
+// SRT formats the transcript's segments as SubRip subtitles
+func (t *Transcript) SRT() string {
+	var srt strings.Builder
+	for i, segment := range t.Segments {
+		fmt.Fprintf(&srt, "%d\n%s --> %s\n%s\n\n", i+1, srtTimestamp(segment.Start), srtTimestamp(segment.End), segment.Text)
+	}
+	return srt.String()
+}
+
+// srtTimestamp formats seconds as an SRT timestamp, HH:MM:SS,mmm
+func srtTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"tiktok-downloader/config"
+)
+
+// translateTimeout bounds one translation request
+const translateTimeout = 30 * time.Second
+
+// Translation providers
+const (
+	TranslateDeepL          = "deepl"
+	TranslateLibreTranslate = "libretranslate"
+	TranslateOpenAI         = "openai"
+)
+
+// Default endpoints of the hosted translation providers
+const (
+	deeplEndpoint  = "https://api-free.deepl.com/v2/translate"
+	openAIEndpoint = "https://api.openai.com/v1/chat/completions"
+)
+
+// Translator translates texts, such as post descriptions and transcripts
+type Translator interface {
+	// Translate returns texts translated to the target language (an
+	// ISO-639-1 code, optionally with a region such as pt-BR), in order
+	Translate(ctx context.Context, texts []string, target string) ([]string, error)
+}
+
+// NewTranslator creates the translation provider selected by
+// TRANSLATE_PROVIDER; it returns nil when translation is disabled
+func NewTranslator(cfg *config.AppConfig) (Translator, error) {
+	endpoint := cfg.TranslateURL
+	switch cfg.TranslateProvider {
+	case "":
+		return nil, nil
+	case TranslateDeepL:
+		if endpoint == "" {
+			endpoint = deeplEndpoint
+		}
+		if cfg.TranslateAPIKey == "" {
+			return nil, fmt.Errorf("TRANSLATE_API_KEY is required for DeepL")
+		}
+		return &deeplTranslator{endpoint: endpoint, apiKey: cfg.TranslateAPIKey}, nil
+	case TranslateLibreTranslate:
+		if endpoint == "" {
+			return nil, fmt.Errorf("TRANSLATE_URL is required for LibreTranslate")
+		}
+		return &libreTranslator{endpoint: endpoint, apiKey: cfg.TranslateAPIKey}, nil
+	case TranslateOpenAI:
+		if endpoint == "" {
+			endpoint = openAIEndpoint
+		}
+		return &openAITranslator{endpoint: endpoint, apiKey: cfg.TranslateAPIKey, model: cfg.TranslateModel}, nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider %q, must be %q, %q or %q",
+			cfg.TranslateProvider, TranslateDeepL, TranslateLibreTranslate, TranslateOpenAI)
+	}
+}
+
+// deeplTranslator translates with the DeepL API
+type deeplTranslator struct {
+	endpoint string
+	apiKey   string
+}
+
+func (t *deeplTranslator) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	var result struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	err := postTranslation(ctx, t.endpoint, "DeepL-Auth-Key "+t.apiKey, map[string]interface{}{
+		"text":        texts,
+		"target_lang": strings.ToUpper(target),
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	translated := make([]string, len(result.Translations))
+	for i, translation := range result.Translations {
+		translated[i] = translation.Text
+	}
+	return checkTranslations(texts, translated)
+}
+
+// libreTranslator translates with a LibreTranslate server's /translate
+type libreTranslator struct {
+	endpoint string
+	apiKey   string
+}
+
+func (t *libreTranslator) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	body := map[string]interface{}{
+		"q":      texts,
+		"source": "auto",
+		// LibreTranslate takes bare language codes
+		"target": strings.ToLower(strings.SplitN(target, "-", 2)[0]),
+		"format": "text",
+	}
+	if t.apiKey != "" {
+		body["api_key"] = t.apiKey
+	}
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := postTranslation(ctx, t.endpoint, "", body, &result); err != nil {
+		return nil, err
+	}
+	return checkTranslations(texts, result.TranslatedText)
+}
+
+// openAITranslator translates with an OpenAI-compatible chat completions
+// endpoint, asking the model for a JSON array of the translations
+type openAITranslator struct {
+	endpoint string
+	apiKey   string
+	model    string
+}
+
+func (t *openAITranslator) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	authorization := ""
+	if t.apiKey != "" {
+		authorization = "Bearer " + t.apiKey
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	err = postTranslation(ctx, t.endpoint, authorization, map[string]interface{}{
+		"model":       t.model,
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": fmt.Sprintf("Translate each string of the JSON array the user sends to the language with code %q. "+
+				"Reply with only a JSON array of the translations, in the same order, without any other text.", target)},
+			{"role": "user", "content": string(input)},
+		},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("translation response has no choices")
+	}
+
+	// Models sometimes wrap the array in a Markdown code block
+	content := strings.TrimSpace(result.Choices[0].Message.Content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+
+	var translated []string
+	if err := json.Unmarshal([]byte(content), &translated); err != nil {
+		return nil, fmt.Errorf("error parsing translation response: %v", err)
+	}
+	return checkTranslations(texts, translated)
+}
+
+// postTranslation POSTs a JSON body to a translation endpoint and decodes
+// its JSON reply into result
+func postTranslation(ctx context.Context, endpoint, authorization string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	client := &http.Client{Timeout: translateTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("translation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("translation endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error parsing translation response: %v", err)
+	}
+	return nil
+}
+
+// checkTranslations makes sure a provider returned one translation per text
+func checkTranslations(texts, translated []string) ([]string, error) {
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("translation returned %d texts for %d", len(translated), len(texts))
+	}
+	return translated, nil
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes for posts the platform refuses to serve
+const (
+	PostPrivate       = "POST_PRIVATE"
+	PostDeleted       = "POST_DELETED"
+	PostAgeRestricted = "POST_AGE_RESTRICTED"
+	PostRegionLocked  = "POST_REGION_LOCKED"
+)
+
+// PostUnavailableError reports that a post exists upstream in a state we
+// cannot resolve, as opposed to the hybrid API itself failing
+type PostUnavailableError struct {
+	Code   string
+	Detail string
+}
+
+func (e *PostUnavailableError) Error() string {
+	return postUnavailableMessages[e.Code]
+}
+
+// HTTPStatus returns the status a client should see for this error
+func (e *PostUnavailableError) HTTPStatus() int {
+	switch e.Code {
+	case PostDeleted:
+		return http.StatusGone
+	case PostRegionLocked:
+		return http.StatusUnavailableForLegalReasons
+	default:
+		return http.StatusForbidden
+	}
+}
+
+// postUnavailableMessages are the user-facing messages per error code
+var postUnavailableMessages = map[string]string{
+	PostPrivate:       "This post is private",
+	PostDeleted:       "This post has been deleted or does not exist",
+	PostAgeRestricted: "This post is age-restricted",
+	PostRegionLocked:  "This post is not available in the server's region",
+}
+
+// postUnavailableHints map fragments of upstream messages, Douyin filter
+// reasons and TikTok status codes to error codes, most specific first
+var postUnavailableHints = []struct {
+	code      string
+	fragments []string
+}{
+	{PostAgeRestricted, []string{"age restrict", "age_restrict", "age-restrict", "age gate", "age_gate"}},
+	{PostRegionLocked, []string{"region lock", "region restrict", "region_restrict", "not available in your country", "geo-block", "geoblock"}},
+	{PostPrivate, []string{"private", "status_self_see", "friends only", "10216", "10222"}},
+	{PostDeleted, []string{"deleted", "removed", "not exist", "status_deleted", "10204"}},
+}
+
+// classifyUnavailable matches a free-form upstream message against the hints
+func classifyUnavailable(message string) *PostUnavailableError {
+	lower := strings.ToLower(message)
+	for _, hint := range postUnavailableHints {
+		for _, fragment := range hint.fragments {
+			if strings.Contains(lower, fragment) {
+				return &PostUnavailableError{Code: hint.code, Detail: message}
+			}
+		}
+	}
+	return nil
+}
+
+// classifyErrorBody looks for a known reason in a hybrid API error body, which
+// FastAPI wraps as {"detail": {"message": ...}} or {"detail": "..."}
+func classifyErrorBody(body []byte) *PostUnavailableError {
+	var parsed struct {
+		Detail  json.RawMessage `json:"detail"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return classifyUnavailable(string(body))
+	}
+
+	messages := []string{parsed.Message}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	var detailText string
+	if err := json.Unmarshal(parsed.Detail, &detail); err == nil {
+		messages = append(messages, detail.Message)
+	} else if err := json.Unmarshal(parsed.Detail, &detailText); err == nil {
+		messages = append(messages, detailText)
+	}
+
+	for _, message := range messages {
+		// The stock hybrid API answers every failure with this placeholder
+		if message == "" || message == "An error occurred." {
+			continue
+		}
+		if unavailable := classifyUnavailable(message); unavailable != nil {
+			return unavailable
+		}
+	}
+	return nil
+}
+
+// classifyPayload detects posts that resolved but carry a deleted, private or
+// filtered status, as seen in full (minimal=false) payloads and in the
+// filter details Douyin returns instead of aweme_detail
+func classifyPayload(data map[string]interface{}) *PostUnavailableError {
+	videoData, _ := data["data"].(map[string]interface{})
+	if videoData == nil {
+		return nil
+	}
+
+	if status, ok := videoData["status"].(map[string]interface{}); ok {
+		if deleted, _ := status["is_delete"].(bool); deleted {
+			return &PostUnavailableError{Code: PostDeleted, Detail: "status.is_delete"}
+		}
+	}
+
+	if filter, ok := videoData["filter_detail"].(map[string]interface{}); ok {
+		for _, key := range []string{"filter_reason", "detail_msg"} {
+			if reason, _ := filter[key].(string); reason != "" {
+				if unavailable := classifyUnavailable(reason); unavailable != nil {
+					return unavailable
+				}
+			}
+		}
+	}
+
+	if statusCode, ok := videoData["statusCode"].(float64); ok && statusCode != 0 {
+		return classifyUnavailable(fmt.Sprintf("%.0f", statusCode))
+	}
+	return nil
+}
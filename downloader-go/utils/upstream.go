@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"tiktok-downloader/metrics"
+)
+
+// FallbackUpstreamKey is set on hybrid API data served by a fallback
+// instance, holding that instance's endpoint
+const FallbackUpstreamKey = "_fallback_upstream"
+
+// HybridEndpointKey is set on hybrid API data to the endpoint that served it
+const HybridEndpointKey = "_hybrid_endpoint"
+
+// ErrUpstreamDown matches hybrid API failures that suggest the instance
+// itself is down rather than the post being unavailable
+var ErrUpstreamDown = errors.New("hybrid API unavailable")
+
+// upstreamDownError keeps the original message while matching ErrUpstreamDown
+type upstreamDownError struct{ error }
+
+func (e upstreamDownError) Is(target error) bool { return target == ErrUpstreamDown }
+
+// hybridBackend is one configured hybrid API instance and its health
+type hybridBackend struct {
+	endpoint string
+	down     atomic.Bool
+}
+
+// setHealthy records the backend's health, logging transitions
+func (b *hybridBackend) setHealthy(healthy bool) {
+	metrics.Set("tikdownloader_hybrid_backend_up", metrics.Labels{"endpoint": b.endpoint}, boolGauge(healthy))
+	if b.down.Swap(!healthy) == !healthy {
+		return
+	}
+	if healthy {
+		log.Printf("Hybrid API %s is back up", b.endpoint)
+	} else {
+		log.Printf("Hybrid API %s is down, failing over", b.endpoint)
+	}
+}
+
+// hybridBackends are the configured hybrid API instances, used round-robin
+// while healthy; hybridFallbacks are public endpoints used while all are down
+var (
+	hybridBackends  []*hybridBackend
+	backendCursor   atomic.Uint64
+	hybridFallbacks []string
+)
+
+func init() {
+	metrics.Register("tikdownloader_hybrid_fallback_requests_total", "Hybrid API requests served by a fallback instance.", metrics.Counter)
+	metrics.Register("tikdownloader_hybrid_backend_up", "Whether a configured hybrid API instance is believed to be up.", metrics.Gauge)
+}
+
+// boolGauge converts a flag to a gauge value
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SetHybridBackends sets the hybrid API instances requests are spread over
+func SetHybridBackends(endpoints []string) {
+	hybridBackends = make([]*hybridBackend, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		backend := &hybridBackend{endpoint: endpoint}
+		hybridBackends = append(hybridBackends, backend)
+		metrics.Set("tikdownloader_hybrid_backend_up", metrics.Labels{"endpoint": endpoint}, 1)
+	}
+}
+
+// SetHybridFallbacks sets the endpoints tried, in order, while every hybrid API backend is down
+func SetHybridFallbacks(endpoints []string) {
+	hybridFallbacks = endpoints
+}
+
+// backendOrder returns the order to try the backends in for one request:
+// the healthy ones starting at the next round-robin position, then the ones
+// believed down
+func backendOrder() (healthy, down []*hybridBackend) {
+	n := len(hybridBackends)
+	if n == 0 {
+		return nil, nil
+	}
+	start := int(backendCursor.Add(1)-1) % n
+	for i := 0; i < n; i++ {
+		backend := hybridBackends[(start+i)%n]
+		if backend.down.Load() {
+			down = append(down, backend)
+		} else {
+			healthy = append(healthy, backend)
+		}
+	}
+	return healthy, down
+}
+
+// HybridEndpoint returns the backend a single request should go to: the next
+// healthy one, or the first one when all are down
+func HybridEndpoint() string {
+	healthy, down := backendOrder()
+	if len(healthy) > 0 {
+		return healthy[0].endpoint
+	}
+	if len(down) > 0 {
+		return down[0].endpoint
+	}
+	return ""
+}
+
+// fetchFromBackends runs fetch against the healthy backends in round-robin
+// order, failing over on errors that suggest the instance is down. Once they
+// are exhausted the fallback instances are tried when useFallbacks is set;
+// without any, the backends believed down get a last try in case they have
+// recovered.
+func fetchFromBackends(ctx context.Context, useFallbacks bool, fetch func(endpoint string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	healthy, down := backendOrder()
+
+	var lastErr error
+	for _, backend := range healthy {
+		data, err := fetch(backend.endpoint)
+		if err == nil || !errors.Is(err, ErrUpstreamDown) || ctx.Err() != nil {
+			return data, err
+		}
+		backend.setHealthy(false)
+		lastErr = err
+	}
+
+	if useFallbacks && len(hybridFallbacks) > 0 {
+		for _, endpoint := range hybridFallbacks {
+			data, err := fetch(endpoint)
+			if err == nil {
+				metrics.Inc("tikdownloader_hybrid_fallback_requests_total", nil)
+				data[FallbackUpstreamKey] = endpoint
+				return data, nil
+			}
+			if !errors.Is(err, ErrUpstreamDown) || ctx.Err() != nil {
+				return nil, err
+			}
+			log.Printf("Fallback hybrid API %s failed: %v", endpoint, err)
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+
+	for _, backend := range down {
+		data, err := fetch(backend.endpoint)
+		if err == nil || !errors.Is(err, ErrUpstreamDown) {
+			backend.setHealthy(true)
+			return data, err
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// fetchHybridWithFallback fetches post data from the hybrid API backends,
+// moving on to the fallback instances while all of them are down
+func fetchHybridWithFallback(ctx context.Context, sourceURL string, minimal bool) (map[string]interface{}, error) {
+	return fetchFromBackends(ctx, true, func(endpoint string) (map[string]interface{}, error) {
+		return FetchHybridDataFrom(ctx, endpoint, sourceURL, minimal)
+	})
+}
+
+// MonitorHybridHealth probes every hybrid API backend every interval until
+// ctx is done, so traffic moves back to a backend once it recovers. Any
+// response below 500 counts as up, since the probe carries no post URL.
+func MonitorHybridHealth(ctx context.Context, interval time.Duration) {
+	if (len(hybridBackends) < 2 && len(hybridFallbacks) == 0) || interval <= 0 {
+		return
+	}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: upstreamTransport}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, backend := range hybridBackends {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.endpoint, nil)
+			if err != nil {
+				log.Printf("Invalid hybrid API URL %q for health checks: %v", backend.endpoint, err)
+				continue
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				backend.setHealthy(false)
+				continue
+			}
+			resp.Body.Close()
+			backend.setHealthy(resp.StatusCode < http.StatusInternalServerError)
+		}
+	}
+}
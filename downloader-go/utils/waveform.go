@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+)
+
+// waveformSampleRate is the rate audio is decoded at for peaks; a scrubber
+// needs far fewer samples than playback
+const waveformSampleRate = 4000
+
+// Waveform is the peak envelope of an audio track: Peaks holds the largest
+// amplitude of each of len(Peaks) equal slices of the track, normalized so
+// the loudest is 1
+type Waveform struct {
+	Duration float64   `json:"duration"`
+	Peaks    []float64 `json:"peaks"`
+}
+
+// ComputeWaveform decodes the audio of a file, which may be a video, to mono
+// PCM and reduces it to count normalized peaks
+func ComputeWaveform(ctx context.Context, audioPath string, count int) (*Waveform, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-i", audioPath,
+		"-vn",
+		"-ac", "1",
+		"-ar", fmt.Sprintf("%d", waveformSampleRate),
+		"-f", "s16le",
+		"-",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("FFmpeg error: %v", err)
+	}
+
+	var samples []int16
+	reader := bufio.NewReader(stdout)
+	buf := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			break
+		}
+		samples = append(samples, int16(binary.LittleEndian.Uint16(buf)))
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("FFmpeg error: %v", err)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no audio found")
+	}
+
+	return &Waveform{
+		Duration: math.Round(float64(len(samples))/waveformSampleRate*1000) / 1000,
+		Peaks:    peaks(samples, count),
+	}, nil
+}
+
+// peaks reduces samples to count normalized peaks, rounded to three decimals
+// to keep the JSON small
+func peaks(samples []int16, count int) []float64 {
+	count = min(count, len(samples))
+	result := make([]float64, count)
+	loudest := 0.0
+	for i := range result {
+		start, end := i*len(samples)/count, (i+1)*len(samples)/count
+		peak := 0.0
+		for _, s := range samples[start:end] {
+			peak = max(peak, math.Abs(float64(s)))
+		}
+		result[i] = peak
+		loudest = max(loudest, peak)
+	}
+	if loudest == 0 {
+		return result
+	}
+	for i, peak := range result {
+		result[i] = math.Round(peak/loudest*1000) / 1000
+	}
+	return result
+}
+
+// RenderWaveform draws the waveform of a file's audio as a width x height PNG
+func RenderWaveform(ctx context.Context, audioPath, outputPath string, width, height int) error {
+	return runFFmpeg(ctx, []string{"-y",
+		"-i", audioPath,
+		"-filter_complex", fmt.Sprintf("[0:a]aformat=channel_layouts=mono,showwavespic=s=%dx%d:colors=0x25f4ee", width, height),
+		"-frames:v", "1",
+		outputPath,
+	})
+}
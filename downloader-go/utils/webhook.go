@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// webhookAttempts is how many times a webhook delivery is tried
+const webhookAttempts = 3
+
+// ErrPrivateWebhook is returned when a callback URL resolves to a private address
+var ErrPrivateWebhook = errors.New("callback URL resolves to a private or loopback address")
+
+// ValidateCallbackURL checks that a callback URL is an absolute http(s) URL
+func ValidateCallbackURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// PostWebhook POSTs payload as JSON to callbackURL, retrying failed
+// deliveries with backoff. When secret is set the body is signed in an
+// X-Signature header (hex HMAC-SHA256). Private and loopback destinations
+// are refused unless allowPrivate is set.
+func PostWebhook(ctx context.Context, callbackURL string, payload interface{}, secret string, allowPrivate bool) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := newWebhookClient(allowPrivate)
+
+	var lastErr error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 2 * time.Second):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			if errors.Is(err, ErrPrivateWebhook) {
+				return err
+			}
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("callback returned status %d", resp.StatusCode)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// newWebhookClient returns the client delivering webhooks. Callbacks are
+// dialed directly, never through HTTP(S)_PROXY: behind a proxy the dialer
+// would check the proxy's address instead of the callback's.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// refusePrivateAddress is a dialer control hook rejecting private, loopback
+// and link-local destinations, checked after DNS resolution
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return ErrPrivateWebhook
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookClientDialsDirectly(t *testing.T) {
+	// Through a proxy the private address check would see the proxy
+	transport := newWebhookClient(false).Transport.(*http.Transport)
+	if transport.Proxy != nil {
+		t.Fatal("webhook transport uses a proxy")
+	}
+}
+
+func TestPostWebhookRefusesPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("callback reached the loopback server")
+	}))
+	defer server.Close()
+
+	err := PostWebhook(context.Background(), server.URL, map[string]string{"status": "completed"}, "", false)
+	if !errors.Is(err, ErrPrivateWebhook) {
+		t.Fatalf("PostWebhook() error = %v, want %v", err, ErrPrivateWebhook)
+	}
+}
+
+func TestPostWebhookAllowPrivate(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	if err := PostWebhook(context.Background(), server.URL, map[string]string{"status": "completed"}, "", true); err != nil {
+		t.Fatalf("PostWebhook() error = %v", err)
+	}
+	if !called {
+		t.Fatal("callback was not delivered")
+	}
+}
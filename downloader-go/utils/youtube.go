@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"tiktok-downloader/config"
+	"tiktok-downloader/logging"
+)
+
+// youtubePlayerURL is the InnerTube endpoint the YouTube apps load videos from
+const youtubePlayerURL = "https://www.youtube.com/youtubei/v1/player?prettyPrint=false"
+
+// The InnerTube client impersonated: the Android app gets stream URLs that
+// need no signature deciphering
+const (
+	youtubeClientName    = "ANDROID"
+	youtubeClientID      = "3"
+	youtubeClientVersion = "19.09.37"
+	youtubeUserAgent     = "com.google.android.youtube/19.09.37 (Linux; U; Android 11) gzip"
+)
+
+// youtubeHDHeight is the smallest height offered as the HD download
+const youtubeHDHeight = 720
+
+// youtubeShortsPattern matches Shorts paths and captures their video ID
+var youtubeShortsPattern = regexp.MustCompile(`^/shorts/([A-Za-z0-9_-]{11})`)
+
+// YouTubeExtractor resolves YouTube Shorts through the InnerTube player API.
+// Only muxed (audio and video) formats can be streamed as-is, so the best
+// one is the no_watermark download, and the HD one only when a muxed format
+// reaches 720p; YouTube has no watermarked variants.
+type YouTubeExtractor struct{}
+
+// Platform implements Extractor
+func (YouTubeExtractor) Platform() string {
+	return PlatformYouTube
+}
+
+// Match implements Extractor
+func (YouTubeExtractor) Match(sourceURL string) bool {
+	return youtubeShortID(sourceURL) != ""
+}
+
+// youtubeShortID returns the video ID of a YouTube Shorts URL, or ""
+func youtubeShortID(sourceURL string) string {
+	parsed, err := parsePostURL(sourceURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "youtube.com" && !strings.HasSuffix(host, ".youtube.com") {
+		return ""
+	}
+	match := youtubeShortsPattern.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// youtubeFormat is a stream of a player response
+type youtubeFormat struct {
+	URL           string `json:"url"`
+	MimeType      string `json:"mimeType"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	ContentLength string `json:"contentLength"`
+}
+
+// youtubePlayer is the part of a player response the extractor reads
+type youtubePlayer struct {
+	PlayabilityStatus struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"playabilityStatus"`
+	VideoDetails struct {
+		VideoID          string `json:"videoId"`
+		Title            string `json:"title"`
+		LengthSeconds    string `json:"lengthSeconds"`
+		ChannelID        string `json:"channelId"`
+		Author           string `json:"author"`
+		ShortDescription string `json:"shortDescription"`
+		ViewCount        string `json:"viewCount"`
+		Thumbnail        struct {
+			Thumbnails []struct {
+				URL   string `json:"url"`
+				Width int    `json:"width"`
+			} `json:"thumbnails"`
+		} `json:"thumbnail"`
+	} `json:"videoDetails"`
+	StreamingData struct {
+		Formats []youtubeFormat `json:"formats"`
+	} `json:"streamingData"`
+}
+
+// Fetch implements Extractor, serving repeated requests for a video from the
+// hybrid API response cache
+func (YouTubeExtractor) Fetch(ctx context.Context, cfg *config.AppConfig, sourceURL string) (map[string]interface{}, error) {
+	videoID := youtubeShortID(sourceURL)
+	if videoID == "" {
+		return nil, fmt.Errorf("not a YouTube Shorts URL")
+	}
+
+	key := "youtube:" + videoID
+	if hybridCache != nil {
+		if raw, ok := hybridCache.Get(ctx, key); ok {
+			var data map[string]interface{}
+			if err := json.Unmarshal(raw, &data); err == nil {
+				data[HybridCacheKey] = "hit"
+				return data, nil
+			}
+		}
+	}
+
+	player, err := fetchYouTubePlayer(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	videoData, err := youtubeVideoData(player)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{
+		"status": "success",
+		"data":   videoData,
+	}
+	logging.Debugf("YouTube resolved Short %s", videoID)
+
+	if hybridCache != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			hybridCache.Set(ctx, key, raw, hybridCacheTTL)
+		}
+	}
+	data[HybridCacheKey] = "miss"
+	return data, nil
+}
+
+// fetchYouTubePlayer requests the player response of a video
+func fetchYouTubePlayer(ctx context.Context, videoID string) (*youtubePlayer, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"videoId": videoID,
+		"context": map[string]interface{}{
+			"client": map[string]interface{}{
+				"clientName":        youtubeClientName,
+				"clientVersion":     youtubeClientVersion,
+				"androidSdkVersion": 30,
+				"hl":                "en",
+				"gl":                "US",
+			},
+		},
+		"contentCheckOk": true,
+		"racyCheckOk":    true,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, youtubePlayerURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch data: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", youtubeUserAgent)
+	req.Header.Set("X-YouTube-Client-Name", youtubeClientID)
+	req.Header.Set("X-YouTube-Client-Version", youtubeClientVersion)
+
+	httpClient := &http.Client{Timeout: hybridTimeout, Transport: upstreamTransport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamDownError{fmt.Errorf("Failed to fetch data: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, upstreamDownError{fmt.Errorf("YouTube returned error: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("YouTube returned error: %d", resp.StatusCode)
+	}
+
+	player := &youtubePlayer{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, hybridMaxPayload)).Decode(player); err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+	return player, nil
+}
+
+// youtubeVideoData converts a player response to hybrid API post data
+func youtubeVideoData(player *youtubePlayer) (map[string]interface{}, error) {
+	if status := player.PlayabilityStatus; status.Status != "OK" {
+		return nil, youtubeUnavailable(status.Status, status.Reason)
+	}
+	details := player.VideoDetails
+
+	// The muxed format with the most pixels, and the largest thumbnail
+	var best *youtubeFormat
+	for i, format := range player.StreamingData.Formats {
+		if format.URL == "" || !strings.HasPrefix(format.MimeType, "video/mp4") {
+			continue
+		}
+		if best == nil || format.Height > best.Height {
+			best = &player.StreamingData.Formats[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no streamable format for YouTube video %s", details.VideoID)
+	}
+	cover, coverWidth := "", 0
+	for _, thumbnail := range details.Thumbnail.Thumbnails {
+		if thumbnail.Width > coverWidth {
+			cover, coverWidth = thumbnail.URL, thumbnail.Width
+		}
+	}
+
+	urls := map[string]interface{}{"nwm_video_url": best.URL}
+	if min(best.Width, best.Height) >= youtubeHDHeight {
+		urls["nwm_video_url_HQ"] = best.URL
+	}
+
+	videoData := map[string]interface{}{
+		"type":     "video",
+		"platform": PlatformYouTube,
+		"aweme_id": details.VideoID,
+		"desc":     firstNonEmpty(details.Title, details.ShortDescription),
+		"author": map[string]interface{}{
+			"nickname": details.Author,
+			"uid":      details.ChannelID,
+		},
+		// The player API reports views only; likes and comments come back
+		// flagged as missing
+		"statistics": map[string]interface{}{
+			"play_count":   details.ViewCount,
+			"repost_count": 0,
+		},
+		"cover_data": map[string]interface{}{
+			"cover": map[string]interface{}{"url_list": []interface{}{cover}},
+		},
+		"video_data": urls,
+	}
+	if seconds, err := strconv.Atoi(details.LengthSeconds); err == nil {
+		videoData["duration"] = float64(seconds * 1000)
+	}
+	if size, err := strconv.ParseInt(best.ContentLength, 10, 64); err == nil {
+		videoData["video"] = map[string]interface{}{
+			"play_addr": map[string]interface{}{"data_size": float64(size)},
+		}
+	}
+	return videoData, nil
+}
+
+// youtubeUnavailable maps a playability status other than OK to an error
+func youtubeUnavailable(status, reason string) error {
+	if unavailable := classifyUnavailable(reason); unavailable != nil {
+		return unavailable
+	}
+	lower := strings.ToLower(reason)
+	switch {
+	case strings.Contains(lower, "confirm your age"):
+		return &PostUnavailableError{Code: PostAgeRestricted, Detail: reason}
+	case strings.Contains(lower, "country"):
+		return &PostUnavailableError{Code: PostRegionLocked, Detail: reason}
+	case status == "ERROR" || strings.Contains(lower, "unavailable"):
+		return &PostUnavailableError{Code: PostDeleted, Detail: reason}
+	}
+	return fmt.Errorf("YouTube refused the video: %s %s", status, reason)
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ytdlpReplacer mirrors yt-dlp's sanitize_filename for non-restricted names,
+// which swaps reserved characters for their full-width lookalikes
+var ytdlpReplacer = strings.NewReplacer(
+	"/", "⧸", "\\", "⧹", ":", "：", "*", "＊", "?", "？",
+	"\"", "＂", "<", "＜", ">", "＞", "|", "｜",
+	"\n", " ", "\r", " ", "\t", " ",
+)
+
+// YtDlpExtractor returns the yt-dlp extractor key for a platform
+func YtDlpExtractor(platform string) string {
+	switch platform {
+	case PlatformDouyin:
+		return "Douyin"
+	case PlatformInstagram:
+		return "Instagram"
+	case PlatformYouTube:
+		return "Youtube"
+	case PlatformKuaishou:
+		return "Kuaishou"
+	}
+	return "TikTok"
+}
+
+// YtDlpArchiveID returns the download-archive line yt-dlp records for a post
+func YtDlpArchiveID(platform, id string) string {
+	return strings.ToLower(YtDlpExtractor(platform)) + " " + id
+}
+
+// YtDlpTitle returns the title yt-dlp would assign to a post
+func YtDlpTitle(videoData map[string]interface{}, id string) string {
+	if desc, ok := videoData["desc"].(string); ok && strings.TrimSpace(desc) != "" {
+		return desc
+	}
+	return fmt.Sprintf("%s video #%s", YtDlpExtractor(fmt.Sprintf("%v", videoData["platform"])), id)
+}
+
+// YtDlpFilename builds a filename following yt-dlp's default
+// "%(title)s [%(id)s].%(ext)s" output template
+func YtDlpFilename(title, id, ext string) string {
+	title = strings.TrimSpace(ytdlpReplacer.Replace(title))
+	title = strings.TrimLeft(title, ".")
+
+	// Keep the full name below the common 255 byte filesystem limit
+	maxTitle := 200 - len(id) - len(ext)
+	for len(title) > maxTitle {
+		_, size := utf8.DecodeLastRuneInString(title)
+		title = title[:len(title)-size]
+	}
+
+	return fmt.Sprintf("%s [%s].%s", strings.TrimSpace(title), id, ext)
+}
+
+// BuildYtDlpInfo builds an info.json structure matching yt-dlp's TikTok/Douyin extractors
+func BuildYtDlpInfo(videoData map[string]interface{}, sourceURL, mediaURL, ext, filename string) map[string]interface{} {
+	id := GetAwemeID(videoData)
+	platform := fmt.Sprintf("%v", videoData["platform"])
+	extractor := YtDlpExtractor(platform)
+
+	author := make(map[string]interface{})
+	if authorVal, ok := videoData["author"].(map[string]interface{}); ok {
+		author = authorVal
+	}
+	statistics := PostStatistics(videoData)
+	music := make(map[string]interface{})
+	if musicVal, ok := videoData["music"].(map[string]interface{}); ok {
+		music = musicVal
+	}
+
+	uniqueID := fmt.Sprintf("%v", GetNestedValue(author, []string{"unique_id"}, ""))
+	nickname := fmt.Sprintf("%v", GetNestedValue(author, []string{"nickname"}, ""))
+	uploaderURL := ""
+	domain := "tiktok.com"
+	if platform == "douyin" {
+		domain = "douyin.com"
+		uploaderURL = fmt.Sprintf("https://www.douyin.com/user/%v", GetNestedValue(author, []string{"sec_uid"}, ""))
+	} else if uniqueID != "" {
+		uploaderURL = "https://www.tiktok.com/@" + uniqueID
+	}
+
+	thumbnail := GetFirstFromNestedList(videoData, []string{"cover_data", "cover", "url_list"}, "")
+	var thumbnails []map[string]interface{}
+	for i, key := range []string{"cover", "origin_cover", "dynamic_cover"} {
+		if thumbURL := GetFirstFromNestedList(videoData, []string{"cover_data", key, "url_list"}, ""); thumbURL != "" {
+			thumbnails = append(thumbnails, map[string]interface{}{"id": key, "url": thumbURL, "preference": -i})
+		}
+	}
+
+	info := map[string]interface{}{
+		"_type":                "video",
+		"id":                   id,
+		"title":                YtDlpTitle(videoData, id),
+		"description":          GetNestedValue(videoData, []string{"desc"}, ""),
+		"uploader":             uniqueID,
+		"uploader_id":          GetNestedValue(author, []string{"uid"}, ""),
+		"uploader_url":         uploaderURL,
+		"channel":              nickname,
+		"channel_id":           GetNestedValue(author, []string{"sec_uid"}, ""),
+		"creators":             []string{nickname},
+		"timestamp":            GetIntStat(videoData, "create_time"),
+		"view_count":           GetIntStat(statistics, "play_count"),
+		"like_count":           GetIntStat(statistics, "digg_count"),
+		"comment_count":        GetIntStat(statistics, "comment_count"),
+		"repost_count":         GetIntStat(statistics, "share_count"),
+		"track":                GetNestedValue(music, []string{"title"}, ""),
+		"artists":              []interface{}{GetNestedValue(music, []string{"author"}, "")},
+		"album":                GetNestedValue(music, []string{"album"}, ""),
+		"thumbnail":            thumbnail,
+		"thumbnails":           thumbnails,
+		"webpage_url":          sourceURL,
+		"original_url":         sourceURL,
+		"webpage_url_basename": path.Base(strings.SplitN(sourceURL, "?", 2)[0]),
+		"webpage_url_domain":   domain,
+		"extractor":            extractor,
+		"extractor_key":        extractor,
+		"format_id":            "download",
+		"url":                  mediaURL,
+		"ext":                  ext,
+		"filename":             filename,
+		"_filename":            filename,
+		"epoch":                time.Now().Unix(),
+		"_version": map[string]interface{}{
+			"version":          "compat",
+			"repository":       "almafazi/Douyin_TikTok_Download_API",
+			"release_git_head": nil,
+		},
+	}
+
+	if durMs := GetIntStat(videoData, "duration"); durMs > 0 {
+		info["duration"] = durMs / 1000
+	}
+	if upload := GetIntStat(videoData, "create_time"); upload > 0 {
+		info["upload_date"] = time.Unix(int64(upload), 0).UTC().Format("20060102")
+	}
+
+	return info
+}
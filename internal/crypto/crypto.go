@@ -0,0 +1,125 @@
+// Package linkcrypto seals the download link tokens shared by downloader-go
+// and downloader-fiber, so a link minted by either service opens in both.
+//
+// Tokens are base64url(nonce || AES-256-GCM(expiry ":" payload)), keyed by
+// the SHA-256 of ENCRYPTION_KEY. Open also accepts the JSON plaintext that
+// downloader-fiber sealed before, and OpenXOR the XOR-obfuscated tokens of
+// older downloader-go releases.
+package linkcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrExpired is returned when a token is past its expiry
+var ErrExpired = errors.New("Link Expired.")
+
+// ErrInvalid is returned when a token cannot be decrypted or authenticated
+var ErrInvalid = errors.New("Invalid Link.")
+
+// Sealer seals and opens link tokens with one key
+type Sealer struct {
+	key  string
+	aead cipher.AEAD
+}
+
+// New derives an AES-256 key from the shared secret
+func New(key string) (*Sealer, error) {
+	if key == "" {
+		return nil, errors.New("link key must not be empty")
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{key: key, aead: aead}, nil
+}
+
+// Seal encrypts and authenticates payload, valid for ttl
+func (s *Sealer) Seal(payload string, ttl time.Duration) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	plaintext := fmt.Sprintf("%d:%s", time.Now().Add(ttl).Unix(), payload)
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// Open decrypts a token sealed by either service and returns its payload
+func (s *Sealer) Open(token string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", ErrInvalid
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if len(plaintext) > 0 && plaintext[0] == '{' {
+		return openLegacyJSON(plaintext)
+	}
+	return checkExpiry(string(plaintext))
+}
+
+// OpenXOR decodes a token of the XOR scheme older downloader-go releases
+// minted. It is not tamper-resistant; accept it only while such links may
+// still be in circulation.
+func (s *Sealer) OpenXOR(token string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	plaintext := make([]byte, len(raw))
+	for i := range raw {
+		plaintext[i] = raw[i] ^ s.key[i%len(s.key)]
+	}
+	return checkExpiry(string(plaintext))
+}
+
+// openLegacyJSON reads the {"t","ts","ttl"} plaintext downloader-fiber sealed
+func openLegacyJSON(plaintext []byte) (string, error) {
+	var data struct {
+		Text      string `json:"t"`
+		Timestamp int64  `json:"ts"`
+		TTL       int    `json:"ttl"`
+	}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return "", ErrInvalid
+	}
+	if time.Now().Unix() > data.Timestamp+int64(data.TTL) {
+		return "", ErrExpired
+	}
+	return data.Text, nil
+}
+
+// checkExpiry strips and enforces the "expiry:" prefix of a plaintext
+func checkExpiry(plaintext string) (string, error) {
+	expiresStr, payload, ok := strings.Cut(plaintext, ":")
+	if !ok {
+		return "", ErrInvalid
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if time.Now().Unix() > expires {
+		return "", ErrExpired
+	}
+	return payload, nil
+}
@@ -0,0 +1,3 @@
+module linkcrypto
+
+go 1.23.5
@@ -0,0 +1,43 @@
+package envconfig
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Defaults of the settings both services read, so an env file that leaves
+// them unset configures both the same way. BASE_URL is left to each service,
+// as it names the deployment.
+const (
+	DefaultPort                     = "3021"
+	DefaultHybridAPIURL             = "http://douyin_tiktok_download_api:8000/api/hybrid/video_data"
+	DefaultHybridAPITimeout         = 30 * time.Second
+	DefaultEncryptionKey            = "overflow"
+	DefaultTempDirMaxMB             = 0
+	DefaultSlideshowImageSeconds    = 3
+	DefaultSlideshowMinImageSeconds = 1
+	DefaultSlideshowMaxImageSeconds = 10
+	DefaultSourceRetryAttempts      = 3
+	DefaultSourceRetryBackoff       = 500 * time.Millisecond
+	DefaultSourceRetryMaxBackoff    = 5 * time.Second
+)
+
+// DefaultTempDir is the default TEMP_DIR, relative to the working directory
+var DefaultTempDir = filepath.Join(".", "temp")
+
+// SharedKeys lists the environment variables both services read with the
+// shared defaults above
+var SharedKeys = []string{
+	"PORT",
+	"DOUYIN_API_URL",
+	"HYBRID_API_TIMEOUT",
+	"ENCRYPTION_KEY",
+	"TEMP_DIR",
+	"TEMP_DIR_MAX_MB",
+	"SLIDESHOW_IMAGE_SECONDS",
+	"SLIDESHOW_MIN_IMAGE_SECONDS",
+	"SLIDESHOW_MAX_IMAGE_SECONDS",
+	"SOURCE_RETRY_ATTEMPTS",
+	"SOURCE_RETRY_BACKOFF",
+	"SOURCE_RETRY_MAX_BACKOFF",
+}
@@ -0,0 +1,82 @@
+// Package envconfig reads the environment variables shared by downloader-go
+// and downloader-fiber, so both services parse the same names the same way.
+// Unset or unparsable variables fall back to the given default.
+package envconfig
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String gets an environment variable or returns a default value
+func String(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+// Bool gets a boolean environment variable or returns a default value
+func Bool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// Int gets an integer environment variable or returns a default value
+func Int(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// Duration gets a duration environment variable (e.g. "30s", "12h") or returns a default value
+func Duration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// List gets a comma-separated environment variable or returns a default value
+func List(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+// Pairs gets a comma-separated list of name:value pairs, e.g.
+// "acme:key1,globex:key2"; entries without a name or value are ignored
+func Pairs(key string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range List(key, nil) {
+		name, value, ok := strings.Cut(item, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if ok && name != "" && value != "" {
+			pairs[name] = value
+		}
+	}
+	return pairs
+}
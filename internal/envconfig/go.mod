@@ -0,0 +1,3 @@
+module envconfig
+
+go 1.23.5